@@ -63,11 +63,80 @@ var stopCmd = &cobra.Command{
 	RunE: wrapCommandFuncForCobra(server.StopServer),
 }
 
+// reloadCmd reload server configuration
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Reload log_manager configuration (routing)",
+	// Make the log management daemon reload the configuration
+	RunE: wrapCommandFuncForCobra(server.ReloadServer),
+}
+
+// patternsCmd print message templates
+var patternsCmd = &cobra.Command{
+	Use:   "patterns",
+	Short: "Print the top message templates",
+	// Print the message templates with the most entries and their change
+	RunE: wrapCommandFuncForCobra(server.ShowPatterns),
+}
+
+// notifyCmd notification commands
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Manage alert notification channels",
+}
+
+// notifyTestCmd send a test notification
+var notifyTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a test notification to a channel",
+	// Send a test alert to the notification channel
+	RunE: wrapCommandFuncForCobra(server.TestNotify),
+}
+
+// configCmd configuration commands
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage log_manager configuration",
+}
+
+// configShowCmd print configuration
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print configuration",
+	// Print the configuration with the source of each value
+	RunE: wrapCommandFuncForCobra(server.ShowConfig),
+}
+
 // init Initialize when importing cmd packages.
 func init() {
+	// Global flags
+	logManagerCmd.PersistentFlags().StringP("config", "c", "",
+		"configuration file path (env: "+config.EnvConfFilePath+")")
+	logManagerCmd.PersistentFlags().String("home", "",
+		"base directory of relative paths (env: "+config.EnvHomePath+")")
+	logManagerCmd.PersistentFlags().StringArray("set", nil,
+		"override a configuration key (key=value, repeatable)")
+
+	configShowCmd.Flags().Bool("effective", false,
+		"print all keys merged from defaults, file, environment variables and flags")
+	configCmd.AddCommand(configShowCmd)
+
+	notifyTestCmd.Flags().String("channel", "", "notification channel name")
+	notifyTestCmd.MarkFlagRequired("channel")
+	notifyCmd.AddCommand(notifyTestCmd)
+
+	patternsCmd.Flags().Int("window", 5, "window in minutes (compared with the previous window, at most half of PatternHistory)")
+	patternsCmd.Flags().Int("limit", 20, "maximum number of templates")
+	patternsCmd.Flags().String("sort", "count", "sort order (count, change)")
+	patternsCmd.Flags().String("address", "", "HTTP API address (DEF: first http listener)")
+
 	logManagerCmd.AddCommand(startCmd)
 	logManagerCmd.AddCommand(debugCmd)
 	logManagerCmd.AddCommand(stopCmd)
+	logManagerCmd.AddCommand(reloadCmd)
+	logManagerCmd.AddCommand(configCmd)
+	logManagerCmd.AddCommand(patternsCmd)
+	logManagerCmd.AddCommand(notifyCmd)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
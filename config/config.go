@@ -22,10 +22,26 @@ package config
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
-	"strconv"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+	// Time zones of the event time rules without the system database
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+	"github.com/hoon-kr/log_manager/pkg/utils/grok"
+	"github.com/hoon-kr/log_manager/pkg/utils/stream"
 )
 
 var (
@@ -34,11 +50,13 @@ var (
 	ModuleName = "log_manager" // Module name
 )
 
+// Default file paths (relative to the home path)
 const (
 	ConfFilePath       = "conf/log_manager.properties"
 	PidFilePath        = "var/log_manager.pid"
 	ConsoleLogFilePath = "log/log_manager.log"
 	JsonLogFilePath    = "log/log_manager_json.log"
+	DataDirPath        = "data"
 )
 
 // Exit Code
@@ -74,6 +92,10 @@ func (e *ExitError) Error() string {
 
 // Config is a global configuration structure
 type Config struct {
+	// PID file path (DEF:var/log_manager.pid)
+	PidFilePath string
+	// Data directory path (DEF:data)
+	DataDirPath string
 	// Maximum size per log file (DEF:100MB, MIN:1MB, MAX:1000MB)
 	MaxLogFileSize int
 	// Maximum number of log file backups (DEF:10, MIN:1, MAX:100)
@@ -82,84 +104,1373 @@ type Config struct {
 	MaxLogFileAge int
 	// Whether backup log files are compressed (DEF:true, ENABLE:true, DISABLE:false)
 	CompBakLogFile bool
+	// Backup log file compression algorithm (DEF:gzip, gzip, zstd)
+	CompBakLogFileAlgo string
+	// Backup log file compression level (DEF:0(algorithm default), GZIP:1~9, ZSTD:1~22)
+	CompBakLogFileLevel int
+	// Number of module log messages with the same level and message logged
+	// per second before sampling (DEF:0(sampling disabled), MIN:0, MAX:100000)
+	LogSamplingFirst int
+	// Every Nth module log message logged after LogSamplingFirst per second
+	// (DEF:100, MIN:1, MAX:100000)
+	LogSamplingThereafter int
+	// Output sinks of the module log
+	LogSinks []LogSink
+	// Input listeners (DEF:none)
+	Listeners []Listener
+	// Capacity of the write pipeline queue (DEF:100000, MIN:100, MAX:10000000)
+	PipelineQueueSize int
+	// Maximum number of lines per ingestion request (DEF:10000, MIN:1, MAX:1000000,
+	// not greater than PipelineQueueSize)
+	IngestMaxBatchLines int
+	// Maximum decoded body size per ingestion request (DEF:16MB, MIN:1MB, MAX:1024MB)
+	IngestMaxBodySize int
+	// Timeout for receiving all chunks of a GELF UDP message (DEF:5s, MIN:1s, MAX:60s)
+	GelfChunkTimeout int
+	// Elasticsearch version reported to bulk API clients (DEF:8.11.0)
+	ElasticCompatVersion string
+	// Sampling and rate limiting rules of the write pipeline (DEF:none)
+	SamplingRules []SamplingRule
+	// Interval of the suppressed message summary entries (DEF:60s, MIN:1s, MAX:3600s)
+	SamplingSummaryInterval int
+	// Redaction rules applied before entries are stored or forwarded (DEF:none)
+	Redactions []Redaction
+	// Key of the hash action of the redaction rules (HMAC-SHA256, DEF:none(SHA-256))
+	RedactionHashKey string
+	// Whether the fingerprint of the message template is stored in the fingerprint field (DEF:false)
+	Fingerprint bool
+	// Window in which duplicate entries are collapsed into the first entry (DEF:0s(disabled), MIN:0s, MAX:3600s)
+	DedupWindow int
+	// Entries that are duplicates (DEF:message, message(same stream, source, level,
+	// labels, fields and message), fingerprint(same stream, source, level, labels,
+	// fields and message template))
+	DedupKey string
+	// Routing destinations (DEF:none, reloadable)
+	Destinations []Destination
+	// Routing rules evaluated in order (DEF:none(entries keep their stream), reloadable)
+	Routes []Route
+	// Whether the templates of the messages are mined (DEF:false)
+	PatternMining bool
+	// Minimum similarity of a message to the template of a pattern (DEF:50%, MIN:1%, MAX:100%)
+	PatternSimilarity int
+	// Maximum number of patterns (DEF:1000, MIN:10, MAX:100000)
+	PatternMaxClusters int
+	// Minutes of the counts kept per pattern (DEF:120, MIN:2, MAX:1440)
+	PatternHistory int
+	// Notification channels of the alerts (DEF:none)
+	NotifyChannels []NotifyChannel
+	// Alert rules evaluated against the stored entries (DEF:none)
+	AlertRules []AlertRule
+	// Interval of the alert rule evaluation (DEF:10s, MIN:1s, MAX:300s)
+	AlertEvaluationInterval int
+	// Stream in which the alert notifications are stored (DEF:alerts)
+	AlertStream string
+	// Maximum size of the active store segment (DEF:64MB, MIN:1MB, MAX:1024MB)
+	SegmentMaxSize int
+	// Archived store segment compression algorithm (DEF:zstd, none, gzip, zstd)
+	SegmentCompAlgo string
+	// Archived store segment compression level (DEF:0(algorithm default), GZIP:1~9, ZSTD:1~22)
+	SegmentCompLevel int
+}
+
+// Log sink name
+const (
+	LogSinkConsoleFile = "ConsoleFile"
+	LogSinkJsonFile    = "JsonFile"
+	LogSinkStdout      = "Stdout"
+	LogSinkStderr      = "Stderr"
+	LogSinkSyslog      = "Syslog"
+)
+
+// Log sink format
+const (
+	LogFormatConsole = "console"
+	LogFormatJson    = "json"
+	LogFormatLogfmt  = "logfmt"
+)
+
+// LogSink is a module log output configuration structure
+type LogSink struct {
+	// Sink name (ConsoleFile, JsonFile, Stdout, Stderr, Syslog)
+	Name string
+	// Whether the sink is used (ENABLE:yes, DISABLE:no)
+	Enable bool
+	// Log format (console, json, logfmt)
+	Format string
+	// Minimum log level (debug, info, warn, error)
+	Level string
+	// Log file path (file sink only)
+	Path string
+}
+
+// IsFile verify that the sink writes to a log file.
+//
+// Returns:
+//   - bool: file sink(true), other sink(false)
+func (l *LogSink) IsFile() bool {
+	return l.Name == LogSinkConsoleFile || l.Name == LogSinkJsonFile
+}
+
+// Listener protocol
+const (
+	ListenerHttp          = "http"
+	ListenerGelfUdp       = "gelf_udp"
+	ListenerGelfTcp       = "gelf_tcp"
+	ListenerForward       = "forward"
+	ListenerTail          = "tail"
+	ListenerTcp           = "tcp"
+	ListenerJournalExport = "journal_export"
+	ListenerJournalSocket = "journal_socket"
+)
+
+// Multiline preset
+const (
+	MultilinePresetJava   = "java"
+	MultilinePresetPython = "python"
+	MultilinePresetGo     = "go"
+)
+
+// Multiline limits (0 means the default)
+const (
+	DefMultilineMaxLines     = 500
+	MaxMultilineMaxLines     = 100000
+	DefMultilineMaxBytes     = 1024 * 1024
+	MaxMultilineMaxBytes     = 16 * 1024 * 1024
+	DefMultilineFlushTimeout = 1000
+	MinMultilineFlushTimeout = 10
+	MaxMultilineFlushTimeout = 60000
+)
+
+// Parser stage type
+const (
+	ParserRegex  = "regex"
+	ParserGrok   = "grok"
+	ParserJson   = "json"
+	ParserLogfmt = "logfmt"
+	ParserCsv    = "csv"
+	ParserKv     = "kv"
+)
+
+// Handling of event times outside the acceptance window
+const (
+	OutOfWindowReceiveTime = "receive_time"
+	OutOfWindowDrop        = "drop"
+)
+
+// Listener is an input listener configuration structure.
+// File inputs (tail) read the files of Paths instead of listening
+// on Address.
+type Listener struct {
+	// Listener name (unique)
+	Name string
+	// Whether the listener is used (DEF:true)
+	Enable bool
+	// Listener protocol (http, gelf_udp, gelf_tcp, forward, tail, tcp,
+	// journal_export, journal_socket)
+	Protocol string
+	// Listen address (host:port, journal_socket: socket path, not used by tail
+	// and journal_export)
+	Address string
+	// Stream of the received entries (DEF:default, not used by http)
+	Stream string
+	// Shared key of the handshake (forward only, DEF:none(no handshake))
+	SharedKey string
+	// Glob patterns of the files to follow (tail only),
+	// file or pipe path (journal_export only, one path)
+	Paths []string
+	// Whether files found at the first start are read from the beginning
+	// (tail only, DEF:false(read from the end))
+	ReadFromHead bool
+	// Rule for assembling lines into one entry (tail, tcp only, DEF:none)
+	Multiline *Multiline
+	// Parser stages applied in order to the received entries (DEF:none)
+	Parsers []Parser
+	// Rule for the event time of the received entries (DEF:none)
+	Timestamp *Timestamp
+}
+
+// Multiline is a multiline rule configuration structure.
+// A line matching StartPattern starts a new entry. Other lines are
+// appended to the current entry if ContinuationPattern is empty or
+// matches, otherwise they start a new entry.
+type Multiline struct {
+	// Built-in rule for stack traces (java, python, go)
+	Preset string
+	// Pattern of the first line of an entry (regular expression)
+	StartPattern string
+	// Pattern of the continuation lines (regular expression, overrides the preset)
+	ContinuationPattern string
+	// Maximum number of lines per entry (DEF:500, MIN:1, MAX:100000)
+	MaxLines int
+	// Maximum size of an entry (DEF:1MB, MIN:1, MAX:16MB)
+	MaxBytes int
+	// Time to wait for the next line before the entry is flushed (DEF:1000ms, MIN:10ms, MAX:60000ms)
+	FlushTimeout int
+}
+
+// Parser is a parser stage configuration structure.
+// The stage parses the message (or a field) and stores the extracted
+// values as structured fields. If parsing fails, the entry is kept and
+// tagged with the parse_error field.
+type Parser struct {
+	// Stage type (regex, grok, json, logfmt, csv, kv)
+	Type string
+	// Field to parse (DEF:msg(message))
+	Field string
+	// Regular expression with named captures (regex) or grok pattern (grok)
+	Pattern string
+	// Additional grok pattern definitions (grok only, name: pattern)
+	Definitions map[string]string
+	// Column names (csv only)
+	Columns []string
+	// Column delimiter (csv, DEF:",") or pair delimiter (kv, DEF:" ")
+	Delimiter string
+	// Separator between a key and a value (kv only, DEF:"=")
+	Separator string
+	// Extracted key used as the entry time (DEF:none)
+	TimeKey string
+	// Time format of TimeKey (Go layout, unix, unix_ms, unix_us, unix_ns, DEF:RFC3339)
+	TimeFormat string
+	// Extracted key used as the entry level (DEF:none)
+	LevelKey string
+	// Extracted key that replaces the message (DEF:none)
+	MessageKey string
+}
+
+// Timestamp is an event time rule configuration structure.
+// The rule is applied after the parser stages.
+type Timestamp struct {
+	// Field from which the event time is read (msg: detected at the beginning
+	// of the message, DEF:none(time set by the input or the parsers))
+	Field string
+	// Time formats tried in order (Go layout, unix, unix_ms, unix_us, unix_ns,
+	// DEF:RFC3339 for fields, common formats for the message)
+	Formats []string
+	// Time zone of times without a zone (IANA name, DEF:UTC)
+	Timezone string
+	// Maximum age of the event time (seconds, DEF:0(unlimited))
+	MaxPast int
+	// Maximum time by which the event time may be ahead (seconds, DEF:0(unlimited))
+	MaxFuture int
+	// Handling of event times outside the window (receive_time, drop, DEF:receive_time)
+	OutOfWindow string
+	// Whether the receive time is stored with the event time (DEF:false)
+	KeepReceiveTime bool
+}
+
+// newListener create a listener with default values.
+//
+// Returns:
+//   - Listener: listener configuration
+func newListener() Listener {
+	return Listener{Enable: true, Protocol: ListenerHttp, Stream: stream.Default}
+}
+
+// validateListeners validate the listener list.
+//
+// Parameters:
+//   - listeners: listener list
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateListeners(listeners []Listener) error {
+	names := make(map[string]bool)
+	for _, listener := range listeners {
+		if listener.Name == "" {
+			return fmt.Errorf("listener name is empty")
+		}
+		if names[listener.Name] {
+			return fmt.Errorf("duplicate listener name: %s", listener.Name)
+		}
+		names[listener.Name] = true
+
+		switch listener.Protocol {
+		case ListenerHttp, ListenerGelfUdp, ListenerGelfTcp, ListenerForward, ListenerTcp:
+			if _, _, err := net.SplitHostPort(listener.Address); err != nil {
+				return fmt.Errorf("invalid listener address: %s (%s)", listener.Address, listener.Name)
+			}
+		case ListenerTail:
+			if len(listener.Paths) == 0 {
+				return fmt.Errorf("listener paths are empty (%s)", listener.Name)
+			}
+			for _, pattern := range listener.Paths {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					return fmt.Errorf("invalid listener path: %s (%s)", pattern, listener.Name)
+				}
+			}
+		case ListenerJournalExport:
+			if len(listener.Paths) != 1 || listener.Paths[0] == "" {
+				return fmt.Errorf("listener requires one path (%s)", listener.Name)
+			}
+		case ListenerJournalSocket:
+			if listener.Address == "" {
+				return fmt.Errorf("listener socket path is empty (%s)", listener.Name)
+			}
+		default:
+			return fmt.Errorf("unsupported listener protocol: %s (%s)", listener.Protocol, listener.Name)
+		}
+		if !stream.Valid(listener.Stream) {
+			return fmt.Errorf("invalid listener stream: %s (%s)", listener.Stream, listener.Name)
+		}
+		if listener.Multiline != nil {
+			if listener.Protocol != ListenerTail && listener.Protocol != ListenerTcp {
+				return fmt.Errorf("multiline is not supported by %s (%s)", listener.Protocol, listener.Name)
+			}
+			if err := validateMultiline(listener.Multiline); err != nil {
+				return fmt.Errorf("%s (%s)", err, listener.Name)
+			}
+		}
+		for _, p := range listener.Parsers {
+			if err := validateParser(&p); err != nil {
+				return fmt.Errorf("%s (%s)", err, listener.Name)
+			}
+		}
+		if listener.Timestamp != nil {
+			if err := validateTimestamp(listener.Timestamp); err != nil {
+				return fmt.Errorf("%s (%s)", err, listener.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateMultiline validate the multiline rule.
+//
+// Parameters:
+//   - m: multiline rule
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateMultiline(m *Multiline) error {
+	switch m.Preset {
+	case "", MultilinePresetJava, MultilinePresetPython, MultilinePresetGo:
+	default:
+		return fmt.Errorf("unsupported multiline preset: %s", m.Preset)
+	}
+	if m.Preset == "" && m.StartPattern == "" && m.ContinuationPattern == "" {
+		return fmt.Errorf("multiline requires a preset or a pattern")
+	}
+
+	for _, pattern := range []string{m.StartPattern, m.ContinuationPattern} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid multiline pattern: %s", err)
+		}
+	}
+
+	if m.MaxLines < 0 || m.MaxLines > MaxMultilineMaxLines {
+		return fmt.Errorf("invalid multiline max lines: %d", m.MaxLines)
+	}
+	if m.MaxBytes < 0 || m.MaxBytes > MaxMultilineMaxBytes {
+		return fmt.Errorf("invalid multiline max bytes: %d", m.MaxBytes)
+	}
+	if m.FlushTimeout != 0 && (m.FlushTimeout < MinMultilineFlushTimeout || m.FlushTimeout > MaxMultilineFlushTimeout) {
+		return fmt.Errorf("invalid multiline flush timeout: %d", m.FlushTimeout)
+	}
+	return nil
+}
+
+// validateParser validate the parser stage.
+//
+// Parameters:
+//   - p: parser stage
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateParser(p *Parser) error {
+	switch p.Type {
+	case ParserRegex:
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid parser pattern: %s", err)
+		}
+		named := false
+		for _, name := range re.SubexpNames() {
+			named = named || name != ""
+		}
+		if !named {
+			return fmt.Errorf("regex parser requires named captures")
+		}
+	case ParserGrok:
+		if p.Pattern == "" {
+			return fmt.Errorf("grok parser requires a pattern")
+		}
+		if _, err := grok.Compile(p.Pattern, p.Definitions); err != nil {
+			return err
+		}
+	case ParserCsv:
+		if len(p.Columns) == 0 {
+			return fmt.Errorf("csv parser requires columns")
+		}
+		if utf8.RuneCountInString(p.Delimiter) > 1 {
+			return fmt.Errorf("invalid csv delimiter: %s", p.Delimiter)
+		}
+	case ParserJson, ParserLogfmt, ParserKv:
+	default:
+		return fmt.Errorf("unsupported parser type: %s", p.Type)
+	}
+	return nil
+}
+
+// validateTimestamp validate the event time rule.
+//
+// Parameters:
+//   - t: event time rule
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateTimestamp(t *Timestamp) error {
+	if _, err := time.LoadLocation(t.Timezone); err != nil {
+		return fmt.Errorf("invalid timestamp timezone: %s", t.Timezone)
+	}
+	for _, format := range t.Formats {
+		if format == "" {
+			return fmt.Errorf("timestamp format is empty")
+		}
+	}
+	if t.MaxPast < 0 || t.MaxFuture < 0 {
+		return fmt.Errorf("invalid timestamp window: past %d, future %d", t.MaxPast, t.MaxFuture)
+	}
+	switch t.OutOfWindow {
+	case "", OutOfWindowReceiveTime, OutOfWindowDrop:
+	default:
+		return fmt.Errorf("unsupported out of window handling: %s", t.OutOfWindow)
+	}
+	return nil
+}
+
+// Deduplication key
+const (
+	DedupKeyMessage     = "message"
+	DedupKeyFingerprint = "fingerprint"
+)
+
+// Sampling rule type
+const (
+	SamplingRateLimit     = "rate_limit"
+	SamplingHead          = "head"
+	SamplingProbabilistic = "probabilistic"
+)
+
+// Sampling key
+const (
+	SamplingKeySource            = "source"
+	SamplingKeyFingerprint       = "fingerprint"
+	SamplingKeySourceFingerprint = "source_fingerprint"
+	SamplingKeyAll               = "all"
+)
+
+// SamplingRule is a sampling or rate limiting rule configuration structure.
+// Entries are counted per key (e.g. per source), and the entries over the
+// limit of the key are suppressed.
+type SamplingRule struct {
+	// Rule name (metrics label, DEF:type)
+	Name string
+	// Rule type (rate_limit, head, probabilistic)
+	Type string
+	// Match expression of the entries to which the rule applies (DEF:all entries)
+	Match string
+	// Key by which entries are counted (DEF:source, source, fingerprint,
+	// source_fingerprint, all)
+	Key string
+	// Entries per second (rate_limit) or entries per Interval (head) (MIN:1)
+	Limit int
+	// Maximum entries passed at once (rate_limit only, DEF:Limit)
+	Burst int
+	// Window in which the first Limit entries are passed (head only, DEF:60s, MIN:1s, MAX:86400s)
+	Interval int
+	// Ratio of the passed entries (probabilistic only, 0 < Ratio <= 1)
+	Ratio float64
+}
+
+// newSamplingRule create a sampling rule with default values.
+//
+// Returns:
+//   - SamplingRule: sampling rule
+func newSamplingRule() SamplingRule {
+	return SamplingRule{Key: SamplingKeySource}
+}
+
+// validateSamplingRules validate the sampling rule list.
+//
+// Parameters:
+//   - rules: sampling rule list
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateSamplingRules(rules []SamplingRule) error {
+	names := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		switch rule.Type {
+		case SamplingRateLimit:
+			if rule.Burst == 0 {
+				rule.Burst = rule.Limit
+			}
+			if rule.Limit < 1 || rule.Burst < 1 {
+				return fmt.Errorf("invalid sampling limit (index: %d)", i)
+			}
+		case SamplingHead:
+			if rule.Interval == 0 {
+				rule.Interval = 60
+			}
+			if rule.Limit < 1 || rule.Interval < 1 || rule.Interval > 86400 {
+				return fmt.Errorf("invalid sampling limit or interval (index: %d)", i)
+			}
+		case SamplingProbabilistic:
+			if rule.Ratio <= 0 || rule.Ratio > 1 {
+				return fmt.Errorf("invalid sampling ratio (index: %d)", i)
+			}
+		default:
+			return fmt.Errorf("unsupported sampling rule type: %s (index: %d)", rule.Type, i)
+		}
+
+		switch rule.Key {
+		case SamplingKeySource, SamplingKeyFingerprint, SamplingKeySourceFingerprint, SamplingKeyAll:
+		default:
+			return fmt.Errorf("unsupported sampling key: %s (index: %d)", rule.Key, i)
+		}
+
+		if rule.Match != "" {
+			if err := validMatch(rule.Match); err != nil {
+				return fmt.Errorf("%s (sampling rule index: %d)", err, i)
+			}
+		}
+
+		if rule.Name == "" {
+			rule.Name = rule.Type
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate sampling rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// Alert rule type
+const (
+	AlertThreshold = "threshold"
+	AlertAbsence   = "absence"
+)
+
+// Alert severity
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// AlertRule is an alert rule configuration structure
+type AlertRule struct {
+	// Rule name (unique)
+	Name string
+	// Rule type (threshold(more than Threshold entries in Window), absence(no entry in Window))
+	Type string
+	// Match expression of the counted entries (DEF:all entries)
+	Match string
+	// Number of entries in Window over which the alert fires (threshold only, MIN:0)
+	Threshold int
+	// Window (seconds, DEF:300, MIN:1, MAX:86400)
+	Window int
+	// Time the condition must last before the alert fires (seconds, DEF:0, MAX:86400)
+	For int
+	// Interval of the repeated notifications while firing (seconds, DEF:0(not repeated))
+	RepeatInterval int
+	// Severity (DEF:warning, info, warning, critical)
+	Severity string
+	// Description included in the notifications (DEF:none)
+	Description string
+	// Notification channel names (DEF:none(stored in AlertStream only))
+	Channels []string
+}
+
+// Notification channel type
+const (
+	NotifyWebhook = "webhook"
+	NotifyEmail   = "email"
+	NotifyScript  = "script"
+)
+
+// Functions of the notification templates
+var NotifyTemplateFuncs = template.FuncMap{
+	// Encode a value as JSON (e.g. {"text": {{json .Message}}})
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// NotifyChannel is a notification channel configuration structure
+type NotifyChannel struct {
+	// Channel name (unique)
+	Name string
+	// Channel type (webhook, email, script)
+	Type string
+	// Request URL (webhook only)
+	Url string
+	// Request headers (webhook only, DEF:Content-Type: application/json)
+	Headers map[string]string
+	// Key of the HMAC-SHA256 signature of the request (webhook only, DEF:none(not signed))
+	SigningKey string
+	// Subject template (email only, Go template of the alert, DEF:[<state>] <rule> (<severity>))
+	Subject string
+	// Body template (webhook, email, Go template of the alert, DEF:webhook:JSON of the alert,
+	// email:message and details of the alert)
+	Body string
+	// SMTP server address (email only, host:port)
+	SmtpAddress string
+	// SMTP user name (email only, DEF:none(no authentication))
+	SmtpUsername string
+	// SMTP password (email only)
+	SmtpPassword string
+	// Whether the SMTP connection uses TLS from the start (email only, DEF:false(STARTTLS if supported))
+	SmtpTls bool
+	// Sender address (email only)
+	From string
+	// Recipient addresses (email only)
+	To []string
+	// Executed command (script only, the alert JSON is written to stdin)
+	Command string
+	// Command arguments (script only)
+	Args []string
+	// Timeout of a delivery attempt (seconds, DEF:10, MIN:1, MAX:300)
+	Timeout int
+	// Number of retries of a failed delivery (DEF:3, MIN:0, MAX:10)
+	MaxRetries int
+	// Wait before the first retry, doubled at every retry (milliseconds, DEF:1000, MIN:10, MAX:60000)
+	RetryBackoff int
+	// Maximum firing notifications per minute, resolved ones are not limited (DEF:0(unlimited))
+	RateLimit int
+}
+
+// newNotifyChannel create a notification channel with default values.
+//
+// Returns:
+//   - NotifyChannel: notification channel
+func newNotifyChannel() NotifyChannel {
+	return NotifyChannel{Timeout: 10, MaxRetries: 3, RetryBackoff: 1000}
+}
+
+// validateNotifyChannels validate the notification channel list.
+//
+// Parameters:
+//   - channels: notification channel list
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateNotifyChannels(channels []NotifyChannel) error {
+	names := make(map[string]bool)
+	for i := range channels {
+		channel := &channels[i]
+		if channel.Name == "" {
+			return fmt.Errorf("notification channel name is empty (index: %d)", i)
+		}
+		if names[channel.Name] {
+			return fmt.Errorf("duplicate notification channel name: %s", channel.Name)
+		}
+		names[channel.Name] = true
+
+		switch channel.Type {
+		case NotifyWebhook:
+			if u, err := url.Parse(channel.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid webhook url: %s (%s)", channel.Url, channel.Name)
+			}
+		case NotifyEmail:
+			if _, _, err := net.SplitHostPort(channel.SmtpAddress); err != nil {
+				return fmt.Errorf("invalid smtp address: %s (%s)", channel.SmtpAddress, channel.Name)
+			}
+			if channel.From == "" || len(channel.To) == 0 {
+				return fmt.Errorf("email sender or recipients are empty (%s)", channel.Name)
+			}
+		case NotifyScript:
+			if channel.Command == "" {
+				return fmt.Errorf("script command is empty (%s)", channel.Name)
+			}
+		default:
+			return fmt.Errorf("unsupported notification channel type: %s (%s)", channel.Type, channel.Name)
+		}
+
+		for _, tmpl := range []string{channel.Subject, channel.Body} {
+			if _, err := template.New(channel.Name).Funcs(NotifyTemplateFuncs).Parse(tmpl); err != nil {
+				return fmt.Errorf("invalid template (%s): %s", channel.Name, err)
+			}
+		}
+		if channel.Timeout < 1 || channel.Timeout > 300 || channel.MaxRetries < 0 || channel.MaxRetries > 10 ||
+			channel.RetryBackoff < 10 || channel.RetryBackoff > 60000 || channel.RateLimit < 0 {
+			return fmt.Errorf("invalid timeout, retries or rate limit (%s)", channel.Name)
+		}
+	}
+	return nil
+}
+
+// newAlertRule create an alert rule with default values.
+//
+// Returns:
+//   - AlertRule: alert rule
+func newAlertRule() AlertRule {
+	return AlertRule{Type: AlertThreshold, Window: 300, Severity: AlertSeverityWarning}
+}
+
+// validateAlertRules validate the alert rule list. The notification
+// channels are validated before the rules.
+//
+// Parameters:
+//   - rules: alert rule list
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateAlertRules(rules []AlertRule) error {
+	channels := make(map[string]bool)
+	for _, channel := range Conf.NotifyChannels {
+		channels[channel.Name] = true
+	}
+
+	names := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("alert rule name is empty (index: %d)", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate alert rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		switch rule.Type {
+		case AlertThreshold, AlertAbsence:
+		default:
+			return fmt.Errorf("unsupported alert rule type: %s (%s)", rule.Type, rule.Name)
+		}
+		switch rule.Severity {
+		case AlertSeverityInfo, AlertSeverityWarning, AlertSeverityCritical:
+		default:
+			return fmt.Errorf("unsupported alert severity: %s (%s)", rule.Severity, rule.Name)
+		}
+		if rule.Threshold < 0 || rule.Window < 1 || rule.Window > 86400 || rule.For < 0 || rule.For > 86400 ||
+			rule.RepeatInterval < 0 {
+			return fmt.Errorf("invalid alert threshold, window or durations (%s)", rule.Name)
+		}
+		if rule.Match != "" {
+			if err := validMatch(rule.Match); err != nil {
+				return fmt.Errorf("%s (%s)", err, rule.Name)
+			}
+		}
+		for _, name := range rule.Channels {
+			if !channels[name] {
+				return fmt.Errorf("unknown notification channel: %s (%s)", name, rule.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Redaction detector
+const (
+	RedactDetectorCreditCard  = "credit_card"
+	RedactDetectorEmail       = "email"
+	RedactDetectorBearerToken = "bearer_token"
+	RedactDetectorPassword    = "password"
+	RedactDetectorJwt         = "jwt"
+	RedactDetectorAwsKey      = "aws_access_key"
+)
+
+// Redaction action
+const (
+	RedactActionMask      = "mask"
+	RedactActionHash      = "hash"
+	RedactActionDropField = "drop_field"
+)
+
+// Redaction is a redaction rule configuration structure.
+// The rule replaces the matches of a detector or a pattern in the message,
+// the fields and the labels of entries.
+type Redaction struct {
+	// Rule name (metrics label, DEF:detector name)
+	Name string
+	// Built-in detector (credit_card, email, bearer_token, password, jwt, aws_access_key)
+	Detector string
+	// Regular expression (instead of Detector, only the "secret" named
+	// capture is replaced if it exists)
+	Pattern string
+	// Action (mask, hash, drop_field, DEF:mask)
+	Action string
+	// Replacement of the mask action (DEF:[REDACTED])
+	Replacement string
+	// Message (msg), field and label keys inspected (DEF:all)
+	Fields []string
+}
+
+// newRedaction create a redaction rule with default values.
+//
+// Returns:
+//   - Redaction: redaction rule
+func newRedaction() Redaction {
+	return Redaction{Action: RedactActionMask, Replacement: "[REDACTED]"}
+}
+
+// validateRedactions validate the redaction rule list.
+//
+// Parameters:
+//   - rules: redaction rule list
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateRedactions(rules []Redaction) error {
+	names := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		switch {
+		case rule.Detector != "" && rule.Pattern != "":
+			return fmt.Errorf("redaction rule has both a detector and a pattern (index: %d)", i)
+		case rule.Pattern != "":
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("invalid redaction pattern (index: %d): %s", i, err)
+			}
+		case rule.Detector == "":
+			return fmt.Errorf("redaction rule requires a detector or a pattern (index: %d)", i)
+		}
+		switch rule.Detector {
+		case "", RedactDetectorCreditCard, RedactDetectorEmail, RedactDetectorBearerToken, RedactDetectorPassword,
+			RedactDetectorJwt, RedactDetectorAwsKey:
+		default:
+			return fmt.Errorf("unsupported redaction detector: %s", rule.Detector)
+		}
+		switch rule.Action {
+		case RedactActionMask, RedactActionHash, RedactActionDropField:
+		default:
+			return fmt.Errorf("unsupported redaction action: %s", rule.Action)
+		}
+
+		if rule.Name == "" {
+			rule.Name = rule.Detector
+		}
+		if rule.Name == "" {
+			return fmt.Errorf("redaction rule with a pattern requires a name (index: %d)", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate redaction rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// Destination type
+const (
+	DestinationStream = "stream"
+	DestinationDrop   = "drop"
+	DestinationHttp   = "http"
+	DestinationSyslog = "syslog"
+)
+
+// Syslog transport of the syslog destination
+const (
+	SyslogUdp = "udp"
+	SyslogTcp = "tcp"
+	SyslogTls = "tls"
+)
+
+// Syslog facility codes by name
+var SyslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Maximum retention of a stream destination (hours)
+const MaxRetentionHours = 87600
+
+// Destination is a routing destination configuration structure
+type Destination struct {
+	// Destination name (unique)
+	Name string
+	// Destination type (stream, drop, http, syslog, DEF:stream)
+	Type string
+	// Stream in which the entries are stored (stream only, DEF:destination name)
+	Stream string
+	// Hours to keep the segments of the stream (stream only, DEF:0(unlimited), MAX:87600)
+	RetentionHours int
+	// Maximum total size of the segments of the stream (stream only, MB, DEF:0(unlimited))
+	RetentionSize int
+	// URL to which the entries are posted as newline-delimited JSON (http only)
+	Url string
+	// Request headers (http only, DEF:none)
+	Headers map[string]string
+	// Compression of the request body (http only, none, gzip, zstd, DEF:gzip)
+	Compression string
+	// Syslog server address (syslog only, host:port)
+	Address string
+	// Syslog transport (syslog only, udp, tcp, tls, DEF:tcp)
+	Protocol string
+	// Syslog facility (syslog only, kern, user, daemon, auth, local0~local7, etc., DEF:user)
+	Facility string
+	// HOSTNAME of the syslog messages (syslog only, DEF:host name of the system)
+	Hostname string
+	// APP-NAME of the syslog messages (syslog only, DEF:source of the entry)
+	AppName string
+	// Private enterprise number of the SD-IDs of the fields and labels (syslog only, DEF:32473)
+	EnterpriseId int
+	// CA certificate file that verifies the server (syslog tls only, DEF:system CA certificates)
+	TlsCaFile string
+	// Client certificate and key files (syslog tls only, DEF:none)
+	TlsCertFile string
+	TlsKeyFile  string
+	// Maximum entries per request or write (http, syslog, DEF:1000, MIN:1, MAX:100000)
+	BatchSize int
+	// Maximum wait before a partial batch is sent (http, syslog, seconds, DEF:5, MIN:1, MAX:300)
+	BatchInterval int
+	// Timeout of a request or write (http, syslog, seconds, DEF:30, MIN:1, MAX:300)
+	Timeout int
+	// Wait before the first retry, doubled at every retry up to 5 minutes
+	// (http, syslog, milliseconds, DEF:1000, MIN:10, MAX:60000)
+	RetryBackoff int
+	// Maximum size of the queue of the unsent batches on disk, the oldest batches
+	// are discarded when it is full (http, syslog, MB, DEF:1024, MIN:1)
+	QueueSize int
+}
+
+// Route is a routing rule configuration structure
+type Route struct {
+	// Rule name (DEF:none)
+	Name string
+	// Match expression (e.g. level >= error and field.status >= 500, DEF:all entries)
+	Match string
+	// Destination names of the matched entries
+	Destinations []string
+	// Whether the next rules are evaluated after a match (DEF:false)
+	Continue bool
+}
+
+// newDestination create a routing destination with default values.
+//
+// Returns:
+//   - Destination: routing destination
+func newDestination() Destination {
+	return Destination{Type: DestinationStream, Compression: string(compress.Gzip), Protocol: SyslogTcp,
+		Facility: "user", EnterpriseId: 32473, BatchSize: 1000, BatchInterval: 5, Timeout: 30,
+		RetryBackoff: 1000, QueueSize: 1024}
+}
+
+// newRoute create a routing rule with default values.
+//
+// Returns:
+//   - Route: routing rule
+func newRoute() Route {
+	return Route{}
+}
+
+// validateDestinations validate the routing destination list.
+//
+// Parameters:
+//   - destinations: routing destination list
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateDestinations(destinations []Destination) error {
+	names := make(map[string]bool)
+	for i := range destinations {
+		dest := &destinations[i]
+		if dest.Name == "" {
+			return fmt.Errorf("destination name is empty")
+		}
+		if names[dest.Name] {
+			return fmt.Errorf("duplicate destination name: %s", dest.Name)
+		}
+		names[dest.Name] = true
+
+		switch dest.Type {
+		case DestinationStream:
+			if dest.Stream == "" {
+				dest.Stream = dest.Name
+			}
+			if !stream.Valid(dest.Stream) {
+				return fmt.Errorf("invalid destination stream: %s (%s)", dest.Stream, dest.Name)
+			}
+			if dest.RetentionHours < 0 || dest.RetentionHours > MaxRetentionHours || dest.RetentionSize < 0 {
+				return fmt.Errorf("invalid destination retention (%s)", dest.Name)
+			}
+		case DestinationDrop:
+		case DestinationHttp:
+			if u, err := url.Parse(dest.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid destination url: %s (%s)", dest.Url, dest.Name)
+			}
+			if _, err := compress.ParseAlgorithm(dest.Compression); err != nil {
+				return fmt.Errorf("%s (%s)", err, dest.Name)
+			}
+		case DestinationSyslog:
+			if _, _, err := net.SplitHostPort(dest.Address); err != nil {
+				return fmt.Errorf("invalid destination address: %s (%s)", dest.Address, dest.Name)
+			}
+			if dest.Protocol != SyslogUdp && dest.Protocol != SyslogTcp && dest.Protocol != SyslogTls {
+				return fmt.Errorf("unsupported syslog protocol: %s (%s)", dest.Protocol, dest.Name)
+			}
+			if _, exists := SyslogFacilities[dest.Facility]; !exists {
+				return fmt.Errorf("unknown syslog facility: %s (%s)", dest.Facility, dest.Name)
+			}
+			if dest.EnterpriseId < 1 {
+				return fmt.Errorf("invalid enterprise id: %d (%s)", dest.EnterpriseId, dest.Name)
+			}
+			if (dest.TlsCertFile == "") != (dest.TlsKeyFile == "") {
+				return fmt.Errorf("tls certificate and key files must be set together (%s)", dest.Name)
+			}
+		default:
+			return fmt.Errorf("unsupported destination type: %s (%s)", dest.Type, dest.Name)
+		}
+
+		if dest.Type == DestinationHttp || dest.Type == DestinationSyslog {
+			if dest.BatchSize < 1 || dest.BatchSize > 100000 || dest.BatchInterval < 1 || dest.BatchInterval > 300 {
+				return fmt.Errorf("invalid destination batch size or interval (%s)", dest.Name)
+			}
+			if dest.Timeout < 1 || dest.Timeout > 300 || dest.RetryBackoff < 10 || dest.RetryBackoff > 60000 ||
+				dest.QueueSize < 1 {
+				return fmt.Errorf("invalid destination timeout, retry backoff or queue size (%s)", dest.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRoutes validate the routing rule list. The destinations are
+// validated before the rules.
+//
+// Parameters:
+//   - routes: routing rule list
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validateRoutes(routes []Route) error {
+	names := make(map[string]bool)
+	for _, dest := range Conf.Destinations {
+		names[dest.Name] = true
+	}
+
+	for i, route := range routes {
+		if route.Match != "" {
+			if err := validMatch(route.Match); err != nil {
+				return fmt.Errorf("%s (route index: %d)", err, i)
+			}
+		}
+		if len(route.Destinations) == 0 {
+			return fmt.Errorf("route has no destination (index: %d)", i)
+		}
+		for _, name := range route.Destinations {
+			if !names[name] {
+				return fmt.Errorf("unknown route destination: %s (index: %d)", name, i)
+			}
+		}
+	}
+	return nil
 }
 
 // RunConfig is a global running configuration structure
 type RunConfig struct {
-	DebugMode bool
-	Pid       int
+	DebugMode      bool
+	Pid            int
+	HomePath       string // Base directory of relative paths
+	ConfFilePath   string // Configuration file path
+	ConfFileGiven  bool   // Whether the configuration file path is given by the user
+	ConfFileLoaded bool   // Whether the configuration file is loaded
 }
 
 var Conf Config
 var RunConf RunConfig
 
+// Source of each configuration value (key name: source)
+var sources map[string]Source
+
+// Default value of each key and command line overrides kept for reloading
+var (
+	defaults      map[string]string
+	flagOverrides map[string]string
+)
+
+// Invalid values of the legacy properties file ignored at load
+var ignoredValues []string
+
 // init Initialize when importing config packages.
 func init() {
+	Conf.PidFilePath = PidFilePath
+	Conf.DataDirPath = DataDirPath
 	Conf.MaxLogFileSize = 100
 	Conf.MaxLogFileBackup = 10
 	Conf.MaxLogFileAge = 90
 	Conf.CompBakLogFile = true
+	Conf.CompBakLogFileAlgo = "gzip"
+	Conf.CompBakLogFileLevel = 0
+	Conf.LogSamplingThereafter = 100
+	Conf.PipelineQueueSize = 100000
+	Conf.IngestMaxBatchLines = 10000
+	Conf.IngestMaxBodySize = 16
+	Conf.GelfChunkTimeout = 5
+	Conf.ElasticCompatVersion = "8.11.0"
+	Conf.SamplingSummaryInterval = 60
+	Conf.DedupKey = DedupKeyMessage
+	Conf.PatternSimilarity = 50
+	Conf.PatternMaxClusters = 1000
+	Conf.PatternHistory = 120
+	Conf.AlertEvaluationInterval = 10
+	Conf.AlertStream = "alerts"
+	Conf.SegmentMaxSize = 64
+	Conf.SegmentCompAlgo = "zstd"
+	Conf.SegmentCompLevel = 0
+	Conf.LogSinks = []LogSink{
+		{Name: LogSinkConsoleFile, Enable: true, Format: LogFormatConsole, Level: "info", Path: ConsoleLogFilePath},
+		{Name: LogSinkJsonFile, Enable: true, Format: LogFormatJson, Level: "info", Path: JsonLogFilePath},
+		{Name: LogSinkStdout, Enable: false, Format: LogFormatConsole, Level: "info"},
+		{Name: LogSinkStderr, Enable: false, Format: LogFormatConsole, Level: "error"},
+		{Name: LogSinkSyslog, Enable: false, Format: LogFormatLogfmt, Level: "info"},
+	}
 }
 
 // LoadConfig loads configuration.
+// Values are applied in ascending order of precedence: defaults,
+// configuration file, environment variables (LOG_MANAGER_<KEY>) and
+// command line overrides. An invalid value is an error, except in the
+// legacy properties file whose invalid values are ignored (see
+// IgnoredValues).
 //
 // Parameters:
 //   - filePath: config file path
+//   - overrides: configuration values given by command line flags
 //
 // Returns:
 //   - error: success(nil), failure(error)
-func LoadConfig(filePath string) error {
+func LoadConfig(filePath string, overrides map[string]string) error {
 	// Parse configuration file
+	// The default configuration file is optional
 	config, err := parseConfig(filePath)
 	if err != nil {
-		return err
+		if RunConf.ConfFileGiven || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		config = map[string]fileValue{}
 	}
+	RunConf.ConfFileLoaded = err == nil
+
+	// Structured configuration files are validated strictly, while the
+	// legacy properties file ignores unknown keys and invalid values
+	strict := structuredFormat(filePath) != ""
 
-	if valueStr, exists := config["MaxLogFileSize"]; exists {
-		value, err := strconv.Atoi(valueStr)
-		if err != nil && value >= 1 && value <= 1000 {
-			Conf.MaxLogFileSize = value
+	keys := confKeys()
+	for name := range overrides {
+		if findConfKey(keys, name) == nil {
+			return fmt.Errorf("unknown configuration key: %s", name)
+		}
+	}
+	if strict {
+		for name := range config {
+			if findConfKey(keys, name) == nil {
+				return fmt.Errorf("unknown configuration key: %s (%s)", name, filePath)
+			}
 		}
 	}
 
-	if valueStr, exists := config["MaxLogFileBackup"]; exists {
-		value, err := strconv.Atoi(valueStr)
-		if err != nil && value >= 1 && value <= 100 {
-			Conf.MaxLogFileBackup = value
+	sources = make(map[string]Source)
+	defaults = make(map[string]string)
+	flagOverrides = overrides
+	ignoredValues = nil
+	for _, key := range keys {
+		defaults[key.name] = key.get()
+		source, err := applyKey(key, config, !strict)
+		if err != nil {
+			return err
 		}
+		sources[key.name] = source
+	}
+
+	// A batch larger than the queue could never be submitted
+	if Conf.IngestMaxBatchLines > Conf.PipelineQueueSize {
+		return fmt.Errorf("IngestMaxBatchLines (%d) must not be greater than PipelineQueueSize (%d)",
+			Conf.IngestMaxBatchLines, Conf.PipelineQueueSize)
 	}
 
-	if valueStr, exists := config["MaxLogFileAge"]; exists {
-		value, err := strconv.Atoi(valueStr)
-		if err != nil && value >= 1 && value <= 365 {
-			Conf.MaxLogFileAge = value
+	return nil
+}
+
+// applyKey applies the value of the key from the source with the highest
+// precedence (command line flag > environment variable > configuration
+// file > default). An invalid value of a command line flag, an environment
+// variable or a structured configuration file is an error. An invalid value
+// of the legacy properties file is ignored when lenient is set (recorded in
+// IgnoredValues and the default is used), and is an error otherwise.
+//
+// Parameters:
+//   - key: configuration key
+//   - file: values of the configuration file
+//   - lenient: whether invalid values of the configuration file are ignored
+//
+// Returns:
+//   - Source: source of the applied value
+//   - error: success(nil), failure(error)
+func applyKey(key *confKey, file map[string]fileValue, lenient bool) (Source, error) {
+	set := func(source Source, value string) (Source, error) {
+		if err := key.set(value); err != nil {
+			return source, fmt.Errorf("invalid value of %s (%s: %s): %s", key.name, source, value, err)
 		}
+		return source, nil
 	}
 
-	if valueStr, exists := config["CompressBackupLogFile"]; exists {
-		if strings.ToLower(valueStr) == "no" {
-			Conf.CompBakLogFile = false
+	if value, exists := flagOverrides[key.name]; exists {
+		return set(SourceFlag, value)
+	}
+	if value, exists := os.LookupEnv(key.EnvName()); exists {
+		return set(SourceEnv, value)
+	}
+	if value, exists := file[key.name]; exists {
+		if value.node != nil {
+			if err := key.decode(value.node); err != nil {
+				return SourceFile, fmt.Errorf("invalid value of %s (%s): %s", key.name, SourceFile, err)
+			}
+			return SourceFile, nil
 		}
+		source, err := set(SourceFile, value.text)
+		if err == nil || !lenient {
+			return source, err
+		}
+		ignoredValues = append(ignoredValues, err.Error())
+	}
+	// Some defaults (e.g. empty values) are not valid input of set
+	if key.get() == defaults[key.name] {
+		return SourceDefault, nil
 	}
+	return set(SourceDefault, defaults[key.name])
+}
+
+// IgnoredValues returns the invalid values of the legacy properties file
+// that were ignored by LoadConfig.
+//
+// Returns:
+//   - []string: descriptions of the ignored values
+func IgnoredValues() []string {
+	return ignoredValues
+}
 
+// ReloadConfig reloads the given keys from the configuration file, the
+// environment variables and the command line overrides given at start
+// by the same precedence as LoadConfig. Every invalid value is an error,
+// and the previous values of all given keys are kept if any of them fails.
+//
+// Parameters:
+//   - names: key names in the order in which they are applied
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func ReloadConfig(names ...string) error {
+	config, err := parseConfig(RunConf.ConfFilePath)
+	if err != nil {
+		if RunConf.ConfFileGiven || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		config = map[string]fileValue{}
+	}
+
+	keys := confKeys()
+	previous := make(map[string]string)
+	reloaded := make(map[string]Source)
+	restore := func() {
+		for _, name := range names {
+			if value, exists := previous[name]; exists {
+				findConfKey(keys, name).set(value)
+			}
+		}
+	}
+
+	for _, name := range names {
+		key := findConfKey(keys, name)
+		if key == nil {
+			restore()
+			return fmt.Errorf("unknown configuration key: %s", name)
+		}
+		previous[name] = key.get()
+
+		// Unlike at start, an invalid value of the properties file is an
+		// error so that the running configuration is not replaced by defaults
+		source, err := applyKey(key, config, false)
+		if err != nil {
+			restore()
+			return err
+		}
+		reloaded[name] = source
+	}
+
+	for name, source := range reloaded {
+		sources[name] = source
+	}
 	return nil
 }
 
+// findConfKey find a configuration key by name.
+//
+// Parameters:
+//   - keys: configuration keys
+//   - name: key name
+//
+// Returns:
+//   - *confKey: configuration key (nil if not found)
+func findConfKey(keys []*confKey, name string) *confKey {
+	for _, key := range keys {
+		if key.name == name {
+			return key
+		}
+	}
+	return nil
+}
+
+// PrintConfig writes the configuration in the configuration file
+// format with the source of each value.
+//
+// Parameters:
+//   - w: output writer
+//   - effective: print all keys(true), print keys not using default values(false)
+func PrintConfig(w io.Writer, effective bool) {
+	status := "not found"
+	if RunConf.ConfFileLoaded {
+		status = "loaded"
+	}
+	fmt.Fprintf(w, "# Configuration file: %s (%s)\n", RunConf.ConfFilePath, status)
+	fmt.Fprintf(w, "# Home path: %s\n", RunConf.HomePath)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, key := range confKeys() {
+		source, exists := sources[key.name]
+		if !exists {
+			source = SourceDefault
+		}
+		if !effective && source == SourceDefault {
+			continue
+		}
+
+		origin := string(source)
+		if source == SourceEnv {
+			origin += " (" + key.EnvName() + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t# %s\n", key.name, key.get(), origin)
+	}
+	tw.Flush()
+}
+
 // parseConfig parse the configuration file and return it to the map.
+// The format is selected by the file extension: YAML(.yaml, .yml),
+// TOML(.toml), and the legacy properties format for the others.
 //
 // Parameters:
 //   - filePath: config file path
 //
 // Returns:
-//   - map[string]string: config map
+//   - map[string]fileValue: config map
 //   - error: success(nil), failure(error)
-func parseConfig(filePath string) (map[string]string, error) {
-	config := make(map[string]string)
+func parseConfig(filePath string) (map[string]fileValue, error) {
+	if format := structuredFormat(filePath); format != "" {
+		return parseStructuredConfig(filePath, format)
+	}
+
+	config := make(map[string]fileValue)
 
 	// Open config file
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %s", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
@@ -174,15 +1485,16 @@ func parseConfig(filePath string) (map[string]string, error) {
 		}
 
 		// Separate line to key, value
+		// The value is the rest of the line (e.g. JSON list values)
 		parts := strings.Fields(line)
-		if len(parts) != 2 {
+		if len(parts) < 2 {
 			continue
 		}
 		key := parts[0]
-		value := parts[1]
+		value := strings.TrimSpace(strings.TrimPrefix(line, key))
 
 		// append key, value to config map
-		config[key] = value
+		config[key] = fileValue{text: value}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -0,0 +1,265 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes a configuration file in a temporary directory.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "log_manager.properties", "MaxLogFileAge 5\nMaxLogFileBackup 3\nMaxLogFileSize 7\n")
+
+	t.Setenv("LOG_MANAGER_MAX_LOG_FILE_BACKUP", "4")
+	t.Setenv("LOG_MANAGER_MAX_LOG_FILE_SIZE", "8")
+	if err := LoadConfig(path, map[string]string{"MaxLogFileSize": "9"}); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		got    int
+		want   int
+		source Source
+	}{
+		{"MaxLogFileAge", Conf.MaxLogFileAge, 5, SourceFile},
+		{"MaxLogFileBackup", Conf.MaxLogFileBackup, 4, SourceEnv},
+		{"MaxLogFileSize", Conf.MaxLogFileSize, 9, SourceFlag},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want || sources[tt.name] != tt.source {
+			t.Errorf("%s = %d (%s), want %d (%s)", tt.name, tt.got, sources[tt.name], tt.want, tt.source)
+		}
+	}
+}
+
+func TestLoadConfigInvalidValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		env       string
+		overrides map[string]string
+		wantErr   string
+		ignored   int
+	}{
+		{
+			name:    "invalid environment variable",
+			file:    "log_manager.properties",
+			content: "MaxLogFileAge 5\n",
+			env:     "xyz",
+			wantErr: "invalid value of MaxLogFileAge (env: xyz)",
+		},
+		{
+			name:      "invalid flag",
+			file:      "log_manager.properties",
+			overrides: map[string]string{"MaxLogFileAge": "0"},
+			wantErr:   "invalid value of MaxLogFileAge (flag: 0)",
+		},
+		{
+			name:    "invalid yaml value",
+			file:    "log_manager.yaml",
+			content: "MaxLogFileAge: 1000\n",
+			wantErr: "MaxLogFileAge",
+		},
+		{
+			name:    "invalid properties value is ignored",
+			file:    "log_manager.properties",
+			content: "MaxLogFileAge 1000\n",
+			ignored: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("LOG_MANAGER_MAX_LOG_FILE_AGE", tt.env)
+			}
+			Conf.MaxLogFileAge = 90
+			err := LoadConfig(writeConfigFile(t, tt.file, tt.content), tt.overrides)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if len(IgnoredValues()) != tt.ignored {
+				t.Errorf("ignored values = %v, want %d", IgnoredValues(), tt.ignored)
+			}
+			if Conf.MaxLogFileAge != 90 || sources["MaxLogFileAge"] != SourceDefault {
+				t.Errorf("MaxLogFileAge = %d (%s), want the default", Conf.MaxLogFileAge, sources["MaxLogFileAge"])
+			}
+		})
+	}
+}
+
+func TestLoadConfigStructured(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{
+			file: "log_manager.yaml",
+			content: `PipelineQueueSize: 1000000
+CompressBackupLogFile: no
+LogSink:
+  Stdout:
+    Level: debug
+Listeners:
+  - Name: http
+    Address: 127.0.0.1:8080
+  - Name: tail
+    Protocol: tail
+    Paths: [/var/log/*.log]
+    Multiline:
+      Preset: java
+`,
+		},
+		{
+			file: "log_manager.toml",
+			content: `PipelineQueueSize = 1000000
+CompressBackupLogFile = false
+
+[LogSink.Stdout]
+Level = "debug"
+
+[[Listeners]]
+Name = "http"
+Address = "127.0.0.1:8080"
+
+[[Listeners]]
+Name = "tail"
+Protocol = "tail"
+Paths = ["/var/log/*.log"]
+Multiline = {Preset = "java"}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if err := LoadConfig(writeConfigFile(t, tt.file, tt.content), nil); err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if Conf.PipelineQueueSize != 1000000 || Conf.CompBakLogFile {
+				t.Errorf("PipelineQueueSize = %d, CompressBackupLogFile = %v", Conf.PipelineQueueSize, Conf.CompBakLogFile)
+			}
+			if Conf.LogSinks[2].Level != "debug" {
+				t.Errorf("LogSink.Stdout.Level = %s, want debug", Conf.LogSinks[2].Level)
+			}
+			if len(Conf.Listeners) != 2 {
+				t.Fatalf("listeners = %d, want 2", len(Conf.Listeners))
+			}
+			http, tail := Conf.Listeners[0], Conf.Listeners[1]
+			if !http.Enable || http.Protocol != ListenerHttp || http.Address != "127.0.0.1:8080" {
+				t.Errorf("listener http = %+v, want defaults and the address", http)
+			}
+			if len(tail.Paths) != 1 || tail.Multiline == nil || tail.Multiline.Preset != "java" {
+				t.Errorf("listener tail = %+v, want paths and multiline", tail)
+			}
+			Conf.Listeners = nil
+		})
+	}
+}
+
+func TestLoadConfigStructuredErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{"yaml type error", "log_manager.yaml", "MaxLogFileAge: 5\nMaxLogFileSize: abc\n", "line 2"},
+		{"yaml unknown field", "log_manager.yaml", "Listeners:\n  - Name: http\n    Adress: :8080\n", `line 3: unknown field "Adress"`},
+		{"yaml list type", "log_manager.yaml", "Listeners:\n  - Name: http\n    Paths: /var/log\n", "line 3"},
+		{"yaml unknown key", "log_manager.yaml", "MaxLogFileAges: 5\n", "unknown configuration key"},
+		{"toml type error", "log_manager.toml", "MaxLogFileAge = 5\nMaxLogFileSize = \"abc\"\n", "line 2"},
+		{"toml unknown field", "log_manager.toml", "[[Listeners]]\nName = \"http\"\nAdress = \":8080\"\n", `unknown field "Adress"`},
+		{"toml range", "log_manager.toml", "MaxLogFileSize = 5000\n", "value must be between 1 and 1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := LoadConfig(writeConfigFile(t, tt.file, tt.content), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadConfig error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSample(t *testing.T) {
+	if err := LoadConfig("log_manager.yaml", nil); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+}
+
+func TestLoadConfigBatchLinesOverQueueSize(t *testing.T) {
+	path := writeConfigFile(t, "log_manager.yaml", "PipelineQueueSize: 1000\nIngestMaxBatchLines: 2000\n")
+	err := LoadConfig(path, nil)
+	if err == nil || !strings.Contains(err.Error(), "must not be greater than PipelineQueueSize") {
+		t.Fatalf("LoadConfig error = %v, want the queue size check", err)
+	}
+}
+
+func TestValidators(t *testing.T) {
+	defer RegisterMatchValidator(nil)
+	RegisterMatchValidator(func(expr string) error {
+		if strings.Contains(expr, "(") {
+			return errors.New("invalid expression")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"stream", "AlertStream: a/b\n", "invalid stream name"},
+		{"listener stream", "Listeners:\n  - Name: http\n    Address: :8080\n    Stream: a/b\n", "invalid listener stream"},
+		{"match", "Routes:\n  - Match: level == (\n    Destinations: [x]\n", "invalid expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := LoadConfig(writeConfigFile(t, "log_manager.yaml", tt.content), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadConfig error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Match expressions are rejected without the validator
+	RegisterMatchValidator(nil)
+	err := LoadConfig(writeConfigFile(t, "log_manager.yaml", "Routes:\n  - Match: level == error\n    Destinations: [x]\n"), nil)
+	if err == nil || !strings.Contains(err.Error(), "validator is not registered") {
+		t.Fatalf("LoadConfig error = %v, want the missing validator", err)
+	}
+}
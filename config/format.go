@@ -0,0 +1,420 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Structured configuration file format
+const (
+	FormatYaml = "yaml"
+	FormatToml = "toml"
+)
+
+// structuredFormat returns the structured format of the configuration
+// file by the file extension.
+//
+// Parameters:
+//   - filePath: config file path
+//
+// Returns:
+//   - string: structured format (empty string if legacy properties format)
+func structuredFormat(filePath string) string {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		return FormatYaml
+	case ".toml":
+		return FormatToml
+	}
+	return ""
+}
+
+// parseStructuredConfig parse a YAML or TOML configuration file to a
+// map of configuration keys. Nested tables are joined with dots (e.g.
+// LogSink.Stdout.Enable), and the other values are kept as nodes that
+// are decoded into the typed fields of the keys.
+//
+// Parameters:
+//   - filePath: config file path
+//   - format: structured format (yaml, toml)
+//
+// Returns:
+//   - map[string]fileValue: config map
+//   - error: success(nil), failure(error)
+func parseStructuredConfig(filePath, format string) (map[string]fileValue, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	config := make(map[string]fileValue)
+	switch format {
+	case FormatYaml:
+		var doc yaml.Node
+		if err = yaml.Unmarshal(data, &doc); err == nil && len(doc.Content) > 0 {
+			err = flattenYaml(config, "", doc.Content[0])
+		}
+	case FormatToml:
+		tree := make(map[string]toml.Primitive)
+		var meta toml.MetaData
+		if meta, err = toml.Decode(string(data), &tree); err == nil {
+			err = flattenToml(config, &meta, nil, tree)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s config file: %s", format, err)
+	}
+
+	return config, nil
+}
+
+// flattenYaml flatten the mapping of the YAML document to the map.
+//
+// Parameters:
+//   - config: config map
+//   - prefix: key prefix of the mapping
+//   - mapping: mapping node
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func flattenYaml(config map[string]fileValue, prefix string, mapping *yaml.Node) error {
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: value must be a mapping", mapping.Line)
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], resolveAlias(mapping.Content[i+1])
+		name := prefix + key.Value
+
+		switch {
+		case value.Kind == yaml.MappingNode:
+			if err := flattenYaml(config, name+".", value); err != nil {
+				return err
+			}
+		case value.Tag == "!!null":
+			continue
+		default:
+			if _, exists := config[name]; exists {
+				return fmt.Errorf("line %d: duplicate key %s", key.Line, name)
+			}
+			config[name] = fileValue{node: yamlNode{value}}
+		}
+	}
+
+	return nil
+}
+
+// flattenToml flatten the table of the TOML document to the map.
+//
+// Parameters:
+//   - config: config map
+//   - meta: metadata of the document
+//   - path: key path of the table
+//   - table: values of the table
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func flattenToml(config map[string]fileValue, meta *toml.MetaData, path []string, table map[string]toml.Primitive) error {
+	for key, value := range table {
+		keyPath := append(append([]string{}, path...), key)
+		name := strings.Join(keyPath, ".")
+
+		// Tables are flattened, including implicit ones (e.g. LogSink of [LogSink.Stdout])
+		var raw interface{}
+		if err := meta.PrimitiveDecode(value, &raw); err != nil {
+			return err
+		}
+		if _, ok := raw.(map[string]interface{}); ok {
+			nested := make(map[string]toml.Primitive)
+			if err := meta.PrimitiveDecode(value, &nested); err != nil {
+				return err
+			}
+			if err := flattenToml(config, meta, keyPath, nested); err != nil {
+				return err
+			}
+			continue
+		}
+		config[name] = fileValue{node: tomlNode{meta: meta, value: value, path: name}}
+	}
+
+	return nil
+}
+
+// fileValue is a value of the configuration file. Values of the legacy
+// properties format are text, while values of the structured formats are
+// nodes decoded into the typed fields of the keys.
+type fileValue struct {
+	text string    // Value of the properties format
+	node valueNode // Value of the structured formats (nil if properties format)
+}
+
+// valueNode is a value of a structured configuration file
+type valueNode interface {
+	// decode the value into v (pointer to a typed field). Fields of
+	// structs are matched case-insensitively and unknown fields are
+	// an error.
+	decode(v interface{}) error
+	// items returns the nodes of a list value.
+	items() ([]valueNode, error)
+}
+
+// decodeScalar decode a scalar node into its text in the format of the
+// properties file, for the keys without a typed decoder.
+//
+// Parameters:
+//   - node: value node
+//
+// Returns:
+//   - string: value text
+//   - error: success(nil), failure(error)
+func decodeScalar(node valueNode) (string, error) {
+	var value interface{}
+	if err := node.decode(&value); err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return map[bool]string{true: "yes", false: "no"}[v], nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("value must be a scalar")
+}
+
+// yamlNode is a value of the YAML configuration file
+type yamlNode struct {
+	node *yaml.Node
+}
+
+func (n yamlNode) decode(v interface{}) error {
+	return decodeYaml(n.node, reflect.ValueOf(v).Elem())
+}
+
+func (n yamlNode) items() ([]valueNode, error) {
+	if n.node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: value must be a list", n.node.Line)
+	}
+
+	items := make([]valueNode, 0, len(n.node.Content))
+	for _, item := range n.node.Content {
+		items = append(items, yamlNode{resolveAlias(item)})
+	}
+	return items, nil
+}
+
+// resolveAlias returns the node referenced by the alias node.
+//
+// Parameters:
+//   - node: YAML node
+//
+// Returns:
+//   - *yaml.Node: resolved node
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// decodeYaml decode the YAML node into the value. Unlike yaml.v3, struct
+// fields are matched by the field names as in the other formats (e.g.
+// Name, not name), and unknown fields are an error.
+//
+// Parameters:
+//   - node: YAML node
+//   - rv: settable destination value
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func decodeYaml(node *yaml.Node, rv reflect.Value) error {
+	node = resolveAlias(node)
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if node.Tag == "!!null" {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeYaml(node, rv.Elem())
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d: value must be a mapping", node.Line)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			field := rv.FieldByNameFunc(func(name string) bool {
+				return strings.EqualFold(name, key.Value)
+			})
+			if !field.IsValid() || !field.CanSet() {
+				return fmt.Errorf("line %d: unknown field %q", key.Line, key.Value)
+			}
+			if err := decodeYaml(node.Content[i+1], field); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return fmt.Errorf("line %d: value must be a list", node.Line)
+		}
+		items := reflect.MakeSlice(rv.Type(), len(node.Content), len(node.Content))
+		for i, item := range node.Content {
+			if err := decodeYaml(item, items.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(items)
+		return nil
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d: value must be a mapping", node.Line)
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := reflect.New(rv.Type().Key()).Elem()
+			value := reflect.New(rv.Type().Elem()).Elem()
+			if err := decodeYaml(node.Content[i], key); err != nil {
+				return err
+			}
+			if err := decodeYaml(node.Content[i+1], value); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		rv.Set(m)
+		return nil
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: value must be a scalar", node.Line)
+	}
+	if err := node.Decode(rv.Addr().Interface()); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return errors.New(strings.Join(typeErr.Errors, ", "))
+		}
+		return err
+	}
+	return nil
+}
+
+// tomlNode is a value of the TOML configuration file
+type tomlNode struct {
+	meta  *toml.MetaData
+	value toml.Primitive
+	path  string // Key path for the error messages
+}
+
+func (n tomlNode) decode(v interface{}) error {
+	if err := n.meta.PrimitiveDecode(n.value, v); err != nil {
+		return err
+	}
+
+	// The TOML decoder ignores unknown fields of structs
+	var raw interface{}
+	if err := n.meta.PrimitiveDecode(n.value, &raw); err != nil {
+		return err
+	}
+	return checkTomlFields(raw, reflect.TypeOf(v).Elem(), n.path)
+}
+
+func (n tomlNode) items() ([]valueNode, error) {
+	values := []toml.Primitive{}
+	if err := n.meta.PrimitiveDecode(n.value, &values); err != nil {
+		return nil, err
+	}
+
+	items := make([]valueNode, 0, len(values))
+	for i, value := range values {
+		items = append(items, tomlNode{meta: n.meta, value: value, path: fmt.Sprintf("%s[%d]", n.path, i)})
+	}
+	return items, nil
+}
+
+// checkTomlFields check that every table key of the decoded TOML value is
+// a field of the destination type.
+//
+// Parameters:
+//   - raw: decoded TOML value
+//   - t: destination type
+//   - path: key path of the value
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func checkTomlFields(raw interface{}, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		table, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for key, value := range table {
+			field, exists := t.FieldByNameFunc(func(name string) bool {
+				return strings.EqualFold(name, key)
+			})
+			if !exists || !field.IsExported() {
+				return fmt.Errorf("unknown field %q (%s)", key, path)
+			}
+			if err := checkTomlFields(value, field.Type, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		switch items := raw.(type) {
+		case []interface{}:
+			for i, item := range items {
+				if err := checkTomlFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		case []map[string]interface{}:
+			for i, item := range items {
+				if err := checkTomlFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,421 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+	"github.com/hoon-kr/log_manager/pkg/utils/stream"
+)
+
+// Prefix of the environment variables overriding configuration keys
+const EnvKeyPrefix = "LOG_MANAGER_"
+
+// Source is where a configuration value came from
+type Source string
+
+// Configuration value source (ascending order of precedence)
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// confKey is a configuration key definition
+type confKey struct {
+	// Key name in the configuration file
+	name string
+	// Validate the value and apply it to the configuration
+	set func(value string) error
+	// Current value in the configuration file format
+	get func() string
+	// Decode the value of a structured configuration file into the typed
+	// field, validate and apply it (nil if the value is a scalar text)
+	decodeNode func(node valueNode) error
+}
+
+// decode decode the value of a structured configuration file and apply
+// it to the configuration. Keys without a typed decoder take the text of
+// a scalar value like the properties format.
+//
+// Parameters:
+//   - node: value node
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (k *confKey) decode(node valueNode) error {
+	if k.decodeNode != nil {
+		return k.decodeNode(node)
+	}
+
+	value, err := decodeScalar(node)
+	if err != nil {
+		return err
+	}
+	return k.set(value)
+}
+
+// EnvName returns the environment variable name of the key.
+// (e.g. MaxLogFileSize -> LOG_MANAGER_MAX_LOG_FILE_SIZE)
+//
+// Returns:
+//   - string: environment variable name
+func (k *confKey) EnvName() string {
+	var sb strings.Builder
+	sb.WriteString(EnvKeyPrefix)
+
+	runes := []rune(k.name)
+	for i, r := range runes {
+		switch {
+		case r == '.':
+			sb.WriteByte('_')
+			continue
+		case i > 0 && unicode.IsUpper(r) && runes[i-1] != '.' &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			sb.WriteByte('_')
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+
+	return sb.String()
+}
+
+// confKeys returns the definitions of all configuration keys in the
+// order in which they are applied.
+//
+// Returns:
+//   - []*confKey: configuration keys
+func confKeys() []*confKey {
+	keys := []*confKey{
+		stringKey("PidFilePath", &Conf.PidFilePath),
+		stringKey("DataDirPath", &Conf.DataDirPath),
+		intKey("MaxLogFileSize", &Conf.MaxLogFileSize, 1, 1000),
+		intKey("MaxLogFileBackup", &Conf.MaxLogFileBackup, 1, 100),
+		intKey("MaxLogFileAge", &Conf.MaxLogFileAge, 1, 365),
+		boolKey("CompressBackupLogFile", &Conf.CompBakLogFile),
+		{
+			name: "BackupCompressAlgorithm",
+			set: func(value string) error {
+				alg, err := compress.ParseAlgorithm(value)
+				if err != nil || alg == compress.None {
+					return fmt.Errorf("unsupported compression algorithm: %s", value)
+				}
+				Conf.CompBakLogFileAlgo = string(alg)
+				return nil
+			},
+			get: func() string { return Conf.CompBakLogFileAlgo },
+		},
+		{
+			name: "BackupCompressLevel",
+			set: func(value string) error {
+				level, err := strconv.Atoi(value)
+				if err != nil || !compress.ValidLevel(compress.Algorithm(Conf.CompBakLogFileAlgo), level) {
+					return fmt.Errorf("invalid compression level: %s", value)
+				}
+				Conf.CompBakLogFileLevel = level
+				return nil
+			},
+			get: func() string { return strconv.Itoa(Conf.CompBakLogFileLevel) },
+		},
+	}
+
+	keys = append(keys,
+		intKey("LogSamplingFirst", &Conf.LogSamplingFirst, 0, 100000),
+		intKey("LogSamplingThereafter", &Conf.LogSamplingThereafter, 1, 100000))
+
+	// Input listener list
+	keys = append(keys, listKey("Listeners", &Conf.Listeners, newListener, validateListeners))
+
+	// Ingestion and store
+	keys = append(keys,
+		intKey("PipelineQueueSize", &Conf.PipelineQueueSize, 100, 10000000),
+		intKey("IngestMaxBatchLines", &Conf.IngestMaxBatchLines, 1, 1000000),
+		intKey("IngestMaxBodySize", &Conf.IngestMaxBodySize, 1, 1024),
+		intKey("GelfChunkTimeout", &Conf.GelfChunkTimeout, 1, 60),
+		stringKey("ElasticCompatVersion", &Conf.ElasticCompatVersion),
+		listKey("SamplingRules", &Conf.SamplingRules, newSamplingRule, validateSamplingRules),
+		intKey("SamplingSummaryInterval", &Conf.SamplingSummaryInterval, 1, 3600),
+		listKey("Redactions", &Conf.Redactions, newRedaction, validateRedactions),
+		stringKey("RedactionHashKey", &Conf.RedactionHashKey),
+		boolKey("Fingerprint", &Conf.Fingerprint),
+		intKey("DedupWindow", &Conf.DedupWindow, 0, 3600),
+		enumKey("DedupKey", &Conf.DedupKey, DedupKeyMessage, DedupKeyFingerprint),
+		listKey("Destinations", &Conf.Destinations, newDestination, validateDestinations),
+		listKey("Routes", &Conf.Routes, newRoute, validateRoutes),
+		boolKey("PatternMining", &Conf.PatternMining),
+		intKey("PatternSimilarity", &Conf.PatternSimilarity, 1, 100),
+		intKey("PatternMaxClusters", &Conf.PatternMaxClusters, 10, 100000),
+		intKey("PatternHistory", &Conf.PatternHistory, 2, 1440),
+		listKey("NotifyChannels", &Conf.NotifyChannels, newNotifyChannel, validateNotifyChannels),
+		listKey("AlertRules", &Conf.AlertRules, newAlertRule, validateAlertRules),
+		intKey("AlertEvaluationInterval", &Conf.AlertEvaluationInterval, 1, 300),
+		&confKey{
+			name: "AlertStream",
+			set: func(value string) error {
+				if !stream.Valid(value) {
+					return fmt.Errorf("invalid stream name: %s", value)
+				}
+				Conf.AlertStream = value
+				return nil
+			},
+			get: func() string { return Conf.AlertStream },
+		},
+		intKey("SegmentMaxSize", &Conf.SegmentMaxSize, 1, 1024),
+		&confKey{
+			name: "SegmentCompressAlgorithm",
+			set: func(value string) error {
+				alg, err := compress.ParseAlgorithm(value)
+				if err != nil {
+					return err
+				}
+				Conf.SegmentCompAlgo = string(alg)
+				return nil
+			},
+			get: func() string { return Conf.SegmentCompAlgo },
+		},
+		&confKey{
+			name: "SegmentCompressLevel",
+			set: func(value string) error {
+				level, err := strconv.Atoi(value)
+				if err != nil || !compress.ValidLevel(compress.Algorithm(Conf.SegmentCompAlgo), level) {
+					return fmt.Errorf("invalid compression level: %s", value)
+				}
+				Conf.SegmentCompLevel = level
+				return nil
+			},
+			get: func() string { return strconv.Itoa(Conf.SegmentCompLevel) },
+		})
+
+	// Log sink keys: LogSink.<Name>.<Enable|Format|Level|Path>
+	for i := range Conf.LogSinks {
+		sink := &Conf.LogSinks[i]
+		prefix := "LogSink." + sink.Name + "."
+
+		keys = append(keys,
+			boolKey(prefix+"Enable", &sink.Enable),
+			enumKey(prefix+"Format", &sink.Format, LogFormatConsole, LogFormatJson, LogFormatLogfmt),
+			enumKey(prefix+"Level", &sink.Level, "debug", "info", "warn", "error"))
+		if sink.IsFile() {
+			keys = append(keys, stringKey(prefix+"Path", &sink.Path))
+		}
+	}
+
+	return keys
+}
+
+// stringKey create a string configuration key.
+//
+// Parameters:
+//   - name: key name
+//   - ptr: configuration field
+//
+// Returns:
+//   - *confKey: configuration key
+func stringKey(name string, ptr *string) *confKey {
+	return &confKey{
+		name: name,
+		set: func(value string) error {
+			if value == "" {
+				return fmt.Errorf("empty value")
+			}
+			*ptr = value
+			return nil
+		},
+		get: func() string { return *ptr },
+	}
+}
+
+// enumKey create a string configuration key with allowed values.
+// Values are case-insensitive and stored in lower case.
+//
+// Parameters:
+//   - name: key name
+//   - ptr: configuration field
+//   - allowed: allowed values
+//
+// Returns:
+//   - *confKey: configuration key
+func enumKey(name string, ptr *string, allowed ...string) *confKey {
+	return &confKey{
+		name: name,
+		set: func(value string) error {
+			value = strings.ToLower(value)
+			for _, a := range allowed {
+				if value == a {
+					*ptr = value
+					return nil
+				}
+			}
+			return fmt.Errorf("allowed values are %s", strings.Join(allowed, ", "))
+		},
+		get: func() string { return *ptr },
+	}
+}
+
+// intKey create an integer configuration key with a range.
+//
+// Parameters:
+//   - name: key name
+//   - ptr: configuration field
+//   - min: minimum value
+//   - max: maximum value
+//
+// Returns:
+//   - *confKey: configuration key
+func intKey(name string, ptr *int, min, max int) *confKey {
+	apply := func(v int) error {
+		if v < min || v > max {
+			return fmt.Errorf("value must be between %d and %d", min, max)
+		}
+		*ptr = v
+		return nil
+	}
+
+	return &confKey{
+		name: name,
+		set: func(value string) error {
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("value must be between %d and %d", min, max)
+			}
+			return apply(v)
+		},
+		get: func() string { return strconv.Itoa(*ptr) },
+		decodeNode: func(node valueNode) error {
+			var v int
+			if err := node.decode(&v); err != nil {
+				return err
+			}
+			return apply(v)
+		},
+	}
+}
+
+// boolKey create a yes/no configuration key.
+//
+// Parameters:
+//   - name: key name
+//   - ptr: configuration field
+//
+// Returns:
+//   - *confKey: configuration key
+func boolKey(name string, ptr *bool) *confKey {
+	return &confKey{
+		name: name,
+		set: func(value string) error {
+			switch strings.ToLower(value) {
+			case "yes", "true":
+				*ptr = true
+			case "no", "false":
+				*ptr = false
+			default:
+				return fmt.Errorf("allowed values are yes, no")
+			}
+			return nil
+		},
+		get: func() string {
+			if *ptr {
+				return "yes"
+			}
+			return "no"
+		},
+		decodeNode: func(node valueNode) error {
+			var v bool
+			if err := node.decode(&v); err != nil {
+				return err
+			}
+			*ptr = v
+			return nil
+		},
+	}
+}
+
+// listKey create a list configuration key of typed items. The value is
+// a JSON array (lists of YAML/TOML files are decoded directly), and each
+// item starts from the default item before the value is decoded.
+//
+// Parameters:
+//   - name: key name
+//   - ptr: configuration field
+//   - newItem: create an item with default values
+//   - validate: validate the decoded list
+//
+// Returns:
+//   - *confKey: configuration key
+func listKey[T any](name string, ptr *[]T, newItem func() T, validate func(items []T) error) *confKey {
+	return &confKey{
+		name: name,
+		set: func(value string) error {
+			raws := []json.RawMessage{}
+			if err := json.Unmarshal([]byte(value), &raws); err != nil {
+				return fmt.Errorf("value must be a JSON array: %s", err)
+			}
+
+			items := make([]T, 0, len(raws))
+			for i, raw := range raws {
+				item := newItem()
+				decoder := json.NewDecoder(bytes.NewReader(raw))
+				decoder.DisallowUnknownFields()
+				if err := decoder.Decode(&item); err != nil {
+					return fmt.Errorf("invalid item (index: %d): %s", i, err)
+				}
+				items = append(items, item)
+			}
+
+			if err := validate(items); err != nil {
+				return err
+			}
+			*ptr = items
+			return nil
+		},
+		get: func() string {
+			data, err := json.Marshal(*ptr)
+			if err != nil {
+				return ""
+			}
+			return string(data)
+		},
+		decodeNode: func(node valueNode) error {
+			nodes, err := node.items()
+			if err != nil {
+				return err
+			}
+
+			items := make([]T, 0, len(nodes))
+			for i, itemNode := range nodes {
+				item := newItem()
+				if err := itemNode.decode(&item); err != nil {
+					return fmt.Errorf("invalid item (index: %d): %s", i, err)
+				}
+				items = append(items, item)
+			}
+
+			if err := validate(items); err != nil {
+				return err
+			}
+			*ptr = items
+			return nil
+		},
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hoon-kr/log_manager/pkg/utils/file"
+)
+
+// Environment variables of the file paths
+const (
+	EnvConfFilePath = "LOG_MANAGER_CONFIG"
+	EnvHomePath     = "LOG_MANAGER_HOME"
+)
+
+// File paths used when the binary is installed in a system binary
+// directory (Filesystem Hierarchy Standard)
+const (
+	FhsConfFilePath = "/etc/log_manager/log_manager.properties"
+	FhsHomePath     = "/var/lib/log_manager"
+	FhsPidFilePath  = "/run/log_manager/log_manager.pid"
+	FhsLogDirPath   = "/var/log/log_manager"
+)
+
+// System binary directories
+var systemBinDirs = []string{"/bin", "/sbin", "/usr/bin", "/usr/sbin", "/usr/local/bin", "/usr/local/sbin"}
+
+// InitPaths determines the home path and the configuration file path.
+// Paths given as parameters take precedence over the environment
+// variables. If neither is set, the binary directory is used as the
+// home path, except that the FHS paths are used when the binary is
+// installed in a system binary directory.
+//
+// Parameters:
+//   - homePath: home path (base directory of relative paths)
+//   - confFilePath: configuration file path
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func InitPaths(homePath, confFilePath string) error {
+	if homePath == "" {
+		homePath = os.Getenv(EnvHomePath)
+	}
+	if confFilePath == "" {
+		confFilePath = os.Getenv(EnvConfFilePath)
+	}
+	RunConf.ConfFileGiven = confFilePath != ""
+
+	modulePath, err := file.GetModulePath()
+	if err != nil {
+		return err
+	}
+
+	fhs := false
+	if homePath == "" {
+		for _, dir := range systemBinDirs {
+			if modulePath == dir {
+				fhs = true
+				break
+			}
+		}
+
+		homePath = modulePath
+		if fhs {
+			homePath = FhsHomePath
+		}
+	}
+
+	// Home path and configuration file path given by the user are
+	// relative to the current working directory
+	RunConf.HomePath, err = filepath.Abs(homePath)
+	if err != nil {
+		return fmt.Errorf("failed to absolute path: %s", err)
+	}
+
+	switch {
+	case confFilePath != "":
+		RunConf.ConfFilePath, err = filepath.Abs(confFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to absolute path: %s", err)
+		}
+	case fhs:
+		RunConf.ConfFilePath = FhsConfFilePath
+	default:
+		RunConf.ConfFilePath = ResolvePath(ConfFilePath)
+	}
+
+	if fhs {
+		Conf.PidFilePath = FhsPidFilePath
+		for i := range Conf.LogSinks {
+			if Conf.LogSinks[i].IsFile() {
+				Conf.LogSinks[i].Path = filepath.Join(FhsLogDirPath, filepath.Base(Conf.LogSinks[i].Path))
+			}
+		}
+	}
+
+	return nil
+}
+
+// ResolvePaths converts the relative file paths of the configuration
+// to absolute paths based on the home path.
+func ResolvePaths() {
+	Conf.PidFilePath = ResolvePath(Conf.PidFilePath)
+	Conf.DataDirPath = ResolvePath(Conf.DataDirPath)
+	for i := range Conf.LogSinks {
+		if Conf.LogSinks[i].IsFile() {
+			Conf.LogSinks[i].Path = ResolvePath(Conf.LogSinks[i].Path)
+		}
+	}
+}
+
+// ResolvePath converts a relative path to an absolute path based on
+// the home path.
+//
+// Parameters:
+//   - path: file path
+//
+// Returns:
+//   - string: absolute file path
+func ResolvePath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(RunConf.HomePath, path)
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package config
+
+import "fmt"
+
+// Validator of the match expressions, whose syntax belongs to the
+// internal match package. It is registered by the packages using the
+// configuration before it is loaded, so that the config package does not
+// depend on the entry model. Expressions are rejected while it is not
+// registered.
+var matchValidator func(expr string) error
+
+// RegisterMatchValidator register the validator of match expressions.
+//
+// Parameters:
+//   - validator: returns the compile error of the expression
+func RegisterMatchValidator(validator func(expr string) error) {
+	matchValidator = validator
+}
+
+// validMatch check the match expression by the registered validator.
+//
+// Parameters:
+//   - expr: match expression
+//
+// Returns:
+//   - error: valid(nil), invalid(error)
+func validMatch(expr string) error {
+	if matchValidator == nil {
+		return fmt.Errorf("match expression validator is not registered: %s", expr)
+	}
+	return matchValidator(expr)
+}
@@ -0,0 +1,379 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package alert evaluates the alert rules against the received entries and
+notifies the changes of the alert states.
+
+A rule is evaluated periodically. While its condition holds, the alert is
+pending, and it fires when the condition has held for the For duration.
+Notifications are sent only when the alert fires and when it is resolved
+(and optionally repeated while it is firing), so the same alert is not
+notified at every evaluation.
+*/
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/match"
+	"github.com/hoon-kr/log_manager/internal/metrics"
+)
+
+// Alert state
+const (
+	StateInactive = "inactive"
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Maximum number of count buckets of a threshold rule
+const maxBuckets = 600
+
+// Number of notifications per rule and state
+var notificationsTotal = metrics.NewCounter("log_manager_alert_notifications_total",
+	"Number of alert notifications per rule and state.", "rule", "state")
+
+// Alert is an alert notification structure
+type Alert struct {
+	Rule        string     `json:"rule"`
+	State       string     `json:"state"` // firing, resolved
+	Type        string     `json:"type"`
+	Severity    string     `json:"severity"`
+	Description string     `json:"description,omitempty"`
+	Match       string     `json:"match,omitempty"`
+	Value       float64    `json:"value"` // Entries in the window (threshold), seconds since the last entry (absence)
+	Threshold   int        `json:"threshold"`
+	Window      int        `json:"window"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	Message     string     `json:"message"`
+}
+
+// Status is a current state of a rule structure
+type Status struct {
+	Rule          string     `json:"rule"`
+	Type          string     `json:"type"`
+	Severity      string     `json:"severity"`
+	State         string     `json:"state"`
+	Value         float64    `json:"value"`
+	ActiveSince   *time.Time `json:"active_since,omitempty"` // Start of the pending or firing state
+	LastEvaluated time.Time  `json:"last_evaluated"`
+}
+
+// Notifier sends alert notifications
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Engine is an alert rule evaluation structure
+type Engine struct {
+	mu        sync.Mutex
+	rules     []*rule
+	interval  time.Duration
+	notifiers []Notifier
+	channels  map[string][]Notifier // Notification channels per rule name
+}
+
+// rule is an alert rule and its state structure
+type rule struct {
+	conf         config.AlertRule
+	expr         *match.Expr
+	bucketSize   time.Duration
+	counts       []int64 // Counts of the buckets (ring, threshold only)
+	epochs       []int64 // Bucket numbers of the counts
+	lastSeen     time.Time
+	state        string
+	value        float64
+	activeSince  time.Time
+	lastNotified time.Time
+	evaluated    time.Time
+}
+
+// NewEngine create alert rule engine. The rules are validated when the
+// configuration is loaded.
+//
+// Parameters:
+//   - rules: alert rules
+//   - interval: evaluation interval
+//   - channels: notification channels by name (Channels of the rules)
+//   - notifiers: notifiers of all alerts
+//
+// Returns:
+//   - *Engine: alert rule engine (nil if there is no rule)
+func NewEngine(rules []config.AlertRule, interval time.Duration, channels map[string]Notifier,
+	notifiers ...Notifier) *Engine {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	e := &Engine{interval: interval, notifiers: notifiers, channels: make(map[string][]Notifier)}
+	for _, conf := range rules {
+		for _, name := range conf.Channels {
+			if channel, exists := channels[name]; exists {
+				e.channels[conf.Name] = append(e.channels[conf.Name], channel)
+			}
+		}
+
+		rl := &rule{conf: conf, state: StateInactive, lastSeen: now}
+		if conf.Match != "" {
+			rl.expr, _ = match.Compile(conf.Match)
+		}
+		if conf.Type == config.AlertThreshold {
+			buckets := min(conf.Window, maxBuckets)
+			rl.bucketSize = time.Duration(conf.Window) * time.Second / time.Duration(buckets)
+			rl.counts = make([]int64, buckets)
+			rl.epochs = make([]int64, buckets)
+		}
+		e.rules = append(e.rules, rl)
+	}
+
+	metrics.NewGaugeFunc("log_manager_alerts_firing", "Number of firing alerts.", func() float64 {
+		firing := 0
+		for _, status := range e.Statuses() {
+			if status.State == StateFiring {
+				firing++
+			}
+		}
+		return float64(firing)
+	})
+	return e
+}
+
+// Process counts the entries matching the rules. The entries are not
+// modified. Alert notification entries are not counted.
+//
+// Parameters:
+//   - entries: log entries
+//
+// Returns:
+//   - []entry.Entry: the same entries
+func (e *Engine) Process(entries []entry.Entry) []entry.Entry {
+	if len(entries) > 0 {
+		e.record(entries, time.Now())
+	}
+	return entries
+}
+
+// record counts the entries matching the rules.
+//
+// Parameters:
+//   - entries: log entries
+//   - now: receive time of the entries
+func (e *Engine) record(entries []entry.Entry, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range entries {
+		if isNotification(&entries[i]) {
+			continue
+		}
+		for _, rl := range e.rules {
+			if rl.expr != nil && !rl.expr.Match(&entries[i]) {
+				continue
+			}
+			rl.lastSeen = now
+			if rl.counts != nil {
+				bucket := now.UnixNano() / int64(rl.bucketSize)
+				slot := bucket % int64(len(rl.counts))
+				if rl.epochs[slot] != bucket {
+					rl.epochs[slot], rl.counts[slot] = bucket, 0
+				}
+				rl.counts[slot]++
+			}
+		}
+	}
+}
+
+// Run evaluates the rules every evaluation interval until the context
+// is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (e *Engine) Run(ctx context.Context) {
+	logger.Log.LogInfo("Start alert rule evaluation (rules:%d, interval:%s)", len(e.rules), e.interval)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, a := range e.evaluate(time.Now()) {
+			e.notify(ctx, a)
+		}
+	}
+}
+
+// Statuses returns the current state of the rules.
+//
+// Returns:
+//   - []Status: rule states
+func (e *Engine) Statuses() []Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	statuses := make([]Status, 0, len(e.rules))
+	for _, rl := range e.rules {
+		status := Status{
+			Rule:          rl.conf.Name,
+			Type:          rl.conf.Type,
+			Severity:      rl.conf.Severity,
+			State:         rl.state,
+			Value:         rl.value,
+			LastEvaluated: rl.evaluated,
+		}
+		if rl.state == StatePending || rl.state == StateFiring {
+			since := rl.activeSince
+			status.ActiveSince = &since
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// evaluate evaluates the rules and changes their states.
+//
+// Parameters:
+//   - now: evaluation time
+//
+// Returns:
+//   - []Alert: alerts to notify
+func (e *Engine) evaluate(now time.Time) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := []Alert{}
+	for _, rl := range e.rules {
+		active := false
+		switch rl.conf.Type {
+		case config.AlertThreshold:
+			rl.value = float64(rl.count(now))
+			active = rl.value > float64(rl.conf.Threshold)
+		case config.AlertAbsence:
+			rl.value = now.Sub(rl.lastSeen).Truncate(time.Second).Seconds()
+			active = rl.value >= float64(rl.conf.Window)
+		}
+		rl.evaluated = now
+
+		if !active {
+			if rl.state == StateFiring {
+				alerts = append(alerts, rl.alert(StateResolved, now))
+				logger.Log.LogInfo("Alert resolved (rule:%s, value:%g)", rl.conf.Name, rl.value)
+			}
+			rl.state = StateInactive
+			continue
+		}
+
+		if rl.state == StateInactive {
+			rl.state, rl.activeSince = StatePending, now
+		}
+		switch {
+		case rl.state == StatePending && now.Sub(rl.activeSince) >= time.Duration(rl.conf.For)*time.Second:
+			rl.state, rl.lastNotified = StateFiring, now
+			alerts = append(alerts, rl.alert(StateFiring, now))
+			logger.Log.LogWarn("Alert firing (rule:%s, value:%g)", rl.conf.Name, rl.value)
+		case rl.state == StateFiring && rl.conf.RepeatInterval > 0 &&
+			now.Sub(rl.lastNotified) >= time.Duration(rl.conf.RepeatInterval)*time.Second:
+			rl.lastNotified = now
+			alerts = append(alerts, rl.alert(StateFiring, now))
+		}
+	}
+	return alerts
+}
+
+// notify sends the alert to the notifiers and the channels of the rule.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - a: alert
+func (e *Engine) notify(ctx context.Context, a Alert) {
+	notificationsTotal.Inc(a.Rule, a.State)
+	notifiers := append(append([]Notifier{}, e.notifiers...), e.channels[a.Rule]...)
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(ctx, a); err != nil {
+			logger.Log.LogWarn("failed to notify alert (rule:%s, state:%s): %s", a.Rule, a.State, err)
+		}
+	}
+}
+
+// count returns the number of entries in the window of the rule.
+//
+// Parameters:
+//   - now: end of the window
+//
+// Returns:
+//   - int64: number of entries
+func (rl *rule) count(now time.Time) int64 {
+	total := int64(0)
+	current := now.UnixNano() / int64(rl.bucketSize)
+	buckets := int64(len(rl.counts))
+	for bucket := current - buckets + 1; bucket <= current; bucket++ {
+		if slot := bucket % buckets; rl.epochs[slot] == bucket {
+			total += rl.counts[slot]
+		}
+	}
+	return total
+}
+
+// alert makes the notification of the rule.
+//
+// Parameters:
+//   - state: notified state (firing, resolved)
+//   - now: evaluation time
+//
+// Returns:
+//   - Alert: alert
+func (rl *rule) alert(state string, now time.Time) Alert {
+	a := Alert{
+		Rule:        rl.conf.Name,
+		State:       state,
+		Type:        rl.conf.Type,
+		Severity:    rl.conf.Severity,
+		Description: rl.conf.Description,
+		Match:       rl.conf.Match,
+		Value:       rl.value,
+		Threshold:   rl.conf.Threshold,
+		Window:      rl.conf.Window,
+		StartsAt:    rl.activeSince.UTC(),
+	}
+	if state == StateResolved {
+		endsAt := now.UTC()
+		a.EndsAt = &endsAt
+	}
+
+	switch rl.conf.Type {
+	case config.AlertThreshold:
+		a.Message = fmt.Sprintf("[%s] %s: %g entries in %ds (threshold: %d)",
+			state, rl.conf.Name, rl.value, rl.conf.Window, rl.conf.Threshold)
+	case config.AlertAbsence:
+		a.Message = fmt.Sprintf("[%s] %s: no entry for %gs (window: %ds)", state, rl.conf.Name, rl.value, rl.conf.Window)
+		if state == StateResolved {
+			a.Message = fmt.Sprintf("[%s] %s: entries received again", state, rl.conf.Name)
+		}
+	}
+	return a
+}
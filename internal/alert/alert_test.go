@@ -0,0 +1,256 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package alert
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	for i := range config.Conf.LogSinks {
+		config.Conf.LogSinks[i].Enable = false
+	}
+	logger.Log.InitializeLogger()
+	os.Exit(m.Run())
+}
+
+// Start of the synthetic evaluation times (multiple of every bucket size)
+var base = time.Unix(1700000400, 0)
+
+// step is an evaluation step of a rule
+type step struct {
+	at      int      // Seconds since base
+	entries []string // Levels of the entries received at the time
+	eval    bool     // Whether the rules are evaluated at the time
+	want    []string // States of the notified alerts
+	state   string   // State of the rule after the step
+}
+
+// levelEntries creates an entry per level.
+func levelEntries(levels []string) []entry.Entry {
+	entries := make([]entry.Entry, len(levels))
+	for i, level := range levels {
+		entries[i] = entry.Entry{Level: level, Message: "test"}
+	}
+	return entries
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  config.AlertRule
+		steps []step
+	}{
+		{
+			name: "threshold",
+			rule: config.AlertRule{Name: "errors", Type: config.AlertThreshold, Match: "level == error",
+				Threshold: 2, Window: 60, For: 10, RepeatInterval: 30},
+			steps: []step{
+				{at: 0, entries: []string{"error", "error", "info"}, eval: true, state: StateInactive},
+				{at: 1, entries: []string{"error"}, eval: true, state: StatePending},
+				{at: 10, eval: true, state: StatePending},
+				{at: 11, eval: true, want: []string{StateFiring}, state: StateFiring},
+				{at: 30, eval: true, state: StateFiring},
+				{at: 41, eval: true, want: []string{StateFiring}, state: StateFiring},
+				{at: 59, eval: true, state: StateFiring},
+				// The first 2 entries leave the window
+				{at: 60, eval: true, want: []string{StateResolved}, state: StateInactive},
+				{at: 61, eval: true, state: StateInactive},
+			},
+		},
+		{
+			name: "threshold without for",
+			rule: config.AlertRule{Name: "burst", Type: config.AlertThreshold, Threshold: 0, Window: 10},
+			steps: []step{
+				{at: 0, entries: []string{"info"}, eval: true, want: []string{StateFiring}, state: StateFiring},
+				{at: 5, eval: true, state: StateFiring},
+				{at: 10, eval: true, want: []string{StateResolved}, state: StateInactive},
+			},
+		},
+		{
+			name: "pending not fired",
+			rule: config.AlertRule{Name: "flap", Type: config.AlertThreshold, Threshold: 0, Window: 5, For: 10},
+			steps: []step{
+				{at: 0, entries: []string{"info"}, eval: true, state: StatePending},
+				{at: 5, eval: true, state: StateInactive},
+			},
+		},
+		{
+			name: "absence",
+			rule: config.AlertRule{Name: "silence", Type: config.AlertAbsence, Window: 30, For: 5},
+			steps: []step{
+				{at: 0, entries: []string{"info"}, eval: true, state: StateInactive},
+				{at: 29, eval: true, state: StateInactive},
+				{at: 30, eval: true, state: StatePending},
+				{at: 35, eval: true, want: []string{StateFiring}, state: StateFiring},
+				{at: 60, eval: true, state: StateFiring},
+				{at: 61, entries: []string{"debug"}},
+				{at: 62, eval: true, want: []string{StateResolved}, state: StateInactive},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine([]config.AlertRule{tt.rule}, time.Second, nil)
+			for _, s := range tt.steps {
+				now := base.Add(time.Duration(s.at) * time.Second)
+				e.record(levelEntries(s.entries), now)
+				if !s.eval {
+					continue
+				}
+				states := []string{}
+				for _, a := range e.evaluate(now) {
+					states = append(states, a.State)
+				}
+				if !reflect.DeepEqual(states, append([]string{}, s.want...)) {
+					t.Errorf("at %ds: alerts = %v, want %v", s.at, states, s.want)
+				}
+				if state := e.rules[0].state; state != s.state {
+					t.Errorf("at %ds: state = %s, want %s", s.at, state, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestAlertContent(t *testing.T) {
+	e := NewEngine([]config.AlertRule{
+		{Name: "errors", Type: config.AlertThreshold, Threshold: 1, Window: 60, Severity: "critical"},
+		{Name: "silence", Type: config.AlertAbsence, Window: 30},
+	}, time.Second, nil)
+	e.record(levelEntries([]string{"error", "error"}), base)
+
+	alerts := e.evaluate(base.Add(30 * time.Second))
+	if len(alerts) != 2 {
+		t.Fatalf("alerts = %+v, want 2", alerts)
+	}
+	if a := alerts[0]; a.Value != 2 || a.EndsAt != nil || !a.StartsAt.Equal(base.Add(30*time.Second)) ||
+		a.Message != "[firing] errors: 2 entries in 60s (threshold: 1)" {
+		t.Errorf("threshold alert = %+v", a)
+	}
+	if a := alerts[1]; a.Value != 30 || a.Message != "[firing] silence: no entry for 30s (window: 30s)" {
+		t.Errorf("absence alert = %+v", a)
+	}
+
+	e.record(levelEntries([]string{"info"}), base.Add(90*time.Second))
+	alerts = e.evaluate(base.Add(91 * time.Second))
+	if len(alerts) != 2 {
+		t.Fatalf("alerts = %+v, want 2", alerts)
+	}
+	for _, a := range alerts {
+		if a.State != StateResolved || a.EndsAt == nil || !a.EndsAt.Equal(base.Add(91*time.Second)) {
+			t.Errorf("resolved alert = %+v", a)
+		}
+	}
+	if alerts[1].Message != "[resolved] silence: entries received again" {
+		t.Errorf("message = %q", alerts[1].Message)
+	}
+}
+
+func TestCount(t *testing.T) {
+	// The window over maxBuckets uses buckets of 2 seconds
+	e := NewEngine([]config.AlertRule{{Name: "ring", Type: config.AlertThreshold, Window: 1200}}, time.Second, nil)
+	rl := e.rules[0]
+	if len(rl.counts) != maxBuckets || rl.bucketSize != 2*time.Second {
+		t.Fatalf("buckets = %d of %s, want %d of 2s", len(rl.counts), rl.bucketSize, maxBuckets)
+	}
+
+	e.record(levelEntries([]string{"info", "info"}), base)
+	e.record(levelEntries([]string{"info"}), base.Add(2*time.Second))
+	e.record(levelEntries([]string{"info"}), base.Add(600*time.Second))
+	tests := []struct {
+		at   int
+		want int64
+	}{
+		{0, 2}, {1, 2}, {2, 3}, {600, 4}, {1199, 4}, {1200, 2}, {1202, 1}, {1799, 1}, {1800, 0},
+	}
+	for _, tt := range tests {
+		if got := rl.count(base.Add(time.Duration(tt.at) * time.Second)); got != tt.want {
+			t.Errorf("count(%ds) = %d, want %d", tt.at, got, tt.want)
+		}
+	}
+
+	// A slot reused by a later bucket restarts its count
+	e.record(levelEntries([]string{"info"}), base.Add(1200*time.Second))
+	if got := rl.count(base.Add(1200 * time.Second)); got != 3 {
+		t.Errorf("count after reuse = %d, want 3", got)
+	}
+}
+
+func TestProcessNotification(t *testing.T) {
+	e := NewEngine([]config.AlertRule{{Name: "all", Type: config.AlertThreshold, Window: 60}}, time.Second, nil)
+	var stored []entry.Entry
+	n := NewStreamNotifier("alerts", func(entries []entry.Entry) error {
+		stored = append(stored, entries...)
+		return nil
+	})
+	if err := n.Notify(context.Background(), Alert{Rule: "all", State: StateFiring, Severity: "critical"}); err != nil {
+		t.Fatalf("Notify: %s", err)
+	}
+	if len(stored) != 1 || stored[0].Level != entry.LevelError || stored[0].Stream != "alerts" {
+		t.Fatalf("stored = %+v", stored)
+	}
+
+	// Notification entries are not counted
+	e.Process(stored)
+	if got := e.rules[0].count(time.Now()); got != 0 {
+		t.Errorf("count = %d, want 0", got)
+	}
+}
+
+// recorder records the notified alerts
+type recorder struct {
+	alerts []Alert
+	err    error
+}
+
+func (r *recorder) Notify(_ context.Context, a Alert) error {
+	r.alerts = append(r.alerts, a)
+	return r.err
+}
+
+func TestNotifyChannels(t *testing.T) {
+	global := &recorder{err: errors.New("unavailable")}
+	ops, dev := &recorder{}, &recorder{}
+	e := NewEngine([]config.AlertRule{
+		{Name: "errors", Type: config.AlertThreshold, Window: 60, Channels: []string{"ops", "missing"}},
+		{Name: "other", Type: config.AlertThreshold, Window: 60},
+	}, time.Second, map[string]Notifier{"ops": ops, "dev": dev}, global)
+
+	// A failed notifier does not stop the others
+	e.notify(context.Background(), Alert{Rule: "errors", State: StateFiring})
+	e.notify(context.Background(), Alert{Rule: "other", State: StateResolved})
+	if len(global.alerts) != 2 {
+		t.Errorf("global notifier got %d alerts, want 2", len(global.alerts))
+	}
+	if len(ops.alerts) != 1 || ops.alerts[0].Rule != "errors" {
+		t.Errorf("ops channel got %+v, want the alert of errors", ops.alerts)
+	}
+	if len(dev.alerts) != 0 {
+		t.Errorf("dev channel got %+v, want none", dev.alerts)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package alert
+
+import (
+	"context"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// Source of the alert notification entries
+const notificationSource = "alert"
+
+// Field of the rule name of the alert notification entries
+const ruleField = "alert_rule"
+
+// StreamNotifier stores alert notifications as entries
+type StreamNotifier struct {
+	stream string
+	submit func(entries []entry.Entry) error
+}
+
+// NewStreamNotifier create alert notifier that stores the notifications
+// as entries.
+//
+// Parameters:
+//   - stream: stream of the notification entries
+//   - submit: submit entries to the write pipeline
+//
+// Returns:
+//   - *StreamNotifier: stream notifier
+func NewStreamNotifier(stream string, submit func(entries []entry.Entry) error) *StreamNotifier {
+	return &StreamNotifier{stream: stream, submit: submit}
+}
+
+// Notify stores the alert as an entry. The level of a firing alert
+// follows its severity, and a resolved alert is stored as info.
+//
+// Parameters:
+//   - ctx: context (not used)
+//   - a: alert
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (n *StreamNotifier) Notify(_ context.Context, a Alert) error {
+	level := entry.LevelInfo
+	if a.State == StateFiring {
+		switch a.Severity {
+		case config.AlertSeverityCritical:
+			level = entry.LevelError
+		case config.AlertSeverityWarning:
+			level = entry.LevelWarn
+		}
+	}
+
+	fields := map[string]interface{}{
+		ruleField:        a.Rule,
+		"alert_state":    a.State,
+		"alert_type":     a.Type,
+		"alert_severity": a.Severity,
+		"value":          a.Value,
+		"starts_at":      a.StartsAt.Format(time.RFC3339Nano),
+	}
+	if a.EndsAt != nil {
+		fields["ends_at"] = a.EndsAt.Format(time.RFC3339Nano)
+	}
+	if a.Description != "" {
+		fields["description"] = a.Description
+	}
+
+	return n.submit([]entry.Entry{{
+		Time:    time.Now(),
+		Level:   level,
+		Source:  notificationSource,
+		Stream:  n.stream,
+		Message: a.Message,
+		Fields:  fields,
+	}})
+}
+
+// isNotification returns whether the entry is an alert notification.
+//
+// Parameters:
+//   - e: log entry
+//
+// Returns:
+//   - bool: notification(true), other entry(false)
+func isNotification(e *entry.Entry) bool {
+	if e.Source != notificationSource {
+		return false
+	}
+	_, exists := e.Fields[ruleField]
+	return exists
+}
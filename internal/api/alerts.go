@@ -0,0 +1,42 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/hoon-kr/log_manager/internal/alert"
+)
+
+// alertsResponse is a response body of the alerts endpoint
+type alertsResponse struct {
+	Alerts []alert.Status `json:"alerts"`
+}
+
+// handleAlerts returns the current state of the alert rules.
+// (GET /api/v1/alerts)
+//
+// Parameters:
+//   - w: response writer
+//   - r: request
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	resp := alertsResponse{Alerts: []alert.Status{}}
+	if s.alerts != nil {
+		resp.Alerts = s.alerts.Statuses()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
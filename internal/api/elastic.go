@@ -0,0 +1,318 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// elasticAction is an action line of the bulk request
+// (e.g. {"index": {"_index": "logs", "_id": "1"}})
+type elasticAction map[string]struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+// elasticItemResult is a per-item result of the bulk response
+type elasticItemResult struct {
+	Index       string            `json:"_index"`
+	ID          string            `json:"_id"`
+	Version     int               `json:"_version,omitempty"`
+	Result      string            `json:"result,omitempty"`
+	Shards      map[string]int    `json:"_shards,omitempty"`
+	SeqNo       *int64            `json:"_seq_no,omitempty"`
+	PrimaryTerm int               `json:"_primary_term,omitempty"`
+	Status      int               `json:"status"`
+	Error       map[string]string `json:"error,omitempty"`
+}
+
+// elasticBulkResponse is a response body of the bulk endpoint
+type elasticBulkResponse struct {
+	Took   int64                          `json:"took"`
+	Errors bool                           `json:"errors"`
+	Items  []map[string]elasticItemResult `json:"items"`
+}
+
+// handleElasticInfo responds the cluster information requested by
+// Elasticsearch clients when they connect. (GET /)
+//
+// Parameters:
+//   - w: response writer
+//   - r: request
+func (s *Server) handleElasticInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":         config.ModuleName,
+		"cluster_name": config.ModuleName,
+		"cluster_uuid": config.ModuleName,
+		"version": map[string]interface{}{
+			"number":                              config.Conf.ElasticCompatVersion,
+			"build_flavor":                        "default",
+			"build_type":                          "tar",
+			"minimum_wire_compatibility_version":  "7.17.0",
+			"minimum_index_compatibility_version": "7.0.0",
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+// handleElasticSetup acknowledges the setup requests of shippers
+// (index templates, ILM policies, ingest pipelines, license) that are
+// not needed by the store.
+//
+// Parameters:
+//   - w: response writer
+//   - r: request
+func (s *Server) handleElasticSetup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/_license"):
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"license": map[string]string{"status": "active", "type": "basic"},
+		})
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	default:
+		writeJSON(w, http.StatusOK, map[string]bool{"acknowledged": true})
+	}
+}
+
+// handleElasticBulk receives documents by the Elasticsearch bulk API.
+// The index and create actions are stored in the stream named after
+// the index. (POST /_bulk, POST /{index}/_bulk)
+//
+// Parameters:
+//   - w: response writer
+//   - r: request
+func (s *Server) handleElasticBulk(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	start := time.Now()
+	defaultIndex := r.PathValue("index")
+
+	body, status, err := s.openBody(r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	defer body.Close()
+
+	now := time.Now().UTC()
+	resp := elasticBulkResponse{Items: []map[string]elasticItemResult{}}
+	entries := []entry.Entry{}
+	// Index of the response item of each entry
+	entryItems := []int{}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), config.Conf.IngestMaxBodySize*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(resp.Items) >= config.Conf.IngestMaxBatchLines {
+			writeError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("too many items (max: %d)", config.Conf.IngestMaxBatchLines))
+			return
+		}
+
+		action := elasticAction{}
+		if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+			writeError(w, http.StatusBadRequest, "malformed action/metadata line")
+			return
+		}
+
+		for op, meta := range action {
+			index := meta.Index
+			if index == "" {
+				index = defaultIndex
+			}
+			item := elasticItemResult{Index: index, ID: meta.ID}
+			if item.ID == "" {
+				item.ID = newElasticID()
+			}
+
+			switch op {
+			case "index", "create":
+				// Source line
+				if !scanner.Scan() {
+					writeError(w, http.StatusBadRequest, "missing source line")
+					return
+				}
+				e, err := s.decodeElasticDocument(scanner.Bytes(), index, item.ID, now)
+				if err != nil {
+					item.Status = http.StatusBadRequest
+					item.Error = map[string]string{"type": "mapper_parsing_exception", "reason": err.Error()}
+					break
+				}
+				item.Status = http.StatusCreated
+				item.Result = "created"
+				item.Version = 1
+				item.PrimaryTerm = 1
+				item.Shards = map[string]int{"total": 1, "successful": 1, "failed": 0}
+				entries = append(entries, e)
+				entryItems = append(entryItems, len(resp.Items))
+			case "update":
+				// Skip the partial document line
+				scanner.Scan()
+				fallthrough
+			case "delete":
+				item.Status = http.StatusBadRequest
+				item.Error = map[string]string{"type": "action_request_validation_exception",
+					"reason": "unsupported bulk action: " + op}
+			default:
+				writeError(w, http.StatusBadRequest, "unknown bulk action: "+op)
+				return
+			}
+
+			if item.Status >= http.StatusBadRequest {
+				resp.Errors = true
+			}
+			resp.Items = append(resp.Items, map[string]elasticItemResult{op: item})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, errBodyTooLarge) || errors.Is(err, bufio.ErrTooLong) {
+			writeError(w, http.StatusRequestEntityTooLarge, errBodyTooLarge.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read request body: "+err.Error())
+		return
+	}
+
+	if !s.submit(w, entries) {
+		return
+	}
+
+	// Sequence numbers in the order of the stored entries
+	for i, itemIdx := range entryItems {
+		for op, item := range resp.Items[itemIdx] {
+			seqNo := int64(i)
+			item.SeqNo = &seqNo
+			resp.Items[itemIdx][op] = item
+		}
+	}
+
+	resp.Took = time.Since(start).Milliseconds()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// decodeElasticDocument converts a bulk source document to an entry.
+// The time is read from @timestamp and the level from log.level.
+//
+// Parameters:
+//   - line: source document line
+//   - index: index name
+//   - id: document id
+//   - now: receive time
+//
+// Returns:
+//   - entry.Entry: log entry
+//   - error: success(nil), failure(error)
+func (s *Server) decodeElasticDocument(line []byte, index, id string, now time.Time) (entry.Entry, error) {
+	stream := elasticIndexToStream(index)
+	if stream == "" {
+		return entry.Entry{}, fmt.Errorf("invalid index name: %s", index)
+	}
+
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(line, &doc); err != nil {
+		return entry.Entry{}, fmt.Errorf("failed to parse document: %s", err)
+	}
+
+	if ts, exists := doc["@timestamp"]; exists {
+		doc["time"] = ts
+		delete(doc, "@timestamp")
+	}
+	if logObj, ok := doc["log"].(map[string]interface{}); ok {
+		if level, ok := logObj["level"].(string); ok {
+			if _, exists := doc["level"]; !exists {
+				doc["level"] = level
+			}
+		}
+	}
+	// Levels unknown to the entry model are kept as a field
+	if level, ok := doc["level"].(string); ok {
+		if _, err := entry.NormalizeLevel(level); err != nil {
+			delete(doc, "level")
+			doc["level_raw"] = level
+		}
+	}
+	doc["stream"] = stream
+
+	e, err := entry.FromObject(doc, now)
+	if err != nil {
+		return e, err
+	}
+	if e.Source == "" {
+		e.Source = s.listener.Name
+	}
+	e.SetField("_index", index)
+	e.SetField("_id", id)
+	return e, nil
+}
+
+// elasticIndexToStream converts an index name to a stream name.
+// Characters not allowed in stream names are replaced with '_'.
+//
+// Parameters:
+//   - index: index name
+//
+// Returns:
+//   - string: stream name (empty string if invalid)
+func elasticIndexToStream(index string) string {
+	if index == "" {
+		return ""
+	}
+
+	stream := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, strings.TrimLeft(index, ".-_+"))
+
+	if len(stream) > 128 {
+		stream = stream[:128]
+	}
+	if !entry.ValidStream(stream) {
+		return ""
+	}
+	return stream
+}
+
+// newElasticID generates a document id.
+//
+// Returns:
+//   - string: document id
+func newElasticID() string {
+	var b [10]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
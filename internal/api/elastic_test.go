@@ -0,0 +1,212 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+)
+
+// entryWriter keeps the entries written by the pipeline.
+type entryWriter struct {
+	entries []entry.Entry
+}
+
+// Write keeps the entries.
+func (w *entryWriter) Write(entries []entry.Entry) error {
+	w.entries = append(w.entries, entries...)
+	return nil
+}
+
+// postBulk sends a bulk request and returns the response and the stored
+// entries.
+func postBulk(t *testing.T, path, body string) (*httptest.ResponseRecorder, []entry.Entry) {
+	t.Helper()
+	w := &entryWriter{}
+	pl := pipeline.NewPipeline(100, w, nil)
+	s, err := NewServer(config.Listener{Name: "elastic"}, pl, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+
+	// Write the queued entries
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pl.Run(ctx)
+	return rec, w.entries
+}
+
+func TestHandleElasticBulk(t *testing.T) {
+	body := `{"index":{}}
+{"@timestamp":"2024-01-02T03:04:05Z","message":"a","log":{"level":"error"}}
+
+{"create":{"_index":"Other Index","_id":"7"}}
+{"message":"b","level":"verbose"}
+{"index":{"_index":"+"}}
+{"message":"c"}
+{"index":{}}
+not json
+{"update":{"_id":"1"}}
+{"doc":{"message":"d"}}
+{"delete":{"_id":"2"}}
+{"index":{"_index":"..logs"}}
+{"message":"e"}
+`
+	rec, entries := postBulk(t, "/app-logs/_bulk", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec.Header().Get("X-Elastic-Product") != "Elasticsearch" {
+		t.Errorf("X-Elastic-Product header is missing")
+	}
+
+	resp := elasticBulkResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Errors {
+		t.Errorf("errors = false, want true")
+	}
+
+	// Items in the order of the actions, stored entries numbered in order
+	var seq0, seq1, seq2 int64 = 0, 1, 2
+	want := []struct {
+		op      string
+		index   string
+		id      string
+		status  int
+		errType string
+		seqNo   *int64
+	}{
+		{"index", "app-logs", "", http.StatusCreated, "", &seq0},
+		{"create", "Other Index", "7", http.StatusCreated, "", &seq1},
+		{"index", "+", "", http.StatusBadRequest, "mapper_parsing_exception", nil},
+		{"index", "app-logs", "", http.StatusBadRequest, "mapper_parsing_exception", nil},
+		{"update", "app-logs", "1", http.StatusBadRequest, "action_request_validation_exception", nil},
+		{"delete", "app-logs", "2", http.StatusBadRequest, "action_request_validation_exception", nil},
+		{"index", "..logs", "", http.StatusCreated, "", &seq2},
+	}
+	if len(resp.Items) != len(want) {
+		t.Fatalf("items = %d, want %d (%s)", len(resp.Items), len(want), rec.Body)
+	}
+	for i, w := range want {
+		item, ok := resp.Items[i][w.op]
+		if !ok {
+			t.Errorf("item %d = %v, want %s", i, resp.Items[i], w.op)
+			continue
+		}
+		if item.Index != w.index || item.Status != w.status || item.Error["type"] != w.errType {
+			t.Errorf("item %d = %+v, want index %q, status %d, error %q", i, item, w.index, w.status, w.errType)
+		}
+		if (w.id != "" && item.ID != w.id) || item.ID == "" {
+			t.Errorf("item %d id = %q, want %q", i, item.ID, w.id)
+		}
+		if (item.SeqNo == nil) != (w.seqNo == nil) || (item.SeqNo != nil && *item.SeqNo != *w.seqNo) {
+			t.Errorf("item %d seq_no = %v, want %v", i, item.SeqNo, w.seqNo)
+		}
+	}
+
+	// Index mapped to the stream, document fields to the entry
+	if len(entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(entries))
+	}
+	if e := entries[0]; e.Stream != "app-logs" || e.Message != "a" || e.Level != entry.LevelError ||
+		e.Time.Unix() != 1704164645 || e.Source != "elastic" || e.Fields["_id"] != resp.Items[0]["index"].ID {
+		t.Errorf("entry 0 = %+v", e)
+	}
+	if e := entries[1]; e.Stream != "Other_Index" || e.Message != "b" || e.Level != entry.LevelInfo ||
+		e.Fields["level_raw"] != "verbose" || e.Fields["_index"] != "Other Index" || e.Fields["_id"] != "7" {
+		t.Errorf("entry 1 = %+v", e)
+	}
+	if e := entries[2]; e.Stream != "logs" || e.Message != "e" {
+		t.Errorf("entry 2 = %+v", e)
+	}
+}
+
+func TestHandleElasticBulkErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantErrors bool
+	}{
+		{"no errors", "/_bulk", "{\"index\":{\"_index\":\"app\"}}\n{\"message\":\"a\"}\n", http.StatusOK, false},
+		{"no index", "/_bulk", "{\"index\":{}}\n{\"message\":\"a\"}\n", http.StatusOK, true},
+		{"malformed action", "/app/_bulk", "{\"message\":\"a\"}\n", http.StatusBadRequest, false},
+		{"two actions in a line", "/app/_bulk", "{\"index\":{},\"create\":{}}\n{\"message\":\"a\"}\n",
+			http.StatusBadRequest, false},
+		{"missing source line", "/app/_bulk", "{\"index\":{}}\n", http.StatusBadRequest, false},
+		{"unknown action", "/app/_bulk", "{\"upsert\":{}}\n{\"message\":\"a\"}\n", http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, entries := postBulk(t, tt.path, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				// A rejected request stores nothing
+				if len(entries) != 0 {
+					t.Errorf("entries = %d, want 0", len(entries))
+				}
+				return
+			}
+
+			resp := elasticBulkResponse{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Errors != tt.wantErrors {
+				t.Errorf("errors = %t, want %t", resp.Errors, tt.wantErrors)
+			}
+			if wantEntries := map[bool]int{false: 1, true: 0}[tt.wantErrors]; len(entries) != wantEntries {
+				t.Errorf("entries = %d, want %d", len(entries), wantEntries)
+			}
+		})
+	}
+}
+
+func TestElasticIndexToStream(t *testing.T) {
+	tests := []struct {
+		index string
+		want  string
+	}{
+		{"logs-app.2024", "logs-app.2024"},
+		{"my index/x", "my_index_x"},
+		{".ds-logs", "ds-logs"},
+		{"-_+", ""},
+		{"", ""},
+		{strings.Repeat("a", 200), strings.Repeat("a", 128)},
+	}
+	for _, tt := range tests {
+		if got := elasticIndexToStream(tt.index); got != tt.want {
+			t.Errorf("elasticIndexToStream(%q) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+)
+
+// Seconds the client should wait before retrying when the queue is full
+const retryAfterSeconds = 1
+
+// Maximum number of line errors in the response
+const maxLineErrors = 100
+
+// errBodyTooLarge is returned when the decoded body exceeds the limit
+var errBodyTooLarge = errors.New("request body too large")
+
+// lineError is a validation error of a line
+type lineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ingestResponse is a response body of the ingestion endpoint
+type ingestResponse struct {
+	Accepted int         `json:"accepted"`
+	Rejected int         `json:"rejected"`
+	Errors   []lineError `json:"errors,omitempty"`
+}
+
+// handleIngest receives newline-delimited JSON log lines.
+// (POST /api/v1/ingest?stream=<default stream>)
+//
+// Parameters:
+//   - w: response writer
+//   - r: request
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	stream := r.URL.Query().Get("stream")
+	if stream == "" {
+		stream = entry.DefaultStream
+	} else if !entry.ValidStream(stream) {
+		writeError(w, http.StatusBadRequest, "invalid stream name: "+stream)
+		return
+	}
+
+	body, status, err := s.openBody(r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	defer body.Close()
+
+	now := time.Now().UTC()
+	resp := ingestResponse{}
+	entries := []entry.Entry{}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), config.Conf.IngestMaxBodySize*1024*1024)
+	lineNum, count := 0, 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		// Blank lines are not counted towards the limit
+		count++
+		if count > config.Conf.IngestMaxBatchLines {
+			writeError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("too many lines (max: %d)", config.Conf.IngestMaxBatchLines))
+			return
+		}
+
+		e, err := s.decodeLine(line, stream, now)
+		if err != nil {
+			resp.Rejected++
+			if len(resp.Errors) < maxLineErrors {
+				resp.Errors = append(resp.Errors, lineError{Line: lineNum, Error: err.Error()})
+			}
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, errBodyTooLarge) || errors.Is(err, bufio.ErrTooLong) {
+			writeError(w, http.StatusRequestEntityTooLarge, errBodyTooLarge.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read request body: "+err.Error())
+		return
+	}
+
+	if len(entries) == 0 && resp.Rejected > 0 {
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+	if !s.submit(w, entries) {
+		return
+	}
+
+	resp.Accepted = len(entries)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// decodeLine converts a JSON log line to an entry.
+//
+// Parameters:
+//   - line: JSON log line
+//   - stream: default stream
+//   - now: receive time
+//
+// Returns:
+//   - entry.Entry: log entry
+//   - error: success(nil), failure(error)
+func (s *Server) decodeLine(line []byte, stream string, now time.Time) (entry.Entry, error) {
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(line, &obj); err != nil {
+		return entry.Entry{}, fmt.Errorf("invalid JSON object: %s", err)
+	}
+	if _, exists := obj["stream"]; !exists {
+		obj["stream"] = stream
+	}
+
+	e, err := entry.FromObject(obj, now)
+	if err != nil {
+		return e, err
+	}
+	if e.Source == "" {
+		e.Source = s.listener.Name
+	}
+	return e, nil
+}
+
+// openBody returns the request body decoded by the content encoding
+// and limited to the maximum body size.
+//
+// Parameters:
+//   - r: request
+//
+// Returns:
+//   - io.ReadCloser: body reader
+//   - int: HTTP status code on failure
+//   - error: success(nil), failure(error)
+func (s *Server) openBody(r *http.Request) (io.ReadCloser, int, error) {
+	reader, err := compress.NewDecoder(r.Body, r.Header.Get("Content-Encoding"))
+	if err != nil {
+		if r.Header.Get("Content-Encoding") != "" {
+			return nil, http.StatusUnsupportedMediaType, err
+		}
+		return nil, http.StatusBadRequest, err
+	}
+
+	limit := int64(config.Conf.IngestMaxBodySize) * 1024 * 1024
+	return &limitedReadCloser{ReadCloser: reader, remain: limit}, 0, nil
+}
+
+// submit applies the parser stages of the listener and submits entries
+// to the write pipeline. If the internal queue is full, it responds 429
+// with Retry-After, and 413 if the entries exceed the queue capacity.
+//
+// Parameters:
+//   - w: response writer
+//   - entries: log entries
+//
+// Returns:
+//   - bool: submitted(true), response written(false)
+func (s *Server) submit(w http.ResponseWriter, entries []entry.Entry) bool {
+	if len(entries) == 0 {
+		return true
+	}
+
+	entries = s.parser.Process(entries)
+	if err := s.pipeline.Submit(entries); err != nil {
+		if errors.Is(err, pipeline.ErrQueueFull) {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			writeError(w, http.StatusTooManyRequests, err.Error())
+			return false
+		}
+		if errors.Is(err, pipeline.ErrBatchTooLarge) {
+			// Retrying the same request can never succeed
+			writeError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("too many entries (max: %d)", s.pipeline.Capacity()))
+			return false
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// limitedReadCloser returns errBodyTooLarge after reading the limit
+type limitedReadCloser struct {
+	io.ReadCloser
+	remain int64
+}
+
+// Read reads up to the remaining limit.
+//
+// Parameters:
+//   - p: read buffer
+//
+// Returns:
+//   - int: number of bytes read
+//   - error: success(nil), failure(error)
+func (l *limitedReadCloser) Read(p []byte) (int, error) {
+	if l.remain <= 0 {
+		// Verify that the body really continues
+		var b [1]byte
+		if n, _ := l.ReadCloser.Read(b[:]); n > 0 {
+			return 0, errBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remain {
+		p = p[:l.remain]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remain -= int64(n)
+	return n, err
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+)
+
+func TestHandleIngestLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		maxLines   int
+		wantStatus int
+		wantDepth  int
+	}{
+		{"blank lines are not counted", "{\"msg\":\"a\"}\n\n\n{\"msg\":\"b\"}\n", 2, http.StatusOK, 2},
+		{"too many lines", "{\"msg\":\"a\"}\n{\"msg\":\"b\"}\n{\"msg\":\"c\"}\n", 2, http.StatusRequestEntityTooLarge, 0},
+		{"more entries than the queue capacity", strings.Repeat("{\"msg\":\"a\"}\n", 101), 1000,
+			http.StatusRequestEntityTooLarge, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxLines := config.Conf.IngestMaxBatchLines
+			config.Conf.IngestMaxBatchLines = tt.maxLines
+			defer func() { config.Conf.IngestMaxBatchLines = maxLines }()
+
+			pl := pipeline.NewPipeline(100, nil, nil)
+			s, err := NewServer(config.Listener{Name: "http"}, pl, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/ingest",
+				strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if pl.Depth() != tt.wantDepth {
+				t.Errorf("queued = %d, want %d", pl.Depth(), tt.wantDepth)
+			}
+		})
+	}
+}
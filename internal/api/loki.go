@@ -0,0 +1,333 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+)
+
+// Labels from which the level of a Loki entry is read
+var lokiLevelLabels = []string{"level", "detected_level", "severity"}
+
+// lokiPushRequest is a JSON body of the Loki push API
+type lokiPushRequest struct {
+	Streams []struct {
+		Stream map[string]string   `json:"stream"`
+		Values [][]json.RawMessage `json:"values"`
+	} `json:"streams"`
+}
+
+// handleLokiPush receives entries by the Loki push API in JSON or
+// snappy-compressed protobuf. Stream labels are stored as indexed labels.
+// (POST /loki/api/v1/push?stream=<stream>)
+//
+// Parameters:
+//   - w: response writer
+//   - r: request
+func (s *Server) handleLokiPush(w http.ResponseWriter, r *http.Request) {
+	stream := r.URL.Query().Get("stream")
+	if stream == "" {
+		stream = entry.DefaultStream
+	} else if !entry.ValidStream(stream) {
+		writeError(w, http.StatusBadRequest, "invalid stream name: "+stream)
+		return
+	}
+
+	var entries []entry.Entry
+	var err error
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "application/json" {
+		entries, err = s.decodeLokiJSON(r, stream)
+	} else {
+		entries, err = s.decodeLokiProto(r, stream)
+	}
+	if err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !s.submit(w, entries) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeLokiJSON decodes a JSON push request.
+//
+// Parameters:
+//   - r: request
+//   - stream: stream of the entries
+//
+// Returns:
+//   - []entry.Entry: log entries
+//   - error: success(nil), failure(error)
+func (s *Server) decodeLokiJSON(r *http.Request, stream string) ([]entry.Entry, error) {
+	body, _, err := s.openBody(r)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	req := lokiPushRequest{}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			return nil, errBodyTooLarge
+		}
+		return nil, fmt.Errorf("invalid push request: %s", err)
+	}
+
+	entries := []entry.Entry{}
+	for _, st := range req.Streams {
+		for i, value := range st.Values {
+			if len(value) < 2 || len(value) > 3 {
+				return nil, fmt.Errorf("invalid value (index: %d): expected [timestamp, line, metadata]", i)
+			}
+
+			var tsStr, line string
+			if err := json.Unmarshal(value[0], &tsStr); err != nil {
+				return nil, fmt.Errorf("invalid timestamp (index: %d): %s", i, err)
+			}
+			ts, err := strconv.ParseInt(tsStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp (index: %d): %s", i, err)
+			}
+			if err := json.Unmarshal(value[1], &line); err != nil {
+				return nil, fmt.Errorf("invalid line (index: %d): %s", i, err)
+			}
+			metadata := map[string]string{}
+			if len(value) == 3 {
+				if err := json.Unmarshal(value[2], &metadata); err != nil {
+					return nil, fmt.Errorf("invalid structured metadata (index: %d): %s", i, err)
+				}
+			}
+
+			entries = append(entries, s.newLokiEntry(stream, st.Stream, time.Unix(0, ts), line, metadata))
+		}
+	}
+
+	return entries, nil
+}
+
+// decodeLokiProto decodes a snappy-compressed protobuf push request.
+//
+//	PushRequest    { repeated StreamAdapter streams = 1; }
+//	StreamAdapter  { string labels = 1; repeated EntryAdapter entries = 2; }
+//	EntryAdapter   { Timestamp timestamp = 1; string line = 2;
+//	                 repeated LabelPairAdapter structuredMetadata = 3; }
+//	LabelPairAdapter { string name = 1; string value = 2; }
+//
+// Parameters:
+//   - r: request
+//   - stream: stream of the entries
+//
+// Returns:
+//   - []entry.Entry: log entries
+//   - error: success(nil), failure(error)
+func (s *Server) decodeLokiProto(r *http.Request, stream string) ([]entry.Entry, error) {
+	limit := config.Conf.IngestMaxBodySize * 1024 * 1024
+	data, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %s", err)
+	}
+	if len(data) > limit {
+		return nil, errBodyTooLarge
+	}
+
+	// Snappy is the default encoding of the protobuf push request
+	switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+	case "", "snappy":
+		if data, err = compress.DecodeSnappy(data, limit); err != nil {
+			return nil, err
+		}
+	case "identity":
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", r.Header.Get("Content-Encoding"))
+	}
+
+	entries := []entry.Entry{}
+	err = walkProto(data, func(f protoField) error {
+		if f.num != 1 {
+			return nil
+		}
+
+		// StreamAdapter
+		var labels map[string]string
+		var rawEntries [][]byte
+		err := walkProto(f.bytes, func(f protoField) error {
+			var err error
+			switch f.num {
+			case 1:
+				labels, err = parseLokiLabels(string(f.bytes))
+			case 2:
+				rawEntries = append(rawEntries, f.bytes)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, raw := range rawEntries {
+			// EntryAdapter
+			var ts time.Time
+			var line string
+			metadata := map[string]string{}
+			err := walkProto(raw, func(f protoField) error {
+				var err error
+				switch f.num {
+				case 1:
+					var sec, nsec int64
+					err = walkProto(f.bytes, func(f protoField) error {
+						switch f.num {
+						case 1:
+							sec = int64(f.varint)
+						case 2:
+							nsec = int64(f.varint)
+						}
+						return nil
+					})
+					ts = time.Unix(sec, nsec)
+				case 2:
+					line = string(f.bytes)
+				case 3:
+					var name, value string
+					err = walkProto(f.bytes, func(f protoField) error {
+						switch f.num {
+						case 1:
+							name = string(f.bytes)
+						case 2:
+							value = string(f.bytes)
+						}
+						return nil
+					})
+					metadata[name] = value
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, s.newLokiEntry(stream, labels, ts, line, metadata))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid push request: %s", err)
+	}
+
+	return entries, nil
+}
+
+// newLokiEntry create an entry from a Loki entry.
+//
+// Parameters:
+//   - stream: stream of the entry
+//   - labels: stream labels
+//   - ts: timestamp
+//   - line: log line
+//   - metadata: structured metadata
+//
+// Returns:
+//   - entry.Entry: log entry
+func (s *Server) newLokiEntry(stream string, labels map[string]string, ts time.Time, line string,
+	metadata map[string]string) entry.Entry {
+	e := entry.Entry{
+		Time:    ts.UTC(),
+		Level:   entry.LevelInfo,
+		Source:  s.listener.Name,
+		Stream:  stream,
+		Message: line,
+	}
+
+	for key, value := range labels {
+		e.SetLabel(key, value)
+	}
+	for key, value := range metadata {
+		e.SetField(key, value)
+	}
+
+	for _, key := range lokiLevelLabels {
+		value, exists := labels[key]
+		if !exists {
+			value, exists = metadata[key]
+		}
+		if !exists {
+			continue
+		}
+		if level, err := entry.NormalizeLevel(value); err == nil {
+			e.Level = level
+			break
+		}
+	}
+
+	return e
+}
+
+// parseLokiLabels parse a label set string (e.g. {job="app", env="prod"}).
+//
+// Parameters:
+//   - s: label set string
+//
+// Returns:
+//   - map[string]string: labels
+//   - error: success(nil), failure(error)
+func parseLokiLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("invalid labels: %s", s)
+	}
+	rest := strings.TrimSpace(s[1 : len(s)-1])
+
+	for rest != "" {
+		key, value, found := strings.Cut(rest, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid labels: %s", s)
+		}
+		key = strings.TrimSpace(key)
+
+		value = strings.TrimSpace(value)
+		quoted, err := strconv.QuotedPrefix(value)
+		if err != nil || key == "" {
+			return nil, fmt.Errorf("invalid labels: %s", s)
+		}
+		labels[key], _ = strconv.Unquote(quoted)
+
+		rest = strings.TrimSpace(value[len(quoted):])
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	}
+
+	return labels, nil
+}
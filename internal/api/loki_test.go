@@ -0,0 +1,107 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoBytes appends a length-delimited field.
+func protoBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// protoVarint appends a varint field.
+func protoVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// lokiPushProto builds a push request with one stream and one entry.
+func lokiPushProto(labels string, timestamp, metadata []byte) []byte {
+	var entryAdapter []byte
+	entryAdapter = protoBytes(entryAdapter, 1, timestamp)
+	entryAdapter = protoBytes(entryAdapter, 2, []byte("level=error msg=failed"))
+	entryAdapter = protoBytes(entryAdapter, 3, metadata)
+
+	var streamAdapter []byte
+	streamAdapter = protoBytes(streamAdapter, 1, []byte(labels))
+	streamAdapter = protoBytes(streamAdapter, 2, entryAdapter)
+	return protoBytes(nil, 1, streamAdapter)
+}
+
+func TestDecodeLokiProto(t *testing.T) {
+	var timestamp []byte
+	timestamp = protoVarint(timestamp, 1, 1700000000)
+	timestamp = protoVarint(timestamp, 2, 123)
+	var metadata []byte
+	metadata = protoBytes(metadata, 1, []byte("trace_id"))
+	metadata = protoBytes(metadata, 2, []byte("abc"))
+	// Truncated length-delimited field
+	invalid := []byte{0x0a, 0x05, 'a'}
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		wantErr  string
+	}{
+		{"snappy", snappy.Encode(nil, lokiPushProto(`{job="app", level="error"}`, timestamp, metadata)), "", ""},
+		{"identity", lokiPushProto(`{job="app", level="error"}`, timestamp, metadata), "identity", ""},
+		{"invalid labels", lokiPushProto(`job="app"`, timestamp, metadata), "identity", "invalid labels"},
+		{"invalid timestamp", lokiPushProto(`{job="app"}`, invalid, metadata), "identity", "invalid protobuf field"},
+		{"invalid metadata", lokiPushProto(`{job="app"}`, timestamp, invalid), "identity", "invalid protobuf field"},
+		{"invalid snappy", []byte("not snappy"), "snappy", "snappy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{listener: config.Listener{Name: "loki"}}
+			r := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", strings.NewReader(string(tt.body)))
+			r.Header.Set("Content-Encoding", tt.encoding)
+
+			entries, err := s.decodeLokiProto(r, "default")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeLokiProto error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeLokiProto: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("entries = %d, want 1", len(entries))
+			}
+			e := entries[0]
+			if !e.Time.Equal(time.Unix(1700000000, 123)) || e.Message != "level=error msg=failed" {
+				t.Errorf("entry = %v %q", e.Time, e.Message)
+			}
+			if e.Labels["job"] != "app" || e.Level != "error" || e.Fields["trace_id"] != "abc" || e.Source != "loki" {
+				t.Errorf("entry = %+v, want labels, level and metadata", e)
+			}
+		})
+	}
+}
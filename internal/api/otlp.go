@@ -0,0 +1,607 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// otlpKeyValue is a JSON attribute of OTLP
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue is a JSON attribute value of OTLP (only one is set)
+type otlpAnyValue struct {
+	StringValue *string      `json:"stringValue"`
+	BoolValue   *bool        `json:"boolValue"`
+	IntValue    *json.Number `json:"intValue"`
+	DoubleValue *float64     `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []otlpAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []otlpKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	BytesValue []byte `json:"bytesValue"`
+}
+
+// otlpLogsRequest is a JSON body of the OTLP logs export request
+type otlpLogsRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name       string         `json:"name"`
+				Version    string         `json:"version"`
+				Attributes []otlpKeyValue `json:"attributes"`
+			} `json:"scope"`
+			LogRecords []struct {
+				TimeUnixNano         json.Number    `json:"timeUnixNano"`
+				ObservedTimeUnixNano json.Number    `json:"observedTimeUnixNano"`
+				SeverityNumber       int            `json:"severityNumber"`
+				SeverityText         string         `json:"severityText"`
+				Body                 *otlpAnyValue  `json:"body"`
+				Attributes           []otlpKeyValue `json:"attributes"`
+				TraceId              string         `json:"traceId"`
+				SpanId               string         `json:"spanId"`
+				EventName            string         `json:"eventName"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+// otlpScope is an instrumentation scope of OTLP
+type otlpScope struct {
+	name       string
+	version    string
+	attributes map[string]interface{}
+}
+
+// otlpLogRecord is a log record of OTLP decoded from JSON or protobuf
+type otlpLogRecord struct {
+	timeUnixNano         uint64
+	observedTimeUnixNano uint64
+	severityNumber       int
+	severityText         string
+	body                 interface{}
+	attributes           map[string]interface{}
+	traceID              []byte
+	spanID               []byte
+	eventName            string
+}
+
+// handleOtlpLogs receives log records by the OTLP/HTTP logs export
+// request in protobuf or JSON. Resource attributes are stored as indexed
+// labels, and the other attributes, trace id and span id as fields.
+// (POST /v1/logs?stream=<stream>)
+//
+// Parameters:
+//   - w: response writer
+//   - r: request
+func (s *Server) handleOtlpLogs(w http.ResponseWriter, r *http.Request) {
+	stream := r.URL.Query().Get("stream")
+	if stream == "" {
+		stream = entry.DefaultStream
+	} else if !entry.ValidStream(stream) {
+		writeError(w, http.StatusBadRequest, "invalid stream name: "+stream)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/json" && contentType != "application/x-protobuf" {
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported content type: "+contentType)
+		return
+	}
+
+	body, status, err := s.openBody(r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read request body: "+err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	var entries []entry.Entry
+	if contentType == "application/json" {
+		entries, err = s.decodeOtlpJSON(data, stream, now)
+	} else {
+		entries, err = s.decodeOtlpProto(data, stream, now)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !s.submit(w, entries) {
+		return
+	}
+
+	// Empty ExportLogsServiceResponse (no partial success)
+	if contentType == "application/json" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+// decodeOtlpJSON decodes a JSON logs export request.
+//
+// Parameters:
+//   - data: request body
+//   - stream: stream of the entries
+//   - now: receive time
+//
+// Returns:
+//   - []entry.Entry: log entries
+//   - error: success(nil), failure(error)
+func (s *Server) decodeOtlpJSON(data []byte, stream string, now time.Time) ([]entry.Entry, error) {
+	req := otlpLogsRequest{}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid export request: %s", err)
+	}
+
+	entries := []entry.Entry{}
+	for _, rl := range req.ResourceLogs {
+		resource := otlpJSONAttributes(rl.Resource.Attributes)
+		for _, sl := range rl.ScopeLogs {
+			scope := otlpScope{
+				name:       sl.Scope.Name,
+				version:    sl.Scope.Version,
+				attributes: otlpJSONAttributes(sl.Scope.Attributes),
+			}
+
+			for i, lr := range sl.LogRecords {
+				rec := otlpLogRecord{
+					severityNumber: lr.SeverityNumber,
+					severityText:   lr.SeverityText,
+					attributes:     otlpJSONAttributes(lr.Attributes),
+					eventName:      lr.EventName,
+				}
+				if lr.Body != nil {
+					rec.body = lr.Body.value()
+				}
+
+				var err error
+				if rec.timeUnixNano, err = parseOtlpNano(lr.TimeUnixNano); err != nil {
+					return nil, fmt.Errorf("invalid timeUnixNano (index: %d): %s", i, err)
+				}
+				if rec.observedTimeUnixNano, err = parseOtlpNano(lr.ObservedTimeUnixNano); err != nil {
+					return nil, fmt.Errorf("invalid observedTimeUnixNano (index: %d): %s", i, err)
+				}
+				if rec.traceID, err = hex.DecodeString(lr.TraceId); err != nil {
+					return nil, fmt.Errorf("invalid traceId (index: %d): %s", i, err)
+				}
+				if rec.spanID, err = hex.DecodeString(lr.SpanId); err != nil {
+					return nil, fmt.Errorf("invalid spanId (index: %d): %s", i, err)
+				}
+
+				entries = append(entries, s.newOtlpEntry(stream, resource, &scope, &rec, now))
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// decodeOtlpProto decodes a protobuf logs export request.
+//
+//	ExportLogsServiceRequest { repeated ResourceLogs resource_logs = 1; }
+//	ResourceLogs { Resource resource = 1; repeated ScopeLogs scope_logs = 2; }
+//	Resource     { repeated KeyValue attributes = 1; }
+//	ScopeLogs    { InstrumentationScope scope = 1; repeated LogRecord log_records = 2; }
+//	InstrumentationScope { string name = 1; string version = 2; repeated KeyValue attributes = 3; }
+//	LogRecord    { fixed64 time_unix_nano = 1; SeverityNumber severity_number = 2;
+//	               string severity_text = 3; AnyValue body = 5; repeated KeyValue attributes = 6;
+//	               bytes trace_id = 9; bytes span_id = 10; fixed64 observed_time_unix_nano = 11;
+//	               string event_name = 12; }
+//
+// Parameters:
+//   - data: request body
+//   - stream: stream of the entries
+//   - now: receive time
+//
+// Returns:
+//   - []entry.Entry: log entries
+//   - error: success(nil), failure(error)
+func (s *Server) decodeOtlpProto(data []byte, stream string, now time.Time) ([]entry.Entry, error) {
+	entries := []entry.Entry{}
+	err := walkProto(data, func(f protoField) error {
+		if f.num != 1 {
+			return nil
+		}
+
+		// ResourceLogs
+		resource := map[string]interface{}{}
+		var rawScopeLogs [][]byte
+		err := walkProto(f.bytes, func(f protoField) error {
+			switch f.num {
+			case 1:
+				return walkProto(f.bytes, func(f protoField) error {
+					if f.num == 1 {
+						return decodeOtlpKeyValue(f.bytes, resource)
+					}
+					return nil
+				})
+			case 2:
+				rawScopeLogs = append(rawScopeLogs, f.bytes)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, raw := range rawScopeLogs {
+			// ScopeLogs
+			scope := otlpScope{attributes: map[string]interface{}{}}
+			var rawRecords [][]byte
+			err := walkProto(raw, func(f protoField) error {
+				switch f.num {
+				case 1:
+					return walkProto(f.bytes, func(f protoField) error {
+						switch f.num {
+						case 1:
+							scope.name = string(f.bytes)
+						case 2:
+							scope.version = string(f.bytes)
+						case 3:
+							return decodeOtlpKeyValue(f.bytes, scope.attributes)
+						}
+						return nil
+					})
+				case 2:
+					rawRecords = append(rawRecords, f.bytes)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, rawRecord := range rawRecords {
+				rec, err := decodeOtlpLogRecord(rawRecord)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, s.newOtlpEntry(stream, resource, &scope, rec, now))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid export request: %s", err)
+	}
+
+	return entries, nil
+}
+
+// decodeOtlpLogRecord decodes a protobuf log record.
+//
+// Parameters:
+//   - data: LogRecord message
+//
+// Returns:
+//   - *otlpLogRecord: log record
+//   - error: success(nil), failure(error)
+func decodeOtlpLogRecord(data []byte) (*otlpLogRecord, error) {
+	rec := &otlpLogRecord{attributes: map[string]interface{}{}}
+	err := walkProto(data, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			rec.timeUnixNano = f.varint
+		case 2:
+			rec.severityNumber = int(f.varint)
+		case 3:
+			rec.severityText = string(f.bytes)
+		case 5:
+			rec.body, err = decodeOtlpAnyValue(f.bytes)
+		case 6:
+			err = decodeOtlpKeyValue(f.bytes, rec.attributes)
+		case 9:
+			rec.traceID = f.bytes
+		case 10:
+			rec.spanID = f.bytes
+		case 11:
+			rec.observedTimeUnixNano = f.varint
+		case 12:
+			rec.eventName = string(f.bytes)
+		}
+		return err
+	})
+	return rec, err
+}
+
+// decodeOtlpKeyValue decodes a protobuf attribute into the map.
+//
+//	KeyValue { string key = 1; AnyValue value = 2; }
+//
+// Parameters:
+//   - data: KeyValue message
+//   - attributes: destination map
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func decodeOtlpKeyValue(data []byte, attributes map[string]interface{}) error {
+	var key string
+	var value interface{}
+	err := walkProto(data, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			key = string(f.bytes)
+		case 2:
+			value, err = decodeOtlpAnyValue(f.bytes)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	attributes[key] = value
+	return nil
+}
+
+// decodeOtlpAnyValue decodes a protobuf attribute value.
+//
+//	AnyValue { oneof { string string_value = 1; bool bool_value = 2; int64 int_value = 3;
+//	           double double_value = 4; ArrayValue array_value = 5;
+//	           KeyValueList kvlist_value = 6; bytes bytes_value = 7; } }
+//	ArrayValue   { repeated AnyValue values = 1; }
+//	KeyValueList { repeated KeyValue values = 1; }
+//
+// Parameters:
+//   - data: AnyValue message
+//
+// Returns:
+//   - interface{}: decoded value (nil if not set)
+//   - error: success(nil), failure(error)
+func decodeOtlpAnyValue(data []byte) (interface{}, error) {
+	var value interface{}
+	err := walkProto(data, func(f protoField) error {
+		switch f.num {
+		case 1:
+			value = string(f.bytes)
+		case 2:
+			value = f.varint != 0
+		case 3:
+			value = int64(f.varint)
+		case 4:
+			value = math.Float64frombits(f.varint)
+		case 5:
+			values := []interface{}{}
+			err := walkProto(f.bytes, func(f protoField) error {
+				if f.num != 1 {
+					return nil
+				}
+				v, err := decodeOtlpAnyValue(f.bytes)
+				values = append(values, v)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			value = values
+		case 6:
+			values := map[string]interface{}{}
+			err := walkProto(f.bytes, func(f protoField) error {
+				if f.num != 1 {
+					return nil
+				}
+				return decodeOtlpKeyValue(f.bytes, values)
+			})
+			if err != nil {
+				return err
+			}
+			value = values
+		case 7:
+			value = f.bytes
+		}
+		return nil
+	})
+	return value, err
+}
+
+// newOtlpEntry create an entry from an OTLP log record.
+//
+// Parameters:
+//   - stream: stream of the entry
+//   - resource: resource attributes
+//   - scope: instrumentation scope
+//   - rec: log record
+//   - now: receive time
+//
+// Returns:
+//   - entry.Entry: log entry
+func (s *Server) newOtlpEntry(stream string, resource map[string]interface{}, scope *otlpScope,
+	rec *otlpLogRecord, now time.Time) entry.Entry {
+	e := entry.Entry{
+		Time:   now,
+		Level:  otlpSeverityLevel(rec.severityNumber, rec.severityText),
+		Source: s.listener.Name,
+		Stream: stream,
+	}
+
+	// The observed time is used if the event time is unknown
+	if rec.timeUnixNano != 0 {
+		e.Time = time.Unix(0, int64(rec.timeUnixNano)).UTC()
+	} else if rec.observedTimeUnixNano != 0 {
+		e.Time = time.Unix(0, int64(rec.observedTimeUnixNano)).UTC()
+	}
+
+	switch body := rec.body.(type) {
+	case nil:
+	case string:
+		e.Message = body
+	default:
+		data, _ := json.Marshal(body)
+		e.Message = string(data)
+	}
+
+	for key, value := range resource {
+		if str, ok := value.(string); ok {
+			e.SetLabel(key, str)
+		} else {
+			data, _ := json.Marshal(value)
+			e.SetLabel(key, string(data))
+		}
+	}
+
+	if scope.name != "" {
+		e.SetField("scope.name", scope.name)
+	}
+	if scope.version != "" {
+		e.SetField("scope.version", scope.version)
+	}
+	for key, value := range scope.attributes {
+		e.SetField("scope."+key, value)
+	}
+	for key, value := range rec.attributes {
+		e.SetField(key, value)
+	}
+	if len(rec.traceID) > 0 {
+		e.SetField("trace_id", hex.EncodeToString(rec.traceID))
+	}
+	if len(rec.spanID) > 0 {
+		e.SetField("span_id", hex.EncodeToString(rec.spanID))
+	}
+	if rec.severityText != "" {
+		e.SetField("severity_text", rec.severityText)
+	}
+	if rec.eventName != "" {
+		e.SetField("event_name", rec.eventName)
+	}
+
+	return e
+}
+
+// otlpSeverityLevel converts an OTLP severity to the entry level.
+//
+// Parameters:
+//   - number: severity number (1~4: TRACE, 5~8: DEBUG, 9~12: INFO,
+//     13~16: WARN, 17~20: ERROR, 21~24: FATAL, 0: unspecified)
+//   - text: severity text (used if the number is unspecified)
+//
+// Returns:
+//   - string: entry level
+func otlpSeverityLevel(number int, text string) string {
+	switch {
+	case number >= 21:
+		return entry.LevelFatal
+	case number >= 17:
+		return entry.LevelError
+	case number >= 13:
+		return entry.LevelWarn
+	case number >= 9:
+		return entry.LevelInfo
+	case number >= 1:
+		return entry.LevelDebug
+	}
+
+	if level, err := entry.NormalizeLevel(text); err == nil {
+		return level
+	}
+	return entry.LevelInfo
+}
+
+// otlpJSONAttributes converts JSON attributes to a map.
+//
+// Parameters:
+//   - kvs: JSON attributes
+//
+// Returns:
+//   - map[string]interface{}: attributes
+func otlpJSONAttributes(kvs []otlpKeyValue) map[string]interface{} {
+	attributes := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		attributes[kv.Key] = kv.Value.value()
+	}
+	return attributes
+}
+
+// value returns the value that is set.
+//
+// Returns:
+//   - interface{}: attribute value (nil if not set)
+func (v *otlpAnyValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		if i, err := v.IntValue.Int64(); err == nil {
+			return i
+		}
+		return v.IntValue.String()
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.ArrayValue != nil:
+		values := make([]interface{}, 0, len(v.ArrayValue.Values))
+		for i := range v.ArrayValue.Values {
+			values = append(values, v.ArrayValue.Values[i].value())
+		}
+		return values
+	case v.KvlistValue != nil:
+		return otlpJSONAttributes(v.KvlistValue.Values)
+	case v.BytesValue != nil:
+		return v.BytesValue
+	}
+	return nil
+}
+
+// parseOtlpNano parses a JSON unix nanoseconds (string or number).
+//
+// Parameters:
+//   - n: unix nanoseconds
+//
+// Returns:
+//   - uint64: unix nanoseconds (0 if not set)
+//   - error: success(nil), failure(error)
+func parseOtlpNano(n json.Number) (uint64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(n.String(), 10, 64)
+}
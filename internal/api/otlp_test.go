@@ -0,0 +1,153 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoKeyValue builds a KeyValue message.
+func protoKeyValue(key string, value []byte) []byte {
+	return protoBytes(protoBytes(nil, 1, []byte(key)), 2, value)
+}
+
+func TestDecodeOtlpAnyValue(t *testing.T) {
+	double := protowire.AppendTag(nil, 4, protowire.Fixed64Type)
+	double = protowire.AppendFixed64(double, math.Float64bits(1.5))
+
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{"string", protoBytes(nil, 1, []byte("text")), "text"},
+		{"bool", protoVarint(nil, 2, 1), true},
+		{"int", protoVarint(nil, 3, uint64(42)), int64(42)},
+		{"negative int", protoVarint(nil, 3, uint64(0xffffffffffffffff)), int64(-1)},
+		{"double", double, 1.5},
+		{"array", protoBytes(nil, 5, append(protoBytes(nil, 1, protoBytes(nil, 1, []byte("a"))),
+			protoBytes(nil, 1, protoVarint(nil, 3, 2))...)), []interface{}{"a", int64(2)}},
+		{"kvlist", protoBytes(nil, 6, protoBytes(nil, 1, protoKeyValue("k", protoBytes(nil, 1, []byte("v"))))),
+			map[string]interface{}{"k": "v"}},
+		{"bytes", protoBytes(nil, 7, []byte{1, 2}), []byte{1, 2}},
+		{"not set", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeOtlpAnyValue(tt.data)
+			if err != nil {
+				t.Fatalf("decodeOtlpAnyValue: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("value = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	// Truncated nested values are errors
+	for _, data := range [][]byte{
+		protoBytes(nil, 5, protoBytes(nil, 1, []byte{0x0a, 0x05})),
+		protoBytes(nil, 6, protoBytes(nil, 1, []byte{0x12, 0x05})),
+	} {
+		if _, err := decodeOtlpAnyValue(data); err == nil {
+			t.Errorf("decodeOtlpAnyValue(%x) succeeded, want an error", data)
+		}
+	}
+}
+
+// otlpExportProto builds an export request with one resource, scope and record.
+func otlpExportProto(record []byte) []byte {
+	resource := protoBytes(nil, 1, protoKeyValue("service.name", protoBytes(nil, 1, []byte("api"))))
+
+	var scope []byte
+	scope = protoBytes(scope, 1, []byte("lib"))
+	scope = protoBytes(scope, 2, []byte("1.0"))
+	scopeLogs := protoBytes(nil, 1, scope)
+	scopeLogs = protoBytes(scopeLogs, 2, record)
+
+	var resourceLogs []byte
+	resourceLogs = protoBytes(resourceLogs, 1, resource)
+	resourceLogs = protoBytes(resourceLogs, 2, scopeLogs)
+	return protoBytes(nil, 1, resourceLogs)
+}
+
+func TestDecodeOtlpProto(t *testing.T) {
+	eventAt := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+
+	var record []byte
+	record = protowire.AppendTag(record, 1, protowire.Fixed64Type)
+	record = protowire.AppendFixed64(record, uint64(eventAt.UnixNano()))
+	record = protoVarint(record, 2, 17)
+	record = protoBytes(record, 3, []byte("ERROR"))
+	record = protoBytes(record, 5, protoBytes(nil, 1, []byte("failed")))
+	record = protoBytes(record, 6, protoKeyValue("user", protoBytes(nil, 1, []byte("alice"))))
+	record = protoBytes(record, 9, []byte{0xab, 0xcd})
+
+	s := &Server{listener: config.Listener{Name: "otlp"}}
+	entries, err := s.decodeOtlpProto(otlpExportProto(record), "default", time.Now().UTC())
+	if err != nil {
+		t.Fatalf("decodeOtlpProto: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(entries))
+	}
+	e := entries[0]
+	if !e.Time.Equal(eventAt) || e.Message != "failed" || e.Level != "error" || e.Labels["service.name"] != "api" {
+		t.Errorf("entry = %v %q %s %v", e.Time, e.Message, e.Level, e.Labels)
+	}
+	wantFields := map[string]interface{}{"scope.name": "lib", "scope.version": "1.0", "user": "alice",
+		"trace_id": "abcd", "severity_text": "ERROR"}
+	if !reflect.DeepEqual(e.Fields, wantFields) {
+		t.Errorf("fields = %v, want %v", e.Fields, wantFields)
+	}
+
+	// A truncated attribute of the record is an error
+	invalid := protoBytes(nil, 6, []byte{0x0a, 0x05})
+	if _, err := s.decodeOtlpProto(otlpExportProto(invalid), "default", time.Now().UTC()); err == nil ||
+		!strings.Contains(err.Error(), "invalid export request") {
+		t.Errorf("decodeOtlpProto error = %v, want an invalid export request", err)
+	}
+}
+
+func TestOtlpSeverityLevel(t *testing.T) {
+	tests := []struct {
+		number int
+		text   string
+		want   string
+	}{
+		{1, "", "debug"},
+		{8, "", "debug"},
+		{9, "", "info"},
+		{13, "", "warn"},
+		{17, "INFO", "error"},
+		{21, "", "fatal"},
+		{0, "warning", "warn"},
+		{0, "unknown", "info"},
+	}
+	for _, tt := range tests {
+		if got := otlpSeverityLevel(tt.number, tt.text); got != tt.want {
+			t.Errorf("otlpSeverityLevel(%d, %q) = %s, want %s", tt.number, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/hoon-kr/log_manager/internal/pattern"
+)
+
+// Number of patterns returned by a request
+const (
+	defaultPatternLimit = 20
+	maxPatternLimit     = 1000
+)
+
+// Default window of the patterns (minutes)
+const defaultPatternWindow = 5
+
+// PatternResponse is a response body of the patterns endpoint
+type PatternResponse struct {
+	Window   int               `json:"window"` // Minutes
+	Patterns []pattern.Pattern `json:"patterns"`
+}
+
+// handlePatterns returns the message templates with the most entries in
+// the window and their change from the previous window.
+// (GET /api/v1/patterns?window=<minutes>&limit=&sort=count|change)
+//
+// Parameters:
+//   - w: response writer
+//   - r: request
+func (s *Server) handlePatterns(w http.ResponseWriter, r *http.Request) {
+	if s.miner == nil {
+		writeError(w, http.StatusNotFound, "pattern mining is disabled (PatternMining)")
+		return
+	}
+
+	window, limit, order, err := parsePatternQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// The counts of longer windows and their previous windows are not kept
+	maxWindow := int(s.miner.MaxWindow() / time.Minute)
+	if r.URL.Query().Get("window") == "" {
+		window = min(window, maxWindow)
+	} else if window > maxWindow {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("window must be at most %d minutes (half of PatternHistory)",
+			maxWindow))
+		return
+	}
+
+	resp := PatternResponse{
+		Window:   window,
+		Patterns: s.miner.Top(time.Duration(window)*time.Minute, limit, order, time.Now()),
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parsePatternQuery converts URL query parameters to the pattern query.
+//
+// Parameters:
+//   - values: URL query parameters
+//
+// Returns:
+//   - int: window (minutes)
+//   - int: maximum number of patterns
+//   - string: sort order
+//   - error: success(nil), failure(error)
+func parsePatternQuery(values url.Values) (int, int, string, error) {
+	window, limit, order := defaultPatternWindow, defaultPatternLimit, pattern.SortCount
+
+	if value := values.Get("window"); value != "" {
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 1 {
+			return 0, 0, "", fmt.Errorf("window must be a positive number of minutes")
+		}
+		window = v
+	}
+	if value := values.Get("limit"); value != "" {
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 1 || v > maxPatternLimit {
+			return 0, 0, "", fmt.Errorf("limit must be between 1 and %d", maxPatternLimit)
+		}
+		limit = v
+	}
+	if value := values.Get("sort"); value != "" {
+		if value != pattern.SortCount && value != pattern.SortChange {
+			return 0, 0, "", fmt.Errorf("sort must be %s or %s", pattern.SortCount, pattern.SortChange)
+		}
+		order = value
+	}
+
+	return window, limit, order, nil
+}
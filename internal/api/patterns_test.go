@@ -0,0 +1,88 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/pattern"
+)
+
+func TestHandlePatternsWindow(t *testing.T) {
+	miner := pattern.NewMiner(0.5, 100, 10*time.Minute)
+	miner.Process([]entry.Entry{{Message: "disk full"}})
+	s, err := NewServer(config.Listener{Name: "http"}, nil, nil, miner, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantWindow int
+	}{
+		{"", http.StatusOK, 5},
+		{"?window=2", http.StatusOK, 2},
+		{"?window=5", http.StatusOK, 5},
+		{"?window=6", http.StatusBadRequest, 0},
+		{"?window=0", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patterns"+tt.query, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%q: status = %d, want %d (%s)", tt.query, rec.Code, tt.wantStatus, rec.Body)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+
+		resp := PatternResponse{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Window != tt.wantWindow || len(resp.Patterns) != 1 {
+			t.Errorf("%q: window = %d, patterns = %d; want %d, 1", tt.query, resp.Window, len(resp.Patterns),
+				tt.wantWindow)
+		}
+	}
+}
+
+func TestHandlePatternsDefaultWindow(t *testing.T) {
+	// The default window is shortened to half of a short history
+	s, err := NewServer(config.Listener{Name: "http"}, nil, nil, pattern.NewMiner(0.5, 100, 2*time.Minute), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patterns", nil))
+	resp := PatternResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || resp.Window != 1 {
+		t.Errorf("status = %d, window = %d; want 200, 1", rec.Code, resp.Window)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/store"
+)
+
+// Number of entries returned by a query
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 10000
+)
+
+// queryResponse is a response body of the query endpoint
+type queryResponse struct {
+	Entries []entry.Entry `json:"entries"`
+}
+
+// handleQuery searches stored entries.
+// (GET /api/v1/logs?stream=&from=&to=&level=&contains=&limit=&field.<key>=<value>&label.<key>=<value>)
+//
+// Parameters:
+//   - w: response writer
+//   - r: request
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := queryResponse{Entries: []entry.Entry{}}
+	err = s.store.Query(q, func(e entry.Entry) bool {
+		resp.Entries = append(resp.Entries, e)
+		return true
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseQuery converts URL query parameters to a store query.
+//
+// Parameters:
+//   - values: URL query parameters
+//
+// Returns:
+//   - store.Query: search condition
+//   - error: success(nil), failure(error)
+func parseQuery(values url.Values) (store.Query, error) {
+	q := store.Query{
+		Stream:   values.Get("stream"),
+		Contains: values.Get("contains"),
+		Fields:   make(map[string]string),
+		Labels:   make(map[string]string),
+		Limit:    defaultQueryLimit,
+	}
+
+	if q.Stream != "" && !entry.ValidStream(q.Stream) {
+		return q, fmt.Errorf("invalid stream name: %s", q.Stream)
+	}
+
+	for name, target := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if value := values.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s (RFC3339): %s", name, value)
+			}
+			*target = t
+		}
+	}
+
+	if value := values.Get("level"); value != "" {
+		level, err := entry.NormalizeLevel(value)
+		if err != nil {
+			return q, err
+		}
+		q.Level = level
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxQueryLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+		}
+		q.Limit = limit
+	}
+
+	for name := range values {
+		if key, found := strings.CutPrefix(name, "field."); found && key != "" {
+			q.Fields[key] = values.Get(name)
+		}
+		if key, found := strings.CutPrefix(name, "label."); found && key != "" {
+			q.Labels[key] = values.Get(name)
+		}
+	}
+
+	return q, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package api provides the HTTP API of log_manager (ingestion and query).
+*/
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/alert"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/parser"
+	"github.com/hoon-kr/log_manager/internal/pattern"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+	"github.com/hoon-kr/log_manager/internal/store"
+)
+
+// HTTP server shutdown timeout
+const shutdownTimeout = 5 * time.Second
+
+// Server is an HTTP API server structure
+type Server struct {
+	listener   config.Listener
+	pipeline   *pipeline.Pipeline
+	parser     *parser.Chain
+	store      *store.Store
+	miner      *pattern.Miner
+	alerts     *alert.Engine
+	httpServer *http.Server
+}
+
+// NewServer create HTTP API server.
+//
+// Parameters:
+//   - listener: listener configuration
+//   - pl: write pipeline
+//   - st: entry store
+//   - miner: message template miner (nil if disabled)
+//   - alerts: alert rule engine (nil if there is no rule)
+//
+// Returns:
+//   - *Server: HTTP API server
+//   - error: success(nil), failure(error)
+func NewServer(listener config.Listener, pl *pipeline.Pipeline, st *store.Store, miner *pattern.Miner,
+	alerts *alert.Engine) (*Server, error) {
+	chain, err := parser.NewChain(&listener)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: listener, pipeline: pl, parser: chain, store: st, miner: miner, alerts: alerts}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/ingest", s.handleIngest)
+	mux.HandleFunc("GET /api/v1/logs", s.handleQuery)
+	mux.HandleFunc("GET /api/v1/patterns", s.handlePatterns)
+	mux.HandleFunc("GET /api/v1/alerts", s.handleAlerts)
+	mux.HandleFunc("POST /loki/api/v1/push", s.handleLokiPush)
+	mux.HandleFunc("POST /v1/logs", s.handleOtlpLogs)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+
+	// Elasticsearch compatible endpoints
+	mux.HandleFunc("GET /{$}", s.handleElasticInfo)
+	mux.HandleFunc("POST /_bulk", s.handleElasticBulk)
+	mux.HandleFunc("PUT /_bulk", s.handleElasticBulk)
+	mux.HandleFunc("POST /{index}/_bulk", s.handleElasticBulk)
+	mux.HandleFunc("GET /_license", s.handleElasticSetup)
+	for _, pattern := range []string{"/_index_template/{name}", "/_template/{name}",
+		"/_ilm/policy/{name}", "/_ingest/pipeline/{name}"} {
+		mux.HandleFunc("GET "+pattern, s.handleElasticSetup)
+		mux.HandleFunc("PUT "+pattern, s.handleElasticSetup)
+	}
+
+	s.httpServer = &http.Server{
+		Addr:              listener.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// Run serves HTTP requests until the context is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (s *Server) Run(ctx context.Context) {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.httpServer.ListenAndServe()
+	}()
+	logger.Log.LogInfo("Start HTTP listener (name:%s, address:%s)", s.listener.Name, s.listener.Address)
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.LogError("HTTP listener stopped (name:%s): %s", s.listener.Name, err)
+		}
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.Log.LogWarn("failed to shut down HTTP listener (name:%s): %s", s.listener.Name, err)
+		}
+	}
+}
+
+// writeJSON writes a JSON response.
+//
+// Parameters:
+//   - w: response writer
+//   - status: HTTP status code
+//   - body: response body
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes a JSON error response.
+//
+// Parameters:
+//   - w: response writer
+//   - status: HTTP status code
+//   - message: error message
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,235 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package dedup collapses duplicate entries received within a window into
+a single entry with a repeat count, and stores the fingerprint of the
+message template of entries as a field.
+*/
+package dedup
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/fingerprint"
+	"github.com/hoon-kr/log_manager/internal/metrics"
+)
+
+// Fields of the collapsed entries
+const (
+	FingerprintField = "fingerprint"
+	RepeatCountField = "repeat_count"
+	FirstSeenField   = "first_seen"
+	LastSeenField    = "last_seen"
+)
+
+// Maximum number of entries held in the window (entries of other keys are not collapsed)
+const maxPending = 100000
+
+// Number of duplicate entries collapsed
+var collapsedTotal = metrics.NewCounter("log_manager_deduplicated_entries_total",
+	"Number of duplicate entries collapsed into a previous entry.")
+
+// Deduplicator is a deduplication structure. It is used by the single
+// writer goroutine of the pipeline.
+type Deduplicator struct {
+	window      time.Duration
+	key         string
+	fingerprint bool
+	pending     map[string]*pendingWindow
+	queue       []*pendingWindow // Windows in the order of their deadlines
+}
+
+// pendingWindow is the window of an entry, which is held with the count
+// of its duplicates until the deadline
+type pendingWindow struct {
+	key      string
+	entry    entry.Entry // First occurrence
+	count    int         // Number of entries including the first occurrence
+	first    time.Time
+	last     time.Time
+	deadline time.Time
+}
+
+// NewDeduplicator create deduplicator.
+//
+// Parameters:
+//   - window: window in which duplicate entries are collapsed (0: disabled)
+//   - key: entries that are duplicates (message, fingerprint)
+//   - fingerprint: whether the fingerprint is stored in the fingerprint field
+//
+// Returns:
+//   - *Deduplicator: deduplicator (nil if both are disabled)
+func NewDeduplicator(window time.Duration, key string, fingerprint bool) *Deduplicator {
+	if window <= 0 && !fingerprint {
+		return nil
+	}
+	return &Deduplicator{
+		window:      window,
+		key:         key,
+		fingerprint: fingerprint,
+		pending:     make(map[string]*pendingWindow),
+	}
+}
+
+// Process stores the fingerprint of the entries and collapses the
+// duplicates. The first entry of a key is held and opens a window, and
+// the duplicates received in the window are dropped. When the window
+// ends, the first entry is written with the repeat_count, first_seen and
+// last_seen fields if duplicates were received.
+//
+// Parameters:
+//   - entries: log entries
+//
+// Returns:
+//   - []entry.Entry: entries whose window ended and entries that are not held
+func (d *Deduplicator) Process(entries []entry.Entry) []entry.Entry {
+	now := time.Now()
+	passed := d.expired(now)
+	for i := range entries {
+		e := &entries[i]
+
+		fp := ""
+		if d.fingerprint || d.key == config.DedupKeyFingerprint {
+			fp = fingerprint.Of(e.Message)
+		}
+		if d.fingerprint {
+			if e.Fields == nil {
+				e.Fields = make(map[string]interface{})
+			}
+			e.Fields[FingerprintField] = fp
+		}
+		if d.window <= 0 {
+			passed = append(passed, *e)
+			continue
+		}
+
+		key := d.keyOf(e, fp)
+		if p, exists := d.pending[key]; exists {
+			p.count++
+			if e.Time.Before(p.first) {
+				p.first = e.Time
+			}
+			if e.Time.After(p.last) {
+				p.last = e.Time
+			}
+			collapsedTotal.Inc()
+			continue
+		}
+
+		if len(d.pending) >= maxPending {
+			passed = append(passed, *e)
+			continue
+		}
+		p := &pendingWindow{key: key, entry: *e, count: 1, first: e.Time, last: e.Time, deadline: now.Add(d.window)}
+		d.pending[key] = p
+		d.queue = append(d.queue, p)
+	}
+	return passed
+}
+
+// Flush returns all held entries.
+//
+// Returns:
+//   - []entry.Entry: held entries
+func (d *Deduplicator) Flush() []entry.Entry {
+	return d.expired(time.Time{})
+}
+
+// keyOf returns the key of the entries that are duplicates of the entry:
+// the same stream, source, level, labels, fields and message (or message
+// template).
+//
+// Parameters:
+//   - e: log entry
+//   - fp: fingerprint of the message
+//
+// Returns:
+//   - string: deduplication key
+func (d *Deduplicator) keyOf(e *entry.Entry, fp string) string {
+	key := e.Stream + "\x00" + e.Source + "\x00" + e.Level + "\x00"
+	if d.key == config.DedupKeyFingerprint {
+		key += fp
+	} else {
+		key += e.Message
+	}
+	// Maps are encoded in key order
+	if len(e.Labels) > 0 {
+		labels, _ := json.Marshal(e.Labels)
+		key += "\x00" + string(labels)
+	}
+	if len(e.Fields) > 0 {
+		fields, err := json.Marshal(e.Fields)
+		if err != nil {
+			fields = []byte(fmt.Sprint(e.Fields))
+		}
+		key += "\x01" + string(fields)
+	}
+	return key
+}
+
+// expired returns the held entries of the windows that ended.
+//
+// Parameters:
+//   - now: current time (zero time: all windows)
+//
+// Returns:
+//   - []entry.Entry: collapsed entries
+func (d *Deduplicator) expired(now time.Time) []entry.Entry {
+	entries := []entry.Entry{}
+	n := 0
+	for ; n < len(d.queue); n++ {
+		p := d.queue[n]
+		if !now.IsZero() && now.Before(p.deadline) {
+			break
+		}
+		delete(d.pending, p.key)
+		entries = append(entries, p.collapsed())
+	}
+	// Release the references of the removed windows
+	clear(d.queue[:n])
+	d.queue = d.queue[n:]
+	return entries
+}
+
+// collapsed returns the first entry with the repeat count of the window.
+// The first_seen field and the time of the entry are the earliest time
+// of the window.
+//
+// Returns:
+//   - entry.Entry: entry
+func (p *pendingWindow) collapsed() entry.Entry {
+	e := p.entry
+	if p.count == 1 {
+		return e
+	}
+
+	// The fields may be shared with the duplicates, so they are copied
+	fields := make(map[string]interface{}, len(e.Fields)+3)
+	for key, value := range e.Fields {
+		fields[key] = value
+	}
+	fields[RepeatCountField] = p.count
+	fields[FirstSeenField] = p.first.UTC().Format(time.RFC3339Nano)
+	fields[LastSeenField] = p.last.UTC().Format(time.RFC3339Nano)
+	e.Fields = fields
+	e.Time = p.first
+	return e
+}
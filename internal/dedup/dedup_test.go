@@ -0,0 +1,162 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package dedup
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// messages returns the messages of the entries.
+func messages(entries []entry.Entry) []string {
+	msgs := []string{}
+	for _, e := range entries {
+		msgs = append(msgs, e.Message)
+	}
+	return msgs
+}
+
+func TestProcessWindow(t *testing.T) {
+	d := NewDeduplicator(time.Minute, config.DedupKeyMessage, false)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mk := func(msg string, sec int) entry.Entry {
+		return entry.Entry{Time: base.Add(time.Duration(sec) * time.Second), Level: "error", Stream: "default",
+			Message: msg}
+	}
+
+	// The first entries and their duplicates are held until the window ends
+	if got := d.Process([]entry.Entry{mk("disk full", 0), mk("disk full", 1), mk("timeout", 2),
+		mk("disk full", 3)}); len(got) != 0 {
+		t.Fatalf("Process() = %v, want no entry in the window", messages(got))
+	}
+	if got := d.Process([]entry.Entry{mk("disk full", 5)}); len(got) != 0 {
+		t.Fatalf("Process() = %v, want no entry in the window", messages(got))
+	}
+
+	// Exactly one stored entry per window
+	got := d.expired(time.Now().Add(2 * time.Minute))
+	if want := []string{"disk full", "timeout"}; !reflect.DeepEqual(messages(got), want) {
+		t.Fatalf("expired() = %v, want %v", messages(got), want)
+	}
+	e := got[0]
+	if e.Level != "error" || e.Stream != "default" {
+		t.Errorf("collapsed entry = %+v", e)
+	}
+	if e.Fields[RepeatCountField] != 4 {
+		t.Errorf("repeat_count = %v, want 4", e.Fields[RepeatCountField])
+	}
+	if e.Fields[FirstSeenField] != "2024-05-01T12:00:00Z" || e.Fields[LastSeenField] != "2024-05-01T12:00:05Z" {
+		t.Errorf("first_seen, last_seen = %v, %v", e.Fields[FirstSeenField], e.Fields[LastSeenField])
+	}
+	if !e.Time.Equal(base) {
+		t.Errorf("time = %s, want the first occurrence", e.Time)
+	}
+
+	// An entry without duplicates is written as it is
+	if got[1].Fields != nil || !got[1].Time.Equal(base.Add(2*time.Second)) {
+		t.Errorf("entry without duplicates = %+v", got[1])
+	}
+
+	// A new window starts with the next entry
+	if got = d.Process([]entry.Entry{mk("disk full", 10)}); len(got) != 0 {
+		t.Errorf("Process() after the window = %v", messages(got))
+	}
+	if got = d.Flush(); len(got) != 1 || got[0].Fields != nil {
+		t.Errorf("Flush() = %v, want the held entry", got)
+	}
+}
+
+func TestProcessKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		entries []entry.Entry
+		stored  int
+	}{
+		{"same message", config.DedupKeyMessage, []entry.Entry{
+			{Message: "a", Labels: map[string]string{"pod": "p1"}, Fields: map[string]interface{}{"n": 1.0}},
+			{Message: "a", Labels: map[string]string{"pod": "p1"}, Fields: map[string]interface{}{"n": 1.0}},
+		}, 1},
+		{"labels", config.DedupKeyMessage, []entry.Entry{
+			{Message: "a", Labels: map[string]string{"pod": "p1"}},
+			{Message: "a", Labels: map[string]string{"pod": "p2"}},
+			{Message: "a"},
+		}, 3},
+		{"fields", config.DedupKeyMessage, []entry.Entry{
+			{Message: "a", Fields: map[string]interface{}{"user": "x"}},
+			{Message: "a", Fields: map[string]interface{}{"user": "y"}},
+		}, 2},
+		{"stream source level", config.DedupKeyMessage, []entry.Entry{
+			{Message: "a", Stream: "s1"}, {Message: "a", Stream: "s2"},
+			{Message: "a", Source: "x"}, {Message: "a", Level: "warn"},
+		}, 4},
+		{"message", config.DedupKeyMessage, []entry.Entry{
+			{Message: "user 1 logged in"}, {Message: "user 2 logged in"},
+		}, 2},
+		{"fingerprint", config.DedupKeyFingerprint, []entry.Entry{
+			{Message: "user 1 logged in"}, {Message: "user 2 logged in"}, {Message: "user 3 logged out"},
+		}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDeduplicator(time.Minute, tt.key, false)
+			if got := d.Process(tt.entries); len(got) != 0 {
+				t.Errorf("Process() passed %d entries, want 0", len(got))
+			}
+			if got := d.Flush(); len(got) != tt.stored {
+				t.Errorf("Flush() = %d entries, want %d", len(got), tt.stored)
+			}
+		})
+	}
+}
+
+func TestProcessMaxPending(t *testing.T) {
+	d := NewDeduplicator(time.Minute, config.DedupKeyMessage, false)
+	entries := make([]entry.Entry, 0, maxPending+3)
+	for i := 0; i < maxPending; i++ {
+		entries = append(entries, entry.Entry{Message: strconv.Itoa(i)})
+	}
+	// Keys over the limit are written in order without being collapsed
+	entries = append(entries, entry.Entry{Message: "over"}, entry.Entry{Message: "over"},
+		entry.Entry{Message: "0"})
+
+	got := d.Process(entries)
+	if !reflect.DeepEqual(messages(got), []string{"over", "over"}) {
+		t.Fatalf("Process() = %v, want [over over]", messages(got))
+	}
+	flushed := d.Flush()
+	if len(flushed) != maxPending || flushed[0].Fields[RepeatCountField] != 2 {
+		t.Errorf("Flush() = %d entries (first: %+v), want %d", len(flushed), flushed[0], maxPending)
+	}
+}
+
+func TestProcessFingerprintOnly(t *testing.T) {
+	d := NewDeduplicator(0, config.DedupKeyMessage, true)
+	got := d.Process([]entry.Entry{{Message: "user 1 logged in"}, {Message: "user 1 logged in"}})
+	if len(got) != 2 {
+		t.Fatalf("Process() passed %d entries, want 2", len(got))
+	}
+	if got[0].Fields[FingerprintField] == "" || got[0].Fields[FingerprintField] != got[1].Fields[FingerprintField] {
+		t.Errorf("fingerprints = %v, %v", got[0].Fields[FingerprintField], got[1].Fields[FingerprintField])
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package entry defines the log entry model shared by inputs, the
+pipeline and the store.
+*/
+package entry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/pkg/utils/stream"
+)
+
+// Default stream name
+const DefaultStream = stream.Default
+
+// Log level
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// Entry is a log entry structure
+type Entry struct {
+	// Event time
+	Time time.Time `json:"time"`
+	// Receive time (stored if the event time rule of the input keeps it)
+	Received *time.Time `json:"received,omitempty"`
+	// Log level (debug, info, warn, error, fatal)
+	Level string `json:"level"`
+	// Input that received the entry
+	Source string `json:"source,omitempty"`
+	// Stream in which the entry is stored
+	Stream string `json:"stream"`
+	// Log message
+	Message string `json:"msg"`
+	// Indexed labels (e.g. Loki stream labels)
+	Labels map[string]string `json:"labels,omitempty"`
+	// Structured fields
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Reserved keys of the JSON log line
+var reservedKeys = map[string]bool{
+	"time": true, "timestamp": true, "level": true, "source": true,
+	"stream": true, "msg": true, "message": true, "fields": true, "labels": true,
+}
+
+// FromObject converts a decoded JSON object to an entry.
+// The message is read from "msg" or "message", the time from "time" or
+// "timestamp" (RFC3339 string or unix seconds), and the other keys
+// become structured fields.
+//
+// Parameters:
+//   - obj: decoded JSON object
+//   - now: time used if the object has no time
+//
+// Returns:
+//   - Entry: log entry
+//   - error: success(nil), failure(error)
+func FromObject(obj map[string]interface{}, now time.Time) (Entry, error) {
+	e := Entry{Time: now, Level: LevelInfo, Stream: DefaultStream}
+
+	// Message
+	for _, key := range []string{"msg", "message"} {
+		if value, exists := obj[key]; exists {
+			message, ok := value.(string)
+			if !ok {
+				return e, fmt.Errorf("%s must be a string", key)
+			}
+			e.Message = message
+			break
+		}
+	}
+	if e.Message == "" {
+		return e, fmt.Errorf("message is empty")
+	}
+
+	// Time
+	for _, key := range []string{"time", "timestamp"} {
+		if value, exists := obj[key]; exists {
+			t, err := parseTime(value)
+			if err != nil {
+				return e, fmt.Errorf("invalid %s: %s", key, err)
+			}
+			e.Time = t
+			break
+		}
+	}
+
+	// Level
+	if value, exists := obj["level"]; exists {
+		levelStr, ok := value.(string)
+		if !ok {
+			return e, fmt.Errorf("level must be a string")
+		}
+		level, err := NormalizeLevel(levelStr)
+		if err != nil {
+			return e, err
+		}
+		e.Level = level
+	}
+
+	// Source, stream
+	if value, ok := obj["source"].(string); ok {
+		e.Source = value
+	}
+	if value, exists := obj["stream"]; exists {
+		stream, ok := value.(string)
+		if !ok || !ValidStream(stream) {
+			return e, fmt.Errorf("invalid stream name: %v", value)
+		}
+		e.Stream = stream
+	}
+
+	// Labels
+	if value, exists := obj["labels"]; exists {
+		labels, ok := value.(map[string]interface{})
+		if !ok {
+			return e, fmt.Errorf("labels must be an object")
+		}
+		for key, v := range labels {
+			label, ok := v.(string)
+			if !ok {
+				return e, fmt.Errorf("label value must be a string: %s", key)
+			}
+			e.SetLabel(key, label)
+		}
+	}
+
+	// Structured fields
+	if value, exists := obj["fields"]; exists {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return e, fmt.Errorf("fields must be an object")
+		}
+		for key, v := range fields {
+			e.SetField(key, v)
+		}
+	}
+	for key, value := range obj {
+		if !reservedKeys[key] {
+			e.SetField(key, value)
+		}
+	}
+
+	return e, nil
+}
+
+// SetField set a structured field of the entry.
+//
+// Parameters:
+//   - key: field key
+//   - value: field value
+func (e *Entry) SetField(key string, value interface{}) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]interface{})
+	}
+	e.Fields[key] = value
+}
+
+// SetLabel set an indexed label of the entry.
+//
+// Parameters:
+//   - key: label key
+//   - value: label value
+func (e *Entry) SetLabel(key, value string) {
+	if e.Labels == nil {
+		e.Labels = make(map[string]string)
+	}
+	e.Labels[key] = value
+}
+
+// Field get a structured field of the entry as a string.
+//
+// Parameters:
+//   - key: field key
+//
+// Returns:
+//   - string: field value
+//   - bool: exists(true), not exists(false)
+func (e *Entry) Field(key string) (string, bool) {
+	value, exists := e.Fields[key]
+	if !exists {
+		return "", false
+	}
+	if s, ok := value.(string); ok {
+		return s, true
+	}
+	return fmt.Sprint(value), true
+}
+
+// NormalizeLevel converts a level name to the level of the entry.
+//
+// Parameters:
+//   - level: level name (case-insensitive, aliases allowed)
+//
+// Returns:
+//   - string: entry level
+//   - error: success(nil), failure(error)
+func NormalizeLevel(level string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "trace", "debug":
+		return LevelDebug, nil
+	case "", "info", "information", "notice":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error", "err":
+		return LevelError, nil
+	case "fatal", "critical", "crit", "panic", "alert", "emerg", "emergency":
+		return LevelFatal, nil
+	}
+	return "", fmt.Errorf("unknown level: %s", level)
+}
+
+// SyslogLevel converts a syslog severity to the entry level.
+//
+// Parameters:
+//   - severity: syslog severity (0: emergency ~ 7: debug)
+//
+// Returns:
+//   - string: entry level
+func SyslogLevel(severity int) string {
+	switch {
+	case severity <= 2:
+		return LevelFatal
+	case severity == 3:
+		return LevelError
+	case severity == 4:
+		return LevelWarn
+	case severity == 7:
+		return LevelDebug
+	}
+	return LevelInfo
+}
+
+// SyslogSeverity converts the entry level to a syslog severity.
+//
+// Parameters:
+//   - level: entry level
+//
+// Returns:
+//   - int: syslog severity (2: critical ~ 7: debug)
+func SyslogSeverity(level string) int {
+	switch level {
+	case LevelFatal:
+		return 2
+	case LevelError:
+		return 3
+	case LevelWarn:
+		return 4
+	case LevelDebug:
+		return 7
+	}
+	return 6
+}
+
+// LevelRank returns the severity order of the level.
+//
+// Parameters:
+//   - level: entry level
+//
+// Returns:
+//   - int: severity order (higher is more severe, -1 if unknown)
+func LevelRank(level string) int {
+	switch level {
+	case LevelDebug:
+		return 0
+	case LevelInfo:
+		return 1
+	case LevelWarn:
+		return 2
+	case LevelError:
+		return 3
+	case LevelFatal:
+		return 4
+	}
+	return -1
+}
+
+// ValidStream verify that the stream name is valid.
+//
+// Parameters:
+//   - name: stream name
+//
+// Returns:
+//   - bool: valid(true), invalid(false)
+func ValidStream(name string) bool {
+	return stream.Valid(name)
+}
+
+// parseTime parse a RFC3339 string or unix seconds.
+//
+// Parameters:
+//   - value: time value
+//
+// Returns:
+//   - time.Time: parsed time
+//   - error: success(nil), failure(error)
+func parseTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	case float64:
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported time type")
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package fingerprint computes the template of a log message, in which the
+variable parts (numbers, IDs, addresses) are masked, and a short
+fingerprint of the template so that messages of the same shape can be
+grouped.
+*/
+package fingerprint
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+)
+
+// Masks of the variable parts
+const (
+	maskUuid = "<uuid>"
+	maskIp   = "<ip>"
+	maskHex  = "<hex>"
+	maskNum  = "<num>"
+)
+
+// Variable parts in the order of priority
+var variableRegexp = regexp.MustCompile(
+	`(?P<uuid>\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b)|` +
+		`(?P<ip>\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b)|` +
+		`(?P<hex>\b(?:0[xX][0-9a-fA-F]+|[0-9a-fA-F]*[0-9][0-9a-fA-F]*[a-fA-F][0-9a-fA-F]*|` +
+		`[0-9a-fA-F]*[a-fA-F][0-9a-fA-F]*[0-9][0-9a-fA-F]*)\b)|` +
+		`(?P<num>\b\d+(?:[.,:]\d+)*)`)
+
+// Masks by capture index
+var masks = func() []string {
+	m := make([]string, variableRegexp.NumSubexp()+1)
+	m[variableRegexp.SubexpIndex("uuid")] = maskUuid
+	m[variableRegexp.SubexpIndex("ip")] = maskIp
+	m[variableRegexp.SubexpIndex("hex")] = maskHex
+	m[variableRegexp.SubexpIndex("num")] = maskNum
+	return m
+}()
+
+// Template returns the message with the variable parts masked
+// (e.g. "user 42 logged in from 10.0.0.1" -> "user <num> logged in from <ip>").
+// Hexadecimal words are masked only if they contain both digits and
+// letters, so that words such as "added" are kept.
+//
+// Parameters:
+//   - msg: log message
+//
+// Returns:
+//   - string: message template
+func Template(msg string) string {
+	matches := variableRegexp.FindAllStringSubmatchIndex(msg, -1)
+	if len(matches) == 0 {
+		return msg
+	}
+
+	buf := make([]byte, 0, len(msg))
+	last := 0
+	for _, loc := range matches {
+		buf = append(buf, msg[last:loc[0]]...)
+		for group := 1; group < len(masks); group++ {
+			if loc[2*group] >= 0 {
+				buf = append(buf, masks[group]...)
+				break
+			}
+		}
+		last = loc[1]
+	}
+	buf = append(buf, msg[last:]...)
+	return string(buf)
+}
+
+// Of returns the fingerprint of the message template (16 hex digits).
+//
+// Parameters:
+//   - msg: log message
+//
+// Returns:
+//   - string: fingerprint
+func Of(msg string) string {
+	return OfTemplate(Template(msg))
+}
+
+// OfTemplate returns the fingerprint of a message template.
+//
+// Parameters:
+//   - template: message template
+//
+// Returns:
+//   - string: fingerprint
+func OfTemplate(template string) string {
+	h := fnv.New64a()
+	h.Write([]byte(template))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,555 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package forward provides the Fluentd forward protocol input.
+
+The Message, Forward, PackedForward and CompressedPackedForward modes
+are supported. When a message has the chunk option, an ack response is
+returned after the pipeline writes the entries, so a client keeps its
+buffer until they are stored. If a shared key
+is configured, clients are authenticated by the handshake (HELO, PING,
+PONG) before messages are accepted.
+*/
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/input"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/parser"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+	"github.com/klauspost/compress/gzip"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Timeout for completing the handshake
+const handshakeTimeout = 10 * time.Second
+
+// Maximum number of acks waiting to be written per connection (acks over
+// it are not sent, so the client sends the chunks again)
+const maxPendingAcks = 1024
+
+// Record keys read as the message (in order)
+var messageKeys = []string{"log", "message", "msg"}
+
+// Record keys read as the level (in order)
+var levelKeys = []string{"level", "severity"}
+
+func init() {
+	msgpack.RegisterExt(0, (*eventTime)(nil))
+}
+
+// eventTime is the EventTime extension type (ext type 0) of the
+// forward protocol
+type eventTime struct {
+	time.Time
+}
+
+// MarshalMsgpack encodes the event time (seconds(4) + nanoseconds(4)).
+//
+// Returns:
+//   - []byte: encoded event time
+//   - error: always nil
+func (t *eventTime) MarshalMsgpack() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(t.Nanosecond()))
+	return b, nil
+}
+
+// UnmarshalMsgpack decodes the event time.
+//
+// Parameters:
+//   - b: encoded event time
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (t *eventTime) UnmarshalMsgpack(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("invalid EventTime length: %d", len(b))
+	}
+	t.Time = time.Unix(int64(binary.BigEndian.Uint32(b)), int64(binary.BigEndian.Uint32(b[4:]))).UTC()
+	return nil
+}
+
+// Input is a forward protocol input structure
+type Input struct {
+	listener       config.Listener
+	pipeline       *pipeline.Pipeline
+	parser         *parser.Chain
+	hostname       string
+	maxMessageSize int
+}
+
+// NewInput create forward protocol input.
+//
+// Parameters:
+//   - listener: listener configuration
+//   - pl: write pipeline
+//
+// Returns:
+//   - *Input: forward protocol input
+//   - error: success(nil), failure(error)
+func NewInput(listener config.Listener, pl *pipeline.Pipeline) (*Input, error) {
+	chain, err := parser.NewChain(&listener)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = config.ModuleName
+	}
+
+	return &Input{
+		listener:       listener,
+		pipeline:       pl,
+		parser:         chain,
+		hostname:       hostname,
+		maxMessageSize: config.Conf.IngestMaxBodySize * 1024 * 1024,
+	}, nil
+}
+
+// Run receives messages until the context is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (in *Input) Run(ctx context.Context) {
+	logger.Log.LogInfo("Start forward listener (name:%s, address:%s)", in.listener.Name, in.listener.Address)
+	if err := input.ServeTCP(ctx, in.listener.Address, in.serveConn); err != nil {
+		logger.Log.LogError("forward listener stopped (name:%s): %s", in.listener.Name, err)
+	}
+}
+
+// serveConn reads messages from a connection.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - conn: client connection
+func (in *Input) serveConn(ctx context.Context, conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	dec := msgpack.NewDecoder(reader)
+	dec.UseLooseInterfaceDecoding(true)
+	enc := msgpack.NewEncoder(conn)
+
+	if in.listener.SharedKey != "" {
+		if err := in.handshake(conn, dec, enc); err != nil {
+			logger.Log.LogWarn("forward handshake failed (name:%s, remote:%s): %s",
+				in.listener.Name, conn.RemoteAddr(), err)
+			return
+		}
+	}
+
+	// The acks are written by their own goroutine so that the pipeline
+	// never waits for a slow client
+	acks := make(chan string, maxPendingAcks)
+	done := make(chan struct{})
+	defer close(done)
+	go writeAcks(enc, acks, done)
+
+	for {
+		value, err := dec.DecodeInterface()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				logger.Log.LogWarn("forward connection closed (name:%s, remote:%s): %s",
+					in.listener.Name, conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		entries, option, err := in.decodeMessage(value, time.Now().UTC())
+		if err != nil {
+			logger.Log.LogWarn("invalid forward message (name:%s, remote:%s): %s",
+				in.listener.Name, conn.RemoteAddr(), err)
+			continue
+		}
+
+		var ack func()
+		if chunk, ok := toString(option["chunk"]); ok && chunk != "" {
+			ack = func() {
+				select {
+				case acks <- chunk:
+				default:
+				}
+			}
+		}
+
+		entries = in.parser.Process(entries)
+		if err := input.SubmitWithAck(ctx, in.pipeline, entries, ack); err != nil {
+			// Not acknowledged, so the client sends the chunk again
+			logger.Log.LogWarn("failed to submit forward message (name:%s): %s", in.listener.Name, err)
+			return
+		}
+	}
+}
+
+// writeAcks writes the ack responses of the chunks written by the
+// pipeline until the connection is closed.
+//
+// Parameters:
+//   - enc: message encoder of the connection
+//   - acks: chunk IDs written by the pipeline
+//   - done: closed when the connection is closed
+func writeAcks(enc *msgpack.Encoder, acks <-chan string, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case chunk := <-acks:
+			if err := enc.Encode(map[string]string{"ack": chunk}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handshake authenticates the client by the shared key.
+//
+// Parameters:
+//   - conn: client connection
+//   - dec: message decoder of the connection
+//   - enc: message encoder of the connection
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (in *Input) handshake(conn net.Conn, dec *msgpack.Decoder, enc *msgpack.Encoder) error {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to make nonce: %s", err)
+	}
+
+	// HELO
+	helo := []interface{}{"HELO", map[string]interface{}{"nonce": nonce, "auth": "", "keepalive": true}}
+	if err := enc.Encode(helo); err != nil {
+		return fmt.Errorf("failed to send HELO: %s", err)
+	}
+
+	// PING (PING, hostname, salt, digest, username, password digest)
+	value, err := dec.DecodeInterface()
+	if err != nil {
+		return fmt.Errorf("failed to read PING: %s", err)
+	}
+	ping, ok := value.([]interface{})
+	if !ok || len(ping) < 4 {
+		return fmt.Errorf("invalid PING message")
+	}
+	if kind, _ := toString(ping[0]); kind != "PING" {
+		return fmt.Errorf("invalid PING message")
+	}
+	clientHostname, _ := toString(ping[1])
+	salt, _ := toString(ping[2])
+	digest, _ := toString(ping[3])
+
+	want := sharedKeyDigest(salt, clientHostname, nonce, in.listener.SharedKey)
+	if subtle.ConstantTimeCompare([]byte(digest), []byte(want)) != 1 {
+		enc.Encode([]interface{}{"PONG", false, "shared key mismatch", "", ""})
+		return fmt.Errorf("shared key mismatch (hostname: %s)", clientHostname)
+	}
+
+	// PONG (PONG, result, reason, hostname, digest)
+	pong := []interface{}{"PONG", true, "", in.hostname,
+		sharedKeyDigest(salt, in.hostname, nonce, in.listener.SharedKey)}
+	if err := enc.Encode(pong); err != nil {
+		return fmt.Errorf("failed to send PONG: %s", err)
+	}
+	return nil
+}
+
+// decodeMessage converts a message of any mode to entries.
+//
+// Parameters:
+//   - value: decoded message
+//   - now: receive time
+//
+// Returns:
+//   - []entry.Entry: log entries
+//   - map[string]interface{}: message option
+//   - error: success(nil), failure(error)
+func (in *Input) decodeMessage(value interface{}, now time.Time) ([]entry.Entry, map[string]interface{}, error) {
+	msg, ok := value.([]interface{})
+	if !ok || len(msg) < 2 {
+		return nil, nil, fmt.Errorf("message must be an array of at least 2 elements")
+	}
+	tag, ok := toString(msg[0])
+	if !ok {
+		return nil, nil, fmt.Errorf("tag must be a string")
+	}
+
+	option := func(i int) map[string]interface{} {
+		if len(msg) > i {
+			if m, ok := msg[i].(map[string]interface{}); ok {
+				return m
+			}
+		}
+		return map[string]interface{}{}
+	}
+
+	entries := []entry.Entry{}
+	switch events := msg[1].(type) {
+	case []interface{}:
+		// Forward mode: [tag, [[time, record], ...], option]
+		for _, event := range events {
+			pair, ok := event.([]interface{})
+			if !ok || len(pair) < 2 {
+				return nil, nil, fmt.Errorf("event must be an array of time and record")
+			}
+			e, err := in.decodeEvent(tag, pair[0], pair[1], now)
+			if err != nil {
+				return nil, nil, err
+			}
+			entries = append(entries, e)
+		}
+		return entries, option(2), nil
+	case string:
+		// (Compressed)PackedForward mode: [tag, msgpack stream, option]
+		return in.decodePacked(tag, []byte(events), option(2), now)
+	case []byte:
+		return in.decodePacked(tag, events, option(2), now)
+	default:
+		// Message mode: [tag, time, record, option]
+		if len(msg) < 3 {
+			return nil, nil, fmt.Errorf("message mode requires time and record")
+		}
+		e, err := in.decodeEvent(tag, msg[1], msg[2], now)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(entries, e), option(3), nil
+	}
+}
+
+// decodePacked converts the msgpack stream of the (Compressed)PackedForward
+// mode to entries.
+//
+// Parameters:
+//   - tag: message tag
+//   - data: msgpack stream of the events (gzip compressed if the option says so)
+//   - opt: message option
+//   - now: receive time
+//
+// Returns:
+//   - []entry.Entry: log entries
+//   - map[string]interface{}: message option
+//   - error: success(nil), failure(error)
+func (in *Input) decodePacked(tag string, data []byte, opt map[string]interface{},
+	now time.Time) ([]entry.Entry, map[string]interface{}, error) {
+	if compressed, _ := toString(opt["compressed"]); compressed == "gzip" {
+		var err error
+		if data, err = in.gunzip(data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	entries := []entry.Entry{}
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.UseLooseInterfaceDecoding(true)
+	for {
+		event, err := dec.DecodeInterface()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode packed events: %s", err)
+		}
+		pair, ok := event.([]interface{})
+		if !ok || len(pair) < 2 {
+			return nil, nil, fmt.Errorf("event must be an array of time and record")
+		}
+		e, err := in.decodeEvent(tag, pair[0], pair[1], now)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, opt, nil
+}
+
+// decodeEvent converts an event to an entry.
+//
+// Parameters:
+//   - tag: event tag
+//   - timeValue: event time (EventTime, unix seconds)
+//   - recordValue: event record
+//   - now: receive time
+//
+// Returns:
+//   - entry.Entry: log entry
+//   - error: success(nil), failure(error)
+func (in *Input) decodeEvent(tag string, timeValue, recordValue interface{}, now time.Time) (entry.Entry, error) {
+	e := entry.Entry{
+		Time:   now,
+		Level:  entry.LevelInfo,
+		Source: in.listener.Name,
+		Stream: in.listener.Stream,
+	}
+
+	switch t := timeValue.(type) {
+	case *eventTime:
+		e.Time = t.Time
+	case int64:
+		e.Time = time.Unix(t, 0).UTC()
+	case uint64:
+		e.Time = time.Unix(int64(t), 0).UTC()
+	case float64:
+		sec := int64(t)
+		e.Time = time.Unix(sec, int64((t-float64(sec))*1e9)).UTC()
+	case nil:
+	default:
+		return e, fmt.Errorf("unsupported event time type: %T", timeValue)
+	}
+
+	record, ok := recordValue.(map[string]interface{})
+	if !ok {
+		return e, fmt.Errorf("record must be a map")
+	}
+
+	consumed := make(map[string]bool)
+	for _, key := range messageKeys {
+		if message, ok := toString(record[key]); ok {
+			e.Message = message
+			consumed[key] = true
+			break
+		}
+	}
+	if e.Message == "" {
+		return e, fmt.Errorf("message is empty (tag: %s)", tag)
+	}
+	for _, key := range levelKeys {
+		if levelStr, ok := toString(record[key]); ok {
+			if level, err := entry.NormalizeLevel(levelStr); err == nil {
+				e.Level = level
+				consumed[key] = true
+				break
+			}
+		}
+	}
+
+	for key, value := range record {
+		if !consumed[key] {
+			e.SetField(key, normalizeValue(value))
+		}
+	}
+	e.SetField("tag", tag)
+	return e, nil
+}
+
+// gunzip decompresses the gzip compressed events.
+//
+// Parameters:
+//   - data: compressed events
+//
+// Returns:
+//   - []byte: decompressed events
+//   - error: success(nil), failure(error)
+func (in *Input) gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %s", err)
+	}
+	defer reader.Close()
+
+	out, err := io.ReadAll(io.LimitReader(reader, int64(in.maxMessageSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress events: %s", err)
+	}
+	if len(out) > in.maxMessageSize {
+		return nil, fmt.Errorf("decompressed events too large (max: %d)", in.maxMessageSize)
+	}
+	return out, nil
+}
+
+// sharedKeyDigest computes the digest of the handshake.
+//
+// Parameters:
+//   - salt: shared key salt
+//   - hostname: hostname of the digest owner
+//   - nonce: nonce of the HELO message
+//   - sharedKey: shared key
+//
+// Returns:
+//   - string: hex encoded SHA-512 digest
+func sharedKeyDigest(salt, hostname string, nonce []byte, sharedKey string) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(sharedKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// toString converts a str or bin value to a string.
+//
+// Parameters:
+//   - value: decoded value
+//
+// Returns:
+//   - string: string value
+//   - bool: converted(true), not a string(false)
+func toString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
+
+// normalizeValue converts bin values in a record value to strings so
+// that they are stored as JSON strings.
+//
+// Parameters:
+//   - value: decoded value
+//
+// Returns:
+//   - interface{}: converted value
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case *eventTime:
+		return v.Time
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeValue(item)
+		}
+	}
+	return value
+}
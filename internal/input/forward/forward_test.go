@@ -0,0 +1,229 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package forward
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+	"github.com/klauspost/compress/gzip"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// packEvents encodes events in the msgpack stream of the PackedForward mode.
+func packEvents(t *testing.T, events ...[]interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// roundTrip encodes a message and decodes it as the connection does.
+func roundTrip(t *testing.T, message []interface{}) interface{} {
+	t.Helper()
+	data, err := msgpack.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.UseLooseInterfaceDecoding(true)
+	value, err := dec.DecodeInterface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestDecodeMessage(t *testing.T) {
+	eventAt := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	record := map[string]interface{}{"log": "failed", "level": "ERROR", "user": []byte("alice")}
+	event := []interface{}{&eventTime{eventAt}, record}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(packEvents(t, event, event))
+	gw.Close()
+
+	tests := []struct {
+		name      string
+		message   []interface{}
+		wantCount int
+		wantChunk string
+		wantErr   string
+	}{
+		{"message", []interface{}{"app", &eventTime{eventAt}, record, map[string]interface{}{"chunk": "c1"}}, 1, "c1", ""},
+		{"message with unix time", []interface{}{"app", eventAt.Unix(), record}, 1, "", ""},
+		{"forward", []interface{}{"app", []interface{}{event, event, event}, map[string]interface{}{"chunk": "c2"}}, 3,
+			"c2", ""},
+		{"packed forward (bin)", []interface{}{"app", packEvents(t, event, event)}, 2, "", ""},
+		{"packed forward (str)", []interface{}{"app", string(packEvents(t, event))}, 1, "", ""},
+		{"compressed packed forward", []interface{}{"app", gz.Bytes(),
+			map[string]interface{}{"compressed": "gzip", "chunk": "c3"}}, 2, "c3", ""},
+		{"invalid tag", []interface{}{1, eventAt.Unix(), record}, 0, "", "tag must be a string"},
+		{"missing record", []interface{}{"app", eventAt.Unix()}, 0, "", "requires time and record"},
+		{"record not a map", []interface{}{"app", eventAt.Unix(), "text"}, 0, "", "record must be a map"},
+		{"empty message", []interface{}{"app", eventAt.Unix(), map[string]interface{}{"a": 1}}, 0, "", "message is empty"},
+		{"invalid packed events", []interface{}{"app", []byte{0xc1}}, 0, "", "failed to decode packed events"},
+		{"invalid gzip", []interface{}{"app", []byte("xx"), map[string]interface{}{"compressed": "gzip"}}, 0, "",
+			"gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := &Input{listener: config.Listener{Name: "forward", Stream: "default"}, maxMessageSize: 1 << 20}
+			entries, option, err := in.decodeMessage(roundTrip(t, tt.message), time.Now().UTC())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeMessage error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeMessage: %v", err)
+			}
+			if len(entries) != tt.wantCount {
+				t.Fatalf("entries = %d, want %d", len(entries), tt.wantCount)
+			}
+			if chunk, _ := toString(option["chunk"]); chunk != tt.wantChunk {
+				t.Errorf("chunk = %q, want %q", chunk, tt.wantChunk)
+			}
+
+			e := entries[0]
+			wantTime := eventAt
+			if tt.name == "message with unix time" {
+				wantTime = eventAt.Truncate(time.Second)
+			}
+			if !e.Time.Equal(wantTime) || e.Message != "failed" || e.Level != "error" {
+				t.Errorf("entry = %v %q %s", e.Time, e.Message, e.Level)
+			}
+			if e.Fields["user"] != "alice" || e.Fields["tag"] != "app" {
+				t.Errorf("fields = %v, want the bin value as a string and the tag", e.Fields)
+			}
+		})
+	}
+}
+
+func TestDecodeMessagePackedBytes(t *testing.T) {
+	// Without loose decoding, a bin payload is decoded as []byte
+	event := []interface{}{time.Now().Unix(), map[string]interface{}{"log": "hello"}}
+	in := &Input{listener: config.Listener{Name: "forward", Stream: "default"}, maxMessageSize: 1 << 20}
+	entries, _, err := in.decodeMessage([]interface{}{"app", packEvents(t, event, event)}, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("decodeMessage: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Message != "hello" {
+		t.Errorf("entries = %+v, want 2 entries", entries)
+	}
+}
+
+// gateWriter fails the writes while closed
+type gateWriter struct {
+	mu      sync.Mutex
+	open    bool
+	entries []entry.Entry
+}
+
+func (w *gateWriter) Write(entries []entry.Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.open {
+		return errors.New("store unavailable")
+	}
+	w.entries = append(w.entries, entries...)
+	return nil
+}
+
+func (w *gateWriter) setOpen(open bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.open = open
+}
+
+func (w *gateWriter) written() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.entries)
+}
+
+func TestServeConnAck(t *testing.T) {
+	writer := &gateWriter{}
+	pl := pipeline.NewPipeline(100, writer, func(err error) {})
+	in, err := NewInput(config.Listener{Name: "forward", Protocol: config.ListenerForward, Stream: "default"}, pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, client := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pl.Run(ctx)
+	done := make(chan struct{})
+	go func() {
+		in.serveConn(ctx, server)
+		server.Close()
+		close(done)
+	}()
+
+	enc := msgpack.NewEncoder(client)
+	dec := msgpack.NewDecoder(client)
+	send := func(chunk string) {
+		record := map[string]interface{}{"message": "hello"}
+		message := []interface{}{"app", []interface{}{[]interface{}{time.Now().Unix(), record}},
+			map[string]interface{}{"chunk": chunk}}
+		if err := enc.Encode(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A chunk that is not stored is not acknowledged
+	send("lost")
+	client.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	ack := map[string]string{}
+	if err := dec.Decode(&ack); err == nil {
+		t.Fatalf("ack = %v before the entries are stored", ack)
+	}
+
+	// The chunk sent again is acknowledged after it is stored
+	writer.setOpen(true)
+	send("abc")
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := dec.Decode(&ack); err != nil {
+		t.Fatalf("failed to read ack: %v", err)
+	}
+	if ack["ack"] != "abc" {
+		t.Errorf("ack = %v, want abc", ack)
+	}
+	if n := writer.written(); n != 1 {
+		t.Errorf("written = %d, want 1", n)
+	}
+
+	client.Close()
+	<-done
+}
@@ -0,0 +1,407 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package gelf provides the GELF (Graylog Extended Log Format) input.
+
+Messages are received over UDP (optionally chunked, gzip or zlib
+compressed) or over TCP (uncompressed, null byte delimited). Additional
+fields (_<name>) of a message are stored as structured fields.
+*/
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/input"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/parser"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+)
+
+// Chunked message header (magic(2) + message id(8) + sequence(1) + count(1))
+const (
+	chunkHeaderSize = 12
+	maxChunkCount   = 128
+)
+
+// Maximum UDP datagram size
+const maxDatagramSize = 65536
+
+// Socket read timeout for checking termination and expired chunks
+const readTimeout = time.Second
+
+// Magic number of the chunked message
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// Input is a GELF input structure
+type Input struct {
+	listener       config.Listener
+	pipeline       *pipeline.Pipeline
+	parser         *parser.Chain
+	chunkTimeout   time.Duration
+	maxMessageSize int
+	chunks         map[string]*chunkedMessage // Used only by the UDP receiver
+}
+
+// chunkedMessage is a chunked message being reassembled
+type chunkedMessage struct {
+	parts     [][]byte
+	received  int
+	size      int
+	firstTime time.Time
+}
+
+// NewInput create GELF input.
+//
+// Parameters:
+//   - listener: listener configuration (gelf_udp, gelf_tcp)
+//   - pl: write pipeline
+//
+// Returns:
+//   - *Input: GELF input
+//   - error: success(nil), failure(error)
+func NewInput(listener config.Listener, pl *pipeline.Pipeline) (*Input, error) {
+	chain, err := parser.NewChain(&listener)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Input{
+		listener:       listener,
+		pipeline:       pl,
+		parser:         chain,
+		chunkTimeout:   time.Duration(config.Conf.GelfChunkTimeout) * time.Second,
+		maxMessageSize: config.Conf.IngestMaxBodySize * 1024 * 1024,
+		chunks:         make(map[string]*chunkedMessage),
+	}, nil
+}
+
+// Run receives messages until the context is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (in *Input) Run(ctx context.Context) {
+	var err error
+	switch in.listener.Protocol {
+	case config.ListenerGelfUdp:
+		err = in.runUdp(ctx)
+	case config.ListenerGelfTcp:
+		err = in.runTcp(ctx)
+	default:
+		err = fmt.Errorf("unsupported protocol: %s", in.listener.Protocol)
+	}
+	if err != nil {
+		logger.Log.LogError("GELF listener stopped (name:%s): %s", in.listener.Name, err)
+	}
+}
+
+// runUdp receives datagrams until the context is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (in *Input) runUdp(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", in.listener.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %s", err)
+	}
+	defer conn.Close()
+	logger.Log.LogInfo("Start GELF UDP listener (name:%s, address:%s)", in.listener.Name, in.listener.Address)
+
+	buf := make([]byte, maxDatagramSize)
+	for ctx.Err() == nil {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		n, _, err := conn.ReadFrom(buf)
+		now := time.Now().UTC()
+		in.expireChunks(now)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return fmt.Errorf("failed to read datagram: %s", err)
+		}
+
+		data := buf[:n]
+		if bytes.HasPrefix(data, chunkMagic) {
+			var complete bool
+			if data, complete, err = in.reassemble(data, now); err != nil {
+				logger.Log.LogWarn("invalid GELF chunk (name:%s): %s", in.listener.Name, err)
+				continue
+			} else if !complete {
+				continue
+			}
+		} else {
+			// The buffer is reused by the next read
+			data = bytes.Clone(data)
+		}
+
+		in.handleMessage(ctx, data, now)
+	}
+	return nil
+}
+
+// reassemble adds a chunk to its message.
+//
+// Parameters:
+//   - data: chunk datagram
+//   - now: receive time
+//
+// Returns:
+//   - []byte: reassembled message (if complete)
+//   - bool: complete(true), waiting for other chunks(false)
+//   - error: success(nil), failure(error)
+func (in *Input) reassemble(data []byte, now time.Time) ([]byte, bool, error) {
+	if len(data) <= chunkHeaderSize {
+		return nil, false, fmt.Errorf("chunk too short (size: %d)", len(data))
+	}
+	id := string(data[2:10])
+	seq, count := int(data[10]), int(data[11])
+	if count == 0 || count > maxChunkCount || seq >= count {
+		return nil, false, fmt.Errorf("invalid chunk sequence (seq: %d, count: %d)", seq, count)
+	}
+
+	msg, exists := in.chunks[id]
+	if !exists {
+		msg = &chunkedMessage{parts: make([][]byte, count), firstTime: now}
+		in.chunks[id] = msg
+	}
+	if len(msg.parts) != count {
+		delete(in.chunks, id)
+		return nil, false, fmt.Errorf("chunk count mismatch (count: %d, expected: %d)", count, len(msg.parts))
+	}
+	if msg.parts[seq] != nil {
+		// Duplicate chunk
+		return nil, false, nil
+	}
+
+	msg.parts[seq] = bytes.Clone(data[chunkHeaderSize:])
+	msg.received++
+	msg.size += len(data) - chunkHeaderSize
+	if msg.size > in.maxMessageSize {
+		delete(in.chunks, id)
+		return nil, false, fmt.Errorf("message too large (max: %d)", in.maxMessageSize)
+	}
+	if msg.received < count {
+		return nil, false, nil
+	}
+
+	delete(in.chunks, id)
+	return bytes.Join(msg.parts, nil), true, nil
+}
+
+// expireChunks discards the messages whose chunks did not all arrive
+// within the chunk timeout.
+//
+// Parameters:
+//   - now: current time
+func (in *Input) expireChunks(now time.Time) {
+	for id, msg := range in.chunks {
+		if now.Sub(msg.firstTime) > in.chunkTimeout {
+			delete(in.chunks, id)
+			logger.Log.LogWarn("GELF chunked message expired (name:%s, received:%d/%d)",
+				in.listener.Name, msg.received, len(msg.parts))
+		}
+	}
+}
+
+// runTcp accepts connections until the context is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (in *Input) runTcp(ctx context.Context) error {
+	logger.Log.LogInfo("Start GELF TCP listener (name:%s, address:%s)", in.listener.Name, in.listener.Address)
+	return input.ServeTCP(ctx, in.listener.Address, in.serveConn)
+}
+
+// serveConn reads null byte delimited messages from a connection.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - conn: client connection
+func (in *Input) serveConn(ctx context.Context, conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), in.maxMessageSize)
+	scanner.Split(scanNull)
+	for scanner.Scan() {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		in.handleMessage(ctx, data, time.Now().UTC())
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+		logger.Log.LogWarn("GELF connection closed (name:%s, remote:%s): %s",
+			in.listener.Name, conn.RemoteAddr(), err)
+	}
+}
+
+// handleMessage decodes a message and submits it to the pipeline.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - data: message (may be compressed)
+//   - now: receive time
+func (in *Input) handleMessage(ctx context.Context, data []byte, now time.Time) {
+	data, err := decompress(data, in.maxMessageSize)
+	if err != nil {
+		logger.Log.LogWarn("invalid GELF message (name:%s): %s", in.listener.Name, err)
+		return
+	}
+
+	e, err := in.decodeMessage(data, now)
+	if err != nil {
+		logger.Log.LogWarn("invalid GELF message (name:%s): %s", in.listener.Name, err)
+		return
+	}
+
+	entries := []entry.Entry{e}
+	entries = in.parser.Process(entries)
+	if err := input.Submit(ctx, in.pipeline, entries); err != nil {
+		logger.Log.LogWarn("failed to submit GELF message (name:%s): %s", in.listener.Name, err)
+	}
+}
+
+// decodeMessage converts a GELF message to an entry.
+//
+// Parameters:
+//   - data: uncompressed message
+//   - now: receive time
+//
+// Returns:
+//   - entry.Entry: log entry
+//   - error: success(nil), failure(error)
+func (in *Input) decodeMessage(data []byte, now time.Time) (entry.Entry, error) {
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return entry.Entry{}, fmt.Errorf("failed to parse message: %s", err)
+	}
+
+	message, _ := obj["short_message"].(string)
+	if message == "" {
+		return entry.Entry{}, fmt.Errorf("short_message is empty")
+	}
+
+	e := entry.Entry{
+		Time:    now,
+		Level:   entry.LevelInfo,
+		Source:  in.listener.Name,
+		Stream:  in.listener.Stream,
+		Message: message,
+	}
+
+	for key, value := range obj {
+		switch key {
+		case "short_message", "version":
+		case "timestamp":
+			ts, ok := value.(float64)
+			if !ok {
+				return e, fmt.Errorf("timestamp must be a number")
+			}
+			sec := int64(ts)
+			e.Time = time.Unix(sec, int64((ts-float64(sec))*1e9)).UTC()
+		case "level":
+			level, ok := value.(float64)
+			if !ok {
+				return e, fmt.Errorf("level must be a number")
+			}
+			e.Level = entry.SyslogLevel(int(level))
+		default:
+			// Additional fields (_<name>) and the other standard fields
+			// (host, full_message, ...)
+			e.SetField(strings.TrimPrefix(key, "_"), value)
+		}
+	}
+
+	return e, nil
+}
+
+// decompress decompresses a gzip or zlib compressed message.
+// Uncompressed messages are returned as they are.
+//
+// Parameters:
+//   - data: message
+//   - maxSize: maximum decompressed size
+//
+// Returns:
+//   - []byte: uncompressed message
+//   - error: success(nil), failure(error)
+func decompress(data []byte, maxSize int) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+	case len(data) >= 2 && data[0] == 0x78 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0:
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decompression reader: %s", err)
+	}
+	defer reader.Close()
+
+	out, err := io.ReadAll(io.LimitReader(reader, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress message: %s", err)
+	}
+	if len(out) > maxSize {
+		return nil, fmt.Errorf("message too large (max: %d)", maxSize)
+	}
+	return out, nil
+}
+
+// scanNull is a split function of bufio.Scanner for null byte
+// delimited messages.
+//
+// Parameters:
+//   - data: buffered data
+//   - atEOF: whether the end of input is reached
+//
+// Returns:
+//   - int: number of bytes to advance
+//   - []byte: message
+//   - error: success(nil), failure(error)
+func scanNull(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+)
+
+// chunk builds a chunk datagram.
+func chunk(id string, seq, count int, payload string) []byte {
+	data := append([]byte{}, chunkMagic...)
+	data = append(data, []byte(id)...)
+	data = append(data, byte(seq), byte(count))
+	return append(data, payload...)
+}
+
+func newTestInput() *Input {
+	return &Input{
+		listener:       config.Listener{Name: "gelf", Stream: "default"},
+		chunkTimeout:   5 * time.Second,
+		maxMessageSize: 16,
+		chunks:         make(map[string]*chunkedMessage),
+	}
+}
+
+func TestReassemble(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunks  [][]byte
+		want    string
+		wantErr string
+	}{
+		{"in order", [][]byte{chunk("AAAAAAAA", 0, 2, "he"), chunk("AAAAAAAA", 1, 2, "llo")}, "hello", ""},
+		{"out of order", [][]byte{chunk("AAAAAAAA", 1, 2, "llo"), chunk("AAAAAAAA", 0, 2, "he")}, "hello", ""},
+		{"duplicate chunk", [][]byte{chunk("AAAAAAAA", 0, 2, "he"), chunk("AAAAAAAA", 0, 2, "he"),
+			chunk("AAAAAAAA", 1, 2, "llo")}, "hello", ""},
+		{"interleaved messages", [][]byte{chunk("AAAAAAAA", 0, 2, "he"), chunk("BBBBBBBB", 0, 2, "wo"),
+			chunk("AAAAAAAA", 1, 2, "llo")}, "hello", ""},
+		{"count mismatch", [][]byte{chunk("AAAAAAAA", 0, 2, "he"), chunk("AAAAAAAA", 1, 3, "llo")}, "", "count mismatch"},
+		{"sequence out of range", [][]byte{chunk("AAAAAAAA", 2, 2, "he")}, "", "invalid chunk sequence"},
+		{"too many chunks", [][]byte{chunk("AAAAAAAA", 0, maxChunkCount+1, "he")}, "", "invalid chunk sequence"},
+		{"too short", [][]byte{chunk("AAAAAAAA", 0, 2, "")}, "", "too short"},
+		{"too large", [][]byte{chunk("AAAAAAAA", 0, 2, "0123456789"), chunk("AAAAAAAA", 1, 2, "0123456789")}, "",
+			"too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := newTestInput()
+			now := time.Now()
+
+			var got []byte
+			var err error
+			for _, c := range tt.chunks {
+				var complete bool
+				if got, complete, err = in.reassemble(c, now); err != nil || complete {
+					break
+				}
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("reassemble error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reassemble: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+			if _, exists := in.chunks["AAAAAAAA"]; exists {
+				t.Errorf("completed message is still held")
+			}
+		})
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	message := `{"short_message":"hello"}`
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(message))
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte(message))
+	zw.Close()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int
+		wantErr bool
+	}{
+		{"uncompressed", []byte(message), 100, false},
+		{"gzip", gz.Bytes(), 100, false},
+		{"zlib", zl.Bytes(), 100, false},
+		{"too large", gz.Bytes(), 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decompress(tt.data, tt.maxSize)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("decompress succeeded, want an error")
+				}
+				return
+			}
+			if err != nil || string(got) != message {
+				t.Errorf("decompress = %q, %v, want %q", got, err, message)
+			}
+		})
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	in := newTestInput()
+	now := time.Now().UTC()
+
+	e, err := in.decodeMessage([]byte(`{"version":"1.1","host":"web","short_message":"failed",
+		"timestamp":1700000000.5,"level":3,"_user":"alice"}`), now)
+	if err != nil {
+		t.Fatalf("decodeMessage: %v", err)
+	}
+	if e.Message != "failed" || e.Level != "error" || !e.Time.Equal(time.Unix(1700000000, 5e8)) {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Fields["user"] != "alice" || e.Fields["host"] != "web" || e.Source != "gelf" {
+		t.Errorf("fields = %v, source = %s", e.Fields, e.Source)
+	}
+
+	for _, data := range []string{`{"host":"web"}`, `{"short_message":"a","level":"x"}`, `not json`} {
+		if _, err := in.decodeMessage([]byte(data), now); err == nil {
+			t.Errorf("decodeMessage(%s) succeeded, want an error", data)
+		}
+	}
+}
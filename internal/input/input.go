@@ -0,0 +1,195 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package input provides the functions shared by the non-HTTP inputs
+(listeners that receive entries over raw sockets, files, etc.).
+Each input is implemented in its own subpackage.
+*/
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+)
+
+// Interval for retrying submission while the pipeline queue is full
+const submitRetryInterval = 100 * time.Millisecond
+
+// Delays for retrying Accept after an error (e.g. EMFILE), doubled up to the maximum
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// Submit adds entries to the pipeline. Unlike the HTTP API which asks
+// the client to retry, inputs wait while the queue is full so that the
+// backpressure reaches the sender (e.g. by TCP flow control). Entries
+// exceeding the queue capacity are submitted in parts.
+//
+// Parameters:
+//   - ctx: context for giving up the submission
+//   - pl: write pipeline
+//   - entries: log entries
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func Submit(ctx context.Context, pl *pipeline.Pipeline, entries []entry.Entry) error {
+	return SubmitWithAck(ctx, pl, entries, nil)
+}
+
+// SubmitWithAck is Submit with a callback called after the entries are
+// written by the pipeline (see pipeline.SubmitWithAck).
+//
+// Parameters:
+//   - ctx: context for giving up the submission
+//   - pl: write pipeline
+//   - entries: log entries
+//   - ack: write acknowledgement callback (nil if not needed)
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func SubmitWithAck(ctx context.Context, pl *pipeline.Pipeline, entries []entry.Entry, ack func()) error {
+	for len(entries) > pl.Capacity() {
+		if err := SubmitWithAck(ctx, pl, entries[:pl.Capacity()], nil); err != nil {
+			return err
+		}
+		entries = entries[pl.Capacity():]
+	}
+	if len(entries) == 0 && ack == nil {
+		return nil
+	}
+
+	for {
+		err := pl.SubmitWithAck(entries, ack)
+		if !errors.Is(err, pipeline.ErrQueueFull) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(submitRetryInterval):
+		}
+	}
+}
+
+// ServeTCP accepts connections and serves each connection in its own
+// goroutine until the context is cancelled. Accept errors are logged and
+// retried with a backoff, as net/http does. Open connections are closed
+// on termination and the function returns after all handlers return.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - address: listen address (host:port)
+//   - handler: connection handler (the connection is closed after return)
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func ServeTCP(ctx context.Context, address string, handler func(ctx context.Context, conn net.Conn)) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %s", err)
+	}
+	serve(ctx, ln, handler)
+	return nil
+}
+
+// serve accepts connections of the listener until the context is
+// cancelled (see ServeTCP).
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - ln: listener
+//   - handler: connection handler (the connection is closed after return)
+func serve(ctx context.Context, ln net.Listener, handler func(ctx context.Context, conn net.Conn)) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[net.Conn]struct{})
+
+	// Unblock Accept and Read on termination
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+	}()
+
+	delay := time.Duration(0)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				wg.Wait()
+				return
+			}
+
+			delay = min(max(delay*2, minAcceptDelay), maxAcceptDelay)
+			logger.Log.LogWarn("failed to accept connection (address:%s): %s, retrying in %s", ln.Addr(), err, delay)
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			continue
+		}
+		delay = 0
+
+		mu.Lock()
+		if ctx.Err() != nil {
+			mu.Unlock()
+			conn.Close()
+			continue
+		}
+		conns[conn] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(ctx, conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// TrimLineFeed converts a line to a string without the line feed.
+//
+// Parameters:
+//   - line: line (with the line feed)
+//
+// Returns:
+//   - string: line without the line feed
+func TrimLineFeed(line []byte) string {
+	n := len(line)
+	for n > 0 && (line[n-1] == '\n' || line[n-1] == '\r') {
+		n--
+	}
+	return string(line[:n])
+}
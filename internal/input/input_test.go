@@ -0,0 +1,133 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package input
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+)
+
+func TestMain(m *testing.M) {
+	for i := range config.Conf.LogSinks {
+		config.Conf.LogSinks[i].Enable = false
+	}
+	logger.Log.InitializeLogger()
+	os.Exit(m.Run())
+}
+
+// recordWriter records the written entries
+type recordWriter struct {
+	entries chan entry.Entry
+}
+
+func (w *recordWriter) Write(entries []entry.Entry) error {
+	for _, e := range entries {
+		w.entries <- e
+	}
+	return nil
+}
+
+func TestSubmitSplitsLargeBatch(t *testing.T) {
+	writer := &recordWriter{entries: make(chan entry.Entry, 1000)}
+	pl := pipeline.NewPipeline(100, writer, func(err error) { t.Error(err) })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pl.Run(ctx)
+
+	entries := make([]entry.Entry, 250)
+	for i := range entries {
+		entries[i].Message = string(rune('a' + i%26))
+	}
+	submitCtx, submitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer submitCancel()
+	if err := Submit(submitCtx, pl, entries); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	for i := range entries {
+		select {
+		case e := <-writer.entries:
+			if e.Message != entries[i].Message {
+				t.Fatalf("entry %d = %q, want %q", i, e.Message, entries[i].Message)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("written %d entries, want %d", i, len(entries))
+		}
+	}
+}
+
+// flakyListener fails the first Accept calls as when file descriptors
+// run out
+type flakyListener struct {
+	net.Listener
+	failures atomic.Int32
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures.Add(-1) >= 0 {
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept4", syscall.EMFILE)}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServeAcceptError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky := &flakyListener{Listener: ln}
+	flaky.failures.Store(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		serve(ctx, flaky, func(ctx context.Context, conn net.Conn) {
+			conn.Write([]byte("ok"))
+		})
+		close(done)
+	}()
+
+	// The listener keeps accepting after the errors
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ok" {
+		t.Fatalf("read = %q, %v; want ok", buf, err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the cancellation")
+	}
+}
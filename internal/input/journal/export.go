@@ -0,0 +1,272 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package journal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/input"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/parser"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+	"github.com/hoon-kr/log_manager/pkg/utils/file"
+)
+
+// Interval for checking new data after the end of the file
+const pollInterval = 500 * time.Millisecond
+
+// Read buffer size
+const readBufferSize = 64 * 1024
+
+// Maximum number of entries submitted to the pipeline at once
+const maxBatchEntries = 100
+
+// Checkpoint directory name under the data directory (not a valid stream name)
+const checkpointDirName = ".checkpoints"
+
+// exportCheckpoint is a saved read offset of the export file
+type exportCheckpoint struct {
+	Dev    uint64 `json:"dev"`
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// ExportInput is a journal export format input structure
+type ExportInput struct {
+	listener       config.Listener
+	pipeline       *pipeline.Pipeline
+	parser         *parser.Chain
+	path           string
+	checkpointPath string
+}
+
+// NewExportInput create journal export format input.
+//
+// Parameters:
+//   - listener: listener configuration
+//   - pl: write pipeline
+//
+// Returns:
+//   - *ExportInput: journal export format input
+//   - error: success(nil), failure(error)
+func NewExportInput(listener config.Listener, pl *pipeline.Pipeline) (*ExportInput, error) {
+	chain, err := parser.NewChain(&listener)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExportInput{
+		listener:       listener,
+		pipeline:       pl,
+		parser:         chain,
+		path:           listener.Paths[0],
+		checkpointPath: filepath.Join(config.Conf.DataDirPath, checkpointDirName, listener.Name+".json"),
+	}, nil
+}
+
+// Run reads entries until the context is cancelled. A regular file is
+// followed like tail -f and its read offset is checkpointed. A named
+// pipe is read whenever a writer (e.g. journalctl -o export -f) is
+// connected.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (in *ExportInput) Run(ctx context.Context) {
+	logger.Log.LogInfo("Start journal export input (name:%s, path:%s)", in.listener.Name, in.path)
+
+	// The same error (e.g. the file does not exist yet) is logged once
+	lastErr := ""
+	for {
+		if err := in.readFile(ctx); err != nil {
+			if err.Error() != lastErr {
+				logger.Log.LogWarn("failed to read journal export (name:%s, path:%s): %s", in.listener.Name, in.path, err)
+			}
+			lastErr = err.Error()
+		} else {
+			lastErr = ""
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// readFile opens the file and reads entries until the context is
+// cancelled or the file is replaced.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (in *ExportInput) readFile(ctx context.Context) error {
+	// Non-blocking open so that opening a pipe without a writer does not block
+	f, err := os.OpenFile(in.path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %s", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %s", err)
+	}
+	regular := info.Mode().IsRegular()
+	cp := exportCheckpoint{}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		cp.Dev, cp.Inode = uint64(st.Dev), st.Ino
+	}
+
+	if regular {
+		if saved, err := in.loadCheckpoint(); err == nil && saved.Dev == cp.Dev &&
+			saved.Inode == cp.Inode && saved.Offset <= info.Size() {
+			cp.Offset = saved.Offset
+		}
+		if _, err := f.Seek(cp.Offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek file: %s", err)
+		}
+	}
+
+	// Unblock the read of a pipe on termination
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.Close()
+		case <-done:
+		}
+	}()
+
+	buf := []byte{}
+	chunk := make([]byte, readBufferSize)
+	for ctx.Err() == nil {
+		n, err := f.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+
+		entries := []entry.Entry{}
+		now := time.Now().UTC()
+		for {
+			fields, used, complete, parseErr := parseFields(buf, true)
+			if parseErr != nil {
+				// Skip to the end of the broken entry
+				used = bytes.Index(buf, []byte("\n\n"))
+				if used < 0 {
+					buf = buf[:0]
+					return fmt.Errorf("invalid export entry: %s", parseErr)
+				}
+				used += 2
+				logger.Log.LogWarn("invalid journal export entry (name:%s): %s", in.listener.Name, parseErr)
+			} else if !complete {
+				break
+			} else if e, ok := newEntry(&in.listener, fields, now); ok {
+				entries = append(entries, e)
+			}
+			buf = buf[used:]
+			cp.Offset += int64(used)
+
+			if len(entries) == maxBatchEntries {
+				entries = in.parser.Process(entries)
+				if err := input.Submit(ctx, in.pipeline, entries); err != nil {
+					return nil
+				}
+				entries = entries[:0]
+			}
+		}
+		entries = in.parser.Process(entries)
+		if err := input.Submit(ctx, in.pipeline, entries); err != nil {
+			return nil
+		}
+		if regular && len(entries) > 0 {
+			in.saveCheckpoint(cp)
+		}
+
+		if n > 0 && err == nil {
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read file: %s", err)
+		}
+
+		// End of data: the file may be replaced or truncated
+		if regular {
+			info, err := os.Stat(in.path)
+			if err != nil {
+				return nil
+			}
+			if st, ok := info.Sys().(*syscall.Stat_t); ok && (uint64(st.Dev) != cp.Dev || st.Ino != cp.Inode) {
+				return nil
+			}
+			if info.Size() < cp.Offset {
+				in.saveCheckpoint(exportCheckpoint{Dev: cp.Dev, Inode: cp.Inode})
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(pollInterval):
+		}
+	}
+	return nil
+}
+
+// loadCheckpoint loads the saved read offset.
+//
+// Returns:
+//   - exportCheckpoint: saved read offset
+//   - error: success(nil), failure(error)
+func (in *ExportInput) loadCheckpoint() (exportCheckpoint, error) {
+	cp := exportCheckpoint{}
+	data, err := os.ReadFile(in.checkpointPath)
+	if err != nil {
+		return cp, err
+	}
+	err = json.Unmarshal(data, &cp)
+	return cp, err
+}
+
+// saveCheckpoint saves the read offset.
+//
+// Parameters:
+//   - cp: read offset
+func (in *ExportInput) saveCheckpoint(cp exportCheckpoint) {
+	data, err := json.Marshal(cp)
+	if err == nil {
+		err = file.WriteDataToTextFileAtomic(in.checkpointPath, string(data), true)
+	}
+	if err != nil {
+		logger.Log.LogWarn("failed to save journal export checkpoint (name:%s): %s", in.listener.Name, err)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package journal provides the systemd journal inputs.
+
+The export input reads the journal export format (journalctl -o export)
+from a file or a named pipe, and the socket input receives the journal
+native protocol on a unix datagram socket. Journal fields such as
+_SYSTEMD_UNIT, PRIORITY and _PID are stored as structured fields.
+*/
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// Maximum size of a field value
+const maxFieldSize = 16 * 1024 * 1024
+
+// parseFields parses journal fields. Each field is either a text field
+// (KEY=value\n) or a binary field (KEY\n, little endian 64-bit size,
+// value, \n). In the export format an entry ends with an empty line.
+//
+// Parameters:
+//   - data: export stream or native protocol datagram
+//   - export: whether data is the export format
+//
+// Returns:
+//   - map[string]string: fields
+//   - int: number of bytes used by the entry
+//   - bool: complete(true), more data is needed(false, export format only)
+//   - error: success(nil), failure(error)
+func parseFields(data []byte, export bool) (map[string]string, int, bool, error) {
+	fields := make(map[string]string)
+	pos := 0
+	for {
+		if pos >= len(data) {
+			if export {
+				return nil, 0, false, nil
+			}
+			return fields, pos, true, nil
+		}
+		if export && data[pos] == '\n' {
+			return fields, pos + 1, true, nil
+		}
+
+		end := bytes.IndexByte(data[pos:], '\n')
+		if end < 0 {
+			if export {
+				return nil, 0, false, nil
+			}
+			// The last field of a datagram may have no line feed
+			end = len(data) - pos
+		}
+		line := data[pos : pos+end]
+		next := pos + end + 1
+
+		if eq := bytes.IndexByte(line, '='); eq >= 0 {
+			if eq == 0 {
+				return nil, 0, false, fmt.Errorf("empty field name")
+			}
+			fields[string(line[:eq])] = string(line[eq+1:])
+			pos = next
+			continue
+		}
+
+		// Binary field
+		if len(line) == 0 {
+			return nil, 0, false, fmt.Errorf("empty field name")
+		}
+		if len(data) < next+8 {
+			if export {
+				return nil, 0, false, nil
+			}
+			return nil, 0, false, fmt.Errorf("binary field size is missing (%s)", line)
+		}
+		size := binary.LittleEndian.Uint64(data[next : next+8])
+		if size > maxFieldSize {
+			return nil, 0, false, fmt.Errorf("binary field too large (%s, size: %d)", line, size)
+		}
+		valueStart := next + 8
+		if uint64(len(data)-valueStart) < size+1 {
+			if export {
+				return nil, 0, false, nil
+			}
+			return nil, 0, false, fmt.Errorf("binary field is truncated (%s)", line)
+		}
+		fields[string(line)] = string(data[valueStart : valueStart+int(size)])
+		pos = valueStart + int(size) + 1
+	}
+}
+
+// newEntry create an entry from journal fields. The message is read
+// from MESSAGE, the level from PRIORITY and the time from
+// _SOURCE_REALTIME_TIMESTAMP or __REALTIME_TIMESTAMP. The other fields
+// are stored as structured fields.
+//
+// Parameters:
+//   - listener: listener configuration
+//   - fields: journal fields
+//   - now: receive time
+//
+// Returns:
+//   - entry.Entry: log entry
+//   - bool: created(true), no message(false)
+func newEntry(listener *config.Listener, fields map[string]string, now time.Time) (entry.Entry, bool) {
+	message := fields["MESSAGE"]
+	if message == "" {
+		return entry.Entry{}, false
+	}
+
+	e := entry.Entry{
+		Time:    now,
+		Level:   entry.LevelInfo,
+		Source:  listener.Name,
+		Stream:  listener.Stream,
+		Message: message,
+	}
+
+	for _, key := range []string{"_SOURCE_REALTIME_TIMESTAMP", "__REALTIME_TIMESTAMP"} {
+		if usec, err := strconv.ParseInt(fields[key], 10, 64); err == nil && usec > 0 {
+			e.Time = time.UnixMicro(usec).UTC()
+			break
+		}
+	}
+	if priority, err := strconv.Atoi(fields["PRIORITY"]); err == nil {
+		e.Level = entry.SyslogLevel(priority)
+	}
+
+	for key, value := range fields {
+		if key != "MESSAGE" {
+			e.SetField(key, value)
+		}
+	}
+	return e, true
+}
@@ -0,0 +1,187 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package journal
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+)
+
+func TestMain(m *testing.M) {
+	for i := range config.Conf.LogSinks {
+		config.Conf.LogSinks[i].Enable = false
+	}
+	logger.Log.InitializeLogger()
+	os.Exit(m.Run())
+}
+
+// binaryField encodes a binary field.
+func binaryField(name, value string) string {
+	size := make([]byte, 8)
+	binary.LittleEndian.PutUint64(size, uint64(len(value)))
+	return name + "\n" + string(size) + value + "\n"
+}
+
+func TestParseFieldsExport(t *testing.T) {
+	first := "__REALTIME_TIMESTAMP=1714564800123456\nPRIORITY=3\nMESSAGE=failed\n\n"
+	second := binaryField("MESSAGE", "line 1\nline 2\x00") + "_SYSTEMD_UNIT=app.service\n\n"
+
+	tests := []struct {
+		name     string
+		data     string
+		want     map[string]string
+		wantUsed int
+		complete bool
+		wantErr  string
+	}{
+		{"text fields", first + second, map[string]string{"__REALTIME_TIMESTAMP": "1714564800123456",
+			"PRIORITY": "3", "MESSAGE": "failed"}, len(first), true, ""},
+		{"binary field", second, map[string]string{"MESSAGE": "line 1\nline 2\x00",
+			"_SYSTEMD_UNIT": "app.service"}, len(second), true, ""},
+		{"incomplete entry", first[:len(first)-1], nil, 0, false, ""},
+		{"incomplete binary field", second[:12], nil, 0, false, ""},
+		{"empty field name", "=value\n\n", nil, 0, false, "empty field name"},
+		{"binary field too large", "MESSAGE\n\xff\xff\xff\xff\xff\xff\xff\xff", nil, 0, false, "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, used, complete, err := parseFields([]byte(tt.data), true)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseFields error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFields: %v", err)
+			}
+			if complete != tt.complete || used != tt.wantUsed {
+				t.Fatalf("complete = %v, used = %d, want %v, %d", complete, used, tt.complete, tt.wantUsed)
+			}
+			if tt.complete && !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("fields = %q, want %q", fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldsNative(t *testing.T) {
+	// The last field of a datagram may have no line feed
+	fields, _, complete, err := parseFields([]byte("PRIORITY=6\n"+binaryField("DATA", "a\nb")+"MESSAGE=hello"), false)
+	if err != nil || !complete {
+		t.Fatalf("parseFields = %v, %v", complete, err)
+	}
+	want := map[string]string{"PRIORITY": "6", "DATA": "a\nb", "MESSAGE": "hello"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %q, want %q", fields, want)
+	}
+
+	if _, _, _, err := parseFields([]byte(binaryField("DATA", "abc")[:10]), false); err == nil {
+		t.Error("truncated binary field succeeded, want an error")
+	}
+}
+
+// channelWriter sends the written entries to the channel
+type channelWriter chan entry.Entry
+
+func (w channelWriter) Write(entries []entry.Entry) error {
+	for _, e := range entries {
+		w <- e
+	}
+	return nil
+}
+
+func TestSocketRoundTrip(t *testing.T) {
+	written := make(channelWriter, 10)
+	pl := pipeline.NewPipeline(100, written, func(err error) { t.Error(err) })
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	in, err := NewSocketInput(config.Listener{Name: "journal", Protocol: config.ListenerJournalSocket,
+		Address: path, Stream: "default"}, pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pl.Run(ctx)
+	go in.Run(ctx)
+
+	// Unconnected socket, as the journal clients send to the path
+	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fd)
+	send := func(data, oob []byte) {
+		t.Helper()
+		for i := 0; ; i++ {
+			err := syscall.Sendmsg(fd, data, oob, &syscall.SockaddrUnix{Name: path}, 0)
+			if err == nil {
+				return
+			}
+			if i == 50 {
+				t.Fatalf("failed to send: %v", err)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// Datagram with a trusted field sent by the client
+	send([]byte("PRIORITY=3\n_PID=1\nMESSAGE=small\n"), nil)
+
+	// Large entry passed by a memfd-like file whose offset is at the end
+	large := strings.Repeat("x", maxDatagramSize+1)
+	f, err := os.CreateTemp(t.TempDir(), "memfd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(binaryField("MESSAGE", large) + "PRIORITY=6\n"); err != nil {
+		t.Fatal(err)
+	}
+	send(nil, syscall.UnixRights(int(f.Fd())))
+
+	for _, want := range []struct {
+		message string
+		level   string
+	}{{"small", "error"}, {large, "info"}} {
+		select {
+		case e := <-written:
+			if e.Message != want.message || e.Level != want.level {
+				t.Errorf("entry = %.20q (%s), want %.20q (%s)", e.Message, e.Level, want.message, want.level)
+			}
+			if pid, _ := e.Field("_PID"); pid != strconv.Itoa(os.Getpid()) {
+				t.Errorf("_PID = %s, want the pid of the sender", pid)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("entry %.20q was not written", want.message)
+		}
+	}
+}
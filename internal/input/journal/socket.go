@@ -0,0 +1,299 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package journal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/input"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/parser"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+)
+
+// Maximum datagram size (larger entries are passed by a memfd)
+const maxDatagramSize = 256 * 1024
+
+// Maximum size of an entry passed by a memfd
+const maxMemfdSize = 64 * 1024 * 1024
+
+// Socket read timeout for checking termination
+const readTimeout = time.Second
+
+// SocketInput is a journal native protocol input structure
+type SocketInput struct {
+	listener config.Listener
+	pipeline *pipeline.Pipeline
+	parser   *parser.Chain
+}
+
+// NewSocketInput create journal native protocol input.
+//
+// Parameters:
+//   - listener: listener configuration (Address is the socket path)
+//   - pl: write pipeline
+//
+// Returns:
+//   - *SocketInput: journal native protocol input
+//   - error: success(nil), failure(error)
+func NewSocketInput(listener config.Listener, pl *pipeline.Pipeline) (*SocketInput, error) {
+	chain, err := parser.NewChain(&listener)
+	if err != nil {
+		return nil, err
+	}
+	return &SocketInput{listener: listener, pipeline: pl, parser: chain}, nil
+}
+
+// Run receives datagrams until the context is cancelled. As journald
+// does, trusted fields (starting with '_') sent by clients are ignored
+// and _PID, _UID, _GID, _COMM and _SYSTEMD_UNIT are added from the
+// credentials of the sender.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (in *SocketInput) Run(ctx context.Context) {
+	if err := in.run(ctx); err != nil {
+		logger.Log.LogError("journal socket listener stopped (name:%s): %s", in.listener.Name, err)
+	}
+}
+
+// run receives datagrams until the context is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (in *SocketInput) run(ctx context.Context) error {
+	path := in.listener.Address
+
+	// Remove the socket left by the previous run
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to listen: %s", err)
+	}
+	defer os.Remove(path)
+	defer conn.Close()
+
+	// Any local process may write to the socket
+	if err := os.Chmod(path, 0666); err != nil {
+		return fmt.Errorf("failed to change socket mode: %s", err)
+	}
+	if err := enablePassCred(conn); err != nil {
+		return err
+	}
+	conn.SetReadBuffer(8 * 1024 * 1024)
+	logger.Log.LogInfo("Start journal socket listener (name:%s, path:%s)", in.listener.Name, path)
+
+	buf := make([]byte, maxDatagramSize)
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofUcred)+syscall.CmsgSpace(4*16))
+	for ctx.Err() == nil {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return fmt.Errorf("failed to read datagram: %s", err)
+		}
+
+		data, cred, err := readControlMessages(buf[:n], oob[:oobn])
+		if err != nil {
+			logger.Log.LogWarn("invalid journal datagram (name:%s): %s", in.listener.Name, err)
+			continue
+		}
+		in.handleDatagram(ctx, data, cred)
+	}
+	return nil
+}
+
+// handleDatagram converts a datagram to an entry and submits it.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - data: native protocol datagram
+//   - cred: credentials of the sender (nil if not received)
+func (in *SocketInput) handleDatagram(ctx context.Context, data []byte, cred *syscall.Ucred) {
+	fields, _, _, err := parseFields(data, false)
+	if err != nil {
+		logger.Log.LogWarn("invalid journal datagram (name:%s): %s", in.listener.Name, err)
+		return
+	}
+
+	for key := range fields {
+		if strings.HasPrefix(key, "_") {
+			delete(fields, key)
+		}
+	}
+	if cred != nil {
+		addTrustedFields(fields, cred)
+	}
+
+	e, ok := newEntry(&in.listener, fields, time.Now().UTC())
+	if !ok {
+		return
+	}
+	entries := []entry.Entry{e}
+	entries = in.parser.Process(entries)
+	if err := input.Submit(ctx, in.pipeline, entries); err != nil {
+		logger.Log.LogWarn("failed to submit journal entry (name:%s): %s", in.listener.Name, err)
+	}
+}
+
+// enablePassCred enables receiving the credentials of the senders.
+//
+// Parameters:
+//   - conn: unix datagram socket
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func enablePassCred(conn *net.UnixConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("failed to get raw connection: %s", err)
+	}
+
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enable SO_PASSCRED: %s", err)
+	}
+	return nil
+}
+
+// readControlMessages reads the credentials and the passed file
+// descriptors of a datagram. If the datagram is empty and a memfd is
+// passed, the entry is read from the memfd.
+//
+// Parameters:
+//   - data: datagram
+//   - oob: control messages
+//
+// Returns:
+//   - []byte: entry data
+//   - *syscall.Ucred: credentials of the sender (nil if not received)
+//   - error: success(nil), failure(error)
+func readControlMessages(data, oob []byte) ([]byte, *syscall.Ucred, error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse control message: %s", err)
+	}
+
+	var cred *syscall.Ucred
+	var fds []int
+	for _, msg := range msgs {
+		if c, err := syscall.ParseUnixCredentials(&msg); err == nil {
+			cred = c
+		} else if rights, err := syscall.ParseUnixRights(&msg); err == nil {
+			fds = append(fds, rights...)
+		}
+	}
+
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "memfd")
+		if i == 0 && len(data) == 0 {
+			data, err = readMemfd(f)
+		}
+		f.Close()
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read memfd: %s", err)
+	}
+	return data, cred, nil
+}
+
+// readMemfd reads the entry of a memfd from the beginning. The sender
+// writes the entry before passing the memfd, so the file offset is at
+// the end.
+//
+// Parameters:
+//   - f: memfd
+//
+// Returns:
+//   - []byte: entry data
+//   - error: success(nil), failure(error)
+func readMemfd(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxMemfdSize {
+		return nil, fmt.Errorf("memfd entry too large (max: %d)", maxMemfdSize)
+	}
+
+	data := make([]byte, info.Size())
+	if _, err := io.ReadFull(io.NewSectionReader(f, 0, info.Size()), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// addTrustedFields adds the fields of the sender process.
+//
+// Parameters:
+//   - fields: journal fields
+//   - cred: credentials of the sender
+func addTrustedFields(fields map[string]string, cred *syscall.Ucred) {
+	pid := strconv.Itoa(int(cred.Pid))
+	fields["_PID"] = pid
+	fields["_UID"] = strconv.Itoa(int(cred.Uid))
+	fields["_GID"] = strconv.Itoa(int(cred.Gid))
+
+	if comm, err := os.ReadFile("/proc/" + pid + "/comm"); err == nil {
+		fields["_COMM"] = strings.TrimSpace(string(comm))
+	}
+
+	// The unit is the last .service or .scope component of the cgroup path
+	// (e.g. 0::/system.slice/nginx.service)
+	f, err := os.Open("/proc/" + pid + "/cgroup")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		path := line[strings.LastIndex(line, ":")+1:]
+		for _, part := range strings.Split(path, "/") {
+			if strings.HasSuffix(part, ".service") || strings.HasSuffix(part, ".scope") {
+				fields["_SYSTEMD_UNIT"] = part
+			}
+		}
+	}
+}
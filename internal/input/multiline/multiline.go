@@ -0,0 +1,201 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package multiline assembles lines into multiline entries (e.g. stack
+traces) by the multiline rule of the input.
+*/
+package multiline
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+)
+
+// Continuation patterns of the presets
+var presetPatterns = map[string]string{
+	// java.lang.IllegalStateException: ..., \tat com.example.Foo.bar(Foo.java:10),
+	// \t... 5 more, Caused by: ..., Suppressed: ...
+	config.MultilinePresetJava: `^(([\w$]+\.)+[\w$]*(Exception|Error|Throwable)(:|$)|\s+at\s|\s+\.\.\.\s+\d+\s+more|` +
+		`\s*Caused by:|\s*Suppressed:)`,
+	// Traceback (most recent call last):, indented frames and the exception line
+	config.MultilinePresetPython: `^(\s|Traceback \(most recent call last\):|During handling of the above exception|` +
+		`The above exception was the direct cause|[\w.]+(Error|Exception|Warning|Exit|Interrupt|Iteration)(:|$))`,
+	// Empty lines, goroutine 1 [running]:, main.main(), \t/src/main.go:10 +0x1d, created by ...
+	config.MultilinePresetGo: `^(\s|$|goroutine \d+ \[|[\w./*()\[\]-]+\(.*\)$|created by |\[signal |exit status \d+)`,
+}
+
+// Assembler is a multiline assembler structure
+type Assembler struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	maxLines     int
+	maxBytes     int
+	flushTimeout time.Duration
+	lines        []string
+	size         int
+	lastLine     time.Time
+}
+
+// NewAssembler create multiline assembler. The rule is validated when
+// the configuration is loaded.
+//
+// Parameters:
+//   - rule: multiline rule (nil: no assembly)
+//
+// Returns:
+//   - *Assembler: multiline assembler (nil if rule is nil)
+func NewAssembler(rule *config.Multiline) *Assembler {
+	if rule == nil {
+		return nil
+	}
+
+	a := &Assembler{
+		maxLines:     rule.MaxLines,
+		maxBytes:     rule.MaxBytes,
+		flushTimeout: time.Duration(rule.FlushTimeout) * time.Millisecond,
+	}
+	if a.maxLines == 0 {
+		a.maxLines = config.DefMultilineMaxLines
+	}
+	if a.maxBytes == 0 {
+		a.maxBytes = config.DefMultilineMaxBytes
+	}
+	if a.flushTimeout == 0 {
+		a.flushTimeout = config.DefMultilineFlushTimeout * time.Millisecond
+	}
+
+	continuation := presetPatterns[rule.Preset]
+	if rule.ContinuationPattern != "" {
+		continuation = rule.ContinuationPattern
+	}
+	if continuation != "" {
+		a.continuation = regexp.MustCompile(continuation)
+	}
+	if rule.StartPattern != "" {
+		a.start = regexp.MustCompile(rule.StartPattern)
+	}
+	return a
+}
+
+// Add adds a line. If the line starts a new entry, the previous entry
+// is returned.
+//
+// Parameters:
+//   - line: line (without the line feed)
+//   - now: receive time of the line
+//
+// Returns:
+//   - string: completed entry message
+//   - bool: completed(true), not completed(false)
+func (a *Assembler) Add(line string, now time.Time) (string, bool) {
+	a.lastLine = now
+	if len(a.lines) == 0 {
+		if line != "" {
+			a.append(line)
+		}
+		return "", false
+	}
+
+	if a.isContinuation(line) && len(a.lines) < a.maxLines && a.size+1+len(line) <= a.maxBytes {
+		a.append(line)
+		return "", false
+	}
+
+	message, _ := a.Flush()
+	if line != "" {
+		a.append(line)
+	}
+	return message, true
+}
+
+// Flush returns the pending entry.
+//
+// Returns:
+//   - string: entry message
+//   - bool: pending entry exists(true), no pending entry(false)
+func (a *Assembler) Flush() (string, bool) {
+	if len(a.lines) == 0 {
+		return "", false
+	}
+
+	// Trailing empty lines are not part of the entry
+	n := len(a.lines)
+	for n > 1 && a.lines[n-1] == "" {
+		n--
+	}
+	message := strings.Join(a.lines[:n], "\n")
+	a.lines = a.lines[:0]
+	a.size = 0
+	return message, true
+}
+
+// Pending verify that an entry is being assembled.
+//
+// Returns:
+//   - bool: pending(true), not pending(false)
+func (a *Assembler) Pending() bool {
+	return len(a.lines) > 0
+}
+
+// Expired verify that no line was added for the flush timeout while
+// an entry is being assembled.
+//
+// Parameters:
+//   - now: current time
+//
+// Returns:
+//   - bool: expired(true), not expired(false)
+func (a *Assembler) Expired(now time.Time) bool {
+	return a.Pending() && now.Sub(a.lastLine) >= a.flushTimeout
+}
+
+// FlushTimeout returns the time to wait for the next line.
+//
+// Returns:
+//   - time.Duration: flush timeout
+func (a *Assembler) FlushTimeout() time.Duration {
+	return a.flushTimeout
+}
+
+// isContinuation verify that the line continues the current entry.
+//
+// Parameters:
+//   - line: line
+//
+// Returns:
+//   - bool: continuation(true), start of a new entry(false)
+func (a *Assembler) isContinuation(line string) bool {
+	if a.start != nil && a.start.MatchString(line) {
+		return false
+	}
+	return a.continuation == nil || a.continuation.MatchString(line)
+}
+
+// append appends a line to the current entry.
+//
+// Parameters:
+//   - line: line
+func (a *Assembler) append(line string) {
+	if len(a.lines) > 0 {
+		a.size++
+	}
+	a.lines = append(a.lines, line)
+	a.size += len(line)
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package multiline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+)
+
+// assemble adds the lines and returns the completed entries and the
+// flushed last entry.
+func assemble(a *Assembler, lines []string) []string {
+	now := time.Now()
+	entries := []string{}
+	for _, line := range lines {
+		if message, done := a.Add(line, now); done {
+			entries = append(entries, message)
+		}
+	}
+	if message, ok := a.Flush(); ok {
+		entries = append(entries, message)
+	}
+	return entries
+}
+
+func TestAssemble(t *testing.T) {
+	java := []string{
+		"2024-05-01 12:00:00 ERROR request failed",
+		"java.lang.IllegalStateException: boom",
+		"\tat com.example.Foo.bar(Foo.java:10)",
+		"\t... 5 more",
+		"Caused by: java.io.IOException: closed",
+		"\tat com.example.Io.read(Io.java:3)",
+		"2024-05-01 12:00:01 INFO recovered",
+	}
+	python := []string{
+		"Traceback (most recent call last):",
+		`  File "app.py", line 3, in <module>`,
+		"    main()",
+		"ValueError: bad value",
+		"INFO next",
+	}
+	golang := []string{
+		"panic: runtime error: index out of range",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/src/main.go:10 +0x1d",
+		"exit status 2",
+		"",
+		"",
+		"next line",
+	}
+
+	tests := []struct {
+		name  string
+		rule  config.Multiline
+		lines []string
+		want  []string
+	}{
+		{"java", config.Multiline{Preset: config.MultilinePresetJava}, java,
+			[]string{strings.Join(java[:6], "\n"), java[6]}},
+		{"python", config.Multiline{Preset: config.MultilinePresetPython}, python,
+			[]string{strings.Join(python[:4], "\n"), python[4]}},
+		{"go with trailing empty lines", config.Multiline{Preset: config.MultilinePresetGo}, golang,
+			[]string{strings.Join(golang[:6], "\n"), golang[8]}},
+		{"start pattern", config.Multiline{StartPattern: `^\d{4}-`}, []string{"2024 a", "x", "2024-01 b", "y", "z"},
+			[]string{"2024 a\nx", "2024-01 b\ny\nz"}},
+		{"start and continuation patterns", config.Multiline{StartPattern: `^\[`, ContinuationPattern: `^\s`},
+			[]string{"[1] a", " b", "c", "[2] d"}, []string{"[1] a\n b", "c", "[2] d"}},
+		{"max lines", config.Multiline{ContinuationPattern: `^\s`, MaxLines: 2},
+			[]string{"a", " 1", " 2", " 3"}, []string{"a\n 1", " 2\n 3"}},
+		{"max bytes", config.Multiline{ContinuationPattern: `^\s`, MaxBytes: 6},
+			[]string{"ab", " cd", " ef"}, []string{"ab\n cd", " ef"}},
+		{"leading empty lines are dropped", config.Multiline{ContinuationPattern: `^\s`},
+			[]string{"", "a", " b"}, []string{"a\n b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assemble(NewAssembler(&tt.rule), tt.lines)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpired(t *testing.T) {
+	if NewAssembler(nil) != nil {
+		t.Fatal("NewAssembler(nil) != nil")
+	}
+
+	a := NewAssembler(&config.Multiline{Preset: config.MultilinePresetJava, FlushTimeout: 100})
+	now := time.Now()
+	if a.Expired(now.Add(time.Hour)) {
+		t.Error("expired without a pending entry")
+	}
+	a.Add("first", now)
+	if a.Expired(now.Add(99 * time.Millisecond)) {
+		t.Error("expired before the flush timeout")
+	}
+	if !a.Expired(now.Add(100 * time.Millisecond)) {
+		t.Error("not expired after the flush timeout")
+	}
+	if message, ok := a.Flush(); !ok || message != "first" || a.Pending() {
+		t.Errorf("Flush = %q, %v, pending = %v", message, ok, a.Pending())
+	}
+}
@@ -0,0 +1,511 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package tail provides the file tail input.
+
+The files matching the glob patterns are followed line by line. Files
+are identified by device and inode, so a file renamed by rotation is
+read to the end through the open descriptor while the new file is read
+from the beginning. A file whose size becomes smaller than the read
+offset (e.g. copytruncate) is read again from the beginning.
+
+The offset of each file up to which the entries have been written to
+the store is saved in a checkpoint file, so that the input resumes there
+after a restart. Entries read but not yet written at a stop or a crash
+are read again (at-least-once delivery), as are the lines of an entry
+being assembled by the multiline rule.
+*/
+package tail
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/input"
+	"github.com/hoon-kr/log_manager/internal/input/multiline"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/parser"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+	"github.com/hoon-kr/log_manager/pkg/utils/file"
+)
+
+// Interval for checking the files
+const pollInterval = 500 * time.Millisecond
+
+// Time to keep reading a file that no longer matches the patterns
+// (e.g. renamed by rotation) after its last data
+const rotateWait = 5 * time.Second
+
+// Read buffer size and maximum line size (longer lines are split)
+const (
+	readBufferSize = 64 * 1024
+	maxLineSize    = 1024 * 1024
+)
+
+// Maximum number of lines submitted to the pipeline at once
+const maxBatchLines = 100
+
+// Checkpoint directory name under the data directory (not a valid stream name)
+const checkpointDirName = ".checkpoints"
+
+// fileKey identifies a file regardless of its path
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// checkpoint is a saved read offset of a file
+type checkpoint struct {
+	Path   string `json:"path"`
+	Dev    uint64 `json:"dev"`
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// tailedFile is a followed file
+type tailedFile struct {
+	path         string
+	file         *os.File
+	offset       int64 // Offset of the next unread line
+	lastData     time.Time
+	assembler    *multiline.Assembler
+	pendingStart int64     // Offset of the first line of the assembling entry
+	pendingTime  time.Time // Read time of the first line of the assembling entry
+
+	mu         sync.Mutex // Protects the fields below updated by the pipeline
+	written    int64      // Offset up to which the entries are written to the store
+	truncation int        // Number of truncations, which invalidate earlier acks
+}
+
+// Input is a file tail input structure
+type Input struct {
+	listener       config.Listener
+	pipeline       *pipeline.Pipeline
+	parser         *parser.Chain
+	checkpointPath string
+	files          map[fileKey]*tailedFile
+	checkpoints    map[fileKey]checkpoint // Loaded checkpoints of the files not yet opened
+	resumed        bool                   // Whether a checkpoint existed at start
+	dirty          bool                   // Whether the offsets changed since the last save
+	acked          atomic.Bool            // Whether written offsets changed since the last save
+}
+
+// NewInput create file tail input.
+//
+// Parameters:
+//   - listener: listener configuration
+//   - pl: write pipeline
+//
+// Returns:
+//   - *Input: file tail input
+//   - error: success(nil), failure(error)
+func NewInput(listener config.Listener, pl *pipeline.Pipeline) (*Input, error) {
+	chain, err := parser.NewChain(&listener)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Input{
+		listener:       listener,
+		pipeline:       pl,
+		parser:         chain,
+		checkpointPath: filepath.Join(config.Conf.DataDirPath, checkpointDirName, listener.Name+".json"),
+		files:          make(map[fileKey]*tailedFile),
+		checkpoints:    make(map[fileKey]checkpoint),
+	}, nil
+}
+
+// Run follows the files until the context is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (in *Input) Run(ctx context.Context) {
+	logger.Log.LogInfo("Start tail input (name:%s, paths:%v)", in.listener.Name, in.listener.Paths)
+	if err := in.loadCheckpoint(); err != nil {
+		logger.Log.LogWarn("failed to load tail checkpoint (name:%s): %s", in.listener.Name, err)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	first := true
+	for {
+		in.poll(ctx, first)
+		first = false
+		in.saveCheckpoint()
+
+		select {
+		case <-ctx.Done():
+			for key, tf := range in.files {
+				tf.file.Close()
+				delete(in.files, key)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll opens the new files matching the patterns and reads the
+// appended lines of the followed files.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - first: whether it is the first poll after start
+func (in *Input) poll(ctx context.Context, first bool) {
+	seen := make(map[fileKey]bool)
+	for _, pattern := range in.listener.Paths {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			key := keyOf(info)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if tf, exists := in.files[key]; exists {
+				// Renamed within the patterns
+				tf.path = path
+				continue
+			}
+			if err := in.openFile(path, key, info.Size(), first); err != nil {
+				logger.Log.LogWarn("failed to open tail file (name:%s): %s", in.listener.Name, err)
+			}
+		}
+	}
+	if first {
+		// Checkpoints of the files that no longer exist
+		in.checkpoints = nil
+	}
+
+	for key, tf := range in.files {
+		if ctx.Err() != nil {
+			return
+		}
+
+		eof, err := in.readFile(ctx, tf)
+		if err != nil {
+			logger.Log.LogWarn("failed to read tail file (name:%s, path:%s): %s", in.listener.Name, tf.path, err)
+		}
+		if err != nil || (eof && !seen[key] && time.Since(tf.lastData) >= rotateWait) {
+			// The assembling entry cannot be read again
+			in.flushPending(ctx, tf)
+			logger.Log.LogInfo("Stop following file (name:%s, path:%s)", in.listener.Name, tf.path)
+			tf.file.Close()
+			delete(in.files, key)
+			in.dirty = true
+		} else if tf.assembler != nil && tf.assembler.Expired(time.Now()) {
+			in.flushPending(ctx, tf)
+		}
+	}
+}
+
+// openFile starts following a file. The read offset is the checkpoint
+// of the file if it exists. Otherwise the file is read from the end if
+// it was found at the first start and ReadFromHead is not set, or from
+// the beginning.
+//
+// Parameters:
+//   - path: file path
+//   - key: file key
+//   - size: file size
+//   - first: whether it is the first poll after start
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (in *Input) openFile(path string, key fileKey, size int64, first bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %s", err)
+	}
+
+	var offset int64
+	if cp, exists := in.checkpoints[key]; exists && cp.Offset <= size {
+		offset = cp.Offset
+	} else if first && !in.resumed && !in.listener.ReadFromHead {
+		offset = size
+	}
+	delete(in.checkpoints, key)
+
+	in.files[key] = &tailedFile{
+		path:      path,
+		file:      f,
+		offset:    offset,
+		lastData:  time.Now(),
+		assembler: multiline.NewAssembler(in.listener.Multiline),
+		written:   offset,
+	}
+	in.dirty = true
+	logger.Log.LogInfo("Start following file (name:%s, path:%s, offset:%d)", in.listener.Name, path, offset)
+	return nil
+}
+
+// readFile reads the complete lines appended after the read offset and
+// submits them to the pipeline. An incomplete last line is read again
+// at the next poll. If the input has a multiline rule, lines are
+// assembled into entries and the assembling entry is kept pending.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - tf: followed file
+//
+// Returns:
+//   - bool: read to the end(true), not read to the end(false)
+//   - error: success(nil), failure(error)
+func (in *Input) readFile(ctx context.Context, tf *tailedFile) (bool, error) {
+	info, err := tf.file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat file: %s", err)
+	}
+
+	size := info.Size()
+	if size < tf.offset {
+		logger.Log.LogInfo("File truncated (name:%s, path:%s)", in.listener.Name, tf.path)
+		in.flushPending(ctx, tf)
+		tf.offset = 0
+		tf.mu.Lock()
+		tf.truncation++
+		tf.written = 0
+		tf.mu.Unlock()
+		in.dirty = true
+	}
+	if size == tf.offset {
+		return true, nil
+	}
+	tf.lastData = time.Now()
+
+	reader := bufio.NewReaderSize(io.NewSectionReader(tf.file, tf.offset, size-tf.offset), readBufferSize)
+	entries := make([]entry.Entry, 0, maxBatchLines)
+	consumed := tf.offset
+	line := []byte{}
+
+	flush := func() error {
+		if len(entries) == 0 && consumed == tf.offset {
+			return nil
+		}
+
+		// The lines of the assembling entry are not submitted yet
+		written := consumed
+		if tf.assembler != nil && tf.assembler.Pending() {
+			written = tf.pendingStart
+		}
+		entries = in.parser.Process(entries)
+		if err := input.SubmitWithAck(ctx, in.pipeline, entries, in.ack(tf, written)); err != nil {
+			return err
+		}
+		entries = entries[:0]
+		tf.offset = consumed
+		return nil
+	}
+
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) && len(line) < maxLineSize {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return false, fmt.Errorf("failed to read file: %s", err)
+		}
+
+		lineStart := consumed
+		consumed += int64(len(line))
+		now := time.Now().UTC()
+		text := input.TrimLineFeed(line)
+		line = line[:0]
+
+		if tf.assembler == nil {
+			if text != "" {
+				entries = append(entries, in.newEntry(tf.path, text, now))
+			}
+		} else {
+			wasPending := tf.assembler.Pending()
+			message, done := tf.assembler.Add(text, now)
+			if done {
+				entries = append(entries, in.newEntry(tf.path, message, tf.pendingTime))
+			}
+			if (!wasPending || done) && tf.assembler.Pending() {
+				tf.pendingStart = lineStart
+				tf.pendingTime = now
+			}
+		}
+
+		if len(entries) == maxBatchLines {
+			if err := flush(); err != nil {
+				return false, nil
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return false, nil
+	}
+	return consumed+int64(len(line)) == size, nil
+}
+
+// flushPending submits the assembling entry of the file.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - tf: followed file
+func (in *Input) flushPending(ctx context.Context, tf *tailedFile) {
+	if tf.assembler == nil {
+		return
+	}
+	message, ok := tf.assembler.Flush()
+	if !ok {
+		return
+	}
+
+	entries := []entry.Entry{in.newEntry(tf.path, message, tf.pendingTime)}
+	entries = in.parser.Process(entries)
+	if err := input.SubmitWithAck(ctx, in.pipeline, entries, in.ack(tf, tf.offset)); err != nil {
+		logger.Log.LogWarn("failed to submit tail entry (name:%s, path:%s): %s", in.listener.Name, tf.path, err)
+	}
+}
+
+// ack returns the callback called by the pipeline after the submitted
+// entries are written, which advances the checkpoint offset of the file.
+//
+// Parameters:
+//   - tf: followed file
+//   - offset: offset up to which the entries are written by the submission
+//
+// Returns:
+//   - func(): write acknowledgement callback
+func (in *Input) ack(tf *tailedFile, offset int64) func() {
+	tf.mu.Lock()
+	truncation := tf.truncation
+	tf.mu.Unlock()
+
+	return func() {
+		tf.mu.Lock()
+		defer tf.mu.Unlock()
+		if tf.truncation == truncation {
+			tf.written = offset
+			in.acked.Store(true)
+		}
+	}
+}
+
+// newEntry create an entry from a line or assembled lines.
+//
+// Parameters:
+//   - path: file path
+//   - message: line (without the line feed)
+//   - now: read time
+//
+// Returns:
+//   - entry.Entry: log entry
+func (in *Input) newEntry(path, message string, now time.Time) entry.Entry {
+	e := entry.Entry{
+		Time:    now,
+		Level:   entry.LevelInfo,
+		Source:  in.listener.Name,
+		Stream:  in.listener.Stream,
+		Message: message,
+	}
+	e.SetField("path", path)
+	return e
+}
+
+// loadCheckpoint loads the saved read offsets.
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (in *Input) loadCheckpoint() error {
+	data, err := os.ReadFile(in.checkpointPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read checkpoint: %s", err)
+	}
+
+	list := []checkpoint{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse checkpoint: %s", err)
+	}
+	for _, cp := range list {
+		in.checkpoints[fileKey{dev: cp.Dev, ino: cp.Inode}] = cp
+	}
+	in.resumed = true
+	return nil
+}
+
+// saveCheckpoint saves the written offsets of the followed files if they
+// changed since the last save.
+func (in *Input) saveCheckpoint() {
+	if in.acked.Swap(false) {
+		in.dirty = true
+	}
+	if !in.dirty {
+		return
+	}
+
+	list := make([]checkpoint, 0, len(in.files))
+	for key, tf := range in.files {
+		tf.mu.Lock()
+		offset := tf.written
+		tf.mu.Unlock()
+		list = append(list, checkpoint{Path: tf.path, Dev: key.dev, Inode: key.ino, Offset: offset})
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		logger.Log.LogWarn("failed to marshal tail checkpoint (name:%s): %s", in.listener.Name, err)
+		return
+	}
+
+	if err := file.WriteDataToTextFileAtomic(in.checkpointPath, string(data), true); err != nil {
+		logger.Log.LogWarn("failed to save tail checkpoint (name:%s): %s", in.listener.Name, err)
+		return
+	}
+	in.dirty = false
+}
+
+// keyOf returns the key of a file.
+//
+// Parameters:
+//   - info: file information
+//
+// Returns:
+//   - fileKey: file key
+func keyOf(info os.FileInfo) fileKey {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fileKey{dev: uint64(st.Dev), ino: st.Ino}
+	}
+	return fileKey{}
+}
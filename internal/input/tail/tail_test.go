@@ -0,0 +1,115 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package tail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+)
+
+func TestMain(m *testing.M) {
+	for i := range config.Conf.LogSinks {
+		config.Conf.LogSinks[i].Enable = false
+	}
+	logger.Log.InitializeLogger()
+	os.Exit(m.Run())
+}
+
+// gateWriter fails the writes while closed
+type gateWriter struct {
+	open    bool
+	entries []entry.Entry
+}
+
+func (w *gateWriter) Write(entries []entry.Entry) error {
+	if !w.open {
+		return errors.New("store unavailable")
+	}
+	w.entries = append(w.entries, entries...)
+	return nil
+}
+
+// savedOffset returns the checkpoint offset of the file.
+func savedOffset(t *testing.T, in *Input) int64 {
+	t.Helper()
+	in.saveCheckpoint()
+	data, err := os.ReadFile(in.checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := []checkpoint{}
+	if err := json.Unmarshal(data, &list); err != nil || len(list) != 1 {
+		t.Fatalf("checkpoint = %s (%v)", data, err)
+	}
+	return list[0].Offset
+}
+
+func TestCheckpointAfterWrite(t *testing.T) {
+	dir := t.TempDir()
+	config.Conf.DataDirPath = dir
+	path := filepath.Join(dir, "app.log")
+	content := "first\n\nsecond\nthird\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writer := &gateWriter{}
+	pl := pipeline.NewPipeline(100, writer, func(err error) {})
+	in, err := NewInput(config.Listener{Name: "tail", Protocol: config.ListenerTail, Stream: "default",
+		Paths: []string{path}, ReadFromHead: true}, pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Entries read but not written do not advance the checkpoint
+	in.poll(ctx, true)
+	if offset := savedOffset(t, in); offset != 0 {
+		t.Fatalf("offset = %d before the write, want 0", offset)
+	}
+
+	// A failed write does not advance the checkpoint
+	runCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	pl.Run(runCtx)
+	if offset := savedOffset(t, in); offset != 0 {
+		t.Fatalf("offset = %d after a failed write, want 0", offset)
+	}
+
+	// The checkpoint advances once the entries are written
+	writer.open = true
+	if err := os.WriteFile(path, []byte(content+"fourth\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	in.poll(ctx, false)
+	pl.Run(runCtx)
+	if offset := savedOffset(t, in); offset != int64(len(content)+len("fourth\n")) {
+		t.Errorf("offset = %d after the write, want %d", offset, len(content)+len("fourth\n"))
+	}
+	if len(writer.entries) != 1 || writer.entries[0].Message != "fourth" {
+		t.Errorf("written = %+v, want the new line", writer.entries)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package tcp provides the TCP line input. Each newline delimited line
+becomes an entry, or lines are assembled into entries by the multiline
+rule of the input.
+*/
+package tcp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/input"
+	"github.com/hoon-kr/log_manager/internal/input/multiline"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/parser"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+)
+
+// Read buffer size and maximum line size (longer lines are split)
+const (
+	readBufferSize = 64 * 1024
+	maxLineSize    = 1024 * 1024
+)
+
+// Input is a TCP line input structure
+type Input struct {
+	listener config.Listener
+	pipeline *pipeline.Pipeline
+	parser   *parser.Chain
+}
+
+// NewInput create TCP line input.
+//
+// Parameters:
+//   - listener: listener configuration
+//   - pl: write pipeline
+//
+// Returns:
+//   - *Input: TCP line input
+//   - error: success(nil), failure(error)
+func NewInput(listener config.Listener, pl *pipeline.Pipeline) (*Input, error) {
+	chain, err := parser.NewChain(&listener)
+	if err != nil {
+		return nil, err
+	}
+	return &Input{listener: listener, pipeline: pl, parser: chain}, nil
+}
+
+// Run receives lines until the context is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (in *Input) Run(ctx context.Context) {
+	logger.Log.LogInfo("Start TCP listener (name:%s, address:%s)", in.listener.Name, in.listener.Address)
+	if err := input.ServeTCP(ctx, in.listener.Address, in.serveConn); err != nil {
+		logger.Log.LogError("TCP listener stopped (name:%s): %s", in.listener.Name, err)
+	}
+}
+
+// serveConn reads lines from a connection. While an entry is being
+// assembled, the read waits up to the flush timeout of the multiline
+// rule before the entry is submitted.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - conn: client connection
+func (in *Input) serveConn(ctx context.Context, conn net.Conn) {
+	remote := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	assembler := multiline.NewAssembler(in.listener.Multiline)
+	var pendingTime time.Time
+	submit := func(message string, t time.Time) {
+		e := entry.Entry{
+			Time:    t,
+			Level:   entry.LevelInfo,
+			Source:  in.listener.Name,
+			Stream:  in.listener.Stream,
+			Message: message,
+		}
+		e.SetField("remote", remote)
+		entries := []entry.Entry{e}
+		entries = in.parser.Process(entries)
+		if err := input.Submit(ctx, in.pipeline, entries); err != nil {
+			logger.Log.LogWarn("failed to submit TCP entry (name:%s): %s", in.listener.Name, err)
+		}
+	}
+	// The assembling entry is submitted when the connection is closed
+	defer func() {
+		if assembler != nil {
+			if message, ok := assembler.Flush(); ok {
+				submit(message, pendingTime)
+			}
+		}
+	}()
+
+	reader := bufio.NewReaderSize(conn, readBufferSize)
+	line := []byte{}
+	for {
+		if assembler != nil && assembler.Pending() {
+			conn.SetReadDeadline(time.Now().Add(assembler.FlushTimeout()))
+		} else {
+			conn.SetReadDeadline(time.Time{})
+		}
+
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) && len(line) < maxLineSize {
+			continue
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if message, ok := assembler.Flush(); ok {
+				submit(message, pendingTime)
+			}
+			continue
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			// The last line without the line feed is still a line
+			if len(line) == 0 {
+				return
+			}
+		}
+
+		now := time.Now().UTC()
+		text := input.TrimLineFeed(line)
+		line = line[:0]
+
+		if assembler == nil {
+			if text != "" {
+				submit(text, now)
+			}
+		} else {
+			wasPending := assembler.Pending()
+			message, done := assembler.Add(text, now)
+			if done {
+				submit(message, pendingTime)
+			}
+			if (!wasPending || done) && assembler.Pending() {
+				pendingTime = now
+			}
+		}
+	}
+}
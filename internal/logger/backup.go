@@ -0,0 +1,164 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package logger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+)
+
+// Backup file management period
+const backupCheckInterval = 10 * time.Second
+
+// Time format of the lumberjack backup file name
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// backupFile is a rotated backup log file information structure
+type backupFile struct {
+	path      string
+	timestamp time.Time
+}
+
+// ManageBackupLogFiles compresses rotated backup log files with the
+// configured algorithm and removes backups that exceed the retention
+// policy. Lumberjack only supports gzip at the default level, so the
+// compression and retention of backups are handled here instead.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (s *SyncLogger) ManageBackupLogFiles(ctx context.Context) {
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.manageBackupLogFiles()
+			return
+		case <-ticker.C:
+			s.manageBackupLogFiles()
+		}
+	}
+}
+
+// manageBackupLogFiles compress and clean up backups of all log files.
+func (s *SyncLogger) manageBackupLogFiles() {
+	for _, logFilePath := range s.logFilePaths() {
+		backups, err := s.findBackupLogFiles(logFilePath)
+		if err != nil {
+			continue
+		}
+
+		backups = s.removeOldBackupLogFiles(backups)
+
+		if !config.Conf.CompBakLogFile {
+			continue
+		}
+		for _, backup := range backups {
+			if compress.HasExtension(backup.path) {
+				continue
+			}
+			_, err := compress.CompressFile(backup.path, compress.Algorithm(config.Conf.CompBakLogFileAlgo),
+				config.Conf.CompBakLogFileLevel)
+			if err != nil {
+				s.LogWarn("failed to compress backup log file (%s): %s", backup.path, err)
+			}
+		}
+	}
+}
+
+// findBackupLogFiles find the rotated backup files of the log file.
+//
+// Parameters:
+//   - logFilePath: log file path
+//
+// Returns:
+//   - []backupFile: backup files (newest first)
+//   - error: success(nil), failure(error)
+func (s *SyncLogger) findBackupLogFiles(logFilePath string) ([]backupFile, error) {
+	dir := filepath.Dir(logFilePath)
+	ext := filepath.Ext(logFilePath)
+	prefix := strings.TrimSuffix(filepath.Base(logFilePath), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []backupFile{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		// Backup file name: <name>-<timestamp><ext>[.gz|.zst]
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, prefix)
+		stamp = strings.TrimSuffix(stamp, compress.Extension(compress.Gzip))
+		stamp = strings.TrimSuffix(stamp, compress.Extension(compress.Zstd))
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		timestamp, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext))
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, backupFile{path: filepath.Join(dir, name), timestamp: timestamp})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+
+	return backups, nil
+}
+
+// removeOldBackupLogFiles remove backups exceeding the maximum number
+// of backups or the number of days to keep.
+//
+// Parameters:
+//   - backups: backup files (newest first)
+//
+// Returns:
+//   - []backupFile: remaining backup files
+func (s *SyncLogger) removeOldBackupLogFiles(backups []backupFile) []backupFile {
+	cutoff := time.Now().Add(-time.Duration(config.Conf.MaxLogFileAge) * 24 * time.Hour)
+
+	remains := []backupFile{}
+	for i, backup := range backups {
+		if i < config.Conf.MaxLogFileBackup && backup.timestamp.After(cutoff) {
+			remains = append(remains, backup)
+			continue
+		}
+		if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) {
+			s.LogWarn("failed to remove backup log file (%s): %s", backup.path, err)
+		}
+	}
+
+	return remains
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtBufferPool = buffer.NewPool()
+
+// logfmtEncoder is a zap encoder that writes key=value pairs (logfmt)
+type logfmtEncoder struct {
+	*zapcore.MapObjectEncoder
+	timeLayout   string
+	encodeCaller func(caller zapcore.EntryCaller) string
+}
+
+// newLogfmtEncoder create logfmt encoder.
+//
+// Parameters:
+//   - timeLayout: time layout of the time field
+//   - encodeCaller: caller message function
+//
+// Returns:
+//   - zapcore.Encoder: logfmt encoder
+func newLogfmtEncoder(timeLayout string, encodeCaller func(caller zapcore.EntryCaller) string) zapcore.Encoder {
+	return &logfmtEncoder{
+		MapObjectEncoder: zapcore.NewMapObjectEncoder(),
+		timeLayout:       timeLayout,
+		encodeCaller:     encodeCaller,
+	}
+}
+
+// Clone copies the encoder, ensuring that adding fields to the copy
+// doesn't affect the original.
+//
+// Returns:
+//   - zapcore.Encoder: copied encoder
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := newLogfmtEncoder(e.timeLayout, e.encodeCaller).(*logfmtEncoder)
+	for key, value := range e.Fields {
+		clone.Fields[key] = value
+	}
+	return clone
+}
+
+// EncodeEntry encodes an entry and fields to a logfmt line.
+//
+// Parameters:
+//   - ent: log entry
+//   - fields: log fields
+//
+// Returns:
+//   - *buffer.Buffer: encoded line
+//   - error: success(nil), failure(error)
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf := logfmtBufferPool.Get()
+
+	e.appendPair(buf, "time", ent.Time.Format(e.timeLayout))
+	e.appendPair(buf, "level", ent.Level.String())
+	if ent.Caller.Defined {
+		e.appendPair(buf, "caller", e.encodeCaller(ent.Caller))
+	}
+	e.appendPair(buf, "msg", ent.Message)
+
+	// Context fields and entry fields in key order
+	enc := zapcore.NewMapObjectEncoder()
+	for key, value := range e.Fields {
+		enc.Fields[key] = value
+	}
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	keys := make([]string, 0, len(enc.Fields))
+	for key := range enc.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		e.appendPair(buf, key, e.formatValue(enc.Fields[key]))
+	}
+
+	if ent.Stack != "" {
+		e.appendPair(buf, "stacktrace", ent.Stack)
+	}
+	buf.AppendString(zapcore.DefaultLineEnding)
+
+	return buf, nil
+}
+
+// appendPair append key=value pair to buffer.
+//
+// Parameters:
+//   - buf: line buffer
+//   - key: field key
+//   - value: field value
+func (e *logfmtEncoder) appendPair(buf *buffer.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.AppendByte(' ')
+	}
+	buf.AppendString(key)
+	buf.AppendByte('=')
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+		buf.AppendString(strconv.Quote(value))
+		return
+	}
+	buf.AppendString(value)
+}
+
+// formatValue convert field value to string.
+//
+// Parameters:
+//   - value: field value
+//
+// Returns:
+//   - string: field value string
+func (e *logfmtEncoder) formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
@@ -20,8 +20,13 @@ Package logger process log.
 package logger
 
 import (
+	"context"
 	"fmt"
+	"log/syslog"
+	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/hoon-kr/log_manager/config"
 	"go.uber.org/zap"
@@ -33,6 +38,7 @@ import (
 type Logger interface {
 	InitializeLogger()
 	FinalizeLogger()
+	ManageBackupLogFiles(ctx context.Context)
 	LogInfo(format string, args ...interface{})
 	LogWarn(format string, args ...interface{})
 	LogError(format string, args ...interface{})
@@ -43,61 +49,52 @@ type Logger interface {
 
 // SyncLogger is a log processing information structure
 type SyncLogger struct {
-	consoleFileLogger *lumberjack.Logger
-	jsonFileLogger    *lumberjack.Logger
-	zapLogger         *zap.Logger
+	fileLoggers  []*lumberjack.Logger
+	syslogWriter *syslog.Writer
+	zapLogger    *zap.Logger
+	suppressed   atomic.Int64 // Messages dropped by sampling since the last report
+	lastReport   atomic.Int64 // Time of the last report of the dropped messages (unix nano)
 }
 
+// Sampling period of the module log
+const samplingTick = time.Second
+
 var Log Logger = &SyncLogger{}
 
-// InitializeLogger initialize console logger and json logger.
+// Standard output streams captured before the server detaches them in normal mode
+var (
+	stdoutFile = os.Stdout
+	stderrFile = os.Stderr
+)
+
+// InitializeLogger initialize the logger with the configured output sinks.
 func (s *SyncLogger) InitializeLogger() {
-	// Set lumberjack - automatically manages log files
-	s.consoleFileLogger = s.newLumberJackLogger(config.ConsoleLogFilePath)
-	s.jsonFileLogger = s.newLumberJackLogger(config.JsonLogFilePath)
+	cores := []zapcore.Core{}
+	for _, sink := range config.Conf.LogSinks {
+		if !sink.Enable {
+			continue
+		}
 
-	// Encoder configuration
-	consoleEncoderConfig := zapcore.EncoderConfig{
-		MessageKey:       "msg",
-		LevelKey:         "level",
-		TimeKey:          "time",
-		CallerKey:        "caller",
-		FunctionKey:      zapcore.OmitKey,
-		StacktraceKey:    "stacktrace",
-		LineEnding:       zapcore.DefaultLineEnding,
-		EncodeLevel:      s.capitalLevelEncoder,
-		EncodeTime:       zapcore.TimeEncoderOfLayout("[2006-01-02 15:04:05]"),
-		EncodeDuration:   zapcore.SecondsDurationEncoder,
-		EncodeCaller:     s.wrapShortCallerEncoder(true),
-		ConsoleSeparator: " ",
-	}
-	jsonEncoderConfig := zapcore.EncoderConfig{
-		MessageKey:     "msg",
-		LevelKey:       "level",
-		TimeKey:        "time",
-		CallerKey:      "caller",
-		FunctionKey:    zapcore.OmitKey,
-		StacktraceKey:  "stacktrace",
-		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    zapcore.CapitalLevelEncoder,
-		EncodeTime:     zapcore.TimeEncoderOfLayout("2006-01-02 15:04:05"),
-		EncodeDuration: zapcore.SecondsDurationEncoder,
-		EncodeCaller:   s.wrapShortCallerEncoder(false),
+		core, err := s.newSinkCore(sink)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARNING] failed to create log sink (%s): %s\n", sink.Name, err)
+			continue
+		}
+		cores = append(cores, core)
 	}
 
-	// Define console and JSON encoders
-	consoleEncoder := zapcore.NewConsoleEncoder(consoleEncoderConfig)
-	jsonEncoder := zapcore.NewJSONEncoder(jsonEncoderConfig)
-
-	// Setup core log writers for console and JSON outputs
-	consoleWriter := zapcore.AddSync(s.consoleFileLogger)
-	jsonWriter := zapcore.AddSync(s.jsonFileLogger)
-
-	// Creating core
-	core := zapcore.NewTee(
-		zapcore.NewCore(consoleEncoder, consoleWriter, zapcore.InfoLevel),
-		zapcore.NewCore(jsonEncoder, jsonWriter, zapcore.InfoLevel),
-	)
+	// Messages with the same level and message over LogSamplingFirst per
+	// second are sampled, and the number of dropped messages is reported
+	core := zapcore.NewTee(cores...)
+	if config.Conf.LogSamplingFirst > 0 {
+		s.lastReport.Store(time.Now().UnixNano())
+		core = zapcore.NewSamplerWithOptions(core, samplingTick, config.Conf.LogSamplingFirst,
+			config.Conf.LogSamplingThereafter, zapcore.SamplerHook(func(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+				if dec&zapcore.LogDropped != 0 {
+					s.suppressed.Add(1)
+				}
+			}))
+	}
 
 	// Creating logger with core
 	s.zapLogger = zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1),
@@ -107,14 +104,108 @@ func (s *SyncLogger) InitializeLogger() {
 // FinalizeLogger At the end of the program, all logs remaining
 // in the buffer are written to the file, and open log files are closed.
 func (s *SyncLogger) FinalizeLogger() {
+	// Report the messages dropped by sampling
+	s.lastReport.Store(0)
+	s.reportSuppressed()
 	// Flush any buffered log entries
 	s.zapLogger.Sync()
 	// Close log files
-	s.consoleFileLogger.Close()
-	s.jsonFileLogger.Close()
+	for _, fileLogger := range s.fileLoggers {
+		fileLogger.Close()
+	}
+	s.fileLoggers = nil
+	// Close syslog connection
+	if s.syslogWriter != nil {
+		s.syslogWriter.Close()
+		s.syslogWriter = nil
+	}
+}
+
+// newSinkCore create zap core of the log sink.
+//
+// Parameters:
+//   - sink: log sink configuration
+//
+// Returns:
+//   - zapcore.Core: zap core
+//   - error: success(nil), failure(error)
+func (s *SyncLogger) newSinkCore(sink config.LogSink) (zapcore.Core, error) {
+	level, err := zapcore.ParseLevel(sink.Level)
+	if err != nil {
+		return nil, err
+	}
+	encoder := s.newEncoder(sink.Format)
+
+	switch sink.Name {
+	case config.LogSinkConsoleFile, config.LogSinkJsonFile:
+		// Set lumberjack - automatically manages log files
+		fileLogger := s.newLumberJackLogger(sink.Path)
+		s.fileLoggers = append(s.fileLoggers, fileLogger)
+		return zapcore.NewCore(encoder, zapcore.AddSync(fileLogger), level), nil
+	case config.LogSinkStdout:
+		return zapcore.NewCore(encoder, zapcore.Lock(stdoutFile), level), nil
+	case config.LogSinkStderr:
+		return zapcore.NewCore(encoder, zapcore.Lock(stderrFile), level), nil
+	case config.LogSinkSyslog:
+		if s.syslogWriter == nil {
+			s.syslogWriter, err = syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, config.ModuleName)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return newSyslogCore(encoder, s.syslogWriter, level), nil
+	}
+
+	return nil, fmt.Errorf("unknown log sink")
+}
+
+// newEncoder create zap encoder of the log format.
+//
+// Parameters:
+//   - format: log format (console, json, logfmt)
+//
+// Returns:
+//   - zapcore.Encoder: zap encoder
+func (s *SyncLogger) newEncoder(format string) zapcore.Encoder {
+	switch format {
+	case config.LogFormatJson:
+		return zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+			MessageKey:     "msg",
+			LevelKey:       "level",
+			TimeKey:        "time",
+			CallerKey:      "caller",
+			FunctionKey:    zapcore.OmitKey,
+			StacktraceKey:  "stacktrace",
+			LineEnding:     zapcore.DefaultLineEnding,
+			EncodeLevel:    zapcore.CapitalLevelEncoder,
+			EncodeTime:     zapcore.TimeEncoderOfLayout("2006-01-02T15:04:05.000Z07:00"),
+			EncodeDuration: zapcore.SecondsDurationEncoder,
+			EncodeCaller:   s.wrapShortCallerEncoder(false),
+		})
+	case config.LogFormatLogfmt:
+		return newLogfmtEncoder("2006-01-02T15:04:05.000Z07:00", func(caller zapcore.EntryCaller) string {
+			return s.shortCaller(false, caller)
+		})
+	}
+
+	return zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+		MessageKey:       "msg",
+		LevelKey:         "level",
+		TimeKey:          "time",
+		CallerKey:        "caller",
+		FunctionKey:      zapcore.OmitKey,
+		StacktraceKey:    "stacktrace",
+		LineEnding:       zapcore.DefaultLineEnding,
+		EncodeLevel:      s.capitalLevelEncoder,
+		EncodeTime:       zapcore.TimeEncoderOfLayout("[2006-01-02 15:04:05]"),
+		EncodeDuration:   zapcore.SecondsDurationEncoder,
+		EncodeCaller:     s.wrapShortCallerEncoder(true),
+		ConsoleSeparator: " ",
+	})
 }
 
 // newLumberJackLogger create lumberjack logger
+// Backup compression and retention are handled by ManageBackupLogFiles.
 //
 // Parameters:
 //   - logFilePath: log file path
@@ -123,12 +214,21 @@ func (s *SyncLogger) FinalizeLogger() {
 //   - *lumberjack.Logger: lumberjack logger
 func (s *SyncLogger) newLumberJackLogger(logFilePath string) *lumberjack.Logger {
 	return &lumberjack.Logger{
-		Filename:   logFilePath,
-		MaxSize:    config.Conf.MaxLogFileSize,
-		MaxBackups: config.Conf.MaxLogFileBackup,
-		MaxAge:     config.Conf.MaxLogFileAge,
-		Compress:   config.Conf.CompBakLogFile,
+		Filename: logFilePath,
+		MaxSize:  config.Conf.MaxLogFileSize,
+	}
+}
+
+// logFilePaths returns the paths of the log files managed by the logger.
+//
+// Returns:
+//   - []string: log file paths
+func (s *SyncLogger) logFilePaths() []string {
+	logFilePaths := []string{}
+	for _, fileLogger := range s.fileLoggers {
+		logFilePaths = append(logFilePaths, fileLogger.Filename)
 	}
+	return logFilePaths
 }
 
 // capitalLevelEncoder customize zap core CapitalLevelEncoder() method.
@@ -148,33 +248,42 @@ func (s *SyncLogger) capitalLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveA
 //   - func: original method
 func (s *SyncLogger) wrapShortCallerEncoder(isConsole bool) func(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
 	return func(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
-		fileIdx := -1
-		funcIdx := -1
+		enc.AppendString(s.shortCaller(isConsole, caller))
+	}
+}
 
-		if !caller.Defined {
-			enc.AppendString(s.putSquareBracketsOnCaller(isConsole, "undefined"))
-			return
-		}
+// shortCaller make a caller message in the form of file:line-function().
+//
+// Parameters:
+//   - isConsole: true(console log), false(json log)
+//   - caller: log caller
+//
+// Returns:
+//   - string: caller message
+func (s *SyncLogger) shortCaller(isConsole bool, caller zapcore.EntryCaller) string {
+	fileIdx := -1
+	funcIdx := -1
 
-		// Get file name index
-		if fileIdx = strings.LastIndex(caller.File, "/"); fileIdx == -1 {
-			enc.AppendString(s.putSquareBracketsOnCaller(isConsole,
-				fmt.Sprintf("%s-%s()", caller.FullPath(), caller.Function)))
-			return
-		}
+	if !caller.Defined {
+		return s.putSquareBracketsOnCaller(isConsole, "undefined")
+	}
 
-		// Get function name index
-		if funcIdx = strings.LastIndex(caller.Function, "."); funcIdx == -1 {
-			enc.AppendString(s.putSquareBracketsOnCaller(isConsole,
-				fmt.Sprintf("%s-%s()", caller.FullPath(), caller.Function)))
-			return
-		}
+	// Get file name index
+	if fileIdx = strings.LastIndex(caller.File, "/"); fileIdx == -1 {
+		return s.putSquareBracketsOnCaller(isConsole,
+			fmt.Sprintf("%s-%s()", caller.FullPath(), caller.Function))
+	}
 
-		// Make caller message and append caller string to log
-		enc.AppendString(s.putSquareBracketsOnCaller(isConsole,
-			fmt.Sprintf("%s:%d-%s()", caller.File[fileIdx+1:], caller.Line,
-				caller.Function[funcIdx+1:])))
+	// Get function name index
+	if funcIdx = strings.LastIndex(caller.Function, "."); funcIdx == -1 {
+		return s.putSquareBracketsOnCaller(isConsole,
+			fmt.Sprintf("%s-%s()", caller.FullPath(), caller.Function))
 	}
+
+	// Make caller message
+	return s.putSquareBracketsOnCaller(isConsole,
+		fmt.Sprintf("%s:%d-%s()", caller.File[fileIdx+1:], caller.Line,
+			caller.Function[funcIdx+1:]))
 }
 
 // putSquareBracketsOnCaller put square brackets on the callers if they are console logs.
@@ -192,6 +301,22 @@ func (s *SyncLogger) putSquareBracketsOnCaller(isConsole bool, format string) st
 	return format
 }
 
+// reportSuppressed writes the number of messages dropped by sampling,
+// at most once per sampling period.
+func (s *SyncLogger) reportSuppressed() {
+	if s.suppressed.Load() == 0 {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := s.lastReport.Load()
+	if now-last < int64(samplingTick) || !s.lastReport.CompareAndSwap(last, now) {
+		return
+	}
+	if n := s.suppressed.Swap(0); n > 0 {
+		s.zapLogger.Warn(fmt.Sprintf("%d log messages suppressed by sampling", n))
+	}
+}
+
 // LogInfo write a log with a log level of INFO.
 //
 // Parameters:
@@ -200,6 +325,7 @@ func (s *SyncLogger) putSquareBracketsOnCaller(isConsole bool, format string) st
 func (s *SyncLogger) LogInfo(format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
 	s.zapLogger.Info(message)
+	s.reportSuppressed()
 }
 
 // LogWarn write a log with a log level of WARN.
@@ -210,6 +336,7 @@ func (s *SyncLogger) LogInfo(format string, args ...interface{}) {
 func (s *SyncLogger) LogWarn(format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
 	s.zapLogger.Warn(message)
+	s.reportSuppressed()
 }
 
 // LogError write a log with a log level of ERROR.
@@ -220,6 +347,7 @@ func (s *SyncLogger) LogWarn(format string, args ...interface{}) {
 func (s *SyncLogger) LogError(format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
 	s.zapLogger.Error(message)
+	s.reportSuppressed()
 }
 
 // LogDebug write a log with a log level of DEBUG.
@@ -230,6 +358,7 @@ func (s *SyncLogger) LogError(format string, args ...interface{}) {
 func (s *SyncLogger) LogDebug(format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
 	s.zapLogger.Debug(message)
+	s.reportSuppressed()
 }
 
 // LogPanic write a log with a log level of PANIC.
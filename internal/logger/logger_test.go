@@ -0,0 +1,71 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/config"
+)
+
+func TestSampling(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	savedStdout, savedConf := stdoutFile, config.Conf
+	defer func() { stdoutFile, config.Conf = savedStdout, savedConf }()
+	stdoutFile = out
+	config.Conf.LogSinks = []config.LogSink{{Name: config.LogSinkStdout, Enable: true, Format: config.LogFormatJson,
+		Level: "debug"}}
+	config.Conf.LogSamplingFirst = 2
+	config.Conf.LogSamplingThereafter = 3
+
+	// The first 2 messages and every 3rd message after them are logged
+	s := &SyncLogger{}
+	s.InitializeLogger()
+	for i := 0; i < 8; i++ {
+		s.LogInfo("repeated")
+	}
+	s.LogWarn("other")
+	s.FinalizeLogger()
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record struct {
+			Msg string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid log line %q: %s", line, err)
+		}
+		messages = append(messages, record.Msg)
+	}
+	want := []string{"repeated", "repeated", "repeated", "repeated", "other", "4 log messages suppressed by sampling"}
+	if strings.Join(messages, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package logger
+
+import (
+	"log/syslog"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// syslogCore is a zap core that writes to the local syslog with the
+// severity of the log level
+type syslogCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	writer  *syslog.Writer
+}
+
+// newSyslogCore create syslog core.
+//
+// Parameters:
+//   - encoder: log encoder
+//   - writer: syslog writer
+//   - level: minimum log level
+//
+// Returns:
+//   - zapcore.Core: syslog core
+func newSyslogCore(encoder zapcore.Encoder, writer *syslog.Writer, level zapcore.LevelEnabler) zapcore.Core {
+	return &syslogCore{LevelEnabler: level, encoder: encoder, writer: writer}
+}
+
+// With adds structured context to the core.
+//
+// Parameters:
+//   - fields: context fields
+//
+// Returns:
+//   - zapcore.Core: core with context fields
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &syslogCore{LevelEnabler: c.LevelEnabler, encoder: c.encoder.Clone(), writer: c.writer}
+	for _, field := range fields {
+		field.AddTo(clone.encoder)
+	}
+	return clone
+}
+
+// Check determines whether the supplied entry should be logged.
+//
+// Parameters:
+//   - ent: log entry
+//   - ce: checked entry
+//
+// Returns:
+//   - *zapcore.CheckedEntry: checked entry
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write serializes the entry and writes it to syslog.
+//
+// Parameters:
+//   - ent: log entry
+//   - fields: log fields
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	message := strings.TrimSuffix(buf.String(), zapcore.DefaultLineEnding)
+	switch ent.Level {
+	case zapcore.DebugLevel:
+		return c.writer.Debug(message)
+	case zapcore.InfoLevel:
+		return c.writer.Info(message)
+	case zapcore.WarnLevel:
+		return c.writer.Warning(message)
+	case zapcore.ErrorLevel:
+		return c.writer.Err(message)
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		return c.writer.Crit(message)
+	}
+	return c.writer.Emerg(message)
+}
+
+// Sync flushes buffered logs (syslog is not buffered).
+//
+// Returns:
+//   - error: always nil
+func (c *syslogCore) Sync() error {
+	return nil
+}
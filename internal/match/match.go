@@ -0,0 +1,489 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package match implements the match expressions evaluated on entries
+(e.g. level >= error and field.status >= 500).
+
+	expr       = or
+	or         = and { ("or" | "||") and }
+	and        = unary { ("and" | "&&") unary }
+	unary      = ("not" | "!") unary | "(" expr ")" | comparison
+	comparison = operand [ op value ]
+	operand    = level | source | stream | msg | field.<key> | label.<key>
+	op         = "==" | "!=" | "=~" | "!~" | "<" | "<=" | ">" | ">="
+	value      = "quoted string" | bare word
+
+An operand without an operator is true if it exists and is not empty.
+Levels are compared by severity, other values numerically if both are
+numbers and lexically otherwise.
+*/
+package match
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// Expr is a compiled match expression structure
+type Expr struct {
+	source string
+	root   node
+}
+
+// node is a node of the expression tree
+type node interface {
+	eval(e *entry.Entry) bool
+}
+
+// Compile compile a match expression.
+//
+// Parameters:
+//   - expr: match expression
+//
+// Returns:
+//   - *Expr: compiled expression
+//   - error: success(nil), failure(error)
+func Compile(expr string) (*Expr, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid match expression: %s", err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("match expression is empty")
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid match expression: %s", err)
+	}
+	return &Expr{source: expr, root: root}, nil
+}
+
+// Match evaluates the expression on the entry.
+//
+// Parameters:
+//   - e: log entry
+//
+// Returns:
+//   - bool: match(true), mismatch(false)
+func (x *Expr) Match(e *entry.Entry) bool {
+	return x.root.eval(e)
+}
+
+// String returns the source of the expression.
+//
+// Returns:
+//   - string: match expression
+func (x *Expr) String() string {
+	return x.source
+}
+
+// token is a token of the expression
+type token struct {
+	text   string
+	quoted bool
+}
+
+// Operators in the order of matching (longer first)
+var operators = []string{"==", "!=", "=~", "!~", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")"}
+
+// tokenize split the expression into tokens.
+//
+// Parameters:
+//   - expr: match expression
+//
+// Returns:
+//   - []token: tokens
+//   - error: success(nil), failure(error)
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		if c == ' ' || c == '\t' || c == '\n' {
+			i++
+			continue
+		}
+
+		if c == '"' {
+			quoted, err := strconv.QuotedPrefix(expr[i:])
+			if err != nil {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, token{text: text, quoted: true})
+			i += len(quoted)
+			continue
+		}
+
+		matched := false
+		for _, op := range operators {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, token{text: op})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		start := i
+		for i < len(expr) && !strings.ContainsRune(" \t\n\"=!<>&|()", rune(expr[i])) {
+			i++
+		}
+		tokens = append(tokens, token{text: expr[start:i]})
+	}
+	return tokens, nil
+}
+
+// exprParser is a recursive descent parser structure
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+// peek returns the current unquoted token text ("" if quoted or at the end).
+//
+// Returns:
+//   - string: token text
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+// parseOr parses the or expression.
+//
+// Returns:
+//   - node: expression node
+//   - error: success(nil), failure(error)
+func (p *exprParser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" || p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses the and expression.
+//
+// Returns:
+//   - node: expression node
+//   - error: success(nil), failure(error)
+func (p *exprParser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" || p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses the not expression, the parenthesized expression
+// and the comparison.
+//
+// Returns:
+//   - node: expression node
+//   - error: success(nil), failure(error)
+func (p *exprParser) parseUnary() (node, error) {
+	switch p.peek() {
+	case "not", "!":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	case "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses the comparison.
+//
+// Returns:
+//   - node: expression node
+//   - error: success(nil), failure(error)
+func (p *exprParser) parseComparison() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	name := p.tokens[p.pos]
+	if name.quoted {
+		return nil, fmt.Errorf("unexpected string %q", name.text)
+	}
+	operand, err := newOperand(name.text)
+	if err != nil {
+		return nil, err
+	}
+	p.pos++
+
+	op := p.peek()
+	switch op {
+	case "==", "!=", "=~", "!~", "<", "<=", ">", ">=":
+	default:
+		return &existsNode{operand: operand}, nil
+	}
+	p.pos++
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("missing value of %s %s", name.text, op)
+	}
+	value := p.tokens[p.pos]
+	if !value.quoted {
+		for _, reserved := range operators {
+			if value.text == reserved {
+				return nil, fmt.Errorf("missing value of %s %s", name.text, op)
+			}
+		}
+	}
+	p.pos++
+
+	return newComparison(operand, op, value.text)
+}
+
+// operand is a value of the entry
+type operand struct {
+	kind string // level, source, stream, msg, field, label
+	key  string
+}
+
+// newOperand parses an operand name.
+//
+// Parameters:
+//   - name: operand name
+//
+// Returns:
+//   - operand: operand
+//   - error: success(nil), failure(error)
+func newOperand(name string) (operand, error) {
+	switch name {
+	case "level", "source", "stream", "msg":
+		return operand{kind: name}, nil
+	case "message":
+		return operand{kind: "msg"}, nil
+	}
+
+	prefix, key, found := strings.Cut(name, ".")
+	if found && key != "" {
+		switch prefix {
+		case "field", "fields":
+			return operand{kind: "field", key: key}, nil
+		case "label", "labels":
+			return operand{kind: "label", key: key}, nil
+		}
+	}
+	return operand{}, fmt.Errorf("unknown operand: %s", name)
+}
+
+// value returns the operand value of the entry.
+//
+// Parameters:
+//   - e: log entry
+//
+// Returns:
+//   - string: value
+//   - bool: exists(true), not exists(false)
+func (o operand) value(e *entry.Entry) (string, bool) {
+	switch o.kind {
+	case "level":
+		return e.Level, true
+	case "source":
+		return e.Source, true
+	case "stream":
+		return e.Stream, true
+	case "msg":
+		return e.Message, true
+	case "field":
+		return e.Field(o.key)
+	case "label":
+		value, exists := e.Labels[o.key]
+		return value, exists
+	}
+	return "", false
+}
+
+// comparison is a comparison node
+type comparison struct {
+	operand operand
+	op      string
+	value   string
+	number  float64
+	numeric bool
+	re      *regexp.Regexp
+}
+
+// newComparison create a comparison node.
+//
+// Parameters:
+//   - o: operand
+//   - op: operator
+//   - value: value to compare
+//
+// Returns:
+//   - node: comparison node
+//   - error: success(nil), failure(error)
+func newComparison(o operand, op, value string) (node, error) {
+	c := &comparison{operand: o, op: op, value: value}
+
+	switch {
+	case op == "=~" || op == "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %s", err)
+		}
+		c.re = re
+	case o.kind == "level":
+		level, err := entry.NormalizeLevel(value)
+		if err != nil {
+			return nil, err
+		}
+		c.value = level
+	default:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			c.number, c.numeric = n, true
+		}
+	}
+	return c, nil
+}
+
+// eval evaluates the comparison.
+//
+// Parameters:
+//   - e: log entry
+//
+// Returns:
+//   - bool: true, false
+func (c *comparison) eval(e *entry.Entry) bool {
+	value, exists := c.operand.value(e)
+	if !exists {
+		return c.op == "!=" || c.op == "!~"
+	}
+
+	switch c.op {
+	case "=~":
+		return c.re.MatchString(value)
+	case "!~":
+		return !c.re.MatchString(value)
+	}
+
+	var cmp int
+	switch {
+	case c.operand.kind == "level":
+		cmp = entry.LevelRank(value) - entry.LevelRank(c.value)
+	case c.numeric:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			cmp = strings.Compare(value, c.value)
+			break
+		}
+		switch {
+		case n < c.number:
+			cmp = -1
+		case n > c.number:
+			cmp = 1
+		}
+	default:
+		cmp = strings.Compare(value, c.value)
+	}
+
+	switch c.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+// existsNode is true if the operand exists and is not empty
+type existsNode struct {
+	operand operand
+}
+
+func (n *existsNode) eval(e *entry.Entry) bool {
+	value, exists := n.operand.value(e)
+	return exists && value != ""
+}
+
+// andNode is a logical and node
+type andNode struct {
+	left, right node
+}
+
+func (n *andNode) eval(e *entry.Entry) bool {
+	return n.left.eval(e) && n.right.eval(e)
+}
+
+// orNode is a logical or node
+type orNode struct {
+	left, right node
+}
+
+func (n *orNode) eval(e *entry.Entry) bool {
+	return n.left.eval(e) || n.right.eval(e)
+}
+
+// notNode is a logical not node
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(e *entry.Entry) bool {
+	return !n.operand.eval(e)
+}
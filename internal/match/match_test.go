@@ -0,0 +1,124 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package match
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+func TestCompileError(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "empty"},
+		{"   ", "empty"},
+		{`msg == "open`, "unterminated string"},
+		{"host == a", "unknown operand: host"},
+		{"field. == a", "unknown operand: field."},
+		{"level == loud", "unknown level: loud"},
+		{"msg =~ (", "missing value"},
+		{`msg =~ "("`, "invalid regular expression"},
+		{"level ==", "missing value"},
+		{"(level == error", "missing )"},
+		{"level == error)", `unexpected ")"`},
+		{"level == error and", "unexpected end"},
+		{`"error"`, "unexpected string"},
+		{"level == error level == info", `unexpected "level"`},
+	}
+
+	for _, tt := range tests {
+		x, err := Compile(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%q) = %v, %v; want error containing %q", tt.expr, x, err, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	e := &entry.Entry{
+		Level:   entry.LevelWarn,
+		Source:  "api",
+		Stream:  "default",
+		Message: "GET /orders failed: timeout",
+		Fields:  map[string]interface{}{"status": float64(504), "user": "bob", "empty": ""},
+		Labels:  map[string]string{"pod": "api-7d9f"},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Levels compare by severity and accept aliases
+		{"level == warning", true},
+		{"level >= info", true},
+		{"level >= error", false},
+		{"level < err", true},
+		{"level != warn", false},
+
+		// Numbers compare numerically, other values lexically
+		{"field.status >= 500", true},
+		{"field.status < 1000", true},
+		{"field.status == 504.0", true},
+		{"fields.user > alice", true},
+		{"field.user >= 9", true},
+		{"source == api", true},
+		{`stream == "default"`, true},
+
+		// Regular expressions
+		{`msg =~ "time(out|d out)"`, true},
+		{`message !~ "^POST"`, true},
+		{`label.pod =~ "^api-"`, true},
+
+		// Missing operands only satisfy the negative operators
+		{"field.missing == x", false},
+		{"field.missing != x", true},
+		{`labels.missing !~ "x"`, true},
+		{"field.missing < 1", false},
+
+		// Existence
+		{"field.user", true},
+		{"field.empty", false},
+		{"label.pod", true},
+		{"label.node", false},
+
+		// Logical operators and precedence (and binds tighter than or)
+		{"level == error or source == api and field.status >= 500", true},
+		{"(level == error or source == api) and field.status < 500", false},
+		{"not level == error", true},
+		{"!(source == api)", false},
+		{"level == warn && label.pod || field.missing", true},
+		{"not not field.user", true},
+	}
+
+	for _, tt := range tests {
+		x, err := Compile(tt.expr)
+		if err != nil {
+			t.Errorf("Compile(%q): %s", tt.expr, err)
+			continue
+		}
+		if got := x.Match(e); got != tt.want {
+			t.Errorf("%q: Match() = %v, want %v", tt.expr, got, tt.want)
+		}
+		if x.String() != tt.expr {
+			t.Errorf("String() = %q, want %q", x.String(), tt.expr)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package metrics provides the counters and gauges of the module and
+writes them in the Prometheus text exposition format.
+*/
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is a registered metric
+type metric interface {
+	write(w io.Writer) error
+}
+
+var (
+	mu      sync.Mutex
+	metrics = make(map[string]metric)
+)
+
+// Counter is a counter metric structure with labels
+type Counter struct {
+	name       string
+	help       string
+	labelNames []string
+	mu         sync.Mutex
+	values     map[string]*counterValue
+}
+
+// counterValue is a counter or gauge value of a label set
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounter create and register a counter. A counter registered with
+// the same name is returned if it exists.
+//
+// Parameters:
+//   - name: metric name
+//   - help: metric description
+//   - labelNames: label names
+//
+// Returns:
+//   - *Counter: counter
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if c, ok := metrics[name].(*Counter); ok {
+		return c
+	}
+	c := &Counter{name: name, help: help, labelNames: labelNames, values: make(map[string]*counterValue)}
+	metrics[name] = c
+	return c
+}
+
+// Add adds the delta to the counter of the label values.
+//
+// Parameters:
+//   - delta: value to add (>= 0)
+//   - labelValues: label values in the order of the label names
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, exists := c.values[key]
+	if !exists {
+		v = &counterValue{labelValues: append([]string{}, labelValues...)}
+		c.values[key] = v
+	}
+	v.value += delta
+}
+
+// Inc adds 1 to the counter of the label values.
+//
+// Parameters:
+//   - labelValues: label values in the order of the label names
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the counter of the label values.
+//
+// Parameters:
+//   - labelValues: label values in the order of the label names
+//
+// Returns:
+//   - float64: counter value
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, exists := c.values[strings.Join(labelValues, "\xff")]; exists {
+		return v.value
+	}
+	return 0
+}
+
+// write writes the counter in the text exposition format.
+//
+// Parameters:
+//   - w: writer
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return writeValues(w, c.name, c.help, "counter", c.labelNames, c.values)
+}
+
+// Gauge is a gauge metric structure with labels
+type Gauge struct {
+	name       string
+	help       string
+	labelNames []string
+	mu         sync.Mutex
+	values     map[string]*counterValue
+}
+
+// NewGauge create and register a gauge. A gauge registered with the
+// same name is returned if it exists.
+//
+// Parameters:
+//   - name: metric name
+//   - help: metric description
+//   - labelNames: label names
+//
+// Returns:
+//   - *Gauge: gauge
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if g, ok := metrics[name].(*Gauge); ok {
+		return g
+	}
+	g := &Gauge{name: name, help: help, labelNames: labelNames, values: make(map[string]*counterValue)}
+	metrics[name] = g
+	return g
+}
+
+// Set sets the gauge of the label values.
+//
+// Parameters:
+//   - value: gauge value
+//   - labelValues: label values in the order of the label names
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	v, exists := g.values[key]
+	if !exists {
+		v = &counterValue{labelValues: append([]string{}, labelValues...)}
+		g.values[key] = v
+	}
+	v.value = value
+}
+
+// Delete removes the gauge of the label values.
+//
+// Parameters:
+//   - labelValues: label values in the order of the label names
+func (g *Gauge) Delete(labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.values, strings.Join(labelValues, "\xff"))
+}
+
+// write writes the gauge in the text exposition format.
+//
+// Parameters:
+//   - w: writer
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (g *Gauge) write(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return writeValues(w, g.name, g.help, "gauge", g.labelNames, g.values)
+}
+
+// writeValues writes the values of a labeled metric ordered by label
+// values.
+//
+// Parameters:
+//   - w: writer
+//   - name: metric name
+//   - help: metric description
+//   - typ: metric type (counter, gauge)
+//   - labelNames: label names
+//   - values: values per label set
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func writeValues(w io.Writer, name, help, typ string, labelNames []string, values map[string]*counterValue) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		v := values[key]
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(labelNames, v.labelValues),
+			formatValue(v.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gaugeFunc is a gauge metric whose value is read when written
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+// NewGaugeFunc register a gauge whose value is read by the function.
+// A metric registered with the same name is replaced.
+//
+// Parameters:
+//   - name: metric name
+//   - help: metric description
+//   - fn: returns the current value
+func NewGaugeFunc(name, help string, fn func() float64) {
+	mu.Lock()
+	defer mu.Unlock()
+	metrics[name] = &gaugeFunc{name: name, help: help, fn: fn}
+}
+
+// write writes the gauge in the text exposition format.
+//
+// Parameters:
+//   - w: writer
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (g *gaugeFunc) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name,
+		formatValue(g.fn()))
+	return err
+}
+
+// Write writes all registered metrics in the Prometheus text exposition
+// format, ordered by name.
+//
+// Parameters:
+//   - w: writer
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func Write(w io.Writer) error {
+	mu.Lock()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	list := make([]metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		list = append(list, metrics[name])
+	}
+	mu.Unlock()
+
+	for _, m := range list {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatLabels formats a label set ({name="value",...}).
+//
+// Parameters:
+//   - names: label names
+//   - values: label values
+//
+// Returns:
+//   - string: label set (empty if there is no label)
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		sb.WriteString(name + `="` + labelEscaper.Replace(value) + `"`)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// Escaper of label values
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatValue formats a sample value.
+//
+// Parameters:
+//   - value: sample value
+//
+// Returns:
+//   - string: formatted value
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
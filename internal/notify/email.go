@@ -0,0 +1,213 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/alert"
+)
+
+// Default templates of the email notifications
+const (
+	defaultSubject = `[{{.State}}] {{.Rule}} ({{.Severity}})`
+	defaultBody    = `{{.Message}}
+
+Rule: {{.Rule}}
+State: {{.State}}
+Severity: {{.Severity}}
+Type: {{.Type}}
+{{- if .Match}}
+Match: {{.Match}}{{end}}
+Value: {{.Value}}
+Starts at: {{.StartsAt}}
+{{- if .EndsAt}}
+Ends at: {{.EndsAt}}{{end}}
+{{- if .Description}}
+
+{{.Description}}{{end}}
+`
+)
+
+// emailSender is an SMTP delivery structure
+type emailSender struct {
+	conf    config.NotifyChannel
+	host    string
+	subject *template.Template
+	body    *template.Template
+}
+
+// newEmailSender create SMTP delivery.
+//
+// Parameters:
+//   - conf: notification channel configuration
+//
+// Returns:
+//   - *emailSender: SMTP delivery
+//   - error: success(nil), failure(error)
+func newEmailSender(conf config.NotifyChannel) (*emailSender, error) {
+	s := &emailSender{conf: conf}
+	s.host, _, _ = net.SplitHostPort(conf.SmtpAddress)
+
+	subject, body := conf.Subject, conf.Body
+	if subject == "" {
+		subject = defaultSubject
+	}
+	if body == "" {
+		body = defaultBody
+	}
+	var err error
+	if s.subject, err = parseTemplate(conf.Name, subject); err != nil {
+		return nil, err
+	}
+	if s.body, err = parseTemplate(conf.Name, body); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// send mails the alert to the recipients. The connection is upgraded by
+// STARTTLS if the server supports it (or uses TLS from the start with
+// SmtpTls), and PLAIN authentication is used if the user name is set.
+// Permanent SMTP errors (5xx) are not retried.
+//
+// Parameters:
+//   - ctx: context for cancellation
+//   - a: alert
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (s *emailSender) send(ctx context.Context, a alert.Alert) error {
+	msg, err := s.message(a)
+	if err != nil {
+		return err
+	}
+
+	var conn net.Conn
+	if s.conf.SmtpTls {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: s.host}}
+		conn, err = dialer.DialContext(ctx, "tcp", s.conf.SmtpAddress)
+	} else {
+		dialer := &net.Dialer{}
+		conn, err = dialer.DialContext(ctx, "tcp", s.conf.SmtpAddress)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect smtp server: %s", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return smtpError("failed to start smtp session", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !s.conf.SmtpTls {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return smtpError("failed to start tls", err)
+		}
+	}
+	if s.conf.SmtpUsername != "" {
+		auth := smtp.PlainAuth("", s.conf.SmtpUsername, s.conf.SmtpPassword, s.host)
+		if err := client.Auth(auth); err != nil {
+			return smtpError("failed to authenticate", err)
+		}
+	}
+
+	if err := client.Mail(s.conf.From); err != nil {
+		return smtpError("failed to set sender", err)
+	}
+	for _, to := range s.conf.To {
+		if err := client.Rcpt(to); err != nil {
+			return smtpError("failed to set recipient", err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return smtpError("failed to send data", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return smtpError("failed to send data", err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError("failed to send data", err)
+	}
+	return client.Quit()
+}
+
+// message makes the mail message of the alert.
+//
+// Parameters:
+//   - a: alert
+//
+// Returns:
+//   - []byte: mail message
+//   - error: success(nil), failure(error)
+func (s *emailSender) message(a alert.Alert) ([]byte, error) {
+	subject, err := execute(s.subject, a)
+	if err != nil {
+		return nil, err
+	}
+	body, err := execute(s.body, a)
+	if err != nil {
+		return nil, err
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("From: " + s.conf.From + "\r\n")
+	sb.WriteString("To: " + strings.Join(s.conf.To, ", ") + "\r\n")
+	sb.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", strings.TrimSpace(string(subject))) + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(sb.String()), nil
+}
+
+// smtpError wraps an SMTP error. Permanent errors (5xx) are not retried.
+//
+// Parameters:
+//   - action: failed action
+//   - err: SMTP error
+//
+// Returns:
+//   - error: wrapped error
+func smtpError(action string, err error) error {
+	wrapped := fmt.Errorf("%s: %s", action, err)
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return &permanentError{wrapped}
+	}
+	return wrapped
+}
@@ -0,0 +1,233 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package notify
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+)
+
+// smtpServer is a minimal SMTP server recording the sessions. Recipients
+// listed in reject are refused with 550.
+type smtpServer struct {
+	listener net.Listener
+	reject   map[string]bool
+	mu       sync.Mutex
+	from     string
+	to       []string
+	data     string
+}
+
+func newSMTPServer(t *testing.T, reject ...string) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{listener: listener, reject: make(map[string]bool)}
+	for _, to := range reject {
+		s.reject[to] = true
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// serve handles an SMTP session.
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = addressOf(line)
+			s.mu.Unlock()
+			reply("250 OK")
+		case "RCPT":
+			to := addressOf(line)
+			if s.reject[to] {
+				reply("550 no such user")
+				continue
+			}
+			s.mu.Lock()
+			s.to = append(s.to, to)
+			s.mu.Unlock()
+			reply("250 OK")
+		case "DATA":
+			reply("354 end with .")
+			data := strings.Builder{}
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// session returns the recorded sender, recipients and message.
+func (s *smtpServer) session() (string, []string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.from, s.to, s.data
+}
+
+// addressOf returns the address of a MAIL or RCPT command.
+func addressOf(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func TestEmailSend(t *testing.T) {
+	srv := newSMTPServer(t)
+	c, err := NewChannel(config.NotifyChannel{Name: "mail", Type: config.NotifyEmail,
+		SmtpAddress: srv.listener.Addr().String(), From: "log_manager@example.com",
+		To: []string{"ops@example.com", "oncall@example.com"}, Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := TestAlert()
+	a.Description = "line one\nline two"
+	if err := c.Send(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to, data := srv.session()
+	if from != "log_manager@example.com" || strings.Join(to, ",") != "ops@example.com,oncall@example.com" {
+		t.Errorf("from = %q, to = %q", from, to)
+	}
+	for _, want := range []string{
+		"From: log_manager@example.com\r\n",
+		"To: ops@example.com, oncall@example.com\r\n",
+		"Subject: [firing] test (info)\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\n[firing] test: test notification of log_manager\r\n",
+		"Rule: test\r\nState: firing\r\n",
+		"line one\r\nline two\r\n",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("message does not contain %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestEmailTemplate(t *testing.T) {
+	srv := newSMTPServer(t)
+	c, err := NewChannel(config.NotifyChannel{Name: "mail", Type: config.NotifyEmail,
+		SmtpAddress: srv.listener.Addr().String(), From: "a@example.com", To: []string{"b@example.com"},
+		Subject: "Alert {{.Rule}} — {{.State}}", Body: "value={{.Value}}", Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(context.Background(), TestAlert()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, data := srv.session()
+	// Non-ASCII subjects are encoded
+	if !strings.Contains(data, "Subject: =?utf-8?q?Alert_test_=E2=80=94_firing?=\r\n") ||
+		!strings.HasSuffix(data, "\r\n\r\nvalue=1\r\n") {
+		t.Errorf("message =\n%s", data)
+	}
+}
+
+func TestEmailPermanentError(t *testing.T) {
+	srv := newSMTPServer(t, "nobody@example.com")
+	c, err := NewChannel(config.NotifyChannel{Name: "mail", Type: config.NotifyEmail,
+		SmtpAddress: srv.listener.Addr().String(), From: "a@example.com", To: []string{"nobody@example.com"},
+		Timeout: 5, MaxRetries: 3, RetryBackoff: 60000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A retry would wait for the backoff and end with the context error
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = c.Send(ctx, TestAlert())
+	var permanent *permanentError
+	if !errors.As(err, &permanent) || !strings.Contains(err.Error(), "550") {
+		t.Errorf("Send() = %v, want permanent 550 error", err)
+	}
+}
+
+func TestEmailConnectError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	c, err := NewChannel(config.NotifyChannel{Name: "mail", Type: config.NotifyEmail, SmtpAddress: address,
+		From: "a@example.com", To: []string{"b@example.com"}, Timeout: 5, MaxRetries: 1, RetryBackoff: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Send(context.Background(), TestAlert())
+	var permanent *permanentError
+	if err == nil || errors.As(err, &permanent) {
+		t.Errorf("Send() = %v, want temporary error", err)
+	}
+}
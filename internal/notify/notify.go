@@ -0,0 +1,288 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package notify delivers alert notifications to the configured channels
+(webhook, email, script).
+
+Each channel has its own queue and delivery goroutine, so a slow or
+unreachable channel does not delay the others or the alert evaluation.
+A failed delivery is retried with exponential backoff, and notifications
+over the rate limit of the channel are dropped.
+*/
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/alert"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/metrics"
+)
+
+// Capacity of the notification queue of a channel
+const queueSize = 100
+
+// Maximum wait between retries
+const maxBackoff = time.Minute
+
+// Delivery result
+const (
+	resultSent        = "sent"
+	resultFailed      = "failed"
+	resultRateLimited = "rate_limited"
+	resultQueueFull   = "queue_full"
+)
+
+// Number of notifications per channel and result
+var notificationsTotal = metrics.NewCounter("log_manager_notifications_total",
+	"Number of alert notifications per channel and delivery result.", "channel", "result")
+
+// sender delivers a notification once
+type sender interface {
+	send(ctx context.Context, a alert.Alert) error
+}
+
+// permanentError is a delivery error that is not retried
+type permanentError struct {
+	err error
+}
+
+// Error returns the error message.
+//
+// Returns:
+//   - string: error message
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Channel is a notification channel structure
+type Channel struct {
+	conf   config.NotifyChannel
+	sender sender
+	queue  chan alert.Alert
+	tokens float64
+	filled time.Time
+}
+
+// NewChannel create notification channel. The configuration is validated
+// when it is loaded.
+//
+// Parameters:
+//   - conf: notification channel configuration
+//
+// Returns:
+//   - *Channel: notification channel
+//   - error: success(nil), failure(error)
+func NewChannel(conf config.NotifyChannel) (*Channel, error) {
+	c := &Channel{
+		conf:   conf,
+		queue:  make(chan alert.Alert, queueSize),
+		tokens: float64(conf.RateLimit),
+		filled: time.Now(),
+	}
+
+	var err error
+	switch conf.Type {
+	case config.NotifyWebhook:
+		c.sender, err = newWebhookSender(conf)
+	case config.NotifyEmail:
+		c.sender, err = newEmailSender(conf)
+	case config.NotifyScript:
+		c.sender = newScriptSender(conf)
+	default:
+		err = fmt.Errorf("unsupported notification channel type: %s", conf.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name returns the channel name.
+//
+// Returns:
+//   - string: channel name
+func (c *Channel) Name() string {
+	return c.conf.Name
+}
+
+// Notify queues the alert without blocking. Firing alerts over the rate
+// limit of the channel are dropped. Resolved alerts are not limited, so
+// that a receiver is not left with an alert that never resolves.
+//
+// Parameters:
+//   - ctx: context (not used)
+//   - a: alert
+//
+// Returns:
+//   - error: queued(nil), dropped(error)
+func (c *Channel) Notify(_ context.Context, a alert.Alert) error {
+	if a.State != alert.StateResolved && !c.allow(time.Now()) {
+		notificationsTotal.Inc(c.conf.Name, resultRateLimited)
+		return fmt.Errorf("rate limit of channel %s exceeded", c.conf.Name)
+	}
+
+	select {
+	case c.queue <- a:
+		return nil
+	default:
+		notificationsTotal.Inc(c.conf.Name, resultQueueFull)
+		return fmt.Errorf("queue of channel %s is full", c.conf.Name)
+	}
+}
+
+// Run delivers the queued alerts until the context is cancelled.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (c *Channel) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case a := <-c.queue:
+			if err := c.Send(ctx, a); err != nil {
+				logger.Log.LogWarn("failed to notify alert (channel:%s, rule:%s, state:%s): %s",
+					c.conf.Name, a.Rule, a.State, err)
+			}
+		}
+	}
+}
+
+// Send delivers the alert, retrying with exponential backoff.
+//
+// Parameters:
+//   - ctx: context for cancellation
+//   - a: alert
+//
+// Returns:
+//   - error: success(nil), failure(error of the last attempt)
+func (c *Channel) Send(ctx context.Context, a alert.Alert) error {
+	backoff := time.Duration(c.conf.RetryBackoff) * time.Millisecond
+	var err error
+	for attempt := 0; attempt <= c.conf.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, time.Duration(c.conf.Timeout)*time.Second)
+		err = c.sender.send(attemptCtx, a)
+		cancel()
+		if err == nil {
+			notificationsTotal.Inc(c.conf.Name, resultSent)
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			break
+		}
+	}
+
+	notificationsTotal.Inc(c.conf.Name, resultFailed)
+	return err
+}
+
+// allow decides whether the rate limit allows a notification (token
+// bucket of RateLimit notifications per minute).
+//
+// Parameters:
+//   - now: current time
+//
+// Returns:
+//   - bool: allowed(true), rate limited(false)
+func (c *Channel) allow(now time.Time) bool {
+	if c.conf.RateLimit <= 0 {
+		return true
+	}
+
+	rate := float64(c.conf.RateLimit) / time.Minute.Seconds()
+	c.tokens = min(float64(c.conf.RateLimit), c.tokens+now.Sub(c.filled).Seconds()*rate)
+	c.filled = now
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	return true
+}
+
+// TestAlert returns an alert for the test delivery of a channel.
+//
+// Returns:
+//   - alert.Alert: test alert
+func TestAlert() alert.Alert {
+	now := time.Now().UTC()
+	return alert.Alert{
+		Rule:        "test",
+		State:       alert.StateFiring,
+		Type:        config.AlertThreshold,
+		Severity:    config.AlertSeverityInfo,
+		Description: "Test notification of log_manager",
+		Value:       1,
+		Window:      60,
+		StartsAt:    now,
+		Message:     "[firing] test: test notification of log_manager",
+	}
+}
+
+// parseTemplate parses the template of the channel.
+//
+// Parameters:
+//   - name: template name
+//   - text: template text (empty: no template)
+//
+// Returns:
+//   - *template.Template: template (nil if the text is empty)
+//   - error: success(nil), failure(error)
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(config.NotifyTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %s", err)
+	}
+	return tmpl, nil
+}
+
+// execute renders the template with the alert.
+//
+// Parameters:
+//   - tmpl: template
+//   - a: alert
+//
+// Returns:
+//   - []byte: rendered text
+//   - error: success(nil), failure(error)
+func execute(tmpl *template.Template, a alert.Alert) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, a); err != nil {
+		return nil, &permanentError{fmt.Errorf("failed to execute template: %s", err)}
+	}
+	return buf.Bytes(), nil
+}
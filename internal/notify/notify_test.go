@@ -0,0 +1,94 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/alert"
+)
+
+func TestAllow(t *testing.T) {
+	start := time.Now()
+	c := &Channel{conf: config.NotifyChannel{RateLimit: 2}, tokens: 2, filled: start}
+
+	steps := []struct {
+		after time.Duration
+		want  bool
+	}{
+		{0, true},
+		{0, true},
+		{0, false},                // Burst of RateLimit
+		{10 * time.Second, false}, // 1/3 token refilled
+		{31 * time.Second, true},  // 1 token refilled
+		{31 * time.Second, false},
+		{10 * time.Minute, true}, // Refilled up to RateLimit only
+		{10 * time.Minute, true},
+		{10 * time.Minute, false},
+	}
+	for i, step := range steps {
+		if got := c.allow(start.Add(step.after)); got != step.want {
+			t.Errorf("step %d (+%s): allow() = %v, want %v", i, step.after, got, step.want)
+		}
+	}
+
+	unlimited := &Channel{conf: config.NotifyChannel{RateLimit: 0}}
+	for i := 0; i < 1000; i++ {
+		if !unlimited.allow(start) {
+			t.Fatalf("allow() without a rate limit = false")
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	c, err := NewChannel(config.NotifyChannel{Name: "script", Type: config.NotifyScript, Command: "true",
+		RateLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Notify(context.Background(), TestAlert()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Notify(context.Background(), TestAlert()); err == nil {
+		t.Errorf("Notify() over the rate limit = nil, want error")
+	}
+
+	// A resolved alert is not rate limited
+	resolved := TestAlert()
+	resolved.State = alert.StateResolved
+	if err := c.Notify(context.Background(), resolved); err != nil {
+		t.Errorf("Notify() of a resolved alert over the rate limit = %s, want nil", err)
+	}
+	if len(c.queue) != 2 {
+		t.Errorf("queued = %d, want 2", len(c.queue))
+	}
+
+	// The queue does not block
+	c.conf.RateLimit = 0
+	for i := 2; i < queueSize; i++ {
+		if err := c.Notify(context.Background(), TestAlert()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Notify(context.Background(), TestAlert()); err == nil {
+		t.Errorf("Notify() with a full queue = nil, want error")
+	}
+}
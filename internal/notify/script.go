@@ -0,0 +1,84 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/alert"
+)
+
+// scriptSender is a script execution delivery structure
+type scriptSender struct {
+	conf config.NotifyChannel
+}
+
+// newScriptSender create script execution delivery.
+//
+// Parameters:
+//   - conf: notification channel configuration
+//
+// Returns:
+//   - *scriptSender: script execution delivery
+func newScriptSender(conf config.NotifyChannel) *scriptSender {
+	return &scriptSender{conf: conf}
+}
+
+// send executes the command with the JSON of the alert on stdin and the
+// ALERT_RULE, ALERT_STATE, ALERT_SEVERITY, ALERT_VALUE and ALERT_MESSAGE
+// environment variables. A non-zero exit status is a failure.
+//
+// Parameters:
+//   - ctx: context for cancellation (kills the command)
+//   - a: alert
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (s *scriptSender) send(ctx context.Context, a alert.Alert) error {
+	input, err := json.Marshal(a)
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to marshal alert: %s", err)}
+	}
+
+	cmd := exec.CommandContext(ctx, s.conf.Command, s.conf.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"ALERT_RULE="+a.Rule,
+		"ALERT_STATE="+a.State,
+		"ALERT_SEVERITY="+a.Severity,
+		"ALERT_VALUE="+strconv.FormatFloat(a.Value, 'f', -1, 64),
+		"ALERT_MESSAGE="+a.Message)
+	output := bytes.Buffer{}
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		detail := bytes.TrimSpace(output.Bytes())
+		if len(detail) > maxErrorBody {
+			detail = detail[:maxErrorBody]
+		}
+		return fmt.Errorf("failed to execute script: %s (%s)", err, detail)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/alert"
+)
+
+func TestScriptSend(t *testing.T) {
+	dir := t.TempDir()
+	stdinPath, envPath := filepath.Join(dir, "stdin.json"), filepath.Join(dir, "env")
+	script := `cat > "$1"; printf '%s|%s|%s|%s|%s' "$ALERT_RULE" "$ALERT_STATE" "$ALERT_SEVERITY" ` +
+		`"$ALERT_VALUE" "$ALERT_MESSAGE" > "$2"`
+
+	c, err := NewChannel(config.NotifyChannel{Name: "script", Type: config.NotifyScript, Command: "sh",
+		Args: []string{"-c", script, "sh", stdinPath, envPath}, Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := TestAlert()
+	a.Value = 12.5
+	a.Message = "disk full; check sda"
+	if err := c.Send(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := os.ReadFile(envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "test|firing|info|12.5|disk full; check sda"; string(env) != want {
+		t.Errorf("environment = %q, want %q", env, want)
+	}
+
+	stdin, err := os.ReadFile(stdinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := alert.Alert{}
+	if err := json.Unmarshal(stdin, &got); err != nil {
+		t.Fatalf("stdin is not the alert JSON: %s (%s)", err, stdin)
+	}
+	if got.Rule != a.Rule || got.Value != a.Value || got.Message != a.Message || !got.StartsAt.Equal(a.StartsAt) {
+		t.Errorf("stdin alert = %+v, want %+v", got, a)
+	}
+}
+
+func TestScriptFailure(t *testing.T) {
+	c, err := NewChannel(config.NotifyChannel{Name: "script", Type: config.NotifyScript, Command: "sh",
+		Args: []string{"-c", "echo boom >&2; exit 3"}, Timeout: 5, MaxRetries: 1, RetryBackoff: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Send(context.Background(), TestAlert())
+	if err == nil || !strings.Contains(err.Error(), "exit status 3 (boom)") {
+		t.Errorf("Send() = %v, want exit status 3 (boom)", err)
+	}
+}
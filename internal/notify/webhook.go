@@ -0,0 +1,143 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/alert"
+)
+
+// Signature headers of the webhook requests
+const (
+	SignatureHeader = "X-Log-Manager-Signature"
+	TimestampHeader = "X-Log-Manager-Timestamp"
+)
+
+// Maximum size of the response body read for error messages
+const maxErrorBody = 512
+
+// webhookSender is a webhook delivery structure
+type webhookSender struct {
+	conf   config.NotifyChannel
+	body   *template.Template
+	client *http.Client
+}
+
+// newWebhookSender create webhook delivery.
+//
+// Parameters:
+//   - conf: notification channel configuration
+//
+// Returns:
+//   - *webhookSender: webhook delivery
+//   - error: success(nil), failure(error)
+func newWebhookSender(conf config.NotifyChannel) (*webhookSender, error) {
+	body, err := parseTemplate(conf.Name, conf.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookSender{conf: conf, body: body, client: &http.Client{}}, nil
+}
+
+// send posts the alert to the URL. The body is the JSON of the alert or
+// the rendered body template. If the signing key is set, the request is
+// signed with HMAC-SHA256 of "<timestamp>.<body>" (X-Log-Manager-Signature:
+// sha256=<hex>, X-Log-Manager-Timestamp: <unix seconds>). Client errors
+// other than 408 and 429 are not retried.
+//
+// Parameters:
+//   - ctx: context for cancellation
+//   - a: alert
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (s *webhookSender) send(ctx context.Context, a alert.Alert) error {
+	var body []byte
+	var err error
+	if s.body != nil {
+		body, err = execute(s.body, a)
+	} else {
+		body, err = json.Marshal(a)
+	}
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.conf.Url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to make request: %s", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", config.ModuleName+"/"+config.Version)
+	for key, value := range s.conf.Headers {
+		req.Header.Set(key, value)
+	}
+	if s.conf.SigningKey != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+Sign([]byte(s.conf.SigningKey), timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	err = fmt.Errorf("unexpected response: %s (%s)", resp.Status, bytes.TrimSpace(detail))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err}
+	}
+	return err
+}
+
+// Sign returns the HMAC-SHA256 signature of a webhook request, so that
+// receivers can verify it.
+//
+// Parameters:
+//   - key: signing key
+//   - timestamp: value of the timestamp header
+//   - body: request body
+//
+// Returns:
+//   - string: signature (hex)
+func Sign(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
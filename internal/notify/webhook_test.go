@@ -0,0 +1,193 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+)
+
+// webhookServer is a webhook receiver answering with the given statuses
+// in order (200 after the last one).
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+	times    []time.Time
+}
+
+func newWebhookServer(statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(body))
+		s.times = append(s.times, time.Now())
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+// received returns the received requests, their bodies and times.
+func (s *webhookServer) received() ([]*http.Request, []string, []time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.bodies, s.times
+}
+
+func TestWebhookBody(t *testing.T) {
+	srv := newWebhookServer()
+	defer srv.Close()
+
+	c, err := NewChannel(config.NotifyChannel{Name: "hook", Type: config.NotifyWebhook, Url: srv.URL,
+		Headers: map[string]string{"X-Team": "ops"}, SigningKey: "secret",
+		Body:    `{"text":{{json .Message}},"rule":"{{.Rule}}","value":{{.Value}}}`,
+		Timeout: 5, MaxRetries: 0, RetryBackoff: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := TestAlert()
+	a.Message = `disk "sda" full`
+	if err := c.Send(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, bodies, _ := srv.received()
+	if len(requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(requests))
+	}
+	req, body := requests[0], bodies[0]
+	if want := `{"text":"disk \"sda\" full","rule":"test","value":1}`; body != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+	if req.Header.Get("X-Team") != "ops" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", req.Header)
+	}
+
+	timestamp := req.Header.Get(TimestampHeader)
+	want := "sha256=" + Sign([]byte("secret"), timestamp, []byte(body))
+	if timestamp == "" || req.Header.Get(SignatureHeader) != want {
+		t.Errorf("signature = %q (timestamp %q), want %q", req.Header.Get(SignatureHeader), timestamp, want)
+	}
+	if Sign([]byte("other"), timestamp, []byte(body)) == Sign([]byte("secret"), timestamp, []byte(body)) {
+		t.Errorf("signature does not depend on the key")
+	}
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	srv := newWebhookServer()
+	defer srv.Close()
+
+	c, err := NewChannel(config.NotifyChannel{Name: "hook", Type: config.NotifyWebhook, Url: srv.URL, Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(context.Background(), TestAlert()); err != nil {
+		t.Fatal(err)
+	}
+	requests, bodies, _ := srv.received()
+	if !strings.Contains(bodies[0], `"rule":"test"`) || requests[0].Header.Get(SignatureHeader) != "" {
+		t.Errorf("body = %s, signature = %q", bodies[0], requests[0].Header.Get(SignatureHeader))
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		attempts  int
+		success   bool
+		permanent bool
+	}{
+		{"server errors are retried", []int{503, 500}, 3, true, false},
+		{"too many requests is retried", []int{429}, 2, true, false},
+		{"request timeout is retried", []int{408}, 2, true, false},
+		{"client errors are permanent", []int{400, 200}, 1, false, true},
+		{"not found is permanent", []int{404}, 1, false, true},
+		{"retries are limited", []int{502, 502, 502, 502, 502}, 4, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newWebhookServer(tt.statuses...)
+			defer srv.Close()
+
+			c, err := NewChannel(config.NotifyChannel{Name: "hook", Type: config.NotifyWebhook, Url: srv.URL,
+				Timeout: 5, MaxRetries: 3, RetryBackoff: 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.Send(context.Background(), TestAlert())
+			if (err == nil) != tt.success {
+				t.Fatalf("Send() = %v, want success %v", err, tt.success)
+			}
+			requests, _, times := srv.received()
+			if len(requests) != tt.attempts {
+				t.Errorf("attempts = %d, want %d", len(requests), tt.attempts)
+			}
+			var permanent *permanentError
+			if errors.As(err, &permanent) != tt.permanent {
+				t.Errorf("permanent = %v, want %v (%v)", !tt.permanent, tt.permanent, err)
+			}
+
+			// The wait doubles at every retry (20ms, 40ms, ...)
+			backoff := 20 * time.Millisecond
+			for i := 1; i < len(times); i++ {
+				if wait := times[i].Sub(times[i-1]); wait < backoff {
+					t.Errorf("wait before attempt %d = %s, want at least %s", i+1, wait, backoff)
+				}
+				backoff *= 2
+			}
+		})
+	}
+}
+
+func TestSendCancel(t *testing.T) {
+	srv := newWebhookServer(503, 503)
+	defer srv.Close()
+
+	c, err := NewChannel(config.NotifyChannel{Name: "hook", Type: config.NotifyWebhook, Url: srv.URL,
+		Timeout: 5, MaxRetries: 3, RetryBackoff: 60000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Send(ctx, TestAlert()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
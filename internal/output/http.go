@@ -0,0 +1,160 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+)
+
+// File extension of an uncompressed batch
+const ndjsonExtension = ".ndjson"
+
+// Maximum size of the response body included in an error
+const maxErrorBodySize = 512
+
+// httpTransport posts the batches as newline-delimited JSON, which is
+// accepted by POST /api/v1/ingest of another log_manager
+type httpTransport struct {
+	client *http.Client
+}
+
+// newHttpTransport create HTTP transport.
+//
+// Returns:
+//   - *httpTransport: HTTP transport
+func newHttpTransport() *httpTransport {
+	return &httpTransport{client: &http.Client{}}
+}
+
+// encode appends an entry as a JSON line.
+//
+// Parameters:
+//   - conf: destination configuration
+//   - buf: batch being built
+//   - e: log entry
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (t *httpTransport) encode(conf config.Destination, buf *bytes.Buffer, e *entry.Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// seal compresses a batch by the compression of the destination.
+//
+// Parameters:
+//   - conf: destination configuration
+//   - data: JSON lines of the batch
+//
+// Returns:
+//   - []byte: batch data
+//   - string: file extension of the batch
+//   - error: success(nil), failure(error)
+func (t *httpTransport) seal(conf config.Destination, data []byte) ([]byte, string, error) {
+	alg, err := compress.ParseAlgorithm(conf.Compression)
+	if err != nil || alg == compress.None {
+		return data, ndjsonExtension, nil
+	}
+
+	var buf bytes.Buffer
+	w, err := compress.NewWriter(&buf, alg, compress.DefaultLevel)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return nil, "", fmt.Errorf("failed to compress batch: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to compress batch: %s", err)
+	}
+	return buf.Bytes(), ndjsonExtension + compress.Extension(alg), nil
+}
+
+// deliver posts a batch. The content encoding follows the compression
+// of the batch, which may differ from the current configuration for the
+// batches queued before a change.
+//
+// Parameters:
+//   - ctx: context for cancellation
+//   - conf: destination configuration
+//   - b: batch
+//   - data: batch data
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (t *httpTransport) deliver(ctx context.Context, conf config.Destination, b batch, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(conf.Timeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, conf.Url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	switch {
+	case strings.HasSuffix(b.name, compress.Extension(compress.Gzip)):
+		req.Header.Set("Content-Encoding", "gzip")
+	case strings.HasSuffix(b.name, compress.Extension(compress.Zstd)):
+		req.Header.Set("Content-Encoding", "zstd")
+	}
+	req.Header.Set("User-Agent", config.ModuleName+"/"+config.Version)
+	for key, value := range conf.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("unexpected response status: %s (%s)", resp.Status, strings.TrimSpace(string(body)))
+	// Client errors other than timeout and throttling are not retried
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err}
+	}
+	seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+	return &retryAfterError{err: err, wait: time.Duration(seconds) * time.Second}
+}
+
+// close releases the idle connections.
+func (t *httpTransport) close() {
+	t.client.CloseIdleConnections()
+}
@@ -0,0 +1,456 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package output implements the routing destinations that forward the
+entries to remote systems.
+
+The entries sent to an output are encoded into batches, and each batch
+is written to a queue on disk before it is delivered, so the entries
+survive outages of the remote system and restarts of the module. The
+batch being built is written to a journal on every Send, so that its
+entries are queued at the next start after a crash. The
+queue is bounded in size and its oldest batches are discarded when it
+is full. A failed delivery is retried with exponential backoff, and the
+batches are delivered in order.
+*/
+package output
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/metrics"
+	"github.com/hoon-kr/log_manager/internal/route"
+)
+
+// Queue directory name under the data directory (not a valid stream name)
+const queueDirName = ".outputs"
+
+// Maximum wait between retries
+const maxBackoff = 5 * time.Minute
+
+// Entry result
+const (
+	resultSent      = "sent"
+	resultRejected  = "rejected"
+	resultDiscarded = "discarded"
+)
+
+var (
+	// Number of entries waiting in the queue of each output
+	queueEntries = metrics.NewGauge("log_manager_output_queue_entries",
+		"Number of entries waiting in the disk queue of each output destination.", "destination")
+	// Size of the batches waiting in the queue of each output
+	queueBytes = metrics.NewGauge("log_manager_output_queue_bytes",
+		"Size of the batches waiting in the disk queue of each output destination.", "destination")
+	// Number of entries per output and result
+	entriesTotal = metrics.NewCounter("log_manager_output_entries_total",
+		"Number of entries of each output destination per result (sent, rejected, discarded).",
+		"destination", "result")
+)
+
+// Output is an output destination
+type Output interface {
+	route.Output
+	// Run delivers the queued batches until the context is cancelled.
+	Run(ctx context.Context)
+	// Reload applies a new configuration of the destination.
+	Reload(conf config.Destination)
+	// Remove deletes the queue of a stopped output if it is empty, and
+	// discards the entries sent afterwards. It returns the number of
+	// entries left in the queue.
+	Remove() int
+}
+
+// transport encodes and delivers the batches of an output type
+type transport interface {
+	// encode appends an entry to the batch being built.
+	encode(conf config.Destination, buf *bytes.Buffer, e *entry.Entry) error
+	// seal finalizes a batch before it is queued and returns the file
+	// extension of the batch.
+	seal(conf config.Destination, data []byte) ([]byte, string, error)
+	// deliver sends a queued batch to the remote system.
+	deliver(ctx context.Context, conf config.Destination, b batch, data []byte) error
+	// close releases the connection to the remote system.
+	close()
+}
+
+// permanentError is a delivery error of a batch that is never accepted
+type permanentError struct {
+	err error
+}
+
+// Error returns the error message.
+//
+// Returns:
+//   - string: error message
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// retryAfterError is a delivery error with the wait requested by the
+// remote system
+type retryAfterError struct {
+	err  error
+	wait time.Duration
+}
+
+// Error returns the error message.
+//
+// Returns:
+//   - string: error message
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+// New create an output of the destination. The configuration is
+// validated when it is loaded.
+//
+// Parameters:
+//   - conf: destination configuration
+//
+// Returns:
+//   - Output: output
+//   - error: success(nil), failure(error)
+func New(conf config.Destination) (Output, error) {
+	switch conf.Type {
+	case config.DestinationHttp:
+		return newForwarder(conf, newHttpTransport())
+	case config.DestinationSyslog:
+		return newForwarder(conf, newSyslogTransport())
+	}
+	return nil, fmt.Errorf("unsupported output type: %s", conf.Type)
+}
+
+// IsOutput reports whether the destination type is an output.
+//
+// Parameters:
+//   - destType: destination type
+//
+// Returns:
+//   - bool: output(true), other destination(false)
+func IsOutput(destType string) bool {
+	return destType == config.DestinationHttp || destType == config.DestinationSyslog
+}
+
+// forwarder batches, queues and delivers the entries of an output
+type forwarder struct {
+	name      string
+	transport transport
+	queue     *queue
+	wake      chan struct{}
+	mu        sync.Mutex
+	conf      config.Destination
+	journal   *journal
+	pending   bytes.Buffer
+	count     int
+	journaled int // Size of the pending data written to the journal
+	jcount    int // Number of the pending entries written to the journal
+	since     time.Time
+	closed    bool
+	removed   bool
+	backoff   time.Duration
+	failures  int
+}
+
+// newForwarder create a forwarder and opens its queue. The entries left
+// in the journal by the previous run are queued.
+//
+// Parameters:
+//   - conf: destination configuration
+//   - t: transport of the output type
+//
+// Returns:
+//   - *forwarder: forwarder
+//   - error: success(nil), failure(error)
+func newForwarder(conf config.Destination, t transport) (*forwarder, error) {
+	dir := filepath.Join(config.Conf.DataDirPath, queueDirName, conf.Type, conf.Name)
+	q, err := openQueue(dir, int64(conf.QueueSize)*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	j, pending, count, err := openJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &forwarder{
+		name:      conf.Name,
+		transport: t,
+		queue:     q,
+		wake:      make(chan struct{}, 1),
+		conf:      conf,
+		journal:   j,
+	}
+	if count > 0 {
+		logger.Log.LogInfo("Queued %d entries of the journal (destination:%s)", count, f.name)
+		f.pending.Write(pending)
+		f.count = count
+		f.flush()
+	}
+	f.updateDepth()
+	return f, nil
+}
+
+// Send encodes the entries into the current batch, which is queued when
+// it is full. The entries not queued are written to the journal. Entries
+// sent after the forwarder is stopped are queued immediately, and those
+// sent after it is removed are discarded.
+//
+// Parameters:
+//   - entries: log entries
+func (f *forwarder) Send(entries []entry.Entry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.removed {
+		logger.Log.LogWarn("Discarded %d entries of the removed destination (destination:%s)", len(entries), f.name)
+		entriesTotal.Add(float64(len(entries)), f.name, resultDiscarded)
+		return
+	}
+
+	for i := range entries {
+		if err := f.transport.encode(f.conf, &f.pending, &entries[i]); err != nil {
+			logger.Log.LogWarn("failed to encode entry (destination:%s): %s", f.name, err)
+			entriesTotal.Inc(f.name, resultRejected)
+			continue
+		}
+		if f.count == 0 {
+			f.since = time.Now()
+		}
+		f.count++
+		if f.count >= f.conf.BatchSize {
+			f.flush()
+		}
+	}
+	if f.closed {
+		f.flush()
+		return
+	}
+
+	if f.pending.Len() > f.journaled {
+		if err := f.journal.append(f.pending.Bytes()[f.journaled:], f.count-f.jcount); err != nil {
+			logger.Log.LogWarn("%s (destination:%s)", err, f.name)
+		}
+		f.journaled, f.jcount = f.pending.Len(), f.count
+	}
+}
+
+// Reload applies a new configuration of the destination.
+//
+// Parameters:
+//   - conf: destination configuration
+func (f *forwarder) Reload(conf config.Destination) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conf = conf
+	f.queue.setMaxBytes(int64(conf.QueueSize) * 1024 * 1024)
+}
+
+// Remove deletes the queue of the stopped forwarder if it is empty, and
+// discards the entries sent afterwards.
+//
+// Returns:
+//   - int: number of entries left in the queue
+func (f *forwarder) Remove() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.removed = true
+	f.journal.close()
+	entries, _ := f.queue.depth()
+	if entries == 0 {
+		if err := os.RemoveAll(f.queue.dir); err != nil {
+			logger.Log.LogWarn("failed to remove queue (destination:%s): %s", f.name, err)
+		}
+	}
+	return entries
+}
+
+// flush writes the current batch to the queue and empties the journal.
+// The lock must be held.
+func (f *forwarder) flush() {
+	if f.count == 0 {
+		return
+	}
+	count := f.count
+	defer func() {
+		f.pending.Reset()
+		f.count, f.journaled, f.jcount = 0, 0, 0
+		if err := f.journal.reset(); err != nil {
+			logger.Log.LogWarn("%s (destination:%s)", err, f.name)
+		}
+	}()
+
+	data, extension, err := f.transport.seal(f.conf, f.pending.Bytes())
+	discarded := 0
+	if err == nil {
+		discarded, err = f.queue.push(data, count, extension)
+	}
+	if err != nil {
+		logger.Log.LogError("failed to queue entries (destination:%s, entries:%d): %s", f.name, count, err)
+		entriesTotal.Add(float64(count), f.name, resultDiscarded)
+		return
+	}
+	if discarded > 0 {
+		logger.Log.LogWarn("Discarded the oldest %d entries of the full queue (destination:%s)", discarded, f.name)
+		entriesTotal.Add(float64(discarded), f.name, resultDiscarded)
+	}
+	f.updateDepth()
+
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers the queued batches until the context is cancelled. The
+// current batch is queued when it is older than the batch interval and
+// when the forwarder is stopped.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (f *forwarder) Run(ctx context.Context) {
+	logger.Log.LogInfo("Start output (destination:%s, type:%s)", f.name, f.conf.Type)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var retryAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			f.mu.Lock()
+			f.flush()
+			f.closed = true
+			f.mu.Unlock()
+			f.transport.close()
+			return
+		case <-timer.C:
+		case <-f.wake:
+		}
+
+		f.mu.Lock()
+		conf := f.conf
+		interval := time.Duration(conf.BatchInterval) * time.Second
+		if f.count > 0 && time.Since(f.since) >= interval {
+			f.flush()
+		}
+		wait := interval
+		if f.count > 0 {
+			wait = interval - time.Since(f.since)
+		}
+		f.mu.Unlock()
+
+		if !time.Now().Before(retryAt) {
+			retryAt = f.deliverAll(ctx, conf)
+		}
+		if !retryAt.IsZero() {
+			wait = min(wait, time.Until(retryAt))
+		}
+		timer.Reset(max(wait, 0))
+	}
+}
+
+// deliverAll delivers the queued batches in order until the queue is
+// empty or a delivery fails.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+//   - conf: destination configuration
+//
+// Returns:
+//   - time.Time: time of the next retry (zero if the queue is empty)
+func (f *forwarder) deliverAll(ctx context.Context, conf config.Destination) time.Time {
+	for ctx.Err() == nil {
+		b, ok := f.queue.peek()
+		if !ok {
+			return time.Time{}
+		}
+
+		data, err := f.queue.read(b)
+		if err != nil {
+			// A batch discarded while being read is skipped silently
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.Log.LogWarn("failed to read batch (destination:%s): %s", f.name, err)
+				entriesTotal.Add(float64(b.entries), f.name, resultDiscarded)
+			}
+			f.queue.remove(b)
+			f.updateDepth()
+			continue
+		}
+
+		err = f.transport.deliver(ctx, conf, b, data)
+		if ctx.Err() != nil {
+			return time.Time{}
+		}
+
+		// A batch discarded by push while being delivered is already
+		// counted as discarded
+		var permanent *permanentError
+		switch {
+		case err == nil:
+			if f.queue.remove(b) {
+				entriesTotal.Add(float64(b.entries), f.name, resultSent)
+			}
+			if f.failures > 0 {
+				logger.Log.LogInfo("Resumed output delivery (destination:%s, failures:%d)", f.name, f.failures)
+			}
+			f.failures = 0
+			f.backoff = 0
+		case errors.As(err, &permanent):
+			logger.Log.LogError("batch rejected by the remote system (destination:%s, entries:%d): %s",
+				f.name, b.entries, err)
+			if f.queue.remove(b) {
+				entriesTotal.Add(float64(b.entries), f.name, resultRejected)
+			}
+		default:
+			f.failures++
+			if f.backoff == 0 {
+				f.backoff = time.Duration(conf.RetryBackoff) * time.Millisecond
+			} else {
+				f.backoff = min(f.backoff*2, maxBackoff)
+			}
+			wait := f.backoff
+			var retryAfter *retryAfterError
+			if errors.As(err, &retryAfter) && retryAfter.wait > 0 {
+				wait = min(retryAfter.wait, maxBackoff)
+			}
+			entries, _ := f.queue.depth()
+			logger.Log.LogWarn("failed to deliver batch (destination:%s, queued entries:%d, retry in:%s): %s",
+				f.name, entries, wait, err)
+			return time.Now().Add(wait)
+		}
+		f.updateDepth()
+	}
+	return time.Time{}
+}
+
+// updateDepth updates the queue depth metrics.
+func (f *forwarder) updateDepth() {
+	entries, size := f.queue.depth()
+	queueEntries.Set(float64(entries), f.name)
+	queueBytes.Set(float64(size), f.name)
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package output
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	for i := range config.Conf.LogSinks {
+		config.Conf.LogSinks[i].Enable = false
+	}
+	logger.Log.InitializeLogger()
+	os.Exit(m.Run())
+}
+
+// newTestForwarder creates an http forwarder whose queue is in a
+// temporary directory.
+func newTestForwarder(t *testing.T, dataDir string) *forwarder {
+	t.Helper()
+	config.Conf.DataDirPath = dataDir
+	conf := config.Destination{Name: "test", Type: config.DestinationHttp, Compression: "none",
+		BatchSize: 3, BatchInterval: 5, QueueSize: 1}
+	f, err := newForwarder(conf, newHttpTransport())
+	if err != nil {
+		t.Fatalf("newForwarder: %s", err)
+	}
+	return f
+}
+
+// testEntries creates log entries with the messages.
+func testEntries(messages ...string) []entry.Entry {
+	entries := make([]entry.Entry, len(messages))
+	for i, msg := range messages {
+		entries[i] = entry.Entry{Time: time.Unix(1700000000, 0), Level: "info", Stream: "app", Message: msg}
+	}
+	return entries
+}
+
+func TestForwarderJournalRecovery(t *testing.T) {
+	dataDir := t.TempDir()
+	f := newTestForwarder(t, dataDir)
+	f.Send(testEntries("one"))
+	f.Send(testEntries("two"))
+	if entries, _ := f.queue.depth(); entries != 0 {
+		t.Fatalf("queued entries = %d, want 0 before the batch is full", entries)
+	}
+
+	// Crash before the batch is queued
+	f.journal.close()
+
+	f = newTestForwarder(t, dataDir)
+	defer f.journal.close()
+	b, ok := f.queue.peek()
+	if !ok || b.entries != 2 {
+		t.Fatalf("peek = (%+v, %t), want a batch of 2 entries", b, ok)
+	}
+	data, err := f.queue.read(b)
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"one"`) || !strings.Contains(lines[1], `"two"`) {
+		t.Errorf("recovered batch = %q, want the entries one and two", data)
+	}
+	if info, err := f.journal.file.Stat(); err != nil || info.Size() != 0 {
+		t.Errorf("journal not emptied after the batch is queued")
+	}
+}
+
+func TestForwarderJournalFlush(t *testing.T) {
+	f := newTestForwarder(t, t.TempDir())
+	defer f.journal.close()
+
+	// The full batch is queued and only the rest stays in the journal
+	f.Send(testEntries("one", "two", "three", "four"))
+	if entries, _ := f.queue.depth(); entries != 3 {
+		t.Errorf("queued entries = %d, want 3", entries)
+	}
+	f.journal.close()
+	j, pending, entries, err := openJournal(f.queue.dir)
+	if err != nil {
+		t.Fatalf("openJournal: %s", err)
+	}
+	f.journal = j
+	if entries != 1 || !strings.Contains(string(pending), `"four"`) {
+		t.Errorf("journal = (%q, %d), want the entry four", pending, entries)
+	}
+}
+
+func TestForwarderRemove(t *testing.T) {
+	f := newTestForwarder(t, t.TempDir())
+	f.Send(testEntries("one", "two", "three"))
+
+	// The queue with entries is kept
+	if left := f.Remove(); left != 3 {
+		t.Errorf("Remove = %d, want 3", left)
+	}
+	if _, err := os.Stat(f.queue.dir); err != nil {
+		t.Errorf("queue with entries was removed: %s", err)
+	}
+
+	// Entries sent after the removal are discarded
+	f.Send(testEntries("four"))
+	if entries, _ := f.queue.depth(); entries != 3 || f.pending.Len() != 0 {
+		t.Errorf("entries sent after the removal were kept")
+	}
+
+	// The empty queue is deleted
+	g := newTestForwarder(t, t.TempDir())
+	if left := g.Remove(); left != 0 {
+		t.Errorf("Remove = %d, want 0", left)
+	}
+	if _, err := os.Stat(g.queue.dir); !os.IsNotExist(err) {
+		t.Errorf("empty queue was not removed")
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package output
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Extension of the temporary file of a batch being written
+const tempExtension = ".tmp"
+
+// batch is a batch file of the queue
+type batch struct {
+	seq     uint64
+	entries int
+	size    int64
+	name    string
+}
+
+// queue is a bounded queue of batch files on disk. The batches are
+// delivered in the order they were pushed and survive restarts.
+type queue struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+	batches  []batch
+	size     int64
+	nextSeq  uint64
+}
+
+// openQueue opens the queue in the directory and loads the batches left
+// by the previous run.
+//
+// Parameters:
+//   - dir: queue directory
+//   - maxBytes: maximum total size of the batches
+//
+// Returns:
+//   - *queue: queue
+//   - error: success(nil), failure(error)
+func openQueue(dir string, maxBytes int64) (*queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %s", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue directory: %s", err)
+	}
+
+	q := &queue{dir: dir, maxBytes: maxBytes}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(f.Name(), tempExtension) {
+			// Batch interrupted while being written
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		b, ok := parseBatchName(f.Name())
+		if !ok {
+			continue
+		}
+		if info, err := f.Info(); err == nil {
+			b.size = info.Size()
+		}
+		q.batches = append(q.batches, b)
+		q.size += b.size
+		q.nextSeq = max(q.nextSeq, b.seq+1)
+	}
+	sort.Slice(q.batches, func(i, j int) bool { return q.batches[i].seq < q.batches[j].seq })
+	return q, nil
+}
+
+// parseBatchName parses a batch file name (<seq>-<entries>.<extension>).
+//
+// Parameters:
+//   - name: file name
+//
+// Returns:
+//   - batch: batch without size
+//   - bool: batch file(true), other file(false)
+func parseBatchName(name string) (batch, bool) {
+	base, _, _ := strings.Cut(name, ".")
+	seqStr, entriesStr, found := strings.Cut(base, "-")
+	if !found {
+		return batch{}, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return batch{}, false
+	}
+	entries, err := strconv.Atoi(entriesStr)
+	if err != nil {
+		return batch{}, false
+	}
+	return batch{seq: seq, entries: entries, name: name}, true
+}
+
+// push writes a batch at the end of the queue. If the queue exceeds its
+// maximum size, the oldest batches are discarded.
+//
+// Parameters:
+//   - data: batch data
+//   - entries: number of entries in the batch
+//   - extension: file extension of the batch (e.g. .ndjson.gz)
+//
+// Returns:
+//   - int: number of discarded entries
+//   - error: success(nil), failure(error)
+func (q *queue) push(data []byte, entries int, extension string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	b := batch{seq: q.nextSeq, entries: entries, size: int64(len(data))}
+	b.name = fmt.Sprintf("%020d-%d%s", b.seq, b.entries, extension)
+	if err := writeFileSync(filepath.Join(q.dir, b.name), data); err != nil {
+		return 0, err
+	}
+	q.nextSeq++
+	q.batches = append(q.batches, b)
+	q.size += b.size
+
+	discarded := 0
+	for q.size > q.maxBytes && len(q.batches) > 1 {
+		oldest := q.batches[0]
+		os.Remove(filepath.Join(q.dir, oldest.name))
+		q.batches = q.batches[1:]
+		q.size -= oldest.size
+		discarded += oldest.entries
+	}
+	return discarded, nil
+}
+
+// writeFileSync writes a file through a temporary file so that a
+// partially written file is never seen as a batch.
+//
+// Parameters:
+//   - path: file path
+//   - data: file data
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func writeFileSync(path string, data []byte) error {
+	tmpPath := path + tempExtension
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create batch file: %s", err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write batch file: %s", err)
+	}
+	return nil
+}
+
+// peek returns the oldest batch.
+//
+// Returns:
+//   - batch: oldest batch
+//   - bool: exists(true), empty queue(false)
+func (q *queue) peek() (batch, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.batches) == 0 {
+		return batch{}, false
+	}
+	return q.batches[0], true
+}
+
+// read reads the data of a batch.
+//
+// Parameters:
+//   - b: batch
+//
+// Returns:
+//   - []byte: batch data
+//   - error: success(nil), failure(error)
+func (q *queue) read(b batch) ([]byte, error) {
+	return os.ReadFile(filepath.Join(q.dir, b.name))
+}
+
+// remove deletes a batch. A batch already discarded is ignored.
+//
+// Parameters:
+//   - b: batch
+//
+// Returns:
+//   - bool: removed(true), already discarded(false)
+func (q *queue) remove(b batch) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.batches {
+		if q.batches[i].seq == b.seq {
+			os.Remove(filepath.Join(q.dir, b.name))
+			q.size -= q.batches[i].size
+			q.batches = append(q.batches[:i], q.batches[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// setMaxBytes changes the maximum total size of the batches. It is
+// applied at the next push.
+//
+// Parameters:
+//   - maxBytes: maximum total size of the batches
+func (q *queue) setMaxBytes(maxBytes int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxBytes = maxBytes
+}
+
+// depth returns the number of entries and the size of the batches.
+//
+// Returns:
+//   - int: number of entries
+//   - int64: total size of the batches
+func (q *queue) depth() (int, int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries := 0
+	for _, b := range q.batches {
+		entries += b.entries
+	}
+	return entries, q.size
+}
+
+// File name of the journal of the batch being built (not a batch name)
+const journalName = "pending.journal"
+
+// Size of the record header of the journal (entries, data length)
+const journalHeaderSize = 8
+
+// journal is the write-ahead file of the batch being built. The entries
+// of every Send are appended to it, so that they survive a crash of the
+// module before the batch is queued. It is emptied when the batch is
+// queued.
+type journal struct {
+	file *os.File
+}
+
+// openJournal opens the journal in the queue directory and reads the
+// entries left by the previous run. A record cut off by a crash is
+// ignored.
+//
+// Parameters:
+//   - dir: queue directory
+//
+// Returns:
+//   - *journal: journal
+//   - []byte: encoded entries of the previous run
+//   - int: number of entries of the previous run
+//   - error: success(nil), failure(error)
+func openJournal(dir string) (*journal, []byte, int, error) {
+	path := filepath.Join(dir, journalName)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, 0, fmt.Errorf("failed to read journal: %s", err)
+	}
+
+	pending := []byte{}
+	entries := 0
+	for len(data) >= journalHeaderSize {
+		count := int(binary.BigEndian.Uint32(data))
+		size := int(binary.BigEndian.Uint32(data[4:]))
+		if len(data) < journalHeaderSize+size {
+			break
+		}
+		pending = append(pending, data[journalHeaderSize:journalHeaderSize+size]...)
+		entries += count
+		data = data[journalHeaderSize+size:]
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to open journal: %s", err)
+	}
+	return &journal{file: file}, pending, entries, nil
+}
+
+// append writes the encoded entries of a Send as a record.
+//
+// Parameters:
+//   - data: encoded entries
+//   - entries: number of entries
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (j *journal) append(data []byte, entries int) error {
+	record := make([]byte, journalHeaderSize, journalHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record, uint32(entries))
+	binary.BigEndian.PutUint32(record[4:], uint32(len(data)))
+	if _, err := j.file.Write(append(record, data...)); err != nil {
+		return fmt.Errorf("failed to write journal: %s", err)
+	}
+	return nil
+}
+
+// reset empties the journal after the batch is queued.
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (j *journal) reset() error {
+	if err := j.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate journal: %s", err)
+	}
+	return nil
+}
+
+// close closes the journal file.
+func (j *journal) close() {
+	j.file.Close()
+}
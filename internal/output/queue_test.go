@@ -0,0 +1,171 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pushBatch pushes a batch and fails the test on error.
+func pushBatch(t *testing.T, q *queue, data string, entries int) int {
+	t.Helper()
+	discarded, err := q.push([]byte(data), entries, ndjsonExtension)
+	if err != nil {
+		t.Fatalf("push: %s", err)
+	}
+	return discarded
+}
+
+func TestQueueRestart(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openQueue(dir, 1024)
+	if err != nil {
+		t.Fatalf("openQueue: %s", err)
+	}
+	pushBatch(t, q, "first\n", 1)
+	pushBatch(t, q, "second\nthird\n", 2)
+
+	// Batch interrupted while being written by the previous run
+	tmpPath := filepath.Join(dir, "00000000000000000002-1"+ndjsonExtension+tempExtension)
+	if err := os.WriteFile(tmpPath, []byte("cut"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = openQueue(dir, 1024)
+	if err != nil {
+		t.Fatalf("openQueue: %s", err)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Errorf("temporary batch file was not removed")
+	}
+	if entries, size := q.depth(); entries != 3 || size != 19 {
+		t.Errorf("depth = (%d, %d), want (3, 19)", entries, size)
+	}
+
+	for _, want := range []string{"first\n", "second\nthird\n"} {
+		b, ok := q.peek()
+		if !ok {
+			t.Fatalf("peek: empty queue, want %q", want)
+		}
+		data, err := q.read(b)
+		if err != nil {
+			t.Fatalf("read: %s", err)
+		}
+		if string(data) != want {
+			t.Errorf("read = %q, want %q", data, want)
+		}
+		q.remove(b)
+	}
+	if _, ok := q.peek(); ok {
+		t.Errorf("peek: queue not empty after removing every batch")
+	}
+
+	// The sequence continues after the batches of the previous run
+	pushBatch(t, q, "fourth\n", 1)
+	if b, _ := q.peek(); b.seq != 2 {
+		t.Errorf("seq = %d, want 2", b.seq)
+	}
+}
+
+func TestQueueDiscard(t *testing.T) {
+	q, err := openQueue(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("openQueue: %s", err)
+	}
+	pushBatch(t, q, "aaaa", 1)
+	pushBatch(t, q, "bbbb", 2)
+	if discarded := pushBatch(t, q, "cccc", 3); discarded != 1 {
+		t.Errorf("discarded = %d, want 1", discarded)
+	}
+	if entries, size := q.depth(); entries != 5 || size != 8 {
+		t.Errorf("depth = (%d, %d), want (5, 8)", entries, size)
+	}
+
+	// The last batch is kept even if it exceeds the maximum size alone
+	if discarded := pushBatch(t, q, "dddddddddddd", 4); discarded != 5 {
+		t.Errorf("discarded = %d, want 5", discarded)
+	}
+	b, ok := q.peek()
+	if !ok || b.entries != 4 {
+		t.Errorf("peek = (%+v, %t), want the last batch", b, ok)
+	}
+
+	// A batch already discarded is ignored, so that a batch discarded
+	// while being delivered is not counted as sent
+	if q.remove(batch{seq: 0, name: "00000000000000000000-1" + ndjsonExtension}) {
+		t.Errorf("remove of a discarded batch = true, want false")
+	}
+	if entries, _ := q.depth(); entries != 4 {
+		t.Errorf("entries = %d, want 4", entries)
+	}
+	if !q.remove(b) {
+		t.Errorf("remove of a queued batch = false, want true")
+	}
+	if entries, size := q.depth(); entries != 0 || size != 0 {
+		t.Errorf("depth = (%d, %d), want (0, 0)", entries, size)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	j, pending, entries, err := openJournal(dir)
+	if err != nil {
+		t.Fatalf("openJournal: %s", err)
+	}
+	if len(pending) != 0 || entries != 0 {
+		t.Errorf("new journal = (%q, %d), want empty", pending, entries)
+	}
+	if err := j.append([]byte("a\nb\n"), 2); err != nil {
+		t.Fatalf("append: %s", err)
+	}
+	if err := j.append([]byte("c\n"), 1); err != nil {
+		t.Fatalf("append: %s", err)
+	}
+	// Record cut off by a crash
+	if _, err := j.file.Write([]byte{0, 0, 0, 1, 0, 0, 0, 9, 'd'}); err != nil {
+		t.Fatal(err)
+	}
+	j.close()
+
+	j, pending, entries, err = openJournal(dir)
+	if err != nil {
+		t.Fatalf("openJournal: %s", err)
+	}
+	if !bytes.Equal(pending, []byte("a\nb\nc\n")) || entries != 3 {
+		t.Errorf("recovered = (%q, %d), want (%q, 3)", pending, entries, "a\nb\nc\n")
+	}
+
+	if err := j.reset(); err != nil {
+		t.Fatalf("reset: %s", err)
+	}
+	if err := j.append([]byte("e\n"), 1); err != nil {
+		t.Fatalf("append: %s", err)
+	}
+	j.close()
+
+	j, pending, entries, err = openJournal(dir)
+	if err != nil {
+		t.Fatalf("openJournal: %s", err)
+	}
+	defer j.close()
+	if string(pending) != "e\n" || entries != 1 {
+		t.Errorf("recovered after reset = (%q, %d), want (%q, 1)", pending, entries, "e\n")
+	}
+}
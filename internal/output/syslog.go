@@ -0,0 +1,389 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// File extension of a batch of octet-counted syslog messages
+const syslogExtension = ".syslog"
+
+// Timestamp format of the syslog messages (RFC 5424, microseconds)
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Maximum size of a UDP syslog message (larger messages are truncated)
+const maxDatagramSize = 65000
+
+// Maximum length of the header fields and SD-PARAM names (RFC 5424)
+const (
+	maxHostnameLength  = 255
+	maxAppNameLength   = 48
+	maxMsgIdLength     = 32
+	maxParamNameLength = 32
+)
+
+// Escaper of the SD-PARAM values
+var paramEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogTransport writes the batches as RFC 5424 messages over UDP, TCP
+// or TLS. A TCP or TLS connection is kept open and reconnected when it
+// fails.
+type syslogTransport struct {
+	hostname string
+	conn     net.Conn
+	target   string
+}
+
+// newSyslogTransport create syslog transport.
+//
+// Returns:
+//   - *syslogTransport: syslog transport
+func newSyslogTransport() *syslogTransport {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+	return &syslogTransport{hostname: hostname}
+}
+
+// encode appends an entry as an octet-counted syslog message
+// (MSG-LEN SP SYSLOG-MSG, RFC 6587).
+//
+// Parameters:
+//   - conf: destination configuration
+//   - buf: batch being built
+//   - e: log entry
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (t *syslogTransport) encode(conf config.Destination, buf *bytes.Buffer, e *entry.Entry) error {
+	msg := t.format(conf, e)
+	buf.WriteString(strconv.Itoa(len(msg)))
+	buf.WriteByte(' ')
+	buf.Write(msg)
+	return nil
+}
+
+// format formats an entry as an RFC 5424 message. The level is mapped to
+// the severity, the stream to the MSGID, and the fields and labels to the
+// fields@<enterprise id> and labels@<enterprise id> SD-ELEMENTs.
+//
+// Parameters:
+//   - conf: destination configuration
+//   - e: log entry
+//
+// Returns:
+//   - []byte: syslog message
+func (t *syslogTransport) format(conf config.Destination, e *entry.Entry) []byte {
+	hostname := conf.Hostname
+	if hostname == "" {
+		hostname = t.hostname
+	}
+	appName := conf.AppName
+	if appName == "" {
+		appName = e.Source
+	}
+
+	var b bytes.Buffer
+	pri := config.SyslogFacilities[conf.Facility]*8 + entry.SyslogSeverity(e.Level)
+	fmt.Fprintf(&b, "<%d>1 %s %s %s - %s ", pri, e.Time.UTC().Format(syslogTimeFormat),
+		headerField(hostname, maxHostnameLength), headerField(appName, maxAppNameLength),
+		headerField(e.Stream, maxMsgIdLength))
+
+	if len(e.Fields) == 0 && len(e.Labels) == 0 {
+		b.WriteByte('-')
+	}
+	if len(e.Fields) > 0 {
+		params := make(map[string]string, len(e.Fields))
+		for key, value := range e.Fields {
+			params[key] = paramValue(value)
+		}
+		writeElement(&b, "fields@"+strconv.Itoa(conf.EnterpriseId), params)
+	}
+	if len(e.Labels) > 0 {
+		writeElement(&b, "labels@"+strconv.Itoa(conf.EnterpriseId), e.Labels)
+	}
+
+	if e.Message != "" {
+		b.WriteByte(' ')
+		b.WriteString(strings.ToValidUTF8(e.Message, "�"))
+	}
+	return b.Bytes()
+}
+
+// headerField converts a value to a header field of printable ASCII
+// characters without spaces.
+//
+// Parameters:
+//   - value: field value
+//   - maxLength: maximum length of the field
+//
+// Returns:
+//   - string: header field (- if empty)
+func headerField(value string, maxLength int) string {
+	field := []byte(value)
+	for i, c := range field {
+		if c < 33 || c > 126 {
+			field[i] = '_'
+		}
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	if len(field) > maxLength {
+		field = field[:maxLength]
+	}
+	return string(field)
+}
+
+// paramValue converts a field value to an SD-PARAM value. Values other
+// than strings are encoded as JSON.
+//
+// Parameters:
+//   - value: field value
+//
+// Returns:
+//   - string: parameter value
+func paramValue(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// writeElement writes an SD-ELEMENT with the parameters ordered by name.
+//
+// Parameters:
+//   - b: message buffer
+//   - id: SD-ID
+//   - params: parameter values by name
+func writeElement(b *bytes.Buffer, id string, params map[string]string) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b.WriteByte('[')
+	b.WriteString(id)
+	for _, name := range names {
+		field := []byte(headerField(name, maxParamNameLength))
+		for i, c := range field {
+			if c == '=' || c == ']' || c == '"' {
+				field[i] = '_'
+			}
+		}
+		b.WriteByte(' ')
+		b.Write(field)
+		b.WriteString(`="`)
+		b.WriteString(paramEscaper.Replace(strings.ToValidUTF8(params[name], "�")))
+		b.WriteByte('"')
+	}
+	b.WriteByte(']')
+}
+
+// seal returns the batch as it is.
+//
+// Parameters:
+//   - conf: destination configuration
+//   - data: octet-counted messages of the batch
+//
+// Returns:
+//   - []byte: batch data
+//   - string: file extension of the batch
+//   - error: success(nil), failure(error)
+func (t *syslogTransport) seal(conf config.Destination, data []byte) ([]byte, string, error) {
+	return data, syslogExtension, nil
+}
+
+// deliver writes a batch. Over TCP and TLS the octet-counted messages are
+// written as they are, and over UDP each message is sent as a datagram.
+// The messages of a batch that failed in the middle are written again,
+// so the receiver may get some of them twice.
+//
+// Parameters:
+//   - ctx: context for cancellation
+//   - conf: destination configuration
+//   - b: batch
+//   - data: batch data
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (t *syslogTransport) deliver(ctx context.Context, conf config.Destination, b batch, data []byte) error {
+	conn, err := t.connect(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(time.Duration(conf.Timeout) * time.Second))
+	if conf.Protocol == config.SyslogUdp {
+		err = writeDatagrams(conn, data)
+	} else {
+		_, err = conn.Write(data)
+	}
+	if err != nil {
+		t.close()
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return err
+		}
+		return fmt.Errorf("failed to write messages: %s", err)
+	}
+	return nil
+}
+
+// writeDatagrams sends each octet-counted message as a datagram.
+//
+// Parameters:
+//   - conn: UDP connection
+//   - data: octet-counted messages
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func writeDatagrams(conn net.Conn, data []byte) error {
+	for len(data) > 0 {
+		lenStr, rest, found := bytes.Cut(data, []byte(" "))
+		n, err := strconv.Atoi(string(lenStr))
+		if !found || err != nil || n < 0 || n > len(rest) {
+			return &permanentError{fmt.Errorf("invalid message frame")}
+		}
+		msg := rest[:n]
+		if len(msg) > maxDatagramSize {
+			msg = msg[:maxDatagramSize]
+		}
+		if _, err := conn.Write(msg); err != nil {
+			return err
+		}
+		data = rest[n:]
+	}
+	return nil
+}
+
+// connect returns the connection to the server. The current connection
+// is reused unless the server closed it or its settings changed.
+//
+// Parameters:
+//   - ctx: context for cancellation
+//   - conf: destination configuration
+//
+// Returns:
+//   - net.Conn: connection
+//   - error: success(nil), failure(error)
+func (t *syslogTransport) connect(ctx context.Context, conf config.Destination) (net.Conn, error) {
+	target := strings.Join([]string{conf.Protocol, conf.Address, conf.TlsCaFile, conf.TlsCertFile, conf.TlsKeyFile}, "\n")
+	if t.conn != nil && t.target == target && (conf.Protocol == config.SyslogUdp || alive(t.conn)) {
+		return t.conn, nil
+	}
+	t.close()
+
+	dialer := &net.Dialer{Timeout: time.Duration(conf.Timeout) * time.Second}
+	var conn net.Conn
+	var err error
+	if conf.Protocol == config.SyslogTls {
+		var tlsConf *tls.Config
+		if tlsConf, err = newTlsConfig(conf); err != nil {
+			return nil, err
+		}
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConf}).DialContext(ctx, "tcp", conf.Address)
+	} else {
+		conn, err = dialer.DialContext(ctx, conf.Protocol, conf.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %s", err)
+	}
+	t.conn, t.target = conn, target
+	return conn, nil
+}
+
+// alive reports whether a stream connection is still usable. The server
+// never sends data, so anything other than a read timeout means that
+// the connection was closed.
+//
+// Parameters:
+//   - conn: connection
+//
+// Returns:
+//   - bool: usable(true), closed(false)
+func alive(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var buf [1]byte
+	_, err := conn.Read(buf[:])
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// newTlsConfig create the TLS configuration of the destination.
+//
+// Parameters:
+//   - conf: destination configuration
+//
+// Returns:
+//   - *tls.Config: TLS configuration
+//   - error: success(nil), failure(error)
+func newTlsConfig(conf config.Destination) (*tls.Config, error) {
+	host, _, _ := net.SplitHostPort(conf.Address)
+	tlsConf := &tls.Config{ServerName: host}
+
+	if conf.TlsCaFile != "" {
+		data, err := os.ReadFile(conf.TlsCaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate in ca file: %s", conf.TlsCaFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+	if conf.TlsCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.TlsCertFile, conf.TlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %s", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConf, nil
+}
+
+// close closes the connection.
+func (t *syslogTransport) close() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// syslogConf returns a syslog destination configuration.
+func syslogConf(protocol, address string) config.Destination {
+	return config.Destination{Name: "siem", Type: config.DestinationSyslog, Protocol: protocol,
+		Address: address, Facility: "user", EnterpriseId: 32473, Hostname: "host", AppName: "app", Timeout: 5}
+}
+
+// encodeBatch encodes the entries as a batch of the syslog transport.
+func encodeBatch(t *testing.T, tr *syslogTransport, conf config.Destination, entries []entry.Entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for i := range entries {
+		if err := tr.encode(conf, &buf, &entries[i]); err != nil {
+			t.Fatalf("encode: %s", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// readFrame reads an octet-counted message (MSG-LEN SP SYSLOG-MSG).
+func readFrame(r *bufio.Reader) (string, error) {
+	lenStr, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(lenStr[:len(lenStr)-1])
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+func TestSyslogFormat(t *testing.T) {
+	tr := &syslogTransport{hostname: "local"}
+	ts := time.Date(2024, 5, 1, 12, 30, 45, 123456000, time.UTC)
+	tests := []struct {
+		name string
+		conf config.Destination
+		e    entry.Entry
+		want string
+	}{
+		{
+			name: "no structured data",
+			conf: syslogConf(config.SyslogTcp, ""),
+			e:    entry.Entry{Time: ts, Level: entry.LevelError, Stream: "app", Message: "disk full"},
+			want: "<11>1 2024-05-01T12:30:45.123456Z host app - app - disk full",
+		},
+		{
+			name: "fields and labels",
+			conf: syslogConf(config.SyslogTcp, ""),
+			e: entry.Entry{Time: ts, Level: entry.LevelInfo, Stream: "web",
+				Fields: map[string]interface{}{"path": `/a"b]`, "code": 500},
+				Labels: map[string]string{"env": "prod"}},
+			want: `<14>1 2024-05-01T12:30:45.123456Z host app - web [fields@32473 code="500" path="/a\"b\]"][labels@32473 env="prod"]`,
+		},
+		{
+			name: "default header fields",
+			conf: config.Destination{Facility: "local0", EnterpriseId: 1},
+			e:    entry.Entry{Time: ts, Level: entry.LevelDebug, Source: "my app", Message: "x"},
+			want: "<135>1 2024-05-01T12:30:45.123456Z local my_app - - - x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tr.format(tt.conf, &tt.e)); got != tt.want {
+				t.Errorf("format =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogTcpFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+
+	tr := &syslogTransport{hostname: "local"}
+	defer tr.close()
+	conf := syslogConf(config.SyslogTcp, ln.Addr().String())
+	entries := []entry.Entry{
+		{Time: time.Unix(1700000000, 0), Level: entry.LevelInfo, Stream: "app", Message: "line 1\nline 2"},
+		{Time: time.Unix(1700000001, 0), Level: entry.LevelWarn, Stream: "app", Message: "12 34"},
+	}
+	data := encodeBatch(t, tr, conf, entries)
+
+	for round := 0; round < 2; round++ {
+		if err := tr.deliver(context.Background(), conf, batch{}, data); err != nil {
+			t.Fatalf("deliver: %s", err)
+		}
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		for i := range entries {
+			msg, err := readFrame(r)
+			if err != nil {
+				t.Fatalf("read frame: %s", err)
+			}
+			if want := string(tr.format(conf, &entries[i])); msg != want {
+				t.Errorf("message = %q, want %q", msg, want)
+			}
+		}
+		// The next delivery reconnects after the server closes the connection
+		conn.Close()
+	}
+}
+
+func TestSyslogUdp(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	tr := &syslogTransport{hostname: "local"}
+	defer tr.close()
+	conf := syslogConf(config.SyslogUdp, pc.LocalAddr().String())
+	entries := []entry.Entry{
+		{Time: time.Unix(1700000000, 0), Level: entry.LevelInfo, Stream: "app", Message: "first"},
+		{Time: time.Unix(1700000001, 0), Level: entry.LevelInfo, Stream: "app", Message: "second"},
+	}
+	if err := tr.deliver(context.Background(), conf, batch{}, encodeBatch(t, tr, conf, entries)); err != nil {
+		t.Fatalf("deliver: %s", err)
+	}
+
+	// Each message is a datagram without the octet count
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxDatagramSize)
+	for i := range entries {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read datagram: %s", err)
+		}
+		if want := string(tr.format(conf, &entries[i])); string(buf[:n]) != want {
+			t.Errorf("datagram = %q, want %q", buf[:n], want)
+		}
+	}
+}
+
+func TestWriteDatagramsInvalidFrame(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	for _, data := range []string{"abc", "5 abc", "-1 abc"} {
+		var permanent *permanentError
+		if err := writeDatagrams(client, []byte(data)); !errors.As(err, &permanent) {
+			t.Errorf("writeDatagrams(%q) = %v, want a permanent error", data, err)
+		}
+	}
+}
@@ -0,0 +1,493 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package parser implements the processing of the entries received by
+an input: the parser stages, which extract structured fields, the time
+and the level from log messages, and the event time rule.
+*/
+package parser
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/pkg/utils/grok"
+)
+
+// Field in which parse failures are recorded
+const ErrorField = "parse_error"
+
+// Chain is a parser stage chain structure
+type Chain struct {
+	stages    []*stage
+	timestamp *config.Timestamp
+	location  *time.Location
+}
+
+// stage is a compiled parser stage structure
+type stage struct {
+	conf config.Parser
+	re   *regexp.Regexp
+	grok *grok.Grok
+}
+
+// NewChain create parser stage chain of the listener. The stages and
+// the event time rule are validated when the configuration is loaded,
+// but a compile failure is still returned instead of panicking.
+//
+// Parameters:
+//   - listener: listener configuration
+//
+// Returns:
+//   - *Chain: parser stage chain (nil if there is no stage and no rule)
+//   - error: success(nil), failure(error)
+func NewChain(listener *config.Listener) (*Chain, error) {
+	if len(listener.Parsers) == 0 && listener.Timestamp == nil {
+		return nil, nil
+	}
+
+	c := &Chain{timestamp: listener.Timestamp, location: time.UTC}
+	if c.timestamp != nil {
+		loc, err := time.LoadLocation(c.timestamp.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load timezone (%s): %s", c.timestamp.Timezone, err)
+		}
+		c.location = loc
+	}
+	for i, p := range listener.Parsers {
+		st := &stage{conf: p}
+		switch p.Type {
+		case config.ParserRegex:
+			re, err := regexp.Compile(p.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile regex parser (index:%d): %s", i, err)
+			}
+			st.re = re
+		case config.ParserGrok:
+			g, err := grok.Compile(p.Pattern, p.Definitions)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile grok parser (index:%d): %s", i, err)
+			}
+			st.grok = g
+		}
+		c.stages = append(c.stages, st)
+	}
+	return c, nil
+}
+
+// Process applies the stages to the entries in order and then the event
+// time rule. An entry that fails a stage is kept with the failure in the
+// parse_error field and the next stages are still applied.
+//
+// Parameters:
+//   - entries: log entries (modified in place)
+//
+// Returns:
+//   - []entry.Entry: entries to submit (entries outside the time window may be dropped)
+func (c *Chain) Process(entries []entry.Entry) []entry.Entry {
+	if c == nil {
+		return entries
+	}
+
+	now := time.Now().UTC()
+	kept := entries[:0]
+	for i := range entries {
+		e := &entries[i]
+		for _, st := range c.stages {
+			if err := st.apply(e, c.location, now); err != nil {
+				tagFailure(e, st.conf.Type, err)
+			}
+		}
+		if c.timestamp != nil && !c.applyTimestamp(e, now) {
+			continue
+		}
+		kept = append(kept, *e)
+	}
+	return kept
+}
+
+// apply parses the entry and sets the extracted values.
+//
+// Parameters:
+//   - e: log entry
+//   - loc: time zone of times without a zone
+//   - now: receive time
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (st *stage) apply(e *entry.Entry, loc *time.Location, now time.Time) error {
+	text := e.Message
+	if st.conf.Field != "" && st.conf.Field != "msg" && st.conf.Field != "message" {
+		var exists bool
+		if text, exists = e.Field(st.conf.Field); !exists {
+			return fmt.Errorf("field not found: %s", st.conf.Field)
+		}
+	}
+
+	values, err := st.parse(text)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for key, value := range values {
+		switch key {
+		case st.conf.TimeKey:
+			t, err := ParseTime(value, st.conf.TimeFormat, loc, now)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid time: %s", err))
+				e.SetField(key, value)
+				continue
+			}
+			e.Time = t
+		case st.conf.LevelKey:
+			level, ok := parseLevel(value)
+			if !ok {
+				errs = append(errs, fmt.Sprintf("unknown level: %v", value))
+				e.SetField(key, value)
+				continue
+			}
+			e.Level = level
+		case st.conf.MessageKey:
+			if s, ok := value.(string); ok {
+				e.Message = s
+			} else {
+				e.Message = fmt.Sprint(value)
+			}
+		default:
+			e.SetField(key, value)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// parse extracts the values from the text.
+//
+// Parameters:
+//   - text: text to parse
+//
+// Returns:
+//   - map[string]interface{}: extracted values
+//   - error: success(nil), failure(error)
+func (st *stage) parse(text string) (map[string]interface{}, error) {
+	switch st.conf.Type {
+	case config.ParserRegex:
+		m := st.re.FindStringSubmatchIndex(text)
+		if m == nil {
+			return nil, fmt.Errorf("pattern does not match")
+		}
+		values := make(map[string]interface{})
+		for i, name := range st.re.SubexpNames() {
+			if name != "" && m[2*i] >= 0 {
+				values[name] = text[m[2*i]:m[2*i+1]]
+			}
+		}
+		return values, nil
+	case config.ParserGrok:
+		values, ok := st.grok.Match(text)
+		if !ok {
+			return nil, fmt.Errorf("pattern does not match")
+		}
+		return values, nil
+	case config.ParserJson:
+		values := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(text), &values); err != nil {
+			return nil, fmt.Errorf("invalid JSON object: %s", err)
+		}
+		return values, nil
+	case config.ParserLogfmt:
+		return parseLogfmt(text)
+	case config.ParserCsv:
+		return parseCsv(text, st.conf.Columns, st.conf.Delimiter)
+	case config.ParserKv:
+		return parseKv(text, st.conf.Delimiter, st.conf.Separator)
+	}
+	return nil, fmt.Errorf("unsupported parser type: %s", st.conf.Type)
+}
+
+// parseLogfmt parses a logfmt line (key=value key="quoted value" flag).
+// A key without a value is set to true.
+//
+// Parameters:
+//   - text: logfmt line
+//
+// Returns:
+//   - map[string]interface{}: extracted values
+//   - error: success(nil), failure(error)
+func parseLogfmt(text string) (map[string]interface{}, error) {
+	pairs, err := splitQuoted(text, " ")
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if key == "" || strings.ContainsAny(key, `"`) {
+			return nil, fmt.Errorf("invalid logfmt pair: %s", pair)
+		}
+		if !found {
+			values[key] = true
+			continue
+		}
+		if strings.HasPrefix(value, `"`) {
+			if value, err = strconv.Unquote(value); err != nil {
+				return nil, fmt.Errorf("invalid logfmt value: %s", pair)
+			}
+		}
+		values[key] = value
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no logfmt pair")
+	}
+	return values, nil
+}
+
+// parseKv parses key-value pairs with the delimiter and the separator.
+// Values may be quoted, and tokens without the separator are ignored.
+//
+// Parameters:
+//   - text: key-value text
+//   - delimiter: pair delimiter (DEF:" ")
+//   - separator: key-value separator (DEF:"=")
+//
+// Returns:
+//   - map[string]interface{}: extracted values
+//   - error: success(nil), failure(error)
+func parseKv(text, delimiter, separator string) (map[string]interface{}, error) {
+	if delimiter == "" {
+		delimiter = " "
+	}
+	if separator == "" {
+		separator = "="
+	}
+
+	pairs, err := splitQuoted(text, delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(strings.TrimSpace(pair), separator)
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no key-value pair")
+	}
+	return values, nil
+}
+
+// parseCsv parses a CSV line into the columns. Columns named "" or "_"
+// are skipped.
+//
+// Parameters:
+//   - text: CSV line
+//   - columns: column names
+//   - delimiter: column delimiter (DEF:",")
+//
+// Returns:
+//   - map[string]interface{}: extracted values
+//   - error: success(nil), failure(error)
+func parseCsv(text string, columns []string, delimiter string) (map[string]interface{}, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	if delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(delimiter)
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	record, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV line: %s", err)
+	}
+	if len(record) != len(columns) {
+		return nil, fmt.Errorf("expected %d columns, got %d", len(columns), len(record))
+	}
+
+	values := make(map[string]interface{})
+	for i, column := range columns {
+		if column != "" && column != "_" {
+			values[column] = record[i]
+		}
+	}
+	return values, nil
+}
+
+// splitQuoted splits the text by the delimiter outside of double quotes.
+// Empty tokens are omitted.
+//
+// Parameters:
+//   - text: text to split
+//   - delimiter: token delimiter
+//
+// Returns:
+//   - []string: tokens
+//   - error: success(nil), failure(error)
+func splitQuoted(text, delimiter string) ([]string, error) {
+	var tokens []string
+	start := 0
+	quoted := false
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && quoted:
+			i++
+		case text[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(text[i:], delimiter):
+			if i > start {
+				tokens = append(tokens, text[start:i])
+			}
+			start = i + len(delimiter)
+			i = start - 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens, nil
+}
+
+// ParseTime parse an extracted time value.
+//
+// Parameters:
+//   - value: time value (string or number)
+//   - format: Go layout, unix, unix_ms, unix_us, unix_ns ("": RFC3339)
+//   - loc: time zone of times without a zone
+//   - now: current time (the year of a layout without a year)
+//
+// Returns:
+//   - time.Time: parsed time (UTC)
+//   - error: success(nil), failure(error)
+func ParseTime(value interface{}, format string, loc *time.Location, now time.Time) (time.Time, error) {
+	var scale float64
+	switch format {
+	case "unix":
+		scale = 1e9
+	case "unix_ms":
+		scale = 1e6
+	case "unix_us":
+		scale = 1e3
+	case "unix_ns":
+		scale = 1
+	}
+
+	if scale != 0 {
+		var n float64
+		switch v := value.(type) {
+		case float64:
+			n = v
+		case int64:
+			n = float64(v)
+		case string:
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return time.Unix(0, i*int64(scale)).UTC(), nil
+			}
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid %s time: %s", format, v)
+			}
+			n = f
+		default:
+			return time.Time{}, fmt.Errorf("invalid %s time: %v", format, value)
+		}
+		return time.Unix(0, int64(n*scale)).UTC(), nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("time must be a string: %v", value)
+	}
+	if format == "" {
+		format = time.RFC3339Nano
+	}
+	t, err := time.ParseInLocation(format, s, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// e.g. syslog timestamps (Jan  2 15:04:05), the entries of December
+	// read in January belong to the previous year
+	if t.Year() == 0 {
+		t = t.AddDate(now.Year(), 0, 0)
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+	}
+	return t.UTC(), nil
+}
+
+// parseLevel converts an extracted level (name or syslog severity) to
+// the entry level.
+//
+// Parameters:
+//   - value: level value
+//
+// Returns:
+//   - string: entry level
+//   - bool: success(true), unknown level(false)
+func parseLevel(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case float64:
+		return entry.SyslogLevel(int(v)), true
+	case int64:
+		return entry.SyslogLevel(int(v)), true
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return entry.SyslogLevel(n), true
+		}
+		level, err := entry.NormalizeLevel(v)
+		return level, err == nil
+	}
+	return "", false
+}
+
+// tagFailure records a parse failure in the parse_error field.
+//
+// Parameters:
+//   - e: log entry
+//   - parserType: type of the failed stage
+//   - err: failure
+func tagFailure(e *entry.Entry, parserType string, err error) {
+	message := parserType + ": " + err.Error()
+	if prev, exists := e.Field(ErrorField); exists {
+		message = prev + "; " + message
+	}
+	e.SetField(ErrorField, message)
+}
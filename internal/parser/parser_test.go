@@ -0,0 +1,137 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+func TestNewChainError(t *testing.T) {
+	tests := []struct {
+		name     string
+		listener config.Listener
+		want     string
+	}{
+		{"regex", config.Listener{Parsers: []config.Parser{{Type: config.ParserRegex, Pattern: `(?P<a>`}}},
+			"regex parser (index:0)"},
+		{"grok", config.Listener{Parsers: []config.Parser{{Type: config.ParserJson},
+			{Type: config.ParserGrok, Pattern: `%{NOSUCHPATTERN:a}`}}}, "grok parser (index:1)"},
+		{"timezone", config.Listener{Timestamp: &config.Timestamp{Timezone: "No/Such_Zone"}}, "timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewChain(&tt.listener)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("NewChain() = %v, %v; want error containing %q", c, err, tt.want)
+			}
+		})
+	}
+
+	if c, err := NewChain(&config.Listener{}); c != nil || err != nil {
+		t.Errorf("NewChain(no stage) = %v, %v; want nil, nil", c, err)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name       string
+		parser     config.Parser
+		message    string
+		wantFields map[string]interface{}
+		wantLevel  string
+		wantMsg    string
+	}{
+		{"grok",
+			config.Parser{Type: config.ParserGrok, LevelKey: "level", MessageKey: "text",
+				Pattern: `%{IP:client} %{WORD:method} %{URIPATHPARAM:path} %{INT:status:int} ` +
+					`%{NUMBER:duration:float} %{LOGLEVEL:level} %{GREEDYDATA:text}`},
+			"10.0.0.1 GET /index.html?a=1 200 0.25 WARN slow request",
+			map[string]interface{}{"client": "10.0.0.1", "method": "GET", "path": "/index.html?a=1",
+				"status": int64(200), "duration": 0.25},
+			"warn", "slow request"},
+		{"grok custom definition",
+			config.Parser{Type: config.ParserGrok, Pattern: `%{REQID:req.id} done`,
+				Definitions: map[string]string{"REQID": `req-[0-9a-f]{4}`}},
+			"req-00af done",
+			map[string]interface{}{"req.id": "req-00af"}, "info", "req-00af done"},
+		{"grok no match",
+			config.Parser{Type: config.ParserGrok, Pattern: `^%{INT:n}$`},
+			"abc",
+			map[string]interface{}{ErrorField: "grok: pattern does not match"}, "info", "abc"},
+		{"csv",
+			config.Parser{Type: config.ParserCsv, Columns: []string{"user", "_", "action", ""}},
+			`alice,1234,"login, web",x`,
+			map[string]interface{}{"user": "alice", "action": "login, web"}, "info", `alice,1234,"login, web",x`},
+		{"csv delimiter",
+			config.Parser{Type: config.ParserCsv, Columns: []string{"a", "b", "c"}, Delimiter: ";"},
+			"1;;3",
+			map[string]interface{}{"a": "1", "b": "", "c": "3"}, "info", "1;;3"},
+		{"csv column count",
+			config.Parser{Type: config.ParserCsv, Columns: []string{"a", "b"}},
+			"1,2,3",
+			map[string]interface{}{ErrorField: "csv: expected 2 columns, got 3"}, "info", "1,2,3"},
+		{"kv",
+			config.Parser{Type: config.ParserKv, LevelKey: "lvl"},
+			`lvl=error user="bob smith" code='42' token ignored=`,
+			map[string]interface{}{"user": "bob smith", "code": "42", "ignored": ""},
+			"error", `lvl=error user="bob smith" code='42' token ignored=`},
+		{"kv delimiter and separator",
+			config.Parser{Type: config.ParserKv, Delimiter: "&", Separator: ":"},
+			"a:1& b : 2&&c",
+			map[string]interface{}{"a": "1", "b": "2"}, "info", "a:1& b : 2&&c"},
+		{"kv no pair",
+			config.Parser{Type: config.ParserKv},
+			"just text",
+			map[string]interface{}{ErrorField: "kv: no key-value pair"}, "info", "just text"},
+		{"kv field",
+			config.Parser{Type: config.ParserKv, Field: "raw"},
+			"x=1",
+			map[string]interface{}{ErrorField: "kv: field not found: raw"}, "info", "x=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewChain(&config.Listener{Parsers: []config.Parser{tt.parser}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now().UTC()
+			entries := c.Process([]entry.Entry{{Time: now, Level: "info", Message: tt.message}})
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(entries))
+			}
+			e := entries[0]
+			if !reflect.DeepEqual(e.Fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", e.Fields, tt.wantFields)
+			}
+			if e.Level != tt.wantLevel {
+				t.Errorf("level = %q, want %q", e.Level, tt.wantLevel)
+			}
+			if e.Message != tt.wantMsg {
+				t.Errorf("message = %q, want %q", e.Message, tt.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// Field in which the original event time of an entry outside the
+// acceptance window is kept
+const EventTimeField = "event_time"
+
+// Length of the beginning of the message in which the time is detected
+const detectLength = 64
+
+// timeDetector is a time format detected in messages
+type timeDetector struct {
+	re      *regexp.Regexp
+	layouts []string
+}
+
+// Time formats detected at the beginning of messages, in order
+var timeDetectors = []timeDetector{
+	// 2024-05-01T10:00:00.123Z, 2024-05-01 10:00:00,123 +0900, 2024-05-01 10:00:00
+	{
+		re: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})?(?: ?(?:Z|[+-]\d{2}:?\d{2}))?`),
+		layouts: []string{"2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05 Z07:00", "2006-01-02T15:04:05Z0700",
+			"2006-01-02T15:04:05 Z0700", "2006-01-02T15:04:05"},
+	},
+	// 10/Oct/2000:13:55:36 -0700 (access logs)
+	{
+		re:      regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+		layouts: []string{"02/Jan/2006:15:04:05 -0700"},
+	},
+	// 2024/05/01 10:00:00.123456 (Go log package)
+	{
+		re:      regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d{1,9})?`),
+		layouts: []string{"2006/01/02 15:04:05"},
+	},
+	// May  1 10:00:00 (syslog)
+	{
+		re:      regexp.MustCompile(`[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d{1,9})?`),
+		layouts: []string{time.Stamp},
+	},
+}
+
+// applyTimestamp applies the event time rule to the entry. Times are
+// normalized to UTC. Parse failures are recorded in the parse_error
+// field and the time set by the input is kept.
+//
+// Parameters:
+//   - e: log entry
+//   - now: receive time
+//
+// Returns:
+//   - bool: keep the entry(true), drop the entry(false)
+func (c *Chain) applyTimestamp(e *entry.Entry, now time.Time) bool {
+	rule := c.timestamp
+
+	switch rule.Field {
+	case "":
+	case "msg", "message":
+		if t, err := c.detectTime(e.Message, now); err != nil {
+			tagFailure(e, "timestamp", err)
+		} else {
+			e.Time = t
+		}
+	default:
+		if t, err := c.fieldTime(e, now); err != nil {
+			tagFailure(e, "timestamp", err)
+		} else {
+			e.Time = t
+		}
+	}
+	e.Time = e.Time.UTC()
+
+	if (rule.MaxPast > 0 && e.Time.Before(now.Add(-time.Duration(rule.MaxPast)*time.Second))) ||
+		(rule.MaxFuture > 0 && e.Time.After(now.Add(time.Duration(rule.MaxFuture)*time.Second))) {
+		if rule.OutOfWindow == config.OutOfWindowDrop {
+			return false
+		}
+		e.SetField(EventTimeField, e.Time.Format(time.RFC3339Nano))
+		e.Time = now
+	}
+
+	if rule.KeepReceiveTime {
+		received := now
+		e.Received = &received
+	}
+	return true
+}
+
+// fieldTime parses the time of the field by the formats of the rule.
+//
+// Parameters:
+//   - e: log entry
+//   - now: receive time
+//
+// Returns:
+//   - time.Time: event time
+//   - error: success(nil), failure(error)
+func (c *Chain) fieldTime(e *entry.Entry, now time.Time) (time.Time, error) {
+	value, exists := e.Fields[c.timestamp.Field]
+	if !exists {
+		return time.Time{}, fmt.Errorf("field not found: %s", c.timestamp.Field)
+	}
+
+	formats := c.timestamp.Formats
+	if len(formats) == 0 {
+		formats = []string{""}
+	}
+	var err error
+	for _, format := range formats {
+		var t time.Time
+		if t, err = ParseTime(value, format, c.location, now); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %v", value)
+}
+
+// detectTime parses the time at the beginning of the message. With
+// formats, the message is matched by the formats (a layout with N
+// spaces matches the first N+1 words), otherwise common formats are
+// detected.
+//
+// Parameters:
+//   - message: log message
+//   - now: receive time
+//
+// Returns:
+//   - time.Time: event time
+//   - error: success(nil), failure(error)
+func (c *Chain) detectTime(message string, now time.Time) (time.Time, error) {
+	if len(c.timestamp.Formats) > 0 {
+		words := strings.Fields(message)
+		for _, format := range c.timestamp.Formats {
+			n := len(strings.Fields(format))
+			if n == 0 || n > len(words) {
+				continue
+			}
+			text := strings.TrimSuffix(strings.TrimPrefix(strings.Join(words[:n], " "), "["), "]")
+			if t, err := ParseTime(text, format, c.location, now); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("no time format matches the message")
+	}
+
+	prefix := message
+	if len(prefix) > detectLength {
+		prefix = prefix[:detectLength]
+	}
+	for _, detector := range timeDetectors {
+		text := detector.re.FindString(prefix)
+		if text == "" {
+			continue
+		}
+		// 2024-05-01 10:00:00,123 -> 2024-05-01T10:00:00.123
+		if len(text) > 10 && text[10] == ' ' && text[4] == '-' {
+			text = text[:10] + "T" + text[11:]
+		}
+		for _, layout := range detector.layouts {
+			if t, err := ParseTime(text, layout, c.location, now); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("no time detected in the message")
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// Receive time of the timestamp tests
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newTimestampChain creates a chain with the event time rule.
+func newTimestampChain(t *testing.T, rule config.Timestamp) *Chain {
+	t.Helper()
+	c, err := NewChain(&config.Listener{Timestamp: &rule})
+	if err != nil {
+		t.Fatalf("NewChain: %s", err)
+	}
+	return c
+}
+
+func TestDetectTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     config.Timestamp
+		message  string
+		want     time.Time
+		wantFail bool
+	}{
+		{"rfc3339", config.Timestamp{}, "2024-05-01T10:00:00.123Z started",
+			time.Date(2024, 5, 1, 10, 0, 0, 123000000, time.UTC), false},
+		{"rfc3339 offset", config.Timestamp{}, "2024-05-01T10:00:00+02:00 started",
+			time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), false},
+		{"comma fraction and space", config.Timestamp{}, "2024-05-01 10:00:00,123 +0900 [main] started",
+			time.Date(2024, 5, 1, 1, 0, 0, 123000000, time.UTC), false},
+		{"zone-less in the rule zone", config.Timestamp{Timezone: "Asia/Seoul"}, "[2024-05-01 10:00:00] [INFO] started",
+			time.Date(2024, 5, 1, 1, 0, 0, 0, time.UTC), false},
+		{"zone-less in UTC", config.Timestamp{}, "2024-05-01 10:00:00 started",
+			time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), false},
+		{"access log", config.Timestamp{}, `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200`,
+			time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC), false},
+		{"go log", config.Timestamp{}, "2024/05/01 10:00:00.123456 started",
+			time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC), false},
+		{"syslog", config.Timestamp{}, "May  1 10:00:00 host app[1]: started",
+			time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), false},
+		{"syslog of the previous year", config.Timestamp{}, "Dec 31 23:59:59 host app[1]: started",
+			time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC), false},
+		{"formats", config.Timestamp{Formats: []string{"unix", "02/01/2006 15:04:05"}}, "[01/05/2024 10:00:00] started",
+			time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), false},
+		{"formats without match", config.Timestamp{Formats: []string{"02/01/2006"}}, "started at 01/05/2024",
+			time.Time{}, true},
+		{"no time", config.Timestamp{}, "started", time.Time{}, true},
+		{"time after the detected length", config.Timestamp{}, strings.Repeat("x", detectLength) + " 2024-05-01T10:00:00Z",
+			time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Field = "msg"
+			got, err := newTimestampChain(t, tt.rule).detectTime(tt.message, now)
+			if tt.wantFail {
+				if err == nil {
+					t.Errorf("detectTime() = %s, want error", got)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("detectTime() = %s, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTimestamp(t *testing.T) {
+	received := now.In(time.FixedZone("KST", 9*3600))
+	tests := []struct {
+		name      string
+		rule      config.Timestamp
+		fields    map[string]interface{}
+		keep      bool
+		want      time.Time
+		eventTime string
+		parseErr  string
+	}{
+		{"field", config.Timestamp{Field: "ts"}, map[string]interface{}{"ts": "2024-05-01T20:30:00+09:00"},
+			true, time.Date(2024, 5, 1, 11, 30, 0, 0, time.UTC), "", ""},
+		{"field formats", config.Timestamp{Field: "ts", Formats: []string{"2006-01-02", "unix_ms"}},
+			map[string]interface{}{"ts": 1714564800000.0}, true, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), "", ""},
+		{"missing field", config.Timestamp{Field: "ts"}, nil,
+			true, now, "", "timestamp: field not found: ts"},
+		{"invalid field", config.Timestamp{Field: "ts"}, map[string]interface{}{"ts": "yesterday"},
+			true, now, "", "timestamp: invalid time: yesterday"},
+		{"within the window", config.Timestamp{Field: "ts", MaxPast: 3600, MaxFuture: 60},
+			map[string]interface{}{"ts": "2024-05-01T11:00:00Z"}, true, time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC), "", ""},
+		{"too old", config.Timestamp{Field: "ts", MaxPast: 3600}, map[string]interface{}{"ts": "2024-05-01T10:59:59Z"},
+			true, now, "2024-05-01T10:59:59Z", ""},
+		{"too new", config.Timestamp{Field: "ts", MaxFuture: 60}, map[string]interface{}{"ts": "2024-05-01T12:01:01Z"},
+			true, now, "2024-05-01T12:01:01Z", ""},
+		{"too old dropped", config.Timestamp{Field: "ts", MaxPast: 3600, OutOfWindow: config.OutOfWindowDrop},
+			map[string]interface{}{"ts": "2024-04-01T00:00:00Z"}, false, time.Time{}, "", ""},
+		{"input time in UTC", config.Timestamp{}, nil, true, now, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTimestampChain(t, tt.rule)
+			e := entry.Entry{Time: received, Message: "started", Fields: tt.fields}
+			if keep := c.applyTimestamp(&e, now); keep != tt.keep {
+				t.Fatalf("applyTimestamp() = %t, want %t", keep, tt.keep)
+			}
+			if !tt.keep {
+				return
+			}
+			if !e.Time.Equal(tt.want) || e.Time.Location() != time.UTC {
+				t.Errorf("time = %s, want %s in UTC", e.Time, tt.want)
+			}
+			if v, _ := e.Field(EventTimeField); v != tt.eventTime {
+				t.Errorf("event_time = %q, want %q", v, tt.eventTime)
+			}
+			if v, _ := e.Field(ErrorField); v != tt.parseErr {
+				t.Errorf("parse_error = %q, want %q", v, tt.parseErr)
+			}
+			if e.Received != nil {
+				t.Errorf("received = %s, want none", e.Received)
+			}
+		})
+	}
+}
+
+func TestApplyTimestampKeepReceiveTime(t *testing.T) {
+	c := newTimestampChain(t, config.Timestamp{Field: "msg", KeepReceiveTime: true})
+	e := entry.Entry{Time: now, Message: "2024-05-01T10:00:00Z started"}
+	if !c.applyTimestamp(&e, now) {
+		t.Fatal("applyTimestamp() dropped the entry")
+	}
+	if !e.Time.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("time = %s, want the detected time", e.Time)
+	}
+	if e.Received == nil || !e.Received.Equal(now) {
+		t.Errorf("received = %v, want %s", e.Received, now)
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package pattern mines the templates of log messages as they are ingested
+with the Drain algorithm, and counts the messages of each template per
+minute so that the templates that are spiking can be found.
+
+Messages are masked (numbers, IDs, addresses) and split into tokens.
+Templates are found through a fixed depth tree whose first level is the
+number of tokens and the next level is the first token. Among the
+templates of the leaf, the most similar one (the ratio of equal tokens)
+absorbs the message if its similarity reaches the threshold, and the
+tokens that differ become the wildcard <*>. Otherwise a new template is
+created.
+*/
+package pattern
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/fingerprint"
+)
+
+// Wildcard token of templates
+const Wildcard = "<*>"
+
+// Number of leading tokens used as tree levels (more levels split the
+// templates whose leading tokens vary, e.g. "user alice logged in")
+const prefixDepth = 1
+
+// Maximum number of children of a tree node (the others share the wildcard child)
+const maxChildren = 100
+
+// Maximum number of tokens of a message (the rest is joined to the last token)
+const maxTokens = 64
+
+// Period of the counts
+const bucketSize = time.Minute
+
+// Ratio of the patterns removed when the maximum number is reached
+const evictRatio = 0.1
+
+// Miner is a template miner structure
+type Miner struct {
+	mu          sync.Mutex
+	similarity  float64
+	maxClusters int
+	history     int64 // Number of count buckets
+	root        map[int]*node
+	clusters    map[int]*cluster
+	nextId      int
+}
+
+// node is a tree node structure
+type node struct {
+	children map[string]*node
+	clusters []*cluster // Leaf only
+}
+
+// cluster is a template and its counts structure
+type cluster struct {
+	id        int
+	tokens    []string
+	leaf      *node
+	total     int64
+	counts    []int64 // Counts of the buckets (ring)
+	epochs    []int64 // Bucket numbers of the counts
+	firstSeen time.Time
+	lastSeen  time.Time
+	sample    string
+}
+
+// Pattern is a template with its counts in a window structure
+type Pattern struct {
+	Id            int       `json:"id"`
+	Template      string    `json:"template"`
+	Count         int64     `json:"count"`
+	PreviousCount int64     `json:"previous_count"`
+	Change        *float64  `json:"change"` // Percent change from the previous window (null: new)
+	Total         int64     `json:"total"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+	Sample        string    `json:"sample"`
+}
+
+// Sort order of the patterns
+const (
+	SortCount  = "count"
+	SortChange = "change"
+)
+
+// NewMiner create template miner.
+//
+// Parameters:
+//   - similarity: minimum similarity of a message to a template (0~1)
+//   - maxClusters: maximum number of templates
+//   - history: period of the counts kept per template
+//
+// Returns:
+//   - *Miner: template miner
+func NewMiner(similarity float64, maxClusters int, history time.Duration) *Miner {
+	return &Miner{
+		similarity:  similarity,
+		maxClusters: maxClusters,
+		history:     int64(history / bucketSize),
+		root:        make(map[int]*node),
+		clusters:    make(map[int]*cluster),
+	}
+}
+
+// Process adds the messages of the entries to the templates. The entries
+// are not modified.
+//
+// Parameters:
+//   - entries: log entries
+//
+// Returns:
+//   - []entry.Entry: the same entries
+func (m *Miner) Process(entries []entry.Entry) []entry.Entry {
+	if len(entries) == 0 {
+		return entries
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range entries {
+		m.add(entries[i].Message, now)
+	}
+	return entries
+}
+
+// add adds a message to the templates. The lock must be held.
+//
+// Parameters:
+//   - message: log message
+//   - now: receive time
+//
+// Returns:
+//   - *cluster: template of the message
+func (m *Miner) add(message string, now time.Time) *cluster {
+	tokens := tokenize(message)
+
+	leaf := m.leaf(tokens)
+	var best *cluster
+	bestSim := -1.0
+	for _, c := range leaf.clusters {
+		if sim := similarity(c.tokens, tokens); sim > bestSim {
+			best, bestSim = c, sim
+		}
+	}
+
+	if best != nil && bestSim >= m.similarity {
+		for i, token := range tokens {
+			if best.tokens[i] != token {
+				best.tokens[i] = Wildcard
+			}
+		}
+	} else {
+		if len(m.clusters) >= m.maxClusters {
+			m.evict()
+		}
+		m.nextId++
+		best = &cluster{
+			id:        m.nextId,
+			tokens:    tokens,
+			leaf:      leaf,
+			counts:    make([]int64, m.history),
+			epochs:    make([]int64, m.history),
+			firstSeen: now,
+		}
+		leaf.clusters = append(leaf.clusters, best)
+		m.clusters[best.id] = best
+	}
+
+	bucket := now.UnixNano() / int64(bucketSize)
+	slot := bucket % m.history
+	if best.epochs[slot] != bucket {
+		best.epochs[slot], best.counts[slot] = bucket, 0
+	}
+	best.counts[slot]++
+	best.total++
+	best.lastSeen = now
+	best.sample = message
+	return best
+}
+
+// leaf returns the leaf node of the tokens, creating the path if it
+// does not exist. Tokens with digits or masks share the wildcard child.
+//
+// Parameters:
+//   - tokens: message tokens
+//
+// Returns:
+//   - *node: leaf node
+func (m *Miner) leaf(tokens []string) *node {
+	n, exists := m.root[len(tokens)]
+	if !exists {
+		n = &node{children: make(map[string]*node)}
+		m.root[len(tokens)] = n
+	}
+
+	for depth := 0; depth < prefixDepth && depth < len(tokens); depth++ {
+		key := tokens[depth]
+		if strings.ContainsAny(key, "0123456789<") {
+			key = Wildcard
+		}
+		child, exists := n.children[key]
+		if !exists {
+			if len(n.children) >= maxChildren {
+				key = Wildcard
+				child, exists = n.children[key]
+			}
+			if !exists {
+				child = &node{children: make(map[string]*node)}
+				n.children[key] = child
+			}
+		}
+		n = child
+	}
+	return n
+}
+
+// evict removes the least recently seen templates. The lock must be held.
+func (m *Miner) evict() {
+	clusters := make([]*cluster, 0, len(m.clusters))
+	for _, c := range m.clusters {
+		clusters = append(clusters, c)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].lastSeen.Before(clusters[j].lastSeen) })
+
+	n := max(1, int(float64(len(clusters))*evictRatio))
+	for _, c := range clusters[:n] {
+		delete(m.clusters, c.id)
+		leaf := c.leaf
+		for i, lc := range leaf.clusters {
+			if lc == c {
+				leaf.clusters = append(leaf.clusters[:i], leaf.clusters[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// MaxWindow returns the longest window of Top, which is half of the
+// history so that the previous window is counted as well.
+//
+// Returns:
+//   - time.Duration: maximum window
+func (m *Miner) MaxWindow() time.Duration {
+	return time.Duration(max(1, m.history/2)) * bucketSize
+}
+
+// Top returns the templates with the most messages in the window, with
+// their counts in the previous window of the same length.
+//
+// Parameters:
+//   - window: window (rounded up to minutes, at most MaxWindow)
+//   - limit: maximum number of templates
+//   - order: sort order (count, change)
+//   - now: end of the window
+//
+// Returns:
+//   - []Pattern: templates
+func (m *Miner) Top(window time.Duration, limit int, order string, now time.Time) []Pattern {
+	buckets := int64((window + bucketSize - 1) / bucketSize)
+	buckets = max(1, min(buckets, m.history/2))
+	current := now.UnixNano() / int64(bucketSize)
+
+	m.mu.Lock()
+	patterns := []Pattern{}
+	for _, c := range m.clusters {
+		p := Pattern{
+			Id:            c.id,
+			Template:      strings.Join(c.tokens, " "),
+			Count:         c.count(current-buckets+1, current),
+			PreviousCount: c.count(current-2*buckets+1, current-buckets),
+			Total:         c.total,
+			FirstSeen:     c.firstSeen.UTC(),
+			LastSeen:      c.lastSeen.UTC(),
+			Sample:        c.sample,
+		}
+		if p.Count == 0 && p.PreviousCount == 0 {
+			continue
+		}
+		if p.PreviousCount > 0 {
+			change := float64(p.Count-p.PreviousCount) / float64(p.PreviousCount) * 100
+			p.Change = &change
+		}
+		patterns = append(patterns, p)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := patterns[i], patterns[j]
+		if order == SortChange {
+			// New templates first, then the largest increase
+			switch {
+			case (a.Change == nil) != (b.Change == nil):
+				return a.Change == nil
+			case a.Change != nil && *a.Change != *b.Change:
+				return *a.Change > *b.Change
+			}
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Id < b.Id
+	})
+
+	if len(patterns) > limit {
+		patterns = patterns[:limit]
+	}
+	return patterns
+}
+
+// count returns the number of messages in the buckets.
+//
+// Parameters:
+//   - from: first bucket number
+//   - to: last bucket number
+//
+// Returns:
+//   - int64: number of messages
+func (c *cluster) count(from, to int64) int64 {
+	total := int64(0)
+	history := int64(len(c.counts))
+	for bucket := max(from, to-history+1); bucket <= to; bucket++ {
+		if bucket < 0 {
+			continue
+		}
+		if slot := bucket % history; c.epochs[slot] == bucket {
+			total += c.counts[slot]
+		}
+	}
+	return total
+}
+
+// tokenize masks the variable parts of the message and splits it into tokens.
+//
+// Parameters:
+//   - message: log message
+//
+// Returns:
+//   - []string: tokens
+func tokenize(message string) []string {
+	tokens := strings.Fields(fingerprint.Template(message))
+	if len(tokens) > maxTokens {
+		tokens = append(tokens[:maxTokens-1], strings.Join(tokens[maxTokens-1:], " "))
+	}
+	if len(tokens) == 0 {
+		tokens = []string{""}
+	}
+	return tokens
+}
+
+// similarity returns the ratio of the tokens equal to the template
+// tokens. Wildcards are not counted as equal.
+//
+// Parameters:
+//   - template: template tokens
+//   - tokens: message tokens (the same length as the template)
+//
+// Returns:
+//   - float64: similarity (0~1)
+func similarity(template, tokens []string) float64 {
+	equal := 0
+	for i, token := range tokens {
+		if template[i] == token && token != Wildcard {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(tokens))
+}
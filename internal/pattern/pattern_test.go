@@ -0,0 +1,162 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package pattern
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// templates returns the sorted templates of the miner.
+func templates(m *Miner) []string {
+	list := []string{}
+	for _, c := range m.clusters {
+		list = append(list, strings.Join(c.tokens, " "))
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name       string
+		similarity float64
+		messages   []string
+		want       []string
+	}{
+		{"masked variables", 0.5, []string{
+			"connection from 10.0.0.1 closed after 12 ms",
+			"connection from 192.168.1.20 closed after 3400 ms",
+		}, []string{"connection from <ip> closed after <num> ms"}},
+		{"wildcard", 0.5, []string{
+			"user alice logged in",
+			"user bob logged in",
+			"user carol logged in",
+		}, []string{"user <*> logged in"}},
+		{"below similarity", 0.8, []string{
+			"user alice logged in",
+			"user bob logged in",
+		}, []string{"user alice logged in", "user bob logged in"}},
+		{"token count", 0.5, []string{
+			"cache miss",
+			"cache miss for key",
+			"cache hit",
+		}, []string{"cache <*>", "cache miss for key"}},
+		{"first token", 0.5, []string{
+			"read failed on disk",
+			"write failed on disk",
+		}, []string{"read failed on disk", "write failed on disk"}},
+		{"empty", 0.5, []string{"", "   "}, []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMiner(tt.similarity, 100, time.Hour)
+			now := time.Now()
+			for _, msg := range tt.messages {
+				m.add(msg, now)
+			}
+			if got := templates(m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("templates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddMaxTokens(t *testing.T) {
+	m := NewMiner(0.5, 100, time.Hour)
+	c := m.add(strings.Repeat("a ", maxTokens+10), time.Now())
+	if len(c.tokens) != maxTokens {
+		t.Fatalf("tokens = %d, want %d", len(c.tokens), maxTokens)
+	}
+	if last := c.tokens[maxTokens-1]; last != strings.TrimSpace(strings.Repeat("a ", 11)) {
+		t.Errorf("last token = %q", last)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	m := NewMiner(0.5, 10, time.Hour)
+	base := time.Now()
+	for i := 0; i < 10; i++ {
+		// Distinct token counts make distinct templates
+		m.add(strings.Repeat("x ", i+1), base.Add(time.Duration(i)*time.Second))
+	}
+	m.add("new template with many tokens in it here now", base.Add(time.Minute))
+
+	if len(m.clusters) != 10 {
+		t.Fatalf("templates = %d, want 10", len(m.clusters))
+	}
+	for _, c := range m.clusters {
+		if c.id == 1 {
+			t.Errorf("least recently seen template was not removed")
+		}
+	}
+}
+
+func TestTop(t *testing.T) {
+	m := NewMiner(0.5, 100, 10*time.Minute)
+	now := time.Date(2024, 5, 1, 12, 0, 30, 0, time.UTC)
+	add := func(msg string, n int, minutesAgo int) {
+		for i := 0; i < n; i++ {
+			m.add(msg, now.Add(-time.Duration(minutesAgo)*time.Minute))
+		}
+	}
+	add("disk full on sda", 10, 3) // Previous window
+	add("disk full on sda", 30, 1) // Current window
+	add("cache miss for key", 20, 3)
+	add("cache miss for key", 10, 0)
+	add("user alice logged in", 5, 0)
+	add("stale entry", 1, 9) // Outside both windows
+
+	got := m.Top(2*time.Minute, 10, SortCount, now)
+	summary := []string{}
+	for _, p := range got {
+		change := "new"
+		if p.Change != nil {
+			change = fmt.Sprintf("%+.0f", *p.Change)
+		}
+		summary = append(summary, fmt.Sprintf("%s|%d|%d|%s", p.Template, p.Count, p.PreviousCount, change))
+	}
+	want := []string{
+		"disk full on sda|30|10|+200",
+		"cache miss for key|10|20|-50",
+		"user alice logged in|5|0|new",
+	}
+	if !reflect.DeepEqual(summary, want) {
+		t.Errorf("Top(count) = %q, want %q", summary, want)
+	}
+
+	got = m.Top(2*time.Minute, 2, SortChange, now)
+	if len(got) != 2 || got[0].Template != "user alice logged in" || got[1].Template != "disk full on sda" {
+		t.Errorf("Top(change) = %+v", got)
+	}
+
+	// The window is limited to half of the history
+	if m.MaxWindow() != 5*time.Minute {
+		t.Errorf("MaxWindow() = %s, want 5m", m.MaxWindow())
+	}
+	got = m.Top(time.Hour, 10, SortCount, now)
+	for _, p := range got {
+		if p.Template == "stale entry" && (p.Count != 0 || p.PreviousCount != 1) {
+			t.Errorf("Top(1h) stale entry = %d, %d; want 0, 1 (window of 5m)", p.Count, p.PreviousCount)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package pipeline is the write pipeline that delivers entries received
+by inputs to the store through a bounded internal queue.
+*/
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// Maximum number of entries written to the store at once
+const maxWriteBatch = 1024
+
+// Interval at which the processors are applied even without entries, so
+// that they can add entries on time (e.g. summaries)
+const tickInterval = time.Second
+
+// ErrQueueFull is returned when the internal queue has no space for
+// the submitted entries
+var ErrQueueFull = errors.New("pipeline queue is full")
+
+// ErrBatchTooLarge is returned when the submitted entries exceed the
+// capacity of the internal queue, so that they could never be queued
+var ErrBatchTooLarge = errors.New("batch exceeds the pipeline queue capacity")
+
+// Writer is the destination of the pipeline
+type Writer interface {
+	Write(entries []entry.Entry) error
+}
+
+// Processor modifies or filters entries before they are written
+type Processor interface {
+	Process(entries []entry.Entry) []entry.Entry
+}
+
+// Flusher is a processor holding entries, which are written when the
+// pipeline stops
+type Flusher interface {
+	Flush() []entry.Entry
+}
+
+// Pipeline is a write pipeline structure
+type Pipeline struct {
+	mu         sync.Mutex
+	queue      chan queuedEntry
+	writer     Writer
+	processors []Processor
+	onError    func(err error)
+}
+
+// queuedEntry is an entry in the internal queue
+type queuedEntry struct {
+	entry  entry.Entry
+	ack    func() // Called after the entries up to this one are written (nil if none)
+	marker bool   // Whether it only carries the ack of an empty submission
+}
+
+// NewPipeline create write pipeline.
+//
+// Parameters:
+//   - queueSize: capacity of the internal queue (entries)
+//   - writer: destination of the entries
+//   - onError: called when writing entries fails
+//
+// Returns:
+//   - *Pipeline: write pipeline
+func NewPipeline(queueSize int, writer Writer, onError func(err error)) *Pipeline {
+	return &Pipeline{
+		queue:   make(chan queuedEntry, queueSize),
+		writer:  writer,
+		onError: onError,
+	}
+}
+
+// Submit adds entries to the internal queue without blocking.
+// Either all entries are queued or none are. Entry times are
+// normalized to UTC.
+//
+// Parameters:
+//   - entries: log entries
+//
+// Returns:
+//   - error: success(nil), queue is full(ErrQueueFull),
+//     more entries than the capacity(ErrBatchTooLarge)
+func (p *Pipeline) Submit(entries []entry.Entry) error {
+	return p.SubmitWithAck(entries, nil)
+}
+
+// SubmitWithAck is Submit with a callback called by the pipeline
+// goroutine after the entries have been applied to the processors and
+// written to the writer successfully. The callback of an empty
+// submission is called after the entries submitted before it are
+// written. Callbacks are called in the order of submission and must
+// not block.
+//
+// Parameters:
+//   - entries: log entries
+//   - ack: write acknowledgement callback (nil if not needed)
+//
+// Returns:
+//   - error: success(nil), queue is full(ErrQueueFull),
+//     more entries than the capacity(ErrBatchTooLarge)
+func (p *Pipeline) SubmitWithAck(entries []entry.Entry, ack func()) error {
+	if len(entries) > cap(p.queue) {
+		return ErrBatchTooLarge
+	}
+	if len(entries) == 0 && ack == nil {
+		return nil
+	}
+
+	// Only submitters add to the queue and they are serialized, so the
+	// free space can only grow after the check
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.queue)+max(len(entries), 1) > cap(p.queue) {
+		return ErrQueueFull
+	}
+	if len(entries) == 0 {
+		p.queue <- queuedEntry{ack: ack, marker: true}
+		return nil
+	}
+	for i, e := range entries {
+		// Stored times are UTC with nanosecond precision
+		e.Time = e.Time.UTC()
+		item := queuedEntry{entry: e}
+		if i == len(entries)-1 {
+			item.ack = ack
+		}
+		p.queue <- item
+	}
+	return nil
+}
+
+// AddProcessor adds a processor applied to the entries before they are
+// written, in the order of addition. Processors must be added before
+// the pipeline runs.
+//
+// Parameters:
+//   - proc: entry processor
+func (p *Pipeline) AddProcessor(proc Processor) {
+	p.processors = append(p.processors, proc)
+}
+
+// Capacity returns the capacity of the queue, the maximum number of
+// entries submitted at once.
+//
+// Returns:
+//   - int: queue capacity
+func (p *Pipeline) Capacity() int {
+	return cap(p.queue)
+}
+
+// Depth returns the number of entries waiting in the queue.
+//
+// Returns:
+//   - int: queue depth
+func (p *Pipeline) Depth() int {
+	return len(p.queue)
+}
+
+// Run writes queued entries to the writer until the context is
+// cancelled. Entries remaining in the queue are written before return.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func (p *Pipeline) Run(ctx context.Context) {
+	batch := make([]entry.Entry, 0, maxWriteBatch)
+	acks := []func(){}
+	add := func(item queuedEntry) {
+		if !item.marker {
+			batch = append(batch, item.entry)
+		}
+		if item.ack != nil {
+			acks = append(acks, item.ack)
+		}
+	}
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Drain the queue
+			for {
+				select {
+				case item := <-p.queue:
+					add(item)
+					if len(batch) == maxWriteBatch {
+						batch, acks = p.flush(batch, acks)
+					}
+				default:
+					p.flush(batch, acks)
+					p.flushProcessors()
+					return
+				}
+			}
+		case item := <-p.queue:
+			add(item)
+			// Collect the entries already queued
+			for len(batch) < maxWriteBatch && len(p.queue) > 0 {
+				add(<-p.queue)
+			}
+			batch, acks = p.flush(batch, acks)
+		case <-ticker.C:
+			batch, acks = p.flush(batch, acks)
+		}
+	}
+}
+
+// flushProcessors writes the entries held by the processors. The
+// entries of a processor are applied to the processors after it.
+func (p *Pipeline) flushProcessors() {
+	entries := []entry.Entry{}
+	for _, proc := range p.processors {
+		entries = proc.Process(entries)
+		if flusher, ok := proc.(Flusher); ok {
+			entries = append(entries, flusher.Flush()...)
+		}
+	}
+	if len(entries) > 0 {
+		if err := p.writer.Write(entries); err != nil {
+			p.onError(err)
+		}
+	}
+}
+
+// flush applies the processors to the batch and writes it to the writer.
+// The processors are applied to an empty batch as well. The acks of the
+// batch are called if it is written.
+//
+// Parameters:
+//   - batch: log entries
+//   - acks: write acknowledgement callbacks of the batch
+//
+// Returns:
+//   - []entry.Entry: emptied batch
+//   - []func(): emptied acks
+func (p *Pipeline) flush(batch []entry.Entry, acks []func()) ([]entry.Entry, []func()) {
+	entries := batch
+	for _, proc := range p.processors {
+		entries = proc.Process(entries)
+	}
+	if len(entries) > 0 {
+		if err := p.writer.Write(entries); err != nil {
+			p.onError(err)
+			return batch[:0], acks[:0]
+		}
+	}
+	for _, ack := range acks {
+		ack()
+	}
+	return batch[:0], acks[:0]
+}
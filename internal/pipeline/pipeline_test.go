@@ -0,0 +1,119 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+func TestSubmit(t *testing.T) {
+	tests := []struct {
+		name    string
+		queued  int
+		submit  int
+		wantErr error
+	}{
+		{"fits", 0, 4, nil},
+		{"fills the queue", 2, 2, nil},
+		{"queue is full", 3, 2, ErrQueueFull},
+		{"larger than the capacity", 0, 5, ErrBatchTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPipeline(4, nil, nil)
+			if err := p.Submit(make([]entry.Entry, tt.queued)); err != nil {
+				t.Fatalf("Submit: %v", err)
+			}
+			err := p.Submit(make([]entry.Entry, tt.submit))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Submit error = %v, want %v", err, tt.wantErr)
+			}
+			want := tt.queued
+			if tt.wantErr == nil {
+				want += tt.submit
+			}
+			if p.Depth() != want {
+				t.Errorf("Depth = %d, want %d (all or none queued)", p.Depth(), want)
+			}
+		})
+	}
+}
+
+// failWriter records the written entries and fails while fail is set
+type failWriter struct {
+	fail    bool
+	written int
+}
+
+func (w *failWriter) Write(entries []entry.Entry) error {
+	if w.fail {
+		return errors.New("write failed")
+	}
+	w.written += len(entries)
+	return nil
+}
+
+func TestSubmitWithAck(t *testing.T) {
+	writer := &failWriter{}
+	var failures int
+	p := NewPipeline(10, writer, func(err error) { failures++ })
+
+	var acks []string
+	ack := func(name string) func() {
+		return func() { acks = append(acks, name) }
+	}
+
+	// Acks are called after the write in the order of submission,
+	// including the ack of an empty submission
+	p.SubmitWithAck(make([]entry.Entry, 2), ack("first"))
+	p.SubmitWithAck(nil, ack("empty"))
+	p.SubmitWithAck(make([]entry.Entry, 1), ack("second"))
+	if len(acks) != 0 {
+		t.Fatalf("acks = %v before the write", acks)
+	}
+	p.flush(p.drain())
+	if writer.written != 3 || len(acks) != 3 || acks[0] != "first" || acks[1] != "empty" || acks[2] != "second" {
+		t.Fatalf("written = %d, acks = %v", writer.written, acks)
+	}
+
+	// A failed write is not acknowledged
+	acks = nil
+	writer.fail = true
+	p.SubmitWithAck(make([]entry.Entry, 1), ack("failed"))
+	p.flush(p.drain())
+	if len(acks) != 0 || failures != 1 {
+		t.Errorf("acks = %v, failures = %d after a failed write", acks, failures)
+	}
+}
+
+// drain takes the queued entries as Run does.
+func (p *Pipeline) drain() ([]entry.Entry, []func()) {
+	batch, acks := []entry.Entry{}, []func(){}
+	for len(p.queue) > 0 {
+		item := <-p.queue
+		if !item.marker {
+			batch = append(batch, item.entry)
+		}
+		if item.ack != nil {
+			acks = append(acks, item.ack)
+		}
+	}
+	return batch, acks
+}
@@ -0,0 +1,352 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package redact implements the redaction rules that remove personal
+information and secrets from entries before they are stored or
+forwarded.
+*/
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"regexp"
+	"strings"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/metrics"
+)
+
+// Named capture replaced instead of the whole match
+const secretGroup = "secret"
+
+// Length of the hex digest of the hash action
+const hashLength = 16
+
+// Patterns of the built-in detectors
+var detectorPatterns = map[string]string{
+	config.RedactDetectorCreditCard:  `\b(?:\d[ -]?){12,18}\d\b`,
+	config.RedactDetectorEmail:       `[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`,
+	config.RedactDetectorBearerToken: `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]+=*)`,
+	config.RedactDetectorPassword: `(?i)\b(?:password|passwd|pwd|secret)["']?\s*[=:]\s*["']?` +
+		`(?P<secret>[^\s"',;&]+)`,
+	config.RedactDetectorJwt:    `\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+	config.RedactDetectorAwsKey: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+}
+
+// Field and label keys whose whole value is a password (password detector)
+var passwordKeyRegexp = regexp.MustCompile(`(?i)^(password|passwd|pwd|secret|token|api[_-]?key|authorization)$`)
+
+// Number of values redacted per rule
+var redactionsTotal = metrics.NewCounter("log_manager_redactions_total",
+	"Number of values redacted by the redaction rules.", "rule")
+
+// Redactor is a redaction rule set structure
+type Redactor struct {
+	rules   []*rule
+	hashKey []byte
+}
+
+// rule is a compiled redaction rule structure
+type rule struct {
+	conf   config.Redaction
+	re     *regexp.Regexp
+	secret int
+	keyRe  *regexp.Regexp
+	valid  func(match string) bool
+	fields map[string]bool
+}
+
+// NewRedactor create redactor. The rules are validated when the
+// configuration is loaded.
+//
+// Parameters:
+//   - rules: redaction rules
+//   - hashKey: key of the hash action (empty: SHA-256 without a key)
+//
+// Returns:
+//   - *Redactor: redactor (nil if there is no rule)
+func NewRedactor(rules []config.Redaction, hashKey string) *Redactor {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	r := &Redactor{hashKey: []byte(hashKey)}
+	for _, conf := range rules {
+		rl := &rule{conf: conf}
+
+		pattern := conf.Pattern
+		if conf.Detector != "" {
+			pattern = detectorPatterns[conf.Detector]
+		}
+		rl.re = regexp.MustCompile(pattern)
+		rl.secret = rl.re.SubexpIndex(secretGroup)
+
+		switch conf.Detector {
+		case config.RedactDetectorCreditCard:
+			rl.valid = validCardNumber
+		case config.RedactDetectorPassword:
+			rl.keyRe = passwordKeyRegexp
+		}
+		if len(conf.Fields) > 0 {
+			rl.fields = make(map[string]bool)
+			for _, field := range conf.Fields {
+				if field == "message" {
+					field = "msg"
+				}
+				rl.fields[field] = true
+			}
+		}
+		r.rules = append(r.rules, rl)
+	}
+	return r
+}
+
+// Process redacts the message, the fields and the labels of the entries.
+//
+// Parameters:
+//   - entries: log entries (modified in place)
+//
+// Returns:
+//   - []entry.Entry: log entries
+func (r *Redactor) Process(entries []entry.Entry) []entry.Entry {
+	if r == nil {
+		return entries
+	}
+
+	for i := range entries {
+		for _, rl := range r.rules {
+			r.redactEntry(&entries[i], rl)
+		}
+	}
+	return entries
+}
+
+// redactEntry applies the rule to the entry. The drop_field action
+// removes the fields and the labels with a match, and masks the matches
+// of the message.
+//
+// Parameters:
+//   - e: log entry
+//   - rl: redaction rule
+func (r *Redactor) redactEntry(e *entry.Entry, rl *rule) {
+	count := 0
+
+	if rl.inspects("msg") {
+		var n int
+		e.Message, n = r.redactString(e.Message, rl, rl.conf.Action == config.RedactActionDropField)
+		count += n
+	}
+	for key, value := range e.Fields {
+		if !rl.inspects(key) {
+			continue
+		}
+		redacted, n, drop := r.redactValue(key, value, rl)
+		count += n
+		if drop {
+			delete(e.Fields, key)
+		} else if n > 0 {
+			e.Fields[key] = redacted
+		}
+	}
+	for key, value := range e.Labels {
+		if !rl.inspects(key) {
+			continue
+		}
+		redacted, n, drop := r.redactValue(key, value, rl)
+		count += n
+		if drop {
+			delete(e.Labels, key)
+		} else if n > 0 {
+			e.Labels[key] = redacted.(string)
+		}
+	}
+
+	if count > 0 {
+		redactionsTotal.Add(float64(count), rl.conf.Name)
+	}
+}
+
+// redactValue applies the rule to a field value. Objects and arrays
+// are inspected recursively.
+//
+// Parameters:
+//   - key: field key
+//   - value: field value
+//   - rl: redaction rule
+//
+// Returns:
+//   - interface{}: redacted value
+//   - int: number of redactions
+//   - bool: drop the field(true), keep the field(false)
+func (r *Redactor) redactValue(key string, value interface{}, rl *rule) (interface{}, int, bool) {
+	dropField := rl.conf.Action == config.RedactActionDropField
+
+	// The whole value of a password key
+	if rl.keyRe != nil && rl.keyRe.MatchString(key) {
+		if dropField {
+			return nil, 1, true
+		}
+		if s, ok := value.(string); ok {
+			return r.replace(s, rl), 1, false
+		}
+		return r.replace("", rl), 1, false
+	}
+
+	switch v := value.(type) {
+	case string:
+		redacted, n := r.redactString(v, rl, false)
+		return redacted, n, n > 0 && dropField
+	case map[string]interface{}:
+		total := 0
+		for k, item := range v {
+			redacted, n, drop := r.redactValue(k, item, rl)
+			total += n
+			if drop {
+				delete(v, k)
+			} else if n > 0 {
+				v[k] = redacted
+			}
+		}
+		return v, total, false
+	case []interface{}:
+		total := 0
+		kept := v[:0]
+		for _, item := range v {
+			redacted, n, drop := r.redactValue("", item, rl)
+			total += n
+			if !drop {
+				kept = append(kept, redacted)
+			}
+		}
+		return kept, total, false
+	}
+	return value, 0, false
+}
+
+// redactString replaces the matches of the rule in the text.
+//
+// Parameters:
+//   - text: text to redact
+//   - rl: redaction rule
+//   - mask: whether the matches are masked regardless of the action
+//
+// Returns:
+//   - string: redacted text
+//   - int: number of redactions
+func (r *Redactor) redactString(text string, rl *rule, mask bool) (string, int) {
+	matches := rl.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, 0
+	}
+
+	var sb strings.Builder
+	count := 0
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if rl.secret > 0 && m[2*rl.secret] >= 0 {
+			start, end = m[2*rl.secret], m[2*rl.secret+1]
+		}
+		if rl.valid != nil && !rl.valid(text[start:end]) {
+			continue
+		}
+
+		sb.WriteString(text[last:start])
+		if mask {
+			sb.WriteString(rl.conf.Replacement)
+		} else {
+			sb.WriteString(r.replace(text[start:end], rl))
+		}
+		last = end
+		count++
+	}
+	if count == 0 {
+		return text, 0
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), count
+}
+
+// replace returns the replacement of a matched value by the action.
+//
+// Parameters:
+//   - value: matched value
+//   - rl: redaction rule
+//
+// Returns:
+//   - string: replacement
+func (r *Redactor) replace(value string, rl *rule) string {
+	if rl.conf.Action != config.RedactActionHash {
+		return rl.conf.Replacement
+	}
+
+	var h hash.Hash
+	if len(r.hashKey) > 0 {
+		h = hmac.New(sha256.New, r.hashKey)
+	} else {
+		h = sha256.New()
+	}
+	h.Write([]byte(value))
+	return "hash:" + hex.EncodeToString(h.Sum(nil))[:hashLength]
+}
+
+// inspects verify that the rule inspects the key.
+//
+// Parameters:
+//   - key: message (msg), field or label key
+//
+// Returns:
+//   - bool: inspected(true), not inspected(false)
+func (rl *rule) inspects(key string) bool {
+	return rl.fields == nil || rl.fields[key]
+}
+
+// validCardNumber verify the card number by the Luhn checksum.
+//
+// Parameters:
+//   - match: matched number (with spaces or hyphens)
+//
+// Returns:
+//   - bool: valid(true), invalid(false)
+func validCardNumber(match string) bool {
+	digits := make([]int, 0, len(match))
+	for _, c := range match {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
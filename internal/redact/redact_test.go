@@ -0,0 +1,112 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+func TestValidCardNumber(t *testing.T) {
+	tests := []struct {
+		match string
+		want  bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"5555-5555-5555-4444", true},
+		{"378282246310005", true},       // 15 digits
+		{"4222222222222", true},         // 13 digits
+		{"4111111111111112", false},     // checksum
+		{"1234567890123", false},        // checksum
+		{"411111111111", false},         // 12 digits
+		{"41111111111111111111", false}, // 20 digits
+	}
+
+	for _, tt := range tests {
+		if got := validCardNumber(tt.match); got != tt.want {
+			t.Errorf("validCardNumber(%q) = %v, want %v", tt.match, got, tt.want)
+		}
+	}
+}
+
+func TestRedactCreditCard(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		message string
+		want    string
+	}{
+		{"valid", config.RedactActionMask, "paid with 4111 1111 1111 1111 today",
+			"paid with [CARD] today"},
+		{"invalid checksum kept", config.RedactActionMask, "order 4111111111111112 shipped",
+			"order 4111111111111112 shipped"},
+		{"mixed", config.RedactActionMask, "a=5555-5555-5555-4444 b=1234567890123 c=378282246310005",
+			"a=[CARD] b=1234567890123 c=[CARD]"},
+		{"hash", config.RedactActionHash, "card 4111111111111111",
+			"card hash:" + hashOf("4111111111111111")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRedactor([]config.Redaction{{Name: "card", Detector: config.RedactDetectorCreditCard,
+				Action: tt.action, Replacement: "[CARD]"}}, "")
+			entries := r.Process([]entry.Entry{{Message: tt.message}})
+			if entries[0].Message != tt.want {
+				t.Errorf("message = %q, want %q", entries[0].Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactCreditCardFields(t *testing.T) {
+	r := NewRedactor([]config.Redaction{{Name: "card", Detector: config.RedactDetectorCreditCard,
+		Action: config.RedactActionDropField, Replacement: "[CARD]"}}, "")
+
+	entries := r.Process([]entry.Entry{{
+		Message: "card 4111111111111111",
+		Fields: map[string]interface{}{
+			"card":    "4111-1111-1111-1111",
+			"order":   "4111111111111112",
+			"payment": map[string]interface{}{"pan": "5555555555554444", "amount": 10.5},
+		},
+		Labels: map[string]string{"pan": "378282246310005", "app": "shop"},
+	}})
+
+	want := entry.Entry{
+		Message: "card [CARD]",
+		Fields: map[string]interface{}{
+			"order":   "4111111111111112",
+			"payment": map[string]interface{}{"amount": 10.5},
+		},
+		Labels: map[string]string{"app": "shop"},
+	}
+	if !reflect.DeepEqual(entries[0], want) {
+		t.Errorf("entry = %+v, want %+v", entries[0], want)
+	}
+}
+
+// hashOf returns the digest of the hash action without a key.
+func hashOf(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
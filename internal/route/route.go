@@ -0,0 +1,190 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package route implements the routing rules that send each entry to one
+or more named destinations. The rules can be replaced while entries are
+being routed.
+*/
+package route
+
+import (
+	"sync/atomic"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/match"
+	"github.com/hoon-kr/log_manager/internal/metrics"
+)
+
+// Destination label of the entries that match no rule
+const unrouted = "unrouted"
+
+// Number of entries sent to each destination
+var routedTotal = metrics.NewCounter("log_manager_routed_entries_total",
+	"Number of entries sent to each routing destination.", "destination")
+
+// Output is a destination that sends the entries out of the module
+type Output interface {
+	// Send takes the entries to be sent. It must not block on the
+	// remote system.
+	Send(entries []entry.Entry)
+}
+
+// Router is a routing rule set structure
+type Router struct {
+	table atomic.Pointer[table]
+}
+
+// table is a compiled routing rule set structure
+type table struct {
+	routes       []*route
+	destinations []config.Destination
+	outputs      map[string]Output
+}
+
+// route is a compiled routing rule structure
+type route struct {
+	conf         config.Route
+	expr         *match.Expr
+	destinations []config.Destination
+}
+
+// NewRouter create router.
+//
+// Parameters:
+//   - destinations: routing destinations
+//   - routes: routing rules
+//   - outputs: outputs of the output destinations by destination name
+//
+// Returns:
+//   - *Router: router
+//   - error: success(nil), failure(error)
+func NewRouter(destinations []config.Destination, routes []config.Route, outputs map[string]Output) (*Router, error) {
+	r := &Router{}
+	if err := r.Reload(destinations, routes, outputs); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload replaces the routing rules. Entries being routed use either
+// the previous or the new rules as a whole.
+//
+// Parameters:
+//   - destinations: routing destinations
+//   - routes: routing rules
+//   - outputs: outputs of the output destinations by destination name
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (r *Router) Reload(destinations []config.Destination, routes []config.Route, outputs map[string]Output) error {
+	byName := make(map[string]config.Destination)
+	for _, dest := range destinations {
+		byName[dest.Name] = dest
+	}
+
+	tb := &table{destinations: destinations, outputs: outputs}
+	for _, conf := range routes {
+		rt := &route{conf: conf}
+		if conf.Match != "" {
+			expr, err := match.Compile(conf.Match)
+			if err != nil {
+				return err
+			}
+			rt.expr = expr
+		}
+		for _, name := range conf.Destinations {
+			rt.destinations = append(rt.destinations, byName[name])
+		}
+		tb.routes = append(tb.routes, rt)
+	}
+
+	r.table.Store(tb)
+	return nil
+}
+
+// Destinations returns the current routing destinations.
+//
+// Returns:
+//   - []config.Destination: routing destinations
+func (r *Router) Destinations() []config.Destination {
+	return r.table.Load().destinations
+}
+
+// Process sends the entries to the destinations of the rules. The rules
+// are evaluated in order and the first matching rule is used, unless it
+// continues to the next rules. An entry is stored once per stream even
+// if several destinations use the same stream, entries of the drop
+// destination are discarded, entries of an output destination are
+// handed to its output, and entries that match no rule keep their
+// stream.
+//
+// Parameters:
+//   - entries: log entries
+//
+// Returns:
+//   - []entry.Entry: routed entries
+func (r *Router) Process(entries []entry.Entry) []entry.Entry {
+	tb := r.table.Load()
+	if len(tb.routes) == 0 {
+		return entries
+	}
+
+	routed := make([]entry.Entry, 0, len(entries))
+	sends := make(map[string][]entry.Entry)
+	for i := range entries {
+		e := &entries[i]
+
+		matched := false
+		streams := make(map[string]bool)
+		for _, rt := range tb.routes {
+			if rt.expr != nil && !rt.expr.Match(e) {
+				continue
+			}
+			matched = true
+
+			for _, dest := range rt.destinations {
+				routedTotal.Inc(dest.Name)
+				if _, ok := tb.outputs[dest.Name]; ok {
+					sends[dest.Name] = append(sends[dest.Name], *e)
+					continue
+				}
+				if dest.Type != config.DestinationStream || streams[dest.Stream] {
+					continue
+				}
+				streams[dest.Stream] = true
+
+				copied := *e
+				copied.Stream = dest.Stream
+				routed = append(routed, copied)
+			}
+			if !rt.conf.Continue {
+				break
+			}
+		}
+
+		if !matched {
+			routedTotal.Inc(unrouted)
+			routed = append(routed, *e)
+		}
+	}
+
+	for name, sent := range sends {
+		tb.outputs[name].Send(sent)
+	}
+	return routed
+}
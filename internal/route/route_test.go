@@ -0,0 +1,170 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package route
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+// recordOutput records the sent entries
+type recordOutput struct {
+	batches [][]entry.Entry
+}
+
+func (o *recordOutput) Send(entries []entry.Entry) {
+	o.batches = append(o.batches, entries)
+}
+
+// streamsOf returns "<message>:<stream>" of the entries.
+func streamsOf(entries []entry.Entry) []string {
+	result := []string{}
+	for _, e := range entries {
+		result = append(result, e.Message+":"+e.Stream)
+	}
+	return result
+}
+
+var testDestinations = []config.Destination{
+	{Name: "errors", Type: config.DestinationStream, Stream: "errors"},
+	{Name: "errors_copy", Type: config.DestinationStream, Stream: "errors"},
+	{Name: "audit", Type: config.DestinationStream, Stream: "audit"},
+	{Name: "discard", Type: config.DestinationDrop},
+	{Name: "central", Type: config.DestinationHttp},
+}
+
+func TestProcess(t *testing.T) {
+	entries := []entry.Entry{
+		{Level: "error", Source: "api", Stream: "default", Message: "e1"},
+		{Level: "debug", Source: "api", Stream: "default", Message: "d1"},
+		{Level: "info", Source: "auth", Stream: "default", Message: "i1"},
+		{Level: "info", Source: "web", Stream: "web", Message: "i2"},
+	}
+	tests := []struct {
+		name   string
+		routes []config.Route
+		want   []string
+		sent   []string
+	}{
+		{"no route", nil, []string{"e1:default", "d1:default", "i1:default", "i2:web"}, nil},
+		{"first match", []config.Route{
+			{Match: "level >= error", Destinations: []string{"errors"}},
+			{Match: "source == api", Destinations: []string{"audit"}},
+		}, []string{"e1:errors", "d1:audit", "i1:default", "i2:web"}, nil},
+		{"continue", []config.Route{
+			{Match: "level >= error", Destinations: []string{"errors"}, Continue: true},
+			{Match: "source == api", Destinations: []string{"audit"}},
+		}, []string{"e1:errors", "e1:audit", "d1:audit", "i1:default", "i2:web"}, nil},
+		{"stored once per stream", []config.Route{
+			{Match: "level >= error", Destinations: []string{"errors", "errors_copy"}, Continue: true},
+			{Match: "level >= warn", Destinations: []string{"errors_copy"}},
+		}, []string{"e1:errors", "d1:default", "i1:default", "i2:web"}, nil},
+		{"drop", []config.Route{
+			{Match: "level == debug", Destinations: []string{"discard"}},
+			{Match: "source == auth", Destinations: []string{"discard", "audit"}},
+		}, []string{"e1:default", "i1:audit", "i2:web"}, nil},
+		{"output", []config.Route{
+			{Match: "level >= info", Destinations: []string{"central"}, Continue: true},
+			{Match: "source == auth", Destinations: []string{"audit"}},
+		}, []string{"d1:default", "i1:audit"}, []string{"e1:default", "i1:default", "i2:web"}},
+		{"output only", []config.Route{
+			{Destinations: []string{"central"}},
+		}, []string{}, []string{"e1:default", "d1:default", "i1:default", "i2:web"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			central := &recordOutput{}
+			r, err := NewRouter(testDestinations, tt.routes, map[string]Output{"central": central})
+			if err != nil {
+				t.Fatalf("NewRouter: %s", err)
+			}
+			input := append([]entry.Entry{}, entries...)
+			if got := streamsOf(r.Process(input)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("routed = %v, want %v", got, tt.want)
+			}
+
+			// The entries of an output are sent at once
+			if tt.sent == nil {
+				if len(central.batches) != 0 {
+					t.Errorf("sent = %v, want none", central.batches)
+				}
+				return
+			}
+			if len(central.batches) != 1 || !reflect.DeepEqual(streamsOf(central.batches[0]), tt.sent) {
+				t.Errorf("sent = %v, want one batch of %v", central.batches, tt.sent)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	r, err := NewRouter(testDestinations, []config.Route{{Destinations: []string{"errors"}}}, nil)
+	if err != nil {
+		t.Fatalf("NewRouter: %s", err)
+	}
+
+	// An invalid rule set is rejected and the current rules stay in effect
+	if err := r.Reload(testDestinations, []config.Route{{Match: "level ==", Destinations: []string{"audit"}}}, nil); err == nil {
+		t.Fatal("Reload() with an invalid match succeeded")
+	}
+	if got := streamsOf(r.Process([]entry.Entry{{Message: "m"}})); !reflect.DeepEqual(got, []string{"m:errors"}) {
+		t.Errorf("routed after the failed reload = %v", got)
+	}
+
+	// Entries being routed use either the previous or the new rules as a whole
+	ruleSets := [][]config.Route{
+		{{Destinations: []string{"errors"}}},
+		{{Destinations: []string{"audit"}}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ctx.Err() == nil; i++ {
+			if err := r.Reload(testDestinations, ruleSets[i%2], nil); err != nil {
+				t.Errorf("Reload: %s", err)
+				return
+			}
+		}
+	}()
+
+	batch := make([]entry.Entry, 100)
+	for i := 0; i < 200; i++ {
+		routed := r.Process(append([]entry.Entry{}, batch...))
+		for _, e := range routed {
+			if e.Stream != routed[0].Stream {
+				t.Fatalf("batch routed by both rule sets: %s and %s", routed[0].Stream, e.Stream)
+			}
+		}
+	}
+	cancel()
+	wg.Wait()
+
+	if err := r.Reload(testDestinations[:1], nil, nil); err != nil {
+		t.Fatalf("Reload: %s", err)
+	}
+	if got := r.Destinations(); len(got) != 1 || got[0].Name != "errors" {
+		t.Errorf("Destinations() = %v, want the reloaded destinations", got)
+	}
+}
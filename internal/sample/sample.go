@@ -0,0 +1,353 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package sample implements the sampling and rate limiting rules that
+suppress the entries of noisy sources in the write pipeline. The number
+of suppressed entries is reported by summary entries so that dropped
+data is visible.
+*/
+package sample
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/internal/fingerprint"
+	"github.com/hoon-kr/log_manager/internal/match"
+	"github.com/hoon-kr/log_manager/internal/metrics"
+)
+
+// Source of the summary entries without a single source
+const summarySource = "log_manager"
+
+// Maximum number of keys tracked per rule (entries of other keys are not limited)
+const maxStates = 100000
+
+// Number of entries suppressed per rule
+var suppressedTotal = metrics.NewCounter("log_manager_suppressed_entries_total",
+	"Number of entries suppressed by the sampling rules.", "rule")
+
+// Sampler is a sampling rule set structure. It is used by the single
+// writer goroutine of the pipeline.
+type Sampler struct {
+	rules           []*rule
+	summaryInterval time.Duration
+	lastSummary     time.Time
+	random          *rand.Rand
+}
+
+// rule is a compiled sampling rule structure
+type rule struct {
+	conf        config.SamplingRule
+	expr        *match.Expr
+	idleTimeout time.Duration
+	states      map[string]*state
+}
+
+// matchedRule is a rule that passes an entry, with the state of its key
+type matchedRule struct {
+	rule  *rule
+	key   string
+	state *state
+}
+
+// state is a sampling state of a key structure
+type state struct {
+	tokens          float64   // Remaining entries of the bucket (rate_limit)
+	windowStart     time.Time // Start of the window (head)
+	passed          int       // Entries passed in the window (head)
+	updated         time.Time // Last passed entry time
+	suppressed      int       // Entries suppressed since the last summary
+	firstSuppressed time.Time
+	lastSuppressed  time.Time
+	last            entry.Entry // Last suppressed entry
+}
+
+// NewSampler create sampler. The rules are validated when the
+// configuration is loaded.
+//
+// Parameters:
+//   - rules: sampling rules
+//   - summaryInterval: interval of the summary entries
+//
+// Returns:
+//   - *Sampler: sampler (nil if there is no rule)
+func NewSampler(rules []config.SamplingRule, summaryInterval time.Duration) *Sampler {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	s := &Sampler{
+		summaryInterval: summaryInterval,
+		lastSummary:     time.Now(),
+		random:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, conf := range rules {
+		rl := &rule{conf: conf, states: make(map[string]*state)}
+		if conf.Match != "" {
+			rl.expr, _ = match.Compile(conf.Match)
+		}
+
+		// A state idle for this time is the same as a new state
+		rl.idleTimeout = summaryInterval
+		switch conf.Type {
+		case config.SamplingRateLimit:
+			refill := time.Duration(float64(conf.Burst) / float64(conf.Limit) * float64(time.Second))
+			rl.idleTimeout = max(rl.idleTimeout, refill)
+		case config.SamplingHead:
+			rl.idleTimeout = max(rl.idleTimeout, time.Duration(conf.Interval)*time.Second)
+		}
+		s.rules = append(s.rules, rl)
+	}
+	return s
+}
+
+// Process suppresses the entries over the limits of the rules. An entry
+// is passed only if every rule that matches it passes it, and only then
+// does it use up the limits of the rules. Summary entries of the
+// suppressed entries are added every summary interval.
+//
+// Parameters:
+//   - entries: log entries
+//
+// Returns:
+//   - []entry.Entry: passed entries and summary entries
+func (s *Sampler) Process(entries []entry.Entry) []entry.Entry {
+	return s.process(entries, time.Now())
+}
+
+// process suppresses the entries over the limits of the rules.
+//
+// Parameters:
+//   - entries: log entries
+//   - now: current time
+//
+// Returns:
+//   - []entry.Entry: passed entries and summary entries
+func (s *Sampler) process(entries []entry.Entry, now time.Time) []entry.Entry {
+	passed := entries[:0]
+	matched := []matchedRule{}
+	for i := range entries {
+		e := &entries[i]
+
+		keep := true
+		fp := ""
+		matched = matched[:0]
+		for _, rl := range s.rules {
+			if rl.expr != nil && !rl.expr.Match(e) {
+				continue
+			}
+			if fp == "" && (rl.conf.Key == config.SamplingKeyFingerprint ||
+				rl.conf.Key == config.SamplingKeySourceFingerprint) {
+				fp = fingerprint.Of(e.Message)
+			}
+
+			key := rl.key(e, fp)
+			st, exists := rl.states[key]
+			if !exists {
+				if len(rl.states) >= maxStates {
+					continue
+				}
+				st = &state{tokens: float64(rl.conf.Burst), windowStart: now, updated: now}
+			}
+			if !s.allow(rl, st, now) {
+				rl.states[key] = st
+				rl.suppress(st, e)
+				keep = false
+				break
+			}
+			matched = append(matched, matchedRule{rule: rl, key: key, state: st})
+		}
+		if !keep {
+			continue
+		}
+
+		for _, m := range matched {
+			m.rule.states[m.key] = m.state
+			m.rule.consume(m.state, now)
+		}
+		passed = append(passed, *e)
+	}
+
+	if now.Sub(s.lastSummary) >= s.summaryInterval {
+		s.lastSummary = now
+		passed = append(passed, s.summaries(now)...)
+	}
+	return passed
+}
+
+// Flush returns the summary entries of the entries suppressed since the
+// last summary.
+//
+// Returns:
+//   - []entry.Entry: summary entries
+func (s *Sampler) Flush() []entry.Entry {
+	return s.summaries(time.Now())
+}
+
+// key returns the key by which the rule counts the entry.
+//
+// Parameters:
+//   - e: log entry
+//   - fp: fingerprint of the message
+//
+// Returns:
+//   - string: sampling key
+func (rl *rule) key(e *entry.Entry, fp string) string {
+	switch rl.conf.Key {
+	case config.SamplingKeySource:
+		return e.Source
+	case config.SamplingKeyFingerprint:
+		return fp
+	case config.SamplingKeySourceFingerprint:
+		return e.Source + "/" + fp
+	}
+	return ""
+}
+
+// allow decides whether the rule passes an entry of the key. The state
+// is not changed.
+//
+// Parameters:
+//   - rl: sampling rule
+//   - st: sampling state of the key
+//   - now: current time
+//
+// Returns:
+//   - bool: passed(true), suppressed(false)
+func (s *Sampler) allow(rl *rule, st *state, now time.Time) bool {
+	switch rl.conf.Type {
+	case config.SamplingRateLimit:
+		return st.refilled(rl, now) >= 1
+	case config.SamplingHead:
+		return st.passed < rl.conf.Limit || now.Sub(st.windowStart) >= time.Duration(rl.conf.Interval)*time.Second
+	case config.SamplingProbabilistic:
+		return s.random.Float64() < rl.conf.Ratio
+	}
+	return true
+}
+
+// consume uses up the limit of the rule for a passed entry.
+//
+// Parameters:
+//   - st: sampling state of the key
+//   - now: current time
+func (rl *rule) consume(st *state, now time.Time) {
+	switch rl.conf.Type {
+	case config.SamplingRateLimit:
+		st.tokens = st.refilled(rl, now) - 1
+	case config.SamplingHead:
+		if now.Sub(st.windowStart) >= time.Duration(rl.conf.Interval)*time.Second {
+			st.windowStart, st.passed = now, 0
+		}
+		st.passed++
+	}
+	st.updated = now
+}
+
+// suppress records a suppressed entry.
+//
+// Parameters:
+//   - st: sampling state of the key
+//   - e: log entry
+func (rl *rule) suppress(st *state, e *entry.Entry) {
+	if st.suppressed == 0 {
+		st.firstSuppressed = e.Time
+	}
+	st.suppressed++
+	st.lastSuppressed = e.Time
+	st.last = *e
+	suppressedTotal.Inc(rl.conf.Name)
+}
+
+// refilled returns the tokens of the bucket refilled since the last
+// passed entry.
+//
+// Parameters:
+//   - rl: sampling rule
+//   - now: current time
+//
+// Returns:
+//   - float64: remaining entries of the bucket
+func (st *state) refilled(rl *rule, now time.Time) float64 {
+	return min(float64(rl.conf.Burst), st.tokens+now.Sub(st.updated).Seconds()*float64(rl.conf.Limit))
+}
+
+// summaries makes the summary entries of the keys with suppressed
+// entries, and removes the idle states.
+//
+// Parameters:
+//   - now: current time
+//
+// Returns:
+//   - []entry.Entry: summary entries
+func (s *Sampler) summaries(now time.Time) []entry.Entry {
+	summaries := []entry.Entry{}
+	for _, rl := range s.rules {
+		for key, st := range rl.states {
+			if st.suppressed > 0 {
+				summaries = append(summaries, rl.summary(key, st, now))
+				st.suppressed = 0
+				st.last = entry.Entry{}
+			} else if now.Sub(st.updated) >= rl.idleTimeout {
+				delete(rl.states, key)
+			}
+		}
+	}
+	return summaries
+}
+
+// summary makes the summary entry of the suppressed entries of a key.
+// The entry is stored in the stream of the last suppressed entry.
+//
+// Parameters:
+//   - key: sampling key
+//   - st: sampling state
+//   - now: current time
+//
+// Returns:
+//   - entry.Entry: summary entry
+func (rl *rule) summary(key string, st *state, now time.Time) entry.Entry {
+	source := st.last.Source
+	if rl.conf.Key == config.SamplingKeyFingerprint || rl.conf.Key == config.SamplingKeyAll {
+		source = summarySource
+	}
+
+	message := fmt.Sprintf("%d messages suppressed (rule:%s)", st.suppressed, rl.conf.Name)
+	if key != "" {
+		message = fmt.Sprintf("%d messages suppressed (rule:%s, %s:%s)", st.suppressed, rl.conf.Name, rl.conf.Key, key)
+	}
+
+	return entry.Entry{
+		Time:    now.UTC(),
+		Level:   entry.LevelWarn,
+		Source:  source,
+		Stream:  st.last.Stream,
+		Message: message,
+		Fields: map[string]interface{}{
+			"suppressed":       st.suppressed,
+			"sampling_rule":    rl.conf.Name,
+			"sampling_key":     key,
+			"first_suppressed": st.firstSuppressed.UTC().Format(time.RFC3339Nano),
+			"last_suppressed":  st.lastSuppressed.UTC().Format(time.RFC3339Nano),
+			"sample":           st.last.Message,
+		},
+	}
+}
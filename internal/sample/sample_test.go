@@ -0,0 +1,227 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package sample
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/entry"
+)
+
+var base = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestSampler creates a sampler with a fixed random source, whose
+// first summary is due at base+summaryInterval.
+func newTestSampler(summaryInterval time.Duration, rules ...config.SamplingRule) *Sampler {
+	s := NewSampler(rules, summaryInterval)
+	s.lastSummary = base
+	s.random = rand.New(rand.NewSource(1))
+	return s
+}
+
+// sourceEntries creates an entry per source.
+func sourceEntries(sources ...string) []entry.Entry {
+	entries := make([]entry.Entry, len(sources))
+	for i, source := range sources {
+		entries[i] = entry.Entry{Time: base, Level: "info", Source: source, Stream: "app",
+			Message: source + " message " + strconv.Itoa(i)}
+	}
+	return entries
+}
+
+// sources returns the sources of the entries.
+func sources(entries []entry.Entry) []string {
+	srcs := []string{}
+	for _, e := range entries {
+		srcs = append(srcs, e.Source)
+	}
+	return srcs
+}
+
+func TestProcess(t *testing.T) {
+	type step struct {
+		at      time.Duration
+		entries []string
+		want    []string
+	}
+	tests := []struct {
+		name  string
+		rule  config.SamplingRule
+		steps []step
+	}{
+		{
+			name: "rate_limit",
+			rule: config.SamplingRule{Name: "rl", Type: config.SamplingRateLimit, Key: config.SamplingKeySource,
+				Limit: 2, Burst: 3},
+			steps: []step{
+				{0, []string{"a", "a", "a", "a", "b"}, []string{"a", "a", "a", "b"}},
+				{time.Second, []string{"a", "a", "a"}, []string{"a", "a"}},
+				{1500 * time.Millisecond, []string{"a", "a"}, []string{"a"}},
+				{10 * time.Second, []string{"a", "a", "a", "a"}, []string{"a", "a", "a"}},
+			},
+		},
+		{
+			name: "rate_limit all",
+			rule: config.SamplingRule{Name: "rl", Type: config.SamplingRateLimit, Key: config.SamplingKeyAll,
+				Limit: 1, Burst: 2},
+			steps: []step{
+				{0, []string{"a", "b", "c"}, []string{"a", "b"}},
+			},
+		},
+		{
+			name: "head",
+			rule: config.SamplingRule{Name: "head", Type: config.SamplingHead, Key: config.SamplingKeySource,
+				Limit: 2, Interval: 60},
+			steps: []step{
+				{0, []string{"a", "a", "a", "b"}, []string{"a", "a", "b"}},
+				{59 * time.Second, []string{"a"}, []string{}},
+				{60 * time.Second, []string{"a", "a", "a"}, []string{"a", "a"}},
+			},
+		},
+		{
+			name: "probabilistic all",
+			rule: config.SamplingRule{Name: "p", Type: config.SamplingProbabilistic, Ratio: 1},
+			steps: []step{
+				{0, []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSampler(time.Hour, tt.rule)
+			for _, st := range tt.steps {
+				got := s.process(sourceEntries(st.entries...), base.Add(st.at))
+				if !reflect.DeepEqual(sources(got), st.want) {
+					t.Errorf("at %s: passed = %v, want %v", st.at, sources(got), st.want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessProbabilistic(t *testing.T) {
+	s := newTestSampler(time.Hour, config.SamplingRule{Name: "p", Type: config.SamplingProbabilistic,
+		Key: config.SamplingKeyAll, Ratio: 0.25})
+	entries := make([]string, 1000)
+	for i := range entries {
+		entries[i] = "a"
+	}
+	passed := len(s.process(sourceEntries(entries...), base))
+	if passed < 200 || passed > 300 {
+		t.Errorf("passed = %d of 1000, want about 250", passed)
+	}
+	if suppressed := s.rules[0].states[""].suppressed; passed+suppressed != 1000 {
+		t.Errorf("passed + suppressed = %d, want 1000", passed+suppressed)
+	}
+}
+
+func TestProcessRuleBudget(t *testing.T) {
+	// An entry suppressed by the second rule does not use up the first
+	s := newTestSampler(time.Hour,
+		config.SamplingRule{Name: "total", Type: config.SamplingRateLimit, Key: config.SamplingKeyAll, Limit: 1, Burst: 2},
+		config.SamplingRule{Name: "per_source", Type: config.SamplingHead, Key: config.SamplingKeySource, Limit: 1,
+			Interval: 60, Match: "source == a"})
+	got := s.process(sourceEntries("a", "a", "b", "c"), base)
+	if want := []string{"a", "b"}; !reflect.DeepEqual(sources(got), want) {
+		t.Errorf("passed = %v, want %v", sources(got), want)
+	}
+	if total, perSource := s.rules[0].states[""].suppressed, s.rules[1].states["a"].suppressed; total != 1 || perSource != 1 {
+		t.Errorf("suppressed = (%d, %d), want (1, 1)", total, perSource)
+	}
+}
+
+func TestProcessMaxStates(t *testing.T) {
+	s := newTestSampler(time.Hour, config.SamplingRule{Name: "head", Type: config.SamplingHead,
+		Key: config.SamplingKeySource, Limit: 1, Interval: 60})
+	srcs := make([]string, maxStates)
+	for i := range srcs {
+		srcs[i] = strconv.Itoa(i)
+	}
+	if got := s.process(sourceEntries(srcs...), base); len(got) != maxStates {
+		t.Fatalf("passed %d entries, want %d", len(got), maxStates)
+	}
+
+	// Keys over the limit are not limited, and the tracked keys still are
+	got := s.process(sourceEntries("over", "over", "0"), base)
+	if want := []string{"over", "over"}; !reflect.DeepEqual(sources(got), want) {
+		t.Errorf("passed = %v, want %v", sources(got), want)
+	}
+	if len(s.rules[0].states) != maxStates {
+		t.Errorf("states = %d, want %d", len(s.rules[0].states), maxStates)
+	}
+}
+
+func TestSummaries(t *testing.T) {
+	s := newTestSampler(10*time.Second,
+		config.SamplingRule{Name: "rl", Type: config.SamplingRateLimit, Key: config.SamplingKeySource, Limit: 1, Burst: 1,
+			Match: "source == api"},
+		config.SamplingRule{Name: "fp", Type: config.SamplingHead, Key: config.SamplingKeyFingerprint, Limit: 1,
+			Interval: 1, Match: "source == db"})
+
+	entries := sourceEntries("api", "api", "api", "db", "db")
+	entries[1].Time = base.Add(time.Second)
+	entries[2].Time = base.Add(2 * time.Second)
+	entries[4].Message = "db message 7"
+	if got := s.process(entries, base); !reflect.DeepEqual(sources(got), []string{"api", "db"}) {
+		t.Fatalf("passed = %v, want [api db]", sources(got))
+	}
+
+	// The summaries are added at the summary interval
+	if got := s.process(nil, base.Add(9*time.Second)); len(got) != 0 {
+		t.Fatalf("entries before the summary interval = %+v", got)
+	}
+	got := s.process(nil, base.Add(10*time.Second))
+	if len(got) != 2 {
+		t.Fatalf("summaries = %+v, want 2", got)
+	}
+	summary := got[0]
+	if summary.Message != "2 messages suppressed (rule:rl, source:api)" || summary.Source != "api" ||
+		summary.Stream != "app" || summary.Level != entry.LevelWarn || !summary.Time.Equal(base.Add(10*time.Second)) {
+		t.Errorf("summary = %+v", summary)
+	}
+	want := map[string]interface{}{
+		"suppressed":       2,
+		"sampling_rule":    "rl",
+		"sampling_key":     "api",
+		"first_suppressed": "2024-05-01T12:00:01Z",
+		"last_suppressed":  "2024-05-01T12:00:02Z",
+		"sample":           "api message 2",
+	}
+	if !reflect.DeepEqual(summary.Fields, want) {
+		t.Errorf("fields = %v, want %v", summary.Fields, want)
+	}
+	if got[1].Source != summarySource || got[1].Fields["sample"] != "db message 7" {
+		t.Errorf("fingerprint summary = %+v", got[1])
+	}
+
+	// The idle states are removed after a summary without suppressed entries
+	if got := s.process(nil, base.Add(20*time.Second)); len(got) != 0 {
+		t.Errorf("summaries = %+v, want none", got)
+	}
+	if len(s.rules[0].states) != 0 || len(s.rules[1].states) != 0 {
+		t.Errorf("states = %d, %d, want no idle state", len(s.rules[0].states), len(s.rules[1].states))
+	}
+	if got := s.Flush(); len(got) != 0 {
+		t.Errorf("Flush() = %+v, want none", got)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package server
+
+import (
+	"context"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/api"
+	"github.com/hoon-kr/log_manager/internal/input/forward"
+	"github.com/hoon-kr/log_manager/internal/input/gelf"
+	"github.com/hoon-kr/log_manager/internal/input/journal"
+	"github.com/hoon-kr/log_manager/internal/input/tail"
+	"github.com/hoon-kr/log_manager/internal/input/tcp"
+	"github.com/hoon-kr/log_manager/internal/logger"
+)
+
+// addInputTasks registers the goroutine tasks of the enabled listeners.
+// A listener whose input cannot be created (e.g. a parser that fails to
+// compile) is logged and not started.
+func addInputTasks() {
+	for _, listener := range config.Conf.Listeners {
+		if !listener.Enable {
+			continue
+		}
+
+		run, err := newInputTask(listener)
+		if err != nil {
+			logger.Log.LogError("failed to start listener (name:%s): %s", listener.Name, err)
+			continue
+		}
+		if run == nil {
+			continue
+		}
+
+		name := "listener_" + listener.Name
+		gm.AddTask(name, run)
+		inputTasks = append(inputTasks, name)
+	}
+}
+
+// newInputTask create the input of the listener.
+//
+// Parameters:
+//   - listener: listener configuration
+//
+// Returns:
+//   - func(context.Context): goroutine task of the input (nil if the protocol is unknown)
+//   - error: success(nil), failure(error)
+func newInputTask(listener config.Listener) (func(context.Context), error) {
+	switch listener.Protocol {
+	case config.ListenerHttp:
+		s, err := api.NewServer(listener, pl, st, miner, alerts)
+		if err != nil {
+			return nil, err
+		}
+		return s.Run, nil
+	case config.ListenerGelfUdp, config.ListenerGelfTcp:
+		in, err := gelf.NewInput(listener, pl)
+		if err != nil {
+			return nil, err
+		}
+		return in.Run, nil
+	case config.ListenerForward:
+		in, err := forward.NewInput(listener, pl)
+		if err != nil {
+			return nil, err
+		}
+		return in.Run, nil
+	case config.ListenerTail:
+		in, err := tail.NewInput(listener, pl)
+		if err != nil {
+			return nil, err
+		}
+		return in.Run, nil
+	case config.ListenerTcp:
+		in, err := tcp.NewInput(listener, pl)
+		if err != nil {
+			return nil, err
+		}
+		return in.Run, nil
+	case config.ListenerJournalExport:
+		in, err := journal.NewExportInput(listener, pl)
+		if err != nil {
+			return nil, err
+		}
+		return in.Run, nil
+	case config.ListenerJournalSocket:
+		in, err := journal.NewSocketInput(listener, pl)
+		if err != nil {
+			return nil, err
+		}
+		return in.Run, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+// TestNotify sends a test alert to a notification channel with the
+// retries of the channel, and prints the result.
+//
+// Parameters:
+//   - cmd: command parameter info
+//
+// Returns:
+//   - int: normal shutdown(0), abnormal shutdown(>=1)
+//   - error: normal shutdown(nil), abnormal shutdown(error)
+func TestNotify(cmd *cobra.Command) (int, error) {
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "[WARNING] invalid parameter: [*cobra.Command] is nil\n")
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	// Set file paths and load configuration
+	err := setupConfig(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	name, _ := cmd.Flags().GetString("channel")
+	var conf *config.NotifyChannel
+	for i := range config.Conf.NotifyChannels {
+		if config.Conf.NotifyChannels[i].Name == name {
+			conf = &config.Conf.NotifyChannels[i]
+		}
+	}
+	if conf == nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] unknown notification channel: %s\n", name)
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	channel, err := notify.NewChannel(*conf)
+	if err == nil {
+		err = channel.Send(context.Background(), notify.TestAlert())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] failed to send test notification (%s): %s\n", name, err)
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	fmt.Fprintf(os.Stdout, "[INFO] sent test notification (%s)\n", name)
+	return config.ExitCodeSuccess, nil
+}
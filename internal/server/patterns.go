@@ -0,0 +1,150 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/api"
+	"github.com/spf13/cobra"
+)
+
+// Timeout of the requests to the HTTP API
+const apiRequestTimeout = 10 * time.Second
+
+// ShowPatterns prints the message templates with the most entries in the
+// window and their change from the previous window, requested from the
+// HTTP API of the running daemon.
+//
+// Parameters:
+//   - cmd: command parameter info
+//
+// Returns:
+//   - int: normal shutdown(0), abnormal shutdown(>=1)
+//   - error: normal shutdown(nil), abnormal shutdown(error)
+func ShowPatterns(cmd *cobra.Command) (int, error) {
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "[WARNING] invalid parameter: [*cobra.Command] is nil\n")
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	// Set file paths and load configuration
+	err := setupConfig(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	address, _ := cmd.Flags().GetString("address")
+	if address == "" {
+		address = apiAddress()
+	}
+	if address == "" {
+		fmt.Fprintf(os.Stderr, "[ERROR] there is no http listener (use --address)\n")
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	limit, _ := cmd.Flags().GetInt("limit")
+	order, _ := cmd.Flags().GetString("sort")
+	query := url.Values{}
+	// Without the flag, the daemon shortens the default window to its history
+	if cmd.Flags().Changed("window") {
+		window, _ := cmd.Flags().GetInt("window")
+		query.Set("window", strconv.Itoa(window))
+	}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("sort", order)
+
+	resp := api.PatternResponse{}
+	if err := getAPI(address, "/api/v1/patterns?"+query.Encode(), &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	fmt.Fprintf(os.Stdout, "# Last %d minutes compared with the %d minutes before\n", resp.Window, resp.Window)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "COUNT\tPREVIOUS\tCHANGE\tTEMPLATE\n")
+	for _, p := range resp.Patterns {
+		change := "new"
+		if p.Change != nil {
+			change = fmt.Sprintf("%+.0f%%", *p.Change)
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\n", p.Count, p.PreviousCount, change, p.Template)
+	}
+	tw.Flush()
+
+	return config.ExitCodeSuccess, nil
+}
+
+// apiAddress returns the address of the first http listener, with a
+// wildcard host replaced by the loopback address.
+//
+// Returns:
+//   - string: address (host:port, empty if there is no http listener)
+func apiAddress() string {
+	for _, listener := range config.Conf.Listeners {
+		if !listener.Enable || listener.Protocol != config.ListenerHttp {
+			continue
+		}
+		host, port, err := net.SplitHostPort(listener.Address)
+		if err != nil {
+			return listener.Address
+		}
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			host = "127.0.0.1"
+		}
+		return net.JoinHostPort(host, port)
+	}
+	return ""
+}
+
+// getAPI requests the HTTP API and decodes the JSON response.
+//
+// Parameters:
+//   - address: API address (host:port)
+//   - path: request path with the query
+//   - body: response body
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func getAPI(address, path string, body interface{}) error {
+	client := &http.Client{Timeout: apiRequestTimeout}
+	resp, err := client.Get("http://" + address + path)
+	if err != nil {
+		return fmt.Errorf("failed to request API: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		failure := map[string]string{}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return fmt.Errorf("failed to request API: %s (%s)", resp.Status, failure["error"])
+	}
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return fmt.Errorf("failed to decode API response: %s", err)
+	}
+	return nil
+}
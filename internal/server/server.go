@@ -20,20 +20,70 @@ Package server controls log_manager module
 package server
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/hoon-kr/log_manager/config"
+	"github.com/hoon-kr/log_manager/internal/alert"
+	"github.com/hoon-kr/log_manager/internal/dedup"
 	"github.com/hoon-kr/log_manager/internal/logger"
+	"github.com/hoon-kr/log_manager/internal/match"
+	"github.com/hoon-kr/log_manager/internal/metrics"
+	"github.com/hoon-kr/log_manager/internal/notify"
+	"github.com/hoon-kr/log_manager/internal/output"
+	"github.com/hoon-kr/log_manager/internal/pattern"
+	"github.com/hoon-kr/log_manager/internal/pipeline"
+	"github.com/hoon-kr/log_manager/internal/redact"
+	"github.com/hoon-kr/log_manager/internal/route"
+	"github.com/hoon-kr/log_manager/internal/sample"
+	"github.com/hoon-kr/log_manager/internal/store"
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
 	"github.com/hoon-kr/log_manager/pkg/utils/file"
+	"github.com/hoon-kr/log_manager/pkg/utils/goroutine"
 	"github.com/hoon-kr/log_manager/pkg/utils/process"
 	"github.com/spf13/cobra"
 )
 
+// Goroutine termination wait timeout
+const goroutineStopTimeout = 10 * time.Second
+
+// Interval for applying the retention of the stream destinations
+const retentionInterval = time.Minute
+
+var (
+	gm         *goroutine.GoroutineManager // Goroutine manager of the module
+	st         *store.Store                // Entry store
+	pl         *pipeline.Pipeline          // Write pipeline
+	router     *route.Router               // Entry router
+	miner      *pattern.Miner              // Message template miner (nil if disabled)
+	alerts     *alert.Engine               // Alert rule engine (nil if there is no rule)
+	outputs    map[string]runningOutput    // Outputs of the output destinations by name
+	inputTasks []string                    // Goroutine task names of the inputs
+)
+
+// runningOutput is an output of a destination whose goroutine is registered
+type runningOutput struct {
+	output   output.Output
+	destType string
+	task     string // Goroutine task name
+}
+
+// init registers the validator of the match expressions of the
+// configuration.
+func init() {
+	config.RegisterMatchValidator(func(expr string) error {
+		_, err := match.Compile(expr)
+		return err
+	})
+}
+
 // StartServer runs the Log Management daemon.
 //
 // Parameters:
@@ -48,8 +98,8 @@ func StartServer(cmd *cobra.Command) (int, error) {
 		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
 	}
 
-	// Change working path to the current process path
-	err := file.ChangeWorkPathToModulePath()
+	// Set file paths and load configuration
+	err := setupConfig(cmd)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
 		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
@@ -63,17 +113,29 @@ func StartServer(cmd *cobra.Command) (int, error) {
 	}
 
 	// Daemonize process
+	// The daemon process resolves the relative paths of the arguments
+	// again, so the working path is changed after daemonization
 	err = process.DaemonizeProcess()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
 		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
 	}
 
+	// Change working path to the home path
+	err = os.MkdirAll(config.RunConf.HomePath, os.ModePerm)
+	if err == nil {
+		err = file.ChangeWorkPath(config.RunConf.HomePath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
 	// Save current process pid
 	config.RunConf.Pid = os.Getpid()
 
 	// Write PID to file
-	err = file.WriteDataToTextFile(config.PidFilePath, config.RunConf.Pid, true)
+	err = file.WriteDataToTextFile(config.Conf.PidFilePath, config.RunConf.Pid, true)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
 		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
@@ -105,8 +167,14 @@ func StartServer(cmd *cobra.Command) (int, error) {
 		}())
 
 	// Wait for the signal to terminate (SIGINT, SIGTERM)
-	sig := <-sigChan
-	logger.Log.LogInfo("Received %s signal (%d)", sig.String(), sig)
+	// SIGHUP reloads the reloadable configuration
+	for sig := range sigChan {
+		logger.Log.LogInfo("Received %s signal (%d)", sig.String(), sig)
+		if sig != syscall.SIGHUP {
+			break
+		}
+		reloadConfig()
+	}
 
 	return config.ExitCodeSuccess, nil
 }
@@ -125,8 +193,8 @@ func StopServer(cmd *cobra.Command) (int, error) {
 		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
 	}
 
-	// Change working path to the current process path
-	err := file.ChangeWorkPathToModulePath()
+	// Set file paths and load configuration
+	err := setupConfig(cmd)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
 		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
@@ -147,6 +215,74 @@ func StopServer(cmd *cobra.Command) (int, error) {
 	return config.ExitCodeSuccess, nil
 }
 
+// ReloadServer makes the Log Management daemon reload the reloadable
+// configuration (routing).
+//
+// Parameters:
+//   - cmd: command parameter info
+//
+// Returns:
+//   - int: normal shutdown(0), abnormal shutdown(>=1)
+//   - error: normal shutdown(nil), abnormal shutdown(error)
+func ReloadServer(cmd *cobra.Command) (int, error) {
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "[WARNING] invalid parameter: [*cobra.Command] is nil\n")
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	// Set file paths and load configuration
+	err := setupConfig(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	// Check process running
+	var pid int
+	if !isRunning(&pid) {
+		fmt.Fprintf(os.Stderr, "[WARNING] there is no process in operation\n")
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	// Send reload(SIGHUP) signal
+	if err := process.SendSignal(pid, syscall.SIGHUP); err != nil {
+		fmt.Fprintf(os.Stderr, "[WARNING] %s\n", err)
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	return config.ExitCodeSuccess, nil
+}
+
+// ShowConfig prints the configuration.
+//
+// Parameters:
+//   - cmd: command parameter info
+//
+// Returns:
+//   - int: normal shutdown(0), abnormal shutdown(>=1)
+//   - error: normal shutdown(nil), abnormal shutdown(error)
+func ShowConfig(cmd *cobra.Command) (int, error) {
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "[WARNING] invalid parameter: [*cobra.Command] is nil\n")
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	// Set file paths and load configuration
+	err := setupConfig(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		return config.ExitCodeFailure, fmt.Errorf("%s(%d)", config.ExitFailure, config.ExitCodeFailure)
+	}
+
+	for _, ignored := range config.IgnoredValues() {
+		fmt.Fprintf(os.Stderr, "[WARNING] ignored %s\n", ignored)
+	}
+	effective, _ := cmd.Flags().GetBool("effective")
+	config.PrintConfig(os.Stdout, effective)
+
+	return config.ExitCodeSuccess, nil
+}
+
 // isRunning check log_manager process running.
 //
 // Returns:
@@ -157,7 +293,7 @@ func isRunning(pid *int) bool {
 	}
 
 	// Open pid file
-	file, err := os.Open(config.PidFilePath)
+	file, err := os.Open(config.Conf.PidFilePath)
 	if err != nil {
 		return false
 	}
@@ -185,26 +321,271 @@ func isRunning(pid *int) bool {
 //   - chan os.Signal: signal channel
 func setupSignal() chan os.Signal {
 	sigChan := make(chan os.Signal, 1)
-	// Set received signal (SIGINT, SIGTERM)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Set received signal (SIGINT, SIGTERM, SIGHUP)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	// Set signal to ignore
-	signal.Ignore(syscall.SIGABRT, syscall.SIGALRM, syscall.SIGFPE, syscall.SIGHUP,
+	signal.Ignore(syscall.SIGABRT, syscall.SIGALRM, syscall.SIGFPE,
 		syscall.SIGILL, syscall.SIGPROF, syscall.SIGQUIT, syscall.SIGTSTP,
 		syscall.SIGVTALRM)
 
 	return sigChan
 }
 
+// setupConfig determines the file paths from the command flags and
+// loads the configuration file.
+//
+// Parameters:
+//   - cmd: command parameter info
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func setupConfig(cmd *cobra.Command) error {
+	homePath, _ := cmd.Flags().GetString("home")
+	confFilePath, _ := cmd.Flags().GetString("config")
+	settings, _ := cmd.Flags().GetStringArray("set")
+
+	// Configuration values given by command line flags (key=value)
+	overrides := make(map[string]string)
+	for _, setting := range settings {
+		key, value, found := strings.Cut(setting, "=")
+		if !found {
+			return fmt.Errorf("invalid --set value (expected key=value): %s", setting)
+		}
+		overrides[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	// Set home path and configuration file path
+	if err := config.InitPaths(homePath, confFilePath); err != nil {
+		return err
+	}
+
+	// Load configuration
+	if err := config.LoadConfig(config.RunConf.ConfFilePath, overrides); err != nil {
+		return err
+	}
+
+	// Relative paths are based on the home path
+	config.ResolvePaths()
+
+	return nil
+}
+
 // initialization initialize the resources required for the module operation.
 func initialization() {
-	// Load configuration
-	config.LoadConfig(config.ConfFilePath)
 	// Initialize logger
 	logger.Log.InitializeLogger()
+	for _, ignored := range config.IgnoredValues() {
+		logger.Log.LogWarn("ignored %s", ignored)
+	}
+
+	// Run background tasks
+	gm = goroutine.NewGoroutineManager()
+	gm.AddTask("log_backup_manager", logger.Log.ManageBackupLogFiles)
+
+	// Initialize store and write pipeline
+	st = store.NewStore(config.Conf.DataDirPath, int64(config.Conf.SegmentMaxSize)*1024*1024,
+		compress.Algorithm(config.Conf.SegmentCompAlgo), config.Conf.SegmentCompLevel)
+	st.SetArchiveErrorHandler(func(path string, err error) {
+		logger.Log.LogWarn("failed to archive segment (%s): %s", path, err)
+	})
+	pl = pipeline.NewPipeline(config.Conf.PipelineQueueSize, st, func(err error) {
+		logger.Log.LogError("failed to write entries: %s", err)
+	})
+	// Alert rules count the entries before they are sampled or dropped
+	channels := make(map[string]alert.Notifier)
+	for _, conf := range config.Conf.NotifyChannels {
+		channel, err := notify.NewChannel(conf)
+		if err != nil {
+			logger.Log.LogError("failed to create notification channel (%s): %s", conf.Name, err)
+			continue
+		}
+		channels[conf.Name] = channel
+		gm.AddTask("notify_"+conf.Name, channel.Run)
+	}
+	alerts = alert.NewEngine(config.Conf.AlertRules, time.Duration(config.Conf.AlertEvaluationInterval)*time.Second,
+		channels, alert.NewStreamNotifier(config.Conf.AlertStream, pl.Submit))
+	if alerts != nil {
+		pl.AddProcessor(alerts)
+		gm.AddTask("alert_manager", alerts.Run)
+	}
+	if redactor := redact.NewRedactor(config.Conf.Redactions, config.Conf.RedactionHashKey); redactor != nil {
+		pl.AddProcessor(redactor)
+	}
+	// The miner sees the entries before sampling, so the spikes of rate
+	// limited sources are visible (the samples of the templates are redacted)
+	if config.Conf.PatternMining {
+		miner = pattern.NewMiner(float64(config.Conf.PatternSimilarity)/100, config.Conf.PatternMaxClusters,
+			time.Duration(config.Conf.PatternHistory)*time.Minute)
+		pl.AddProcessor(miner)
+	}
+	if sampler := sample.NewSampler(config.Conf.SamplingRules,
+		time.Duration(config.Conf.SamplingSummaryInterval)*time.Second); sampler != nil {
+		pl.AddProcessor(sampler)
+	}
+	if deduplicator := dedup.NewDeduplicator(time.Duration(config.Conf.DedupWindow)*time.Second,
+		config.Conf.DedupKey, config.Conf.Fingerprint); deduplicator != nil {
+		pl.AddProcessor(deduplicator)
+	}
+	routeOutputs, _ := updateOutputs(config.Conf.Destinations, false)
+	var err error
+	router, err = route.NewRouter(config.Conf.Destinations, config.Conf.Routes, routeOutputs)
+	if err != nil {
+		logger.Log.LogError("failed to initialize router: %s", err)
+		router, _ = route.NewRouter(nil, nil, nil)
+	}
+	pl.AddProcessor(router)
+	metrics.NewGaugeFunc("log_manager_pipeline_queue_depth", "Number of entries waiting in the write pipeline queue.",
+		func() float64 { return float64(pl.Depth()) })
+	gm.AddTask("pipeline", pl.Run)
+	gm.AddTask("retention_manager", manageRetention)
+
+	// Register inputs
+	addInputTasks()
+
+	gm.StartAll()
+}
+
+// reloadConfig reloads the routing configuration and applies it to the
+// router. The current configuration is kept if the new one is invalid.
+func reloadConfig() {
+	if err := config.ReloadConfig("Destinations", "Routes"); err != nil {
+		logger.Log.LogError("failed to reload configuration: %s", err)
+		return
+	}
+	routeOutputs, removed := updateOutputs(config.Conf.Destinations, true)
+	if err := router.Reload(config.Conf.Destinations, config.Conf.Routes, routeOutputs); err != nil {
+		logger.Log.LogError("failed to reload routes: %s", err)
+		return
+	}
+	// The removed outputs are stopped once the routing table no longer uses them
+	removeOutputs(removed)
+	logger.Log.LogInfo("Reloaded configuration (destinations:%d, routes:%d)",
+		len(config.Conf.Destinations), len(config.Conf.Routes))
+}
+
+// updateOutputs creates the outputs of the new output destinations and
+// applies the configuration to the existing ones. The outputs of the
+// removed destinations (or whose type changed) are returned to be
+// stopped by removeOutputs after the routing table is replaced.
+//
+// Parameters:
+//   - destinations: routing destinations
+//   - start: whether the goroutines of the new outputs are started
+//
+// Returns:
+//   - map[string]route.Output: outputs by destination name
+//   - []runningOutput: outputs of the removed destinations
+func updateOutputs(destinations []config.Destination, start bool) (map[string]route.Output, []runningOutput) {
+	dests := make(map[string]config.Destination)
+	for _, dest := range destinations {
+		if output.IsOutput(dest.Type) {
+			dests[dest.Name] = dest
+		}
+	}
+
+	removed := []runningOutput{}
+	for name, running := range outputs {
+		if dest, exists := dests[name]; !exists || dest.Type != running.destType {
+			removed = append(removed, running)
+			delete(outputs, name)
+		}
+	}
+
+	if outputs == nil {
+		outputs = make(map[string]runningOutput)
+	}
+	routeOutputs := make(map[string]route.Output)
+	for name, dest := range dests {
+		if running, exists := outputs[name]; exists {
+			running.output.Reload(dest)
+			routeOutputs[name] = running.output
+			continue
+		}
+
+		out, err := output.New(dest)
+		if err != nil {
+			logger.Log.LogError("failed to create output (destination:%s): %s", name, err)
+			continue
+		}
+		// The task of a destination whose type changed is still running
+		task := "output_" + dest.Type + "_" + name
+		outputs[name] = runningOutput{output: out, destType: dest.Type, task: task}
+		routeOutputs[name] = out
+		gm.AddTask(task, out.Run)
+		if start {
+			if err := gm.Start(task); err != nil {
+				logger.Log.LogWarn("%s", err)
+			}
+		}
+	}
+	return routeOutputs, removed
+}
+
+// removeOutputs stops the outputs of the removed destinations. An empty
+// queue is deleted, and a queue with undelivered entries is kept for the
+// destination being added again.
+//
+// Parameters:
+//   - removed: outputs of the removed destinations
+func removeOutputs(removed []runningOutput) {
+	for _, running := range removed {
+		if err := gm.RemoveTask(running.task, goroutineStopTimeout); err != nil {
+			logger.Log.LogWarn("%s", err)
+		}
+		if left := running.output.Remove(); left > 0 {
+			logger.Log.LogWarn("Removed output left %d undelivered entries in its queue (task:%s), "+
+				"delivered if the destination is added again", left, running.task)
+		}
+	}
+}
+
+// manageRetention periodically deletes the segments of the stream
+// destinations that exceed their retention.
+//
+// Parameters:
+//   - ctx: context for goroutine termination
+func manageRetention(ctx context.Context) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, dest := range router.Destinations() {
+			if dest.Type != config.DestinationStream {
+				continue
+			}
+			deleted, err := st.ApplyRetention(dest.Stream, time.Duration(dest.RetentionHours)*time.Hour,
+				int64(dest.RetentionSize)*1024*1024)
+			if err != nil {
+				logger.Log.LogWarn("failed to apply retention (stream:%s): %s", dest.Stream, err)
+			} else if deleted > 0 {
+				logger.Log.LogInfo("Deleted %d segments by retention (stream:%s)", deleted, dest.Stream)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 // finalization clean up all resources in use at the end of the module.
 func finalization() {
+	// Stop inputs first so that all received entries reach the pipeline
+	for _, name := range inputTasks {
+		if err := gm.Stop(name, goroutineStopTimeout); err != nil {
+			logger.Log.LogWarn("%s", err)
+		}
+	}
+	// Stop background tasks
+	if err := gm.StopAll(goroutineStopTimeout); err != nil {
+		logger.Log.LogWarn("%s", err)
+	}
+	// Close store
+	if err := st.Close(); err != nil {
+		logger.Log.LogWarn("failed to close store: %s", err)
+	}
 	// Clean up log resources
 	logger.Log.FinalizeLogger()
 }
@@ -0,0 +1,259 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+)
+
+// Maximum line size of the segment
+const maxLineSize = 16 * 1024 * 1024
+
+// Query is an entry search condition structure
+type Query struct {
+	// Stream name (empty: all streams)
+	Stream string
+	// Start time (inclusive, zero: unlimited)
+	From time.Time
+	// End time (exclusive, zero: unlimited)
+	To time.Time
+	// Minimum level (empty: all levels)
+	Level string
+	// Substring of the message
+	Contains string
+	// Fields that must be equal
+	Fields map[string]string
+	// Labels that must be equal (uses the label index)
+	Labels map[string]string
+	// Maximum number of entries (0: unlimited)
+	Limit int
+}
+
+// Match verify that the entry satisfies the query.
+//
+// Parameters:
+//   - e: log entry
+//
+// Returns:
+//   - bool: match(true), mismatch(false)
+func (q *Query) Match(e *entry.Entry) bool {
+	if q.Stream != "" && e.Stream != q.Stream {
+		return false
+	}
+	if !q.From.IsZero() && e.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !e.Time.Before(q.To) {
+		return false
+	}
+	if q.Level != "" && entry.LevelRank(e.Level) < entry.LevelRank(q.Level) {
+		return false
+	}
+	if q.Contains != "" && !strings.Contains(e.Message, q.Contains) {
+		return false
+	}
+	for key, want := range q.Labels {
+		if value, exists := e.Labels[key]; !exists || value != want {
+			return false
+		}
+	}
+	for key, want := range q.Fields {
+		if value, exists := e.Field(key); !exists || value != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Query reads the entries that satisfy the query in stored order.
+// Archived segments are transparently decompressed.
+//
+// Parameters:
+//   - q: search condition
+//   - fn: called for each entry, stops reading when it returns false
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (s *Store) Query(q Query, fn func(e entry.Entry) bool) error {
+	streams := []string{q.Stream}
+	if q.Stream == "" {
+		var err error
+		if streams, err = s.Streams(); err != nil {
+			return err
+		}
+	}
+
+	// Flush the active segments so that all entries are readable
+	s.mu.Lock()
+	for _, seg := range s.streams {
+		seg.writer.Flush()
+	}
+	s.mu.Unlock()
+
+	count := 0
+	for _, stream := range streams {
+		segments, err := s.segments(stream)
+		if err != nil {
+			return err
+		}
+
+		for _, segPath := range segments {
+			if !s.mayContainLabels(segPath, q.Labels) {
+				continue
+			}
+			stop, err := s.querySegment(segPath, &q, func(e entry.Entry) bool {
+				count++
+				if !fn(e) {
+					return false
+				}
+				return q.Limit <= 0 || count < q.Limit
+			})
+			if err != nil {
+				return err
+			}
+			if stop {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// querySegment reads the entries of a segment that satisfy the query.
+//
+// Parameters:
+//   - segPath: segment file path
+//   - q: search condition
+//   - fn: called for each entry, stops reading when it returns false
+//
+// Returns:
+//   - bool: stopped(true), read to the end(false)
+//   - error: success(nil), failure(error)
+func (s *Store) querySegment(segPath string, q *Query, fn func(e entry.Entry) bool) (bool, error) {
+	reader, err := s.openSegment(segPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Removed while the segments were read
+			return false, nil
+		}
+		return false, err
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var e entry.Entry
+		// The last line of the active segment may be incomplete
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if q.Match(&e) && !fn(e) {
+			return true, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, err
+	}
+	return false, nil
+}
+
+// mayContainLabels verify that the segment may contain entries with
+// the labels by the label index. Segments without an index (e.g. not
+// closed normally) are always read.
+//
+// Parameters:
+//   - segPath: segment file path
+//   - labels: labels that must be equal
+//
+// Returns:
+//   - bool: may contain(true), does not contain(false)
+func (s *Store) mayContainLabels(segPath string, labels map[string]string) bool {
+	if len(labels) == 0 {
+		return true
+	}
+
+	pairs := make(map[string]bool)
+
+	// Active segment
+	s.mu.Lock()
+	active := false
+	for _, seg := range s.streams {
+		if seg.path == segPath {
+			active = true
+			for pair := range seg.labels {
+				pairs[pair] = true
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if !active {
+		data, err := os.ReadFile(labelIndexPath(segPath))
+		if err != nil {
+			return true
+		}
+		list := []string{}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return true
+		}
+		for _, pair := range list {
+			pairs[pair] = true
+		}
+	}
+
+	for key, value := range labels {
+		if !pairs[key+"="+value] {
+			return false
+		}
+	}
+	return true
+}
+
+// openSegment open a segment file. If the segment was archived after
+// the segment list was read, the archived file is opened instead.
+//
+// Parameters:
+//   - segPath: segment file path
+//
+// Returns:
+//   - io.ReadCloser: segment reader
+//   - error: success(nil), failure(error)
+func (s *Store) openSegment(segPath string) (io.ReadCloser, error) {
+	reader, err := compress.OpenFile(segPath)
+	if err == nil || compress.HasExtension(segPath) || !errors.Is(err, os.ErrNotExist) {
+		return reader, err
+	}
+
+	for _, alg := range []compress.Algorithm{compress.Gzip, compress.Zstd} {
+		if reader, err = compress.OpenFile(segPath + compress.Extension(alg)); err == nil {
+			return reader, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package store
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+)
+
+// ApplyRetention deletes the oldest segments of the stream (with their
+// label indexes) that were last written before maxAge, and then the
+// oldest segments while the total size of the stream exceeds maxBytes.
+// The active segment and segments being archived (with their
+// compressed files) are kept.
+//
+// Parameters:
+//   - stream: stream name
+//   - maxAge: maximum age of the segments (0: unlimited)
+//   - maxBytes: maximum total size of the segments (0: unlimited)
+//
+// Returns:
+//   - int: number of deleted segments
+//   - error: success(nil), failure(error)
+func (s *Store) ApplyRetention(stream string, maxAge time.Duration, maxBytes int64) (int, error) {
+	if maxAge <= 0 && maxBytes <= 0 {
+		return 0, nil
+	}
+
+	// The active segment and the segments being archived are kept
+	s.mu.Lock()
+	kept := make(map[string]bool)
+	if seg, exists := s.streams[stream]; exists {
+		kept[seg.path] = true
+	}
+	for segPath := range s.archiving {
+		// The compressed segment exists before the original is removed
+		kept[segPath] = true
+		kept[segPath+compress.Extension(s.compAlgo)] = true
+	}
+	s.mu.Unlock()
+
+	segments, err := s.segments(stream)
+	if err != nil {
+		return 0, err
+	}
+
+	type segmentInfo struct {
+		path      string
+		size      int64
+		modTime   time.Time
+		deletable bool
+	}
+	infos := make([]segmentInfo, 0, len(segments))
+	total := int64(0)
+	for _, segPath := range segments {
+		info, err := os.Stat(segPath)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, segmentInfo{
+			path:      segPath,
+			size:      info.Size(),
+			modTime:   info.ModTime(),
+			deletable: !kept[segPath],
+		})
+		total += info.Size()
+	}
+
+	deleted := 0
+	cutoff := time.Now().Add(-maxAge)
+	for _, info := range infos {
+		if !info.deletable {
+			continue
+		}
+		expired := maxAge > 0 && info.modTime.Before(cutoff)
+		oversize := maxBytes > 0 && total > maxBytes
+		if !expired && !oversize {
+			// Segments are in creation order, so the rest are newer
+			break
+		}
+
+		if err := os.Remove(info.path); err != nil && !os.IsNotExist(err) {
+			return deleted, fmt.Errorf("failed to remove segment: %s", err)
+		}
+		os.Remove(labelIndexPath(info.path))
+		total -= info.size
+		deleted++
+	}
+
+	return deleted, nil
+}
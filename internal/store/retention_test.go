@@ -0,0 +1,63 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+)
+
+func TestApplyRetentionKeepsArchiving(t *testing.T) {
+	dir := t.TempDir()
+	streamDir := filepath.Join(dir, "default")
+	if err := os.MkdirAll(streamDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Segment 2 is being archived: its compressed file is already renamed
+	// into place but the original is not removed yet
+	names := []string{"1.ndjson.gz", "2.ndjson", "2.ndjson.gz", "3.ndjson.gz"}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(streamDir, name), []byte("0123456789"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewStore(dir, 1024, compress.Gzip, 0)
+	s.archiving[filepath.Join(streamDir, "2.ndjson")] = true
+
+	deleted, err := s.ApplyRetention("default", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted = %d, want 2", deleted)
+	}
+
+	remaining, err := s.segments("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(streamDir, "2.ndjson"), filepath.Join(streamDir, "2.ndjson.gz")}
+	if !reflect.DeepEqual(remaining, want) {
+		t.Errorf("remaining = %v, want %v", remaining, want)
+	}
+}
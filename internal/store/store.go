@@ -0,0 +1,384 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package store stores log entries in segment files per stream.
+
+Each stream is a directory under the data directory, and entries are
+appended to the active segment as JSON lines. When the active segment
+exceeds the maximum size, it is archived (compressed) and a new segment
+is started. Archived segments are transparently decompressed by queries.
+
+The label pairs of the entries in a segment are indexed in a sidecar
+file (<segment>.labels) so that queries by label skip segments that
+contain no matching entries.
+*/
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoon-kr/log_manager/internal/entry"
+	"github.com/hoon-kr/log_manager/pkg/utils/compress"
+)
+
+// Segment file extension (before the compression extension)
+const segmentExt = ".ndjson"
+
+// Label index file extension
+const labelIndexExt = ".labels"
+
+// Store is a segment file store structure
+type Store struct {
+	mu             sync.Mutex
+	archiveWG      sync.WaitGroup
+	dirPath        string
+	maxSegmentSize int64
+	compAlgo       compress.Algorithm
+	compLevel      int
+	streams        map[string]*segmentWriter
+	opened         map[string]bool
+	archiving      map[string]bool // Segments being archived
+	onArchiveError func(path string, err error)
+}
+
+// segmentWriter is an active segment information structure
+type segmentWriter struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+	labels map[string]bool // Label pairs (key=value) of the entries
+}
+
+// NewStore create segment file store.
+//
+// Parameters:
+//   - dirPath: data directory path
+//   - maxSegmentSize: maximum size of the active segment (bytes)
+//   - compAlgo: compression algorithm of archived segments
+//   - compLevel: compression level of archived segments
+//
+// Returns:
+//   - *Store: segment file store
+func NewStore(dirPath string, maxSegmentSize int64, compAlgo compress.Algorithm, compLevel int) *Store {
+	return &Store{
+		dirPath:        dirPath,
+		maxSegmentSize: maxSegmentSize,
+		compAlgo:       compAlgo,
+		compLevel:      compLevel,
+		streams:        make(map[string]*segmentWriter),
+		opened:         make(map[string]bool),
+		archiving:      make(map[string]bool),
+		onArchiveError: func(string, error) {},
+	}
+}
+
+// SetArchiveErrorHandler set the function called when archiving a
+// segment fails in the background.
+//
+// Parameters:
+//   - handler: error handler
+func (s *Store) SetArchiveErrorHandler(handler func(path string, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onArchiveError = handler
+}
+
+// Write appends entries to the active segment of each stream.
+//
+// Parameters:
+//   - entries: log entries
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (s *Store) Write(entries []entry.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirty := make(map[string]*segmentWriter)
+	for _, e := range entries {
+		if !entry.ValidStream(e.Stream) {
+			return fmt.Errorf("invalid stream name: %s", e.Stream)
+		}
+
+		seg, err := s.activeSegment(e.Stream)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal entry: %s", err)
+		}
+		data = append(data, '\n')
+
+		n, err := seg.writer.Write(data)
+		seg.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("failed to write segment: %s", err)
+		}
+		dirty[e.Stream] = seg
+		for key, value := range e.Labels {
+			seg.labels[key+"="+value] = true
+		}
+
+		if seg.size >= s.maxSegmentSize {
+			delete(dirty, e.Stream)
+			if err := s.rotate(e.Stream); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Flush so that the entries are visible to queries
+	for _, seg := range dirty {
+		if err := seg.writer.Flush(); err != nil {
+			return fmt.Errorf("failed to write segment: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes active segments and waits for archiving to finish.
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (s *Store) Close() error {
+	s.mu.Lock()
+	var lastErr error
+	for stream, seg := range s.streams {
+		if err := seg.close(); err != nil {
+			lastErr = err
+		}
+		delete(s.streams, stream)
+	}
+	s.mu.Unlock()
+
+	s.archiveWG.Wait()
+	return lastErr
+}
+
+// Streams returns the names of the stored streams.
+//
+// Returns:
+//   - []string: stream names
+//   - error: success(nil), failure(error)
+func (s *Store) Streams() ([]string, error) {
+	dirEntries, err := os.ReadDir(s.dirPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read data directory: %s", err)
+	}
+
+	streams := []string{}
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() && entry.ValidStream(dirEntry.Name()) {
+			streams = append(streams, dirEntry.Name())
+		}
+	}
+	return streams, nil
+}
+
+// activeSegment returns the active segment of the stream, creating it
+// if it does not exist. Segments left uncompressed by a previous run
+// are archived when the stream is opened.
+//
+// Parameters:
+//   - stream: stream name
+//
+// Returns:
+//   - *segmentWriter: active segment
+//   - error: success(nil), failure(error)
+func (s *Store) activeSegment(stream string) (*segmentWriter, error) {
+	if seg, exists := s.streams[stream]; exists {
+		return seg, nil
+	}
+
+	streamDir := filepath.Join(s.dirPath, stream)
+	if err := os.MkdirAll(streamDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to make directory: %s", err)
+	}
+
+	// Archive the segments of the previous run
+	if !s.opened[stream] {
+		segments, err := s.segments(stream)
+		if err != nil {
+			return nil, err
+		}
+		for _, segPath := range segments {
+			if !compress.HasExtension(segPath) {
+				s.archive(segPath)
+			}
+		}
+		s.opened[stream] = true
+	}
+
+	segPath := filepath.Join(streamDir, s.newSegmentName())
+	file, err := os.OpenFile(segPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment: %s", err)
+	}
+
+	seg := &segmentWriter{path: segPath, file: file, writer: bufio.NewWriter(file), labels: make(map[string]bool)}
+	s.streams[stream] = seg
+	return seg, nil
+}
+
+// rotate closes the active segment of the stream and archives it.
+//
+// Parameters:
+//   - stream: stream name
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (s *Store) rotate(stream string) error {
+	seg, exists := s.streams[stream]
+	if !exists {
+		return nil
+	}
+	delete(s.streams, stream)
+
+	if err := seg.close(); err != nil {
+		return err
+	}
+	s.archive(seg.path)
+	return nil
+}
+
+// archive compresses a closed segment in the background.
+//
+// Parameters:
+//   - segPath: segment file path
+func (s *Store) archive(segPath string) {
+	if s.compAlgo == compress.None {
+		return
+	}
+
+	onError := s.onArchiveError
+	s.archiving[segPath] = true
+	s.archiveWG.Add(1)
+	go func() {
+		defer s.archiveWG.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.archiving, segPath)
+			s.mu.Unlock()
+		}()
+		if _, err := compress.CompressFile(segPath, s.compAlgo, s.compLevel); err != nil {
+			onError(segPath, err)
+		}
+	}()
+}
+
+// newSegmentName make a segment file name that sorts in creation order.
+//
+// Returns:
+//   - string: segment file name
+func (s *Store) newSegmentName() string {
+	return fmt.Sprintf("%020d%s", time.Now().UnixNano(), segmentExt)
+}
+
+// segments returns the segment file paths of the stream in creation order.
+//
+// Parameters:
+//   - stream: stream name
+//
+// Returns:
+//   - []string: segment file paths
+//   - error: success(nil), failure(error)
+func (s *Store) segments(stream string) ([]string, error) {
+	streamDir := filepath.Join(s.dirPath, stream)
+	dirEntries, err := os.ReadDir(streamDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read stream directory: %s", err)
+	}
+
+	segments := []string{}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || !strings.Contains(name, segmentExt) || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		if _, err := strconv.ParseInt(name[:strings.Index(name, segmentExt)], 10, 64); err != nil {
+			continue
+		}
+		segments = append(segments, filepath.Join(streamDir, name))
+	}
+
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// labelIndexPath returns the label index file path of the segment.
+//
+// Parameters:
+//   - segPath: segment file path
+//
+// Returns:
+//   - string: label index file path
+func labelIndexPath(segPath string) string {
+	return segPath[:strings.LastIndex(segPath, segmentExt)] + labelIndexExt
+}
+
+// close flushes and closes the segment, and writes the label index.
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (w *segmentWriter) close() error {
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to write segment: %s", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close segment: %s", err)
+	}
+
+	pairs := make([]string, 0, len(w.labels))
+	for pair := range w.labels {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	data, err := json.Marshal(pairs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal label index: %s", err)
+	}
+
+	// Write to a temporary file so that a partial index is never read
+	indexPath := labelIndexPath(w.path)
+	if err := os.WriteFile(indexPath+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write label index: %s", err)
+	}
+	if err := os.Rename(indexPath+".tmp", indexPath); err != nil {
+		return fmt.Errorf("failed to write label index: %s", err)
+	}
+	return nil
+}
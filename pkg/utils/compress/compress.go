@@ -0,0 +1,351 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package compress provides gzip, zstd and snappy compression-related functions.
+*/
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Algorithm is a compression algorithm name
+type Algorithm string
+
+// Compression algorithm
+const (
+	None Algorithm = "none"
+	Gzip Algorithm = "gzip"
+	Zstd Algorithm = "zstd"
+)
+
+// Compression level range per algorithm (0 means the algorithm default)
+const (
+	DefaultLevel = 0
+	GzipMinLevel = 1
+	GzipMaxLevel = 9
+	ZstdMinLevel = 1
+	ZstdMaxLevel = 22
+)
+
+// Magic number of the compressed stream
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ParseAlgorithm converts a string to a compression algorithm.
+//
+// Parameters:
+//   - name: algorithm name (none, gzip, zstd)
+//
+// Returns:
+//   - Algorithm: compression algorithm
+//   - error: success(nil), failure(error)
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch alg := Algorithm(strings.ToLower(name)); alg {
+	case None, Gzip, Zstd:
+		return alg, nil
+	}
+	return None, fmt.Errorf("unsupported compression algorithm: %s", name)
+}
+
+// ValidLevel verify that the compression level is valid for the algorithm.
+//
+// Parameters:
+//   - alg: compression algorithm
+//   - level: compression level
+//
+// Returns:
+//   - bool: valid(true), invalid(false)
+func ValidLevel(alg Algorithm, level int) bool {
+	if level == DefaultLevel {
+		return true
+	}
+
+	switch alg {
+	case Gzip:
+		return level >= GzipMinLevel && level <= GzipMaxLevel
+	case Zstd:
+		return level >= ZstdMinLevel && level <= ZstdMaxLevel
+	}
+	return false
+}
+
+// Extension returns the file extension of the algorithm.
+//
+// Parameters:
+//   - alg: compression algorithm
+//
+// Returns:
+//   - string: file extension (empty string if not compressed)
+func Extension(alg Algorithm) string {
+	switch alg {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// HasExtension verify that the file name has a compressed file extension.
+//
+// Parameters:
+//   - name: file name
+//
+// Returns:
+//   - bool: compressed file(true), not compressed file(false)
+func HasExtension(name string) bool {
+	return strings.HasSuffix(name, Extension(Gzip)) || strings.HasSuffix(name, Extension(Zstd))
+}
+
+// NewWriter create a compression writer. The writer must be closed
+// to flush the remaining data.
+//
+// Parameters:
+//   - w: destination writer
+//   - alg: compression algorithm
+//   - level: compression level (0: algorithm default)
+//
+// Returns:
+//   - io.WriteCloser: compression writer
+//   - error: success(nil), failure(error)
+func NewWriter(w io.Writer, alg Algorithm, level int) (io.WriteCloser, error) {
+	if !ValidLevel(alg, level) && alg != None {
+		return nil, fmt.Errorf("invalid compression level (algorithm: %s, level: %d)", alg, level)
+	}
+
+	switch alg {
+	case None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		if level == DefaultLevel {
+			level = gzip.DefaultCompression
+		}
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip writer: %s", err)
+		}
+		return gw, nil
+	case Zstd:
+		opts := []zstd.EOption{}
+		if level != DefaultLevel {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		zw, err := zstd.NewWriter(w, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %s", err)
+		}
+		return zw, nil
+	}
+
+	return nil, fmt.Errorf("unsupported compression algorithm: %s", alg)
+}
+
+// NewReader create a reader that transparently decompresses the data.
+// The algorithm is detected by the magic number of the stream, and
+// uncompressed data is returned as it is.
+//
+// Parameters:
+//   - r: source reader
+//
+// Returns:
+//   - io.ReadCloser: decompression reader
+//   - error: success(nil), failure(error)
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %s", err)
+		}
+		return gr, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %s", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+
+	return io.NopCloser(br), nil
+}
+
+// NewDecoder create a reader that decompresses the data according to
+// the content encoding (HTTP Content-Encoding header value).
+//
+// Parameters:
+//   - r: source reader
+//   - encoding: content encoding (empty, identity, gzip, zstd)
+//
+// Returns:
+//   - io.ReadCloser: decompression reader
+//   - error: success(nil), failure(error)
+func NewDecoder(r io.Reader, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return io.NopCloser(r), nil
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %s", err)
+		}
+		return gr, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %s", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+}
+
+// OpenFile open a file that may be compressed. Compressed files
+// are transparently decompressed while reading.
+//
+// Parameters:
+//   - filePath: file path
+//
+// Returns:
+//   - io.ReadCloser: file reader
+//   - error: success(nil), failure(error)
+func OpenFile(filePath string) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	reader, err := NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &fileReadCloser{ReadCloser: reader, file: file}, nil
+}
+
+// CompressFile compress a file and remove the original file.
+// The compressed file is written to a temporary file first so that
+// a partially written file is never left under the final name.
+//
+// Parameters:
+//   - filePath: file path to be compressed
+//   - alg: compression algorithm
+//   - level: compression level (0: algorithm default)
+//
+// Returns:
+//   - string: compressed file path
+//   - error: success(nil), failure(error)
+func CompressFile(filePath string, alg Algorithm, level int) (string, error) {
+	if alg == None {
+		return filePath, nil
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %s", err)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %s", err)
+	}
+
+	dstPath := filePath + Extension(alg)
+	tmpPath := dstPath + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %s", err)
+	}
+
+	err = func() error {
+		defer dst.Close()
+
+		writer, err := NewWriter(dst, alg, level)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(writer, src); err != nil {
+			writer.Close()
+			return fmt.Errorf("failed to compress file: %s", err)
+		}
+		if err = writer.Close(); err != nil {
+			return fmt.Errorf("failed to compress file: %s", err)
+		}
+		if err = dst.Sync(); err != nil {
+			return fmt.Errorf("failed to sync file: %s", err)
+		}
+		return nil
+	}()
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	if err = os.Rename(tmpPath, dstPath); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to rename file: %s", err)
+	}
+	if err = os.Remove(filePath); err != nil {
+		return "", fmt.Errorf("failed to remove file: %s", err)
+	}
+
+	return dstPath, nil
+}
+
+// nopWriteCloser is a writer with no-op close method
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close no-op close method.
+//
+// Returns:
+//   - error: always nil
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// fileReadCloser closes both the decompression reader and the file
+type fileReadCloser struct {
+	io.ReadCloser
+	file *os.File
+}
+
+// Close closes the decompression reader and the file.
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func (f *fileReadCloser) Close() error {
+	f.ReadCloser.Close()
+	return f.file.Close()
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package compress
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("level=info msg=\"hello world\"\n", 1000))
+
+	tests := []struct {
+		name  string
+		alg   Algorithm
+		level int
+	}{
+		{"none", None, DefaultLevel},
+		{"gzip default", Gzip, DefaultLevel},
+		{"gzip min", Gzip, GzipMinLevel},
+		{"gzip max", Gzip, GzipMaxLevel},
+		{"zstd default", Zstd, DefaultLevel},
+		{"zstd min", Zstd, ZstdMinLevel},
+		{"zstd max", Zstd, ZstdMaxLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, tt.alg, tt.level)
+			if err != nil {
+				t.Fatalf("NewWriter: %v", err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if tt.alg != None && buf.Len() >= len(data) {
+				t.Errorf("compressed size %d is not smaller than %d", buf.Len(), len(data))
+			}
+
+			// The reader detects the algorithm by the magic number
+			r, err := NewReader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("NewReader: %v", err)
+			}
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("round trip mismatch (got %d bytes, want %d)", len(got), len(data))
+			}
+		})
+	}
+}
+
+func TestNewWriterInvalidLevel(t *testing.T) {
+	tests := []struct {
+		alg   Algorithm
+		level int
+	}{
+		{Gzip, GzipMaxLevel + 1},
+		{Gzip, -2},
+		{Zstd, ZstdMaxLevel + 1},
+	}
+	for _, tt := range tests {
+		if _, err := NewWriter(io.Discard, tt.alg, tt.level); err == nil {
+			t.Errorf("NewWriter(%s, %d): expected error", tt.alg, tt.level)
+		}
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Algorithm
+		wantErr bool
+	}{
+		{"none", None, false},
+		{"GZIP", Gzip, false},
+		{"zstd", Zstd, false},
+		{"lz4", None, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAlgorithm(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAlgorithm(%q) = %q, %v", tt.name, got, err)
+		}
+	}
+}
+
+func TestNewDecoder(t *testing.T) {
+	data := []byte("payload")
+	encode := func(alg Algorithm) []byte {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf, alg, DefaultLevel)
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		encoding string
+		body     []byte
+		wantErr  bool
+	}{
+		{"", data, false},
+		{"identity", data, false},
+		{"gzip", encode(Gzip), false},
+		{"x-gzip", encode(Gzip), false},
+		{" ZSTD ", encode(Zstd), false},
+		{"br", data, true},
+		{"gzip", data, true},
+	}
+	for _, tt := range tests {
+		r, err := NewDecoder(bytes.NewReader(tt.body), tt.encoding)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NewDecoder(%q): expected error", tt.encoding)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("NewDecoder(%q): %v", tt.encoding, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("NewDecoder(%q) = %q, %v", tt.encoding, got, err)
+		}
+	}
+}
+
+func TestCompressFile(t *testing.T) {
+	for _, alg := range []Algorithm{Gzip, Zstd} {
+		t.Run(string(alg), func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			data := []byte(strings.Repeat("line\n", 100))
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			dstPath, err := CompressFile(path, alg, DefaultLevel)
+			if err != nil {
+				t.Fatalf("CompressFile: %v", err)
+			}
+			if dstPath != path+Extension(alg) || !HasExtension(dstPath) {
+				t.Errorf("compressed path = %s", dstPath)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Error("original file was not removed")
+			}
+			if _, err := os.Stat(dstPath + ".tmp"); !os.IsNotExist(err) {
+				t.Error("temporary file was left")
+			}
+
+			r, err := OpenFile(dstPath)
+			if err != nil {
+				t.Fatalf("OpenFile: %v", err)
+			}
+			defer r.Close()
+			got, _ := io.ReadAll(r)
+			if !bytes.Equal(got, data) {
+				t.Errorf("OpenFile content mismatch")
+			}
+		})
+	}
+}
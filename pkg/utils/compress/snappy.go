@@ -0,0 +1,48 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package compress
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// DecodeSnappy decodes a snappy block format buffer.
+//
+// Parameters:
+//   - src: snappy encoded data
+//   - maxSize: maximum decoded size
+//
+// Returns:
+//   - []byte: decoded data
+//   - error: success(nil), failure(error)
+func DecodeSnappy(src []byte, maxSize int) ([]byte, error) {
+	size, err := snappy.DecodedLen(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode snappy data: %s", err)
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("decoded snappy data too large (size: %d, max: %d)", size, maxSize)
+	}
+
+	data, err := snappy.Decode(nil, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode snappy data: %s", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package compress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+func TestDecodeSnappy(t *testing.T) {
+	data := []byte(strings.Repeat("snappy ", 100))
+	encoded := snappy.Encode(nil, data)
+
+	got, err := DecodeSnappy(encoded, len(data))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("DecodeSnappy = %q, %v", got, err)
+	}
+	if _, err := DecodeSnappy(encoded, len(data)-1); err == nil {
+		t.Error("DecodeSnappy over the maximum size: expected error")
+	}
+	if _, err := DecodeSnappy([]byte{0xff, 0xff, 0xff}, 100); err == nil {
+		t.Error("DecodeSnappy of invalid data: expected error")
+	}
+}
@@ -30,17 +30,40 @@ import (
 // Returns:
 //   - error: success(nil), failure(error)
 func ChangeWorkPathToModulePath() error {
+	// Get path's directory
+	dirPath, err := GetModulePath()
+	if err != nil {
+		return err
+	}
+
+	// Change working directory
+	return ChangeWorkPath(dirPath)
+}
+
+// GetModulePath get the directory path of the current process.
+//
+// Returns:
+//   - string: module directory path
+//   - error: success(nil), failure(error)
+func GetModulePath() (string, error) {
 	// Get absolute path of the current process
 	exePath, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("failed to absolute path: %s", err)
+		return "", fmt.Errorf("failed to absolute path: %s", err)
 	}
 
-	// Get path's directory
-	dirPath := filepath.Dir(exePath)
+	return filepath.Dir(exePath), nil
+}
 
-	// Change working directory
-	err = os.Chdir(dirPath)
+// ChangeWorkPath change the working path.
+//
+// Parameters:
+//   - dirPath: working directory path
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func ChangeWorkPath(dirPath string) error {
+	err := os.Chdir(dirPath)
 	if err != nil {
 		return fmt.Errorf("failed to change dir: %s", err)
 	}
@@ -81,3 +104,57 @@ func WriteDataToTextFile[T any](filePath string, data T, isMakeDir bool) error {
 
 	return nil
 }
+
+// WriteDataToTextFileAtomic is a crash-safe version of WriteDataToTextFile.
+// The data is written to a temporary file in the same directory, synced
+// and renamed over the file, so the file always has either the previous
+// or the new contents.
+//
+// Parameters:
+//   - filePath: file path to be written
+//   - data: generic type data
+//   - isMakeDir: option to create file path directory if it does not exist
+//
+// Returns:
+//   - error: success(nil), failure(error)
+func WriteDataToTextFileAtomic[T any](filePath string, data T, isMakeDir bool) error {
+	dir := filepath.Dir(filePath)
+	if isMakeDir {
+		// If directory does not exist, create directory
+		err := os.MkdirAll(dir, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to make directory: %s", err)
+		}
+	}
+
+	tmpPath := filePath + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %s", err)
+	}
+
+	_, err = fmt.Fprintf(file, "%v", data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write file: %s", err)
+	}
+
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename file: %s", err)
+	}
+
+	// Sync the directory so that the rename is durable
+	if dirFile, err := os.Open(dir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+
+	return nil
+}
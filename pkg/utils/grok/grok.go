@@ -0,0 +1,228 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package grok provides grok pattern (%{PATTERN:field:type}) matching
+with a built-in pattern library.
+*/
+package grok
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Maximum nesting depth of pattern references
+const maxDepth = 32
+
+// Pattern reference (%{NAME}, %{NAME:field}, %{NAME:field:int|float})
+var referenceRegexp = regexp.MustCompile(`%\{(\w+)(?::([\w.@\[\]-]+))?(?::(int|float))?\}`)
+
+// Built-in pattern library (subset of the Logstash grok patterns)
+var patterns = map[string]string{
+	"USERNAME":       `[a-zA-Z0-9._-]+`,
+	"USER":           `%{USERNAME}`,
+	"EMAILLOCALPART": `[a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+(?:\.[a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+)*`,
+	"EMAILADDRESS":   `%{EMAILLOCALPART}@%{HOSTNAME}`,
+	"INT":            `[+-]?[0-9]+`,
+	"BASE10NUM":      `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":         `%{BASE10NUM}`,
+	"BASE16NUM":      `(?:0[xX])?[0-9A-Fa-f]+`,
+	"POSINT":         `\b[1-9][0-9]*\b`,
+	"NONNEGINT":      `\b[0-9]+\b`,
+	"WORD":           `\b\w+\b`,
+	"NOTSPACE":       `\S+`,
+	"SPACE":          `\s*`,
+	"DATA":           `.*?`,
+	"GREEDYDATA":     `.*`,
+	"QUOTEDSTRING":   `(?:"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` + "`(?:[^`\\\\]|\\\\.)*`)",
+	"QS":             `%{QUOTEDSTRING}`,
+	"UUID":           `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"MAC":            `(?:[A-Fa-f0-9]{2}[:-]){5}[A-Fa-f0-9]{2}|(?:[A-Fa-f0-9]{4}\.){2}[A-Fa-f0-9]{4}`,
+	"IPV4":           `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})`,
+	"IPV6":           `(?:[0-9A-Fa-f]{0,4}:){2,7}(?:[0-9A-Fa-f]{1,4}|%{IPV4})?(?:%[\w.]+)?`,
+	"IP":             `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":       `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?`,
+	"IPORHOST":       `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":       `%{IPORHOST}:%{POSINT}`,
+	"UNIXPATH":       `(?:/[\w%!$@:.,+~-]*)+`,
+	"PATH":           `%{UNIXPATH}`,
+	"URIPROTO":       `[A-Za-z][A-Za-z0-9+.-]*`,
+	"URIHOST":        `%{IPORHOST}(?::%{POSINT})?`,
+	"URIPATH":        `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_-]*)+`,
+	"URIPARAM":       `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\[\]<>-]*`,
+	"URIPATHPARAM":   `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":            `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?`,
+	"MONTH": `\b(?:[Jj]an(?:uary)?|[Ff]eb(?:ruary)?|[Mm]ar(?:ch)?|[Aa]pr(?:il)?|[Mm]ay|[Jj]un(?:e)?|[Jj]ul(?:y)?|` +
+		`[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo]ct(?:ober)?|[Nn]ov(?:ember)?|[Dd]ec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:0[1-9]|[12][0-9]|3[01]|[1-9])`,
+	"DAY":               `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"DATE_US":           `%{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}`,
+	"DATE_EU":           `%{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}`,
+	"DATE":              `(?:%{DATE_US}|%{DATE_EU})`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"PROG":              `[\x21-\x5a\x5c\x5e-\x7e]+`,
+	"SYSLOGPROG":        `%{PROG:program}(?:\[%{POSINT:pid}\])?`,
+	"SYSLOGHOST":        `%{IPORHOST}`,
+	"SYSLOGBASE":        `%{SYSLOGTIMESTAMP:timestamp} %{SYSLOGHOST:logsource} %{SYSLOGPROG}:`,
+	"LOGLEVEL": `(?:[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo(?:rmation)?|INFO(?:RMATION)?|` +
+		`[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|` +
+		`[Ss]evere|SEVERE|EMERG(?:ENCY)?|[Ee]merg(?:ency)?)`,
+	"HTTPDUSER": `(?:%{EMAILADDRESS}|%{USER})`,
+	"COMMONAPACHELOG": `%{IPORHOST:clientip} %{HTTPDUSER:ident} %{USER:auth} \[%{HTTPDATE:timestamp}\] ` +
+		`"(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" ` +
+		`%{NUMBER:response:int} (?:%{NUMBER:bytes:int}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+}
+
+// capture is a named capture of the pattern
+type capture struct {
+	field string
+	kind  string
+}
+
+// Grok is a compiled grok pattern structure
+type Grok struct {
+	re       *regexp.Regexp
+	captures map[string]capture
+}
+
+// Compile compile a grok pattern. The pattern is a regular expression
+// with references to named patterns (%{NAME}). A reference with a field
+// (%{NAME:field}) captures the matched text, optionally converted to
+// a number (%{NAME:field:int}, %{NAME:field:float}).
+//
+// Parameters:
+//   - pattern: grok pattern
+//   - custom: additional pattern definitions (name: pattern, overrides the built-in patterns)
+//
+// Returns:
+//   - *Grok: compiled pattern
+//   - error: success(nil), failure(error)
+func Compile(pattern string, custom map[string]string) (*Grok, error) {
+	g := &Grok{captures: make(map[string]capture)}
+
+	expanded, err := g.expand(pattern, custom, 0)
+	if err != nil {
+		return nil, err
+	}
+	if g.re, err = regexp.Compile(expanded); err != nil {
+		return nil, fmt.Errorf("invalid grok pattern: %s", err)
+	}
+	return g, nil
+}
+
+// Match match the text and returns the captured fields.
+// Captures that did not participate in the match are omitted.
+//
+// Parameters:
+//   - text: text to match
+//
+// Returns:
+//   - map[string]interface{}: captured fields
+//   - bool: matched(true), not matched(false)
+func (g *Grok) Match(text string) (map[string]interface{}, bool) {
+	loc := g.re.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return nil, false
+	}
+
+	fields := make(map[string]interface{})
+	for i, name := range g.re.SubexpNames() {
+		c, exists := g.captures[name]
+		if !exists || loc[2*i] < 0 {
+			continue
+		}
+		value := text[loc[2*i]:loc[2*i+1]]
+
+		switch c.kind {
+		case "int":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				fields[c.field] = n
+				continue
+			}
+		case "float":
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				fields[c.field] = n
+				continue
+			}
+		}
+		fields[c.field] = value
+	}
+	return fields, true
+}
+
+// expand replaces the pattern references with regular expressions.
+// Field names are not valid group names (e.g. log.level), so captures
+// are named g<N> and mapped to the fields.
+//
+// Parameters:
+//   - pattern: grok pattern
+//   - custom: additional pattern definitions
+//   - depth: nesting depth
+//
+// Returns:
+//   - string: regular expression
+//   - error: success(nil), failure(error)
+func (g *Grok) expand(pattern string, custom map[string]string, depth int) (string, error) {
+	if depth > maxDepth {
+		return "", fmt.Errorf("grok pattern nesting is too deep (recursive reference?)")
+	}
+
+	var expandErr error
+	result := referenceRegexp.ReplaceAllStringFunc(pattern, func(ref string) string {
+		if expandErr != nil {
+			return ""
+		}
+		m := referenceRegexp.FindStringSubmatch(ref)
+		name, field, kind := m[1], m[2], m[3]
+
+		definition, exists := custom[name]
+		if !exists {
+			definition, exists = patterns[name]
+		}
+		if !exists {
+			expandErr = fmt.Errorf("unknown grok pattern: %s", name)
+			return ""
+		}
+
+		inner, err := g.expand(definition, custom, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+		if field == "" {
+			return "(?:" + inner + ")"
+		}
+		group := "g" + strconv.Itoa(len(g.captures))
+		g.captures[group] = capture{field: field, kind: kind}
+		return "(?P<" + group + ">" + inner + ")"
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return result, nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package stream provides the stream name rules shared by the
+configuration and the log entry model.
+*/
+package stream
+
+import "regexp"
+
+// Default stream name
+const Default = "default"
+
+// Stream name rule (used as a directory name of the store)
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// Valid verify that the stream name is valid.
+//
+// Parameters:
+//   - name: stream name
+//
+// Returns:
+//   - bool: valid(true), invalid(false)
+func Valid(name string) bool {
+	return nameRegexp.MatchString(name)
+}
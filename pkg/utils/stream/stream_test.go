@@ -0,0 +1,46 @@
+// Copyright 2024 JongHoon Shim and The log_manager Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package stream
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{Default, true},
+		{"app_1.prod-eu", true},
+		{"_internal", true},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+		{"", false},
+		{".hidden", false},
+		{"-flag", false},
+		{"a/b", false},
+		{"..", false},
+		{"app log", false},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.name); got != tt.want {
+			t.Errorf("Valid(%q) = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}